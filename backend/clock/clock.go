@@ -0,0 +1,60 @@
+// Package clock отделяет менеджер сценариев от системных часов: расписания,
+// даты начала и окончания и задержки цепочек можно проверять без реального
+// ожидания, подставив Fake.
+package clock
+
+import "time"
+
+// Clock - источник времени, таймеров и тикеров
+type Clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+	Sleep(d time.Duration)
+	NewTimer(d time.Duration) Timer
+	NewTicker(d time.Duration) Ticker
+}
+
+// Timer - одноразовый таймер, как time.Timer
+type Timer interface {
+	C() <-chan time.Time
+	Stop() bool
+}
+
+// Ticker - периодический таймер, как time.Ticker
+type Ticker interface {
+	C() <-chan time.Time
+	Stop()
+}
+
+// New возвращает системные часы
+func New() Clock {
+	return realClock{}
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time                         { return time.Now() }
+func (realClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+func (realClock) Sleep(d time.Duration)                  { time.Sleep(d) }
+
+func (realClock) NewTimer(d time.Duration) Timer {
+	return realTimer{timer: time.NewTimer(d)}
+}
+
+func (realClock) NewTicker(d time.Duration) Ticker {
+	return realTicker{ticker: time.NewTicker(d)}
+}
+
+type realTimer struct {
+	timer *time.Timer
+}
+
+func (t realTimer) C() <-chan time.Time { return t.timer.C }
+func (t realTimer) Stop() bool          { return t.timer.Stop() }
+
+type realTicker struct {
+	ticker *time.Ticker
+}
+
+func (t realTicker) C() <-chan time.Time { return t.ticker.C }
+func (t realTicker) Stop()               { t.ticker.Stop() }
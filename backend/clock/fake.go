@@ -0,0 +1,160 @@
+package clock
+
+import (
+	"sync"
+	"time"
+)
+
+// Fake - часы, время которых двигается только вызовами Advance и Set.
+// Таймеры и тикеры срабатывают по очереди своих моментов, пока часы
+// переводятся вперед.
+type Fake struct {
+	mutex   sync.Mutex
+	cond    *sync.Cond
+	now     time.Time
+	waiters []*fakeWaiter
+}
+
+// fakeWaiter - таймер или тикер (period > 0) поддельных часов
+type fakeWaiter struct {
+	fake   *Fake
+	at     time.Time
+	period time.Duration
+	c      chan time.Time
+}
+
+// NewFake создает часы, показывающие now
+func NewFake(now time.Time) *Fake {
+	f := &Fake{now: now}
+	f.cond = sync.NewCond(&f.mutex)
+	return f
+}
+
+func (f *Fake) Now() time.Time {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return f.now
+}
+
+func (f *Fake) After(d time.Duration) <-chan time.Time {
+	return f.NewTimer(d).C()
+}
+
+func (f *Fake) Sleep(d time.Duration) {
+	<-f.After(d)
+}
+
+func (f *Fake) NewTimer(d time.Duration) Timer {
+	return f.add(d, 0)
+}
+
+func (f *Fake) NewTicker(d time.Duration) Ticker {
+	if d <= 0 {
+		panic("clock: неположительный период тикера")
+	}
+	return fakeTicker{f.add(d, d)}
+}
+
+func (f *Fake) add(d, period time.Duration) *fakeWaiter {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	w := &fakeWaiter{fake: f, at: f.now.Add(d), period: period, c: make(chan time.Time, 1)}
+	if period == 0 && d <= 0 {
+		w.c <- f.now
+		return w
+	}
+	f.waiters = append(f.waiters, w)
+	f.cond.Broadcast()
+	return w
+}
+
+// Advance переводит часы вперед на d
+func (f *Fake) Advance(d time.Duration) {
+	f.Set(f.Now().Add(d))
+}
+
+// Set переводит часы на момент t, по очереди срабатывая таймеры и тикеры,
+// моменты которых наступили. Часы назад не переводятся.
+func (f *Fake) Set(t time.Time) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	for {
+		var next *fakeWaiter
+		for _, w := range f.waiters {
+			if !w.at.After(t) && (next == nil || w.at.Before(next.at)) {
+				next = w
+			}
+		}
+		if next == nil {
+			break
+		}
+
+		if next.at.After(f.now) {
+			f.now = next.at
+		}
+		// Как и у time.Ticker, непрочитанные срабатывания пропускаются
+		select {
+		case next.c <- f.now:
+		default:
+		}
+		if next.period > 0 {
+			next.at = next.at.Add(next.period)
+		} else {
+			f.remove(next)
+		}
+	}
+
+	if t.After(f.now) {
+		f.now = t
+	}
+}
+
+// Waiters возвращает число ожидающих таймеров и тикеров
+func (f *Fake) Waiters() int {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return len(f.waiters)
+}
+
+// BlockUntil ждет, пока таймеров и тикеров станет не меньше n: так тест
+// узнает, что проверяемый код дошел до ожидания
+func (f *Fake) BlockUntil(n int) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	for len(f.waiters) < n {
+		f.cond.Wait()
+	}
+}
+
+// remove удаляет ожидающего; вызывается под mutex
+func (f *Fake) remove(w *fakeWaiter) bool {
+	for i, other := range f.waiters {
+		if other == w {
+			f.waiters = append(f.waiters[:i], f.waiters[i+1:]...)
+			f.cond.Broadcast()
+			return true
+		}
+	}
+	return false
+}
+
+func (w *fakeWaiter) C() <-chan time.Time {
+	return w.c
+}
+
+// Stop останавливает таймер или тикер; true, если он еще не сработал
+func (w *fakeWaiter) Stop() bool {
+	w.fake.mutex.Lock()
+	defer w.fake.mutex.Unlock()
+	return w.fake.remove(w)
+}
+
+type fakeTicker struct {
+	*fakeWaiter
+}
+
+func (t fakeTicker) Stop() {
+	t.fakeWaiter.Stop()
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// fileConfig - настройки из config.yaml. Переменные окружения имеют
+// приоритет над файлом, незаданные в файле значения берутся по умолчанию.
+type fileConfig struct {
+	Storage storageFileConfig `yaml:"storage"`
+}
+
+// storageFileConfig - раздел storage: хранилище расписаний, логов и метрик
+type storageFileConfig struct {
+	Type      string         `yaml:"type"`
+	Path      string         `yaml:"path"`
+	DSN       string         `yaml:"dsn"`
+	Prefix    string         `yaml:"prefix"`
+	Retention *time.Duration `yaml:"retention"`
+
+	MaxLogs       *int           `yaml:"max_logs"`
+	LogsRetention *time.Duration `yaml:"logs_retention"`
+	LogsPath      string         `yaml:"logs_path"`
+
+	MaxMetricSeries  *int           `yaml:"max_metric_series"`
+	MetricsRetention *time.Duration `yaml:"metrics_retention"`
+	MetricsPath      string         `yaml:"metrics_path"`
+}
+
+// loadFileConfig читает файл конфигурации; отсутствующий файл не ошибка
+func loadFileConfig(path string) (fileConfig, error) {
+	var cfg fileConfig
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return cfg, nil
+	}
+	if err != nil {
+		return cfg, err
+	}
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return cfg, fmt.Errorf("ошибка разбора %s: %v", path, err)
+	}
+	return cfg, nil
+}
+
+// valueOr возвращает значение из файла или defaultValue, если оно не задано
+func valueOr[T any](value *T, defaultValue T) T {
+	if value != nil {
+		return *value
+	}
+	return defaultValue
+}
+
+// stringOr возвращает строку из файла или defaultValue, если она пустая
+func stringOr(value, defaultValue string) string {
+	if value != "" {
+		return value
+	}
+	return defaultValue
+}
@@ -0,0 +1,346 @@
+package generator
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"log-metrics-simulator/models"
+	"log-metrics-simulator/sinks"
+)
+
+// MetricHistoryWriter принимает снимки метрик моделируемого периода
+type MetricHistoryWriter interface {
+	Write(ts time.Time, metrics []models.Metric) error
+	Flush() error
+}
+
+// backfillJob генерирует прошлый период по модельным часам: на каждом шаге
+// выпускаются события, положенные по форме нагрузки, и отправляются в
+// приемники в порядке меток времени
+type backfillJob struct {
+	request models.BackfillJobRequest
+	opts    Options
+	shape   *TrafficShape
+	step    time.Duration
+	metrics MetricHistoryWriter
+	stop    chan struct{}
+
+	mutex sync.RWMutex
+	state models.BackfillJob
+}
+
+var (
+	backfillJobs  = make(map[string]*backfillJob)
+	backfillOrder []string
+	backfillMutex sync.RWMutex
+)
+
+// Сколько завершенных заданий хранится для просмотра
+const maxBackfillJobs = 50
+
+// Наибольшее число шагов задания: на каждом шаге генерируется пакет
+// событий и, при отправке метрик, снимок всех серий
+const maxBackfillSteps = 100000
+
+// StartBackfill проверяет запрос и запускает генерацию истории в фоне.
+// metrics получает снимки метрик на каждом шаге; nil - метрики не отправляются.
+func StartBackfill(req models.BackfillJobRequest, metrics MetricHistoryWriter) (models.BackfillJob, error) {
+	if req.To.IsZero() {
+		req.To = time.Now()
+	}
+	if req.Period != "" {
+		period, err := time.ParseDuration(req.Period)
+		if err != nil || period <= 0 {
+			return models.BackfillJob{}, fmt.Errorf("неверный period: %s", req.Period)
+		}
+		req.From = req.To.Add(-period)
+	}
+	if req.From.IsZero() {
+		return models.BackfillJob{}, fmt.Errorf("не задано начало периода (from или period)")
+	}
+	if !req.To.After(req.From) {
+		return models.BackfillJob{}, fmt.Errorf("конец периода должен быть позже начала")
+	}
+
+	step := time.Minute
+	if req.Step != "" {
+		parsed, err := time.ParseDuration(req.Step)
+		if err != nil || parsed <= 0 {
+			return models.BackfillJob{}, fmt.Errorf("неверный step: %s", req.Step)
+		}
+		step = parsed
+	}
+	if steps := int64(req.To.Sub(req.From) / step); steps > maxBackfillSteps {
+		return models.BackfillJob{}, fmt.Errorf("период с шагом %s дает %d шагов, допустимо не больше %d: увеличьте step", step, steps, maxBackfillSteps)
+	}
+	if req.Workers < 0 || req.Workers > maxWorkers {
+		return models.BackfillJob{}, fmt.Errorf("workers должен быть от 0 до %d (0 - по умолчанию)", maxWorkers)
+	}
+
+	spec := models.TrafficShape{BaseRate: req.EventsPerSecond}
+	if req.Shape != nil {
+		spec = *req.Shape
+		if spec.BaseRate == 0 {
+			spec.BaseRate = req.EventsPerSecond
+		}
+	}
+	if spec.BaseRate == 0 {
+		spec.BaseRate = 1
+	}
+	if spec.BaseRate < 0 || spec.BaseRate > maxEventsPerSec {
+		return models.BackfillJob{}, fmt.Errorf("events_per_second должен быть от 0 до %d (0 - по умолчанию, 1 событие/с)", maxEventsPerSec)
+	}
+	seed := time.Now().UnixNano()
+	if req.Seed != nil {
+		seed = *req.Seed
+	}
+	shape, err := NewTrafficShape(spec, req.From, seed)
+	if err != nil {
+		return models.BackfillJob{}, err
+	}
+
+	profile := req.Profile
+	if profile == nil {
+		if profile, err = ParseProfile(req.Config); err != nil {
+			return models.BackfillJob{}, err
+		}
+	}
+
+	var out sinks.Sink
+	if len(req.Sinks) > 0 {
+		fanout, err := sinks.NewFanout(req.Sinks)
+		if err != nil {
+			return models.BackfillJob{}, fmt.Errorf("ошибка создания приемников: %v", err)
+		}
+		out = fanout
+	}
+
+	now := time.Now()
+	job := &backfillJob{
+		request: req,
+		opts: Options{
+			Seed:    req.Seed,
+			Labels:  req.Labels,
+			Sink:    out,
+			Traces:  req.Traces,
+			Profile: profile,
+			Workers: req.Workers,
+			history: metricRegistry.cloneDefinitions(),
+		},
+		shape:   shape,
+		step:    step,
+		metrics: metrics,
+		stop:    make(chan struct{}),
+		state: models.BackfillJob{
+			ID:        strconv.FormatInt(now.UnixNano(), 36),
+			Status:    "running",
+			Scenario:  req.Scenario,
+			From:      req.From,
+			To:        req.To,
+			Current:   req.From,
+			StartedAt: now,
+		},
+	}
+
+	backfillMutex.Lock()
+	backfillJobs[job.state.ID] = job
+	backfillOrder = append(backfillOrder, job.state.ID)
+	pruneBackfillJobs()
+	backfillMutex.Unlock()
+
+	go job.run()
+
+	log.Printf("⏪ Генерация истории %s запущена: %s - %s, шаг %s",
+		job.state.ID, req.From.Format(time.RFC3339), req.To.Format(time.RFC3339), step)
+	return job.status(), nil
+}
+
+// pruneBackfillJobs удаляет самые старые завершенные задания сверх maxBackfillJobs
+func pruneBackfillJobs() {
+	for i := 0; len(backfillOrder) > maxBackfillJobs && i < len(backfillOrder); {
+		id := backfillOrder[i]
+		if backfillJobs[id].status().Status == "running" {
+			i++
+			continue
+		}
+		delete(backfillJobs, id)
+		backfillOrder = append(backfillOrder[:i], backfillOrder[i+1:]...)
+	}
+}
+
+// GetBackfillJob возвращает состояние задания
+func GetBackfillJob(id string) (models.BackfillJob, error) {
+	backfillMutex.RLock()
+	defer backfillMutex.RUnlock()
+
+	job, ok := backfillJobs[id]
+	if !ok {
+		return models.BackfillJob{}, fmt.Errorf("задание не найдено: %s", id)
+	}
+	return job.status(), nil
+}
+
+// ListBackfillJobs возвращает задания в порядке запуска
+func ListBackfillJobs() []models.BackfillJob {
+	backfillMutex.RLock()
+	defer backfillMutex.RUnlock()
+
+	result := make([]models.BackfillJob, 0, len(backfillOrder))
+	for _, id := range backfillOrder {
+		result = append(result, backfillJobs[id].status())
+	}
+	return result
+}
+
+// CancelBackfill прерывает задание после текущего шага
+func CancelBackfill(id string) (models.BackfillJob, error) {
+	backfillMutex.RLock()
+	job, ok := backfillJobs[id]
+	backfillMutex.RUnlock()
+	if !ok {
+		return models.BackfillJob{}, fmt.Errorf("задание не найдено: %s", id)
+	}
+
+	select {
+	case <-job.stop:
+	default:
+		close(job.stop)
+	}
+	return job.status(), nil
+}
+
+func (j *backfillJob) run() {
+	var (
+		expected  float64
+		emitted   int64
+		generated int64
+		pending   []models.LogEntry // Логи, время которых еще не наступило по модельным часам
+	)
+
+	err := func() error {
+		for ts, batch := j.request.From, int64(0); ts.Before(j.request.To); batch++ {
+			select {
+			case <-j.stop:
+				return errBackfillCancelled
+			default:
+			}
+
+			end := ts.Add(j.step)
+			if end.After(j.request.To) {
+				end = j.request.To
+			}
+
+			expected += j.shape.Events(ts, end)
+			count := int64(expected) - emitted
+			emitted += count
+
+			if count > 0 {
+				opts := j.opts
+				opts.StartTime = ts
+				opts.Rate = float64(count) / end.Sub(ts).Seconds()
+				if opts.Seed != nil {
+					seed := *opts.Seed + batch*maxWorkers
+					opts.Seed = &seed
+				}
+				pending = append(pending, generate(int(count), j.request.Scenario, opts)...)
+			}
+
+			// Спаны трассировок и логи разных генераторов перемешаны во времени:
+			// в приемники уходит только то, что уже произошло к концу шага
+			sort.SliceStable(pending, func(a, b int) bool { return pending[a].Timestamp.Before(pending[b].Timestamp) })
+			ready := sort.Search(len(pending), func(i int) bool { return !pending[i].Timestamp.Before(end) })
+			if ready > 0 {
+				writeSinks(j.request.Scenario, j.opts, pending[:ready])
+				generated += int64(ready)
+				pending = append([]models.LogEntry(nil), pending[ready:]...)
+			}
+
+			if j.metrics != nil {
+				if err := j.metrics.Write(end, j.opts.history.snapshot()); err != nil {
+					return fmt.Errorf("ошибка отправки метрик: %v", err)
+				}
+			}
+
+			j.progress(end, generated)
+			ts = end
+		}
+
+		if len(pending) > 0 {
+			writeSinks(j.request.Scenario, j.opts, pending)
+			generated += int64(len(pending))
+		}
+		if j.metrics != nil {
+			if err := j.metrics.Flush(); err != nil {
+				return fmt.Errorf("ошибка отправки метрик: %v", err)
+			}
+		}
+		return nil
+	}()
+
+	j.finish(generated, err)
+}
+
+var errBackfillCancelled = fmt.Errorf("задание отменено")
+
+// progress обновляет модельное время и оценку оставшегося времени
+func (j *backfillJob) progress(current time.Time, generated int64) {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+
+	total := j.state.To.Sub(j.state.From).Seconds()
+	done := current.Sub(j.state.From).Seconds()
+	elapsed := time.Since(j.state.StartedAt).Seconds()
+
+	j.state.Current = current
+	j.state.Progress = done / total
+	j.state.Generated = generated
+	if elapsed > 0 {
+		j.state.Rate = math.Round(float64(generated)/elapsed*100) / 100
+		j.state.ETASeconds = math.Round(elapsed / done * (total - done))
+	}
+}
+
+func (j *backfillJob) finish(generated int64, err error) {
+	now := time.Now()
+	j.mutex.Lock()
+	j.state.FinishedAt = &now
+	j.state.Generated = generated
+	j.state.ETASeconds = 0
+	switch {
+	case err == errBackfillCancelled:
+		j.state.Status = "cancelled"
+	case err != nil:
+		j.state.Status = "failed"
+		j.state.Error = err.Error()
+	default:
+		j.state.Status = "completed"
+		j.state.Progress = 1
+	}
+	state := j.state
+	j.mutex.Unlock()
+
+	if j.opts.Sink != nil {
+		if err := j.opts.Sink.Close(); err != nil {
+			log.Printf("❌ Ошибка закрытия приемников: %v", err)
+		}
+	}
+
+	switch state.Status {
+	case "failed":
+		log.Printf("❌ Генерация истории %s прервана: %s", state.ID, state.Error)
+	default:
+		log.Printf("⏪ Генерация истории %s: %s, событий %d за %s",
+			state.ID, state.Status, generated, now.Sub(state.StartedAt).Round(time.Millisecond))
+	}
+}
+
+func (j *backfillJob) status() models.BackfillJob {
+	j.mutex.RLock()
+	defer j.mutex.RUnlock()
+	return j.state
+}
@@ -0,0 +1,267 @@
+package generator
+
+import (
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"log-metrics-simulator/models"
+	"log-metrics-simulator/sinks"
+)
+
+// captureSink запоминает логи, отправленные заданием
+type captureSink struct {
+	mutex   sync.Mutex
+	batches [][]models.LogEntry
+}
+
+func (s *captureSink) Write(batch sinks.Batch) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.batches = append(s.batches, batch.Logs)
+	return nil
+}
+
+func (s *captureSink) Close() error { return nil }
+
+func (s *captureSink) logs() []models.LogEntry {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	var all []models.LogEntry
+	for _, batch := range s.batches {
+		all = append(all, batch...)
+	}
+	return all
+}
+
+// useCaptureSink регистрирует приемник, собирающий логи задания
+func useCaptureSink(t *testing.T) (models.SinkConfig, *captureSink) {
+	t.Helper()
+	sink := &captureSink{}
+	sinkType := "capture-" + strings.ToLower(t.Name())
+	sinks.Register(sinkType, func(map[string]interface{}) (sinks.Sink, error) { return sink, nil })
+	return models.SinkConfig{Type: sinkType}, sink
+}
+
+// historyRecorder запоминает моменты снимков метрик; перед снимком с
+// номером block (с 1) ждет закрытия release
+type historyRecorder struct {
+	mutex   sync.Mutex
+	times   []time.Time
+	flushed bool
+	block   int
+	blocked chan struct{}
+	release chan struct{}
+}
+
+func (h *historyRecorder) Write(ts time.Time, metrics []models.Metric) error {
+	h.mutex.Lock()
+	h.times = append(h.times, ts)
+	n := len(h.times)
+	h.mutex.Unlock()
+	if n == h.block {
+		close(h.blocked)
+		<-h.release
+	}
+	return nil
+}
+
+func (h *historyRecorder) Flush() error {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.flushed = true
+	return nil
+}
+
+// waitBackfill ждет завершения задания
+func waitBackfill(t *testing.T, id string) models.BackfillJob {
+	t.Helper()
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		job, err := GetBackfillJob(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if job.Status != "running" {
+			return job
+		}
+		if time.Now().After(deadline) {
+			CancelBackfill(id)
+			t.Fatalf("задание %s не завершилось за 10с", id)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+var backfillFrom = time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+func TestBackfillOrdersAcrossSteps(t *testing.T) {
+	out, sink := useCaptureSink(t)
+	history := &historyRecorder{}
+	seed := int64(5)
+	started, err := StartBackfill(models.BackfillJobRequest{
+		From:            backfillFrom,
+		To:              backfillFrom.Add(10 * time.Second),
+		Step:            "1s",
+		EventsPerSecond: 200,
+		Scenario:        "normal_load",
+		Sinks:           []models.SinkConfig{out},
+		Seed:            &seed,
+		Traces:          true,
+		Workers:         4,
+	}, history)
+	if err != nil {
+		t.Fatal(err)
+	}
+	job := waitBackfill(t, started.ID)
+	if job.Status != "completed" || job.Progress != 1 || !job.Current.Equal(job.To) {
+		t.Fatalf("задание %+v, ожидалось completed на конце периода", job)
+	}
+
+	logs := sink.logs()
+	if int64(len(logs)) != job.Generated || len(logs) < 2000 {
+		t.Fatalf("отправлено %d логов, в состоянии %d, ожидалось не меньше 2000", len(logs), job.Generated)
+	}
+	// Спаны трассировок выходят за конец своего шага: они дожидаются
+	// следующих шагов, и поток в приемниках остается упорядоченным
+	for i := 1; i < len(logs); i++ {
+		if logs[i].Timestamp.Before(logs[i-1].Timestamp) {
+			t.Fatalf("лог %d (%v) раньше предыдущего (%v)", i, logs[i].Timestamp, logs[i-1].Timestamp)
+		}
+	}
+	if logs[0].Timestamp.Before(backfillFrom) {
+		t.Errorf("первый лог %v раньше начала периода", logs[0].Timestamp)
+	}
+	// Все пакеты, кроме последнего, содержат только наступившие к концу шага события
+	for i, batch := range sink.batches[:len(sink.batches)-1] {
+		if end := history.times[i]; !batch[len(batch)-1].Timestamp.Before(end) {
+			t.Errorf("пакет %d содержит событие %v после конца шага %v", i, batch[len(batch)-1].Timestamp, end)
+		}
+	}
+
+	if len(history.times) != 10 || !history.flushed {
+		t.Fatalf("снимков метрик %d (flush %v), ожидалось 10 и flush", len(history.times), history.flushed)
+	}
+	for i, ts := range history.times {
+		if want := backfillFrom.Add(time.Duration(i+1) * time.Second); !ts.Equal(want) {
+			t.Errorf("снимок %d на %v, ожидалось %v", i, ts, want)
+		}
+	}
+}
+
+func TestBackfillSeededIsReproducible(t *testing.T) {
+	run := func(seed int64) []models.LogEntry {
+		out, sink := useCaptureSink(t)
+		started, err := StartBackfill(models.BackfillJobRequest{
+			From:            backfillFrom,
+			To:              backfillFrom.Add(5 * time.Minute),
+			EventsPerSecond: 2,
+			Scenario:        "black_friday",
+			Sinks:           []models.SinkConfig{out},
+			Seed:            &seed,
+			Traces:          true,
+		}, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		waitBackfill(t, started.ID)
+		return sink.logs()
+	}
+
+	first := run(42)
+	if len(first) < 600 {
+		t.Fatalf("отправлено %d логов, ожидалось не меньше 600", len(first))
+	}
+	if second := run(42); !reflect.DeepEqual(first, second) {
+		t.Fatal("задания с одним seed дали разные логи")
+	}
+	if other := run(43); reflect.DeepEqual(first, other) {
+		t.Fatal("задания с разными seed дали одинаковые логи")
+	}
+}
+
+func TestBackfillProgressAndCancel(t *testing.T) {
+	history := &historyRecorder{block: 2, blocked: make(chan struct{}), release: make(chan struct{})}
+	started, err := StartBackfill(models.BackfillJobRequest{
+		From:            backfillFrom,
+		To:              backfillFrom.Add(10 * time.Minute),
+		EventsPerSecond: 1,
+		Scenario:        "normal_load",
+	}, history)
+	if err != nil {
+		t.Fatal(err)
+	}
+	<-history.blocked
+
+	// Второй шаг занят отправкой метрик: состояние отражает первый
+	job, err := GetBackfillJob(started.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if job.Status != "running" || job.Progress != 0.1 || !job.Current.Equal(backfillFrom.Add(time.Minute)) {
+		t.Errorf("состояние после первого шага %+v", job)
+	}
+	if job.Generated < 55 || job.Generated > 60 {
+		t.Errorf("выпущено %d событий за первый шаг, ожидалось около 60", job.Generated)
+	}
+	if job.ETASeconds < 0 {
+		t.Errorf("оценка оставшегося времени %v", job.ETASeconds)
+	}
+
+	if _, err := CancelBackfill(started.ID); err != nil {
+		t.Fatal(err)
+	}
+	close(history.release)
+	job = waitBackfill(t, started.ID)
+	if job.Status != "cancelled" || job.FinishedAt == nil || job.ETASeconds != 0 {
+		t.Errorf("задание после отмены %+v", job)
+	}
+	if job.Progress != 0.2 {
+		t.Errorf("прогресс после отмены %v, ожидалось 0.2", job.Progress)
+	}
+	if len(history.times) != 2 || history.flushed {
+		t.Errorf("снимков метрик %d (flush %v), ожидалось 2 без flush", len(history.times), history.flushed)
+	}
+	if _, err := CancelBackfill(started.ID); err != nil {
+		t.Errorf("повторная отмена: %v", err)
+	}
+
+	found := false
+	for _, listed := range ListBackfillJobs() {
+		found = found || listed.ID == started.ID
+	}
+	if !found {
+		t.Error("задание отсутствует в списке")
+	}
+}
+
+func TestStartBackfillValidation(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		req  models.BackfillJobRequest
+		want string
+	}{
+		{"без начала", models.BackfillJobRequest{}, "не задано начало"},
+		{"пустой период", models.BackfillJobRequest{From: backfillFrom, To: backfillFrom}, "позже начала"},
+		{"неверный period", models.BackfillJobRequest{Period: "-1h"}, "неверный period"},
+		{"неверный step", models.BackfillJobRequest{Period: "1h", Step: "0s"}, "неверный step"},
+		{"слишком много шагов", models.BackfillJobRequest{Period: "720h", Step: "1s"}, "шагов"},
+		{"отрицательная скорость", models.BackfillJobRequest{Period: "1h", EventsPerSecond: -1}, "events_per_second"},
+		{"workers", models.BackfillJobRequest{Period: "1h", Workers: maxWorkers + 1}, "workers"},
+	} {
+		if _, err := StartBackfill(tc.req, nil); err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("%s: ошибка %v, ожидалась с %q", tc.name, err, tc.want)
+		}
+	}
+}
+
+func TestGetBackfillJobUnknown(t *testing.T) {
+	if _, err := GetBackfillJob("unknown"); err == nil {
+		t.Error("ожидалась ошибка для неизвестного задания")
+	}
+	if _, err := CancelBackfill("unknown"); err == nil {
+		t.Error("ожидалась ошибка отмены неизвестного задания")
+	}
+}
@@ -0,0 +1,98 @@
+package generator
+
+import (
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// GetMetricsPrometheus возвращает метрики в текстовом формате Prometheus 0.0.4.
+// Семейства и серии отсортированы, метки упорядочены по имени и экранированы,
+// значения выводятся с полной точностью.
+func GetMetricsPrometheus() string {
+	var b strings.Builder
+
+	for _, family := range metricRegistry.snapshotFamilies() {
+		b.WriteString("# HELP " + family.name + " " + escapeHelp(family.help) + "\n")
+		b.WriteString("# TYPE " + family.name + " " + family.typ + "\n")
+
+		for _, metric := range family.flatten() {
+			b.WriteString(metric.Name)
+			writeLabels(&b, metric.Labels)
+			b.WriteString(" " + formatFloat(metric.Value) + "\n")
+		}
+		b.WriteString("\n")
+	}
+
+	return b.String()
+}
+
+// Общие функции форматирования для текстового формата Prometheus и OpenMetrics
+
+// escapeLabelValue экранирует значение метки: обратный слеш, кавычку и перевод строки
+func escapeLabelValue(value string) string {
+	if !strings.ContainsAny(value, "\\\"\n") {
+		return value
+	}
+	var b strings.Builder
+	for _, r := range value {
+		switch r {
+		case '\\':
+			b.WriteString(`\\`)
+		case '"':
+			b.WriteString(`\"`)
+		case '\n':
+			b.WriteString(`\n`)
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// escapeHelp экранирует текст HELP: обратный слеш и перевод строки
+func escapeHelp(help string) string {
+	help = strings.ReplaceAll(help, `\`, `\\`)
+	return strings.ReplaceAll(help, "\n", `\n`)
+}
+
+// writeLabels пишет набор меток в фигурных скобках, отсортированный по имени.
+// Пустой набор не выводится.
+func writeLabels(b *strings.Builder, labels map[string]string) {
+	if len(labels) == 0 {
+		return
+	}
+
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	b.WriteByte('{')
+	for i, name := range names {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(name)
+		b.WriteString(`="`)
+		b.WriteString(escapeLabelValue(labels[name]))
+		b.WriteByte('"')
+	}
+	b.WriteByte('}')
+}
+
+// formatFloat форматирует значение с полной точностью, включая +Inf, -Inf и NaN
+func formatFloat(v float64) string {
+	switch {
+	case math.IsNaN(v):
+		return "NaN"
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	default:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	}
+}
@@ -0,0 +1,94 @@
+package generator
+
+import (
+	"math"
+	"testing"
+)
+
+func TestGetMetricsPrometheus(t *testing.T) {
+	fillExpositionRegistry(t, useRegistry(t))
+
+	want := `# HELP active_users Application metric
+# TYPE active_users gauge
+active_users 2.5
+
+# HELP http_requests_total Total requests
+# TYPE http_requests_total counter
+http_requests_total{path="a\"b\\c\nd",service="cart"} 3
+http_requests_total{service="auth"} 1
+
+# HELP latency_ms Latency\nin ms
+# TYPE latency_ms histogram
+latency_ms_bucket{le="10",service="cart"} 1
+latency_ms_bucket{le="100",service="cart"} 2
+latency_ms_bucket{le="+Inf",service="cart"} 3
+latency_ms_sum{service="cart"} 555
+latency_ms_count{service="cart"} 3
+
+`
+	for i := 0; i < 3; i++ {
+		if got := GetMetricsPrometheus(); got != want {
+			t.Fatalf("вывод Prometheus:\n%s\nожидался:\n%s", got, want)
+		}
+	}
+}
+
+func TestGetMetricsPrometheusSummary(t *testing.T) {
+	r := useRegistry(t)
+	if err := r.defineSummary("latency_summary_ms", []float64{0.5, 0.9}); err != nil {
+		t.Fatal(err)
+	}
+	for _, v := range []float64{1, 2, 3, 4} {
+		r.observe("latency_summary_ms", map[string]string{"service": "cart"}, v, testTime, "")
+	}
+
+	want := `# HELP latency_summary_ms Application metric
+# TYPE latency_summary_ms summary
+latency_summary_ms{quantile="0.5",service="cart"} 2
+latency_summary_ms{quantile="0.9",service="cart"} 4
+latency_summary_ms_sum{service="cart"} 10
+latency_summary_ms_count{service="cart"} 4
+
+`
+	if got := GetMetricsPrometheus(); got != want {
+		t.Fatalf("вывод Prometheus:\n%s\nожидался:\n%s", got, want)
+	}
+}
+
+func TestEscapeLabelValue(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{"plain", "plain"},
+		{`a"b`, `a\"b`},
+		{`a\b`, `a\\b`},
+		{"a\nb", `a\nb`},
+		{"тест\t", "тест\t"},
+	}
+	for _, tt := range tests {
+		if got := escapeLabelValue(tt.value); got != tt.want {
+			t.Errorf("escapeLabelValue(%q) = %q, ожидалось %q", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestFormatFloat(t *testing.T) {
+	tests := []struct {
+		value float64
+		want  string
+	}{
+		{0, "0"},
+		{1.5, "1.5"},
+		{0.123456789012, "0.123456789012"},
+		{1e21, "1e+21"},
+		{math.Inf(1), "+Inf"},
+		{math.Inf(-1), "-Inf"},
+		{math.NaN(), "NaN"},
+	}
+	for _, tt := range tests {
+		if got := formatFloat(tt.value); got != tt.want {
+			t.Errorf("formatFloat(%v) = %q, ожидалось %q", tt.value, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,502 @@
+package generator
+
+import (
+	"fmt"
+	"log"
+	"math/rand"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"log-metrics-simulator/models"
+)
+
+// Внедренные отказы действуют на все новые запуски генерации
+var (
+	faults      []models.Fault
+	faultsMutex sync.RWMutex
+)
+
+// Поведение вызывающих сервисов при отказе зависимости
+const (
+	upstreamTimeout  = 3000 // Таймаут вызова сервиса, мс
+	maxCallRetries   = 2    // Повторы после первой неудачной попытки
+	breakerThreshold = 5    // Неудач подряд до размыкания circuit breaker
+	breakerOpenCalls = 10   // Отклоненных вызовов до пробного вызова в полуоткрытом состоянии
+	downstreamShare  = 0.5  // Доля запросов сервиса, доходящих до отказавшей зависимости
+)
+
+// AddFault проверяет отказ, заполняет значения по умолчанию и включает его
+func AddFault(fault models.Fault) (models.Fault, error) {
+	if fault.Service == "" {
+		return fault, fmt.Errorf("не задан сервис")
+	}
+	if !knownService(currentTopology(), fault.Service) {
+		return fault, fmt.Errorf("неизвестный сервис: %s", fault.Service)
+	}
+	if fault.Type == "" {
+		fault.Type = "unavailable"
+	}
+	switch fault.Type {
+	case "unavailable", "database_down", "latency", "errors":
+	default:
+		return fault, fmt.Errorf("неизвестный тип отказа: %s (unavailable, database_down, latency, errors)", fault.Type)
+	}
+	if fault.ErrorRate < 0 || fault.ErrorRate > 1 {
+		return fault, fmt.Errorf("error_rate должен быть от 0 до 1")
+	}
+	if fault.LatencyMs < 0 || fault.DelaySeconds < 0 || fault.OnsetSeconds < 0 ||
+		fault.DurationSeconds < 0 || fault.RecoverySeconds < 0 {
+		return fault, fmt.Errorf("задержки и длительности не могут быть отрицательными")
+	}
+
+	if fault.ErrorRate == 0 {
+		fault.ErrorRate = 1
+	}
+	if fault.Type == "latency" && fault.LatencyMs == 0 {
+		fault.LatencyMs = 5000
+	}
+	if fault.Error == "" {
+		switch fault.Type {
+		case "unavailable":
+			fault.Error = "Service unavailable"
+		case "database_down":
+			fault.Error = "Database connection failed"
+		case "errors":
+			fault.Error = "Internal server error"
+		}
+	}
+
+	now := time.Now()
+	fault.ID = strconv.FormatInt(now.UnixNano(), 36)
+	fault.CreatedAt = now
+	fault.StartAt = now.Add(time.Duration(fault.DelaySeconds) * time.Second)
+
+	faultsMutex.Lock()
+	faults = append(faults, fault)
+	faultsMutex.Unlock()
+
+	log.Printf("💥 Внедрен отказ %s: %s (%s)", fault.ID, fault.Service, fault.Type)
+	return fault, nil
+}
+
+// RemoveFault снимает отказ немедленно, без фазы восстановления
+func RemoveFault(id string) error {
+	faultsMutex.Lock()
+	defer faultsMutex.Unlock()
+
+	for i, f := range faults {
+		if f.ID == id {
+			faults = append(faults[:i], faults[i+1:]...)
+			metricRegistry.setGauge("app_fault_intensity",
+				map[string]string{"app": "simulator", "fault_id": f.ID, "service": f.Service, "type": f.Type},
+				0, time.Now())
+			return nil
+		}
+	}
+	return fmt.Errorf("отказ не найден: %s", id)
+}
+
+// ListFaults возвращает отказы с фазой и интенсивностью на текущий момент
+func ListFaults() []models.FaultStatus {
+	return faultStatuses(time.Now())
+}
+
+func faultStatuses(at time.Time) []models.FaultStatus {
+	faultsMutex.RLock()
+	defer faultsMutex.RUnlock()
+
+	result := make([]models.FaultStatus, 0, len(faults))
+	for _, f := range faults {
+		phase, intensity := faultPhase(f, at)
+		result = append(result, models.FaultStatus{Fault: f, Phase: phase, Intensity: intensity})
+	}
+	return result
+}
+
+// faultPhase вычисляет фазу отказа и долю пикового воздействия: линейное
+// нарастание за onset, пик на duration, линейный спад за recovery
+func faultPhase(f models.Fault, at time.Time) (string, float64) {
+	elapsed := at.Sub(f.StartAt)
+	onset := time.Duration(f.OnsetSeconds) * time.Second
+	duration := time.Duration(f.DurationSeconds) * time.Second
+	recovery := time.Duration(f.RecoverySeconds) * time.Second
+
+	switch {
+	case elapsed < 0:
+		return "pending", 0
+	case elapsed < onset:
+		return "onset", float64(elapsed) / float64(onset)
+	case f.DurationSeconds == 0 || elapsed < onset+duration:
+		return "active", 1
+	case elapsed < onset+duration+recovery:
+		return "recovery", 1 - float64(elapsed-onset-duration)/float64(recovery)
+	default:
+		return "recovered", 0
+	}
+}
+
+// faultEffect - действующий на сервис отказ
+type faultEffect struct {
+	fault     models.Fault
+	intensity float64
+}
+
+// hit определяет, затронут ли отказом очередной запрос
+func (e faultEffect) hit(rnd *rand.Rand) bool {
+	return rnd.Float64() < e.intensity*e.fault.ErrorRate
+}
+
+// activeFaultEffects возвращает сильнейший действующий отказ каждого сервиса
+func activeFaultEffects(statuses []models.FaultStatus) map[string]faultEffect {
+	effects := make(map[string]faultEffect)
+	for _, s := range statuses {
+		if s.Intensity <= 0 {
+			continue
+		}
+		current, ok := effects[s.Service]
+		if !ok || s.Intensity*s.ErrorRate > current.intensity*current.fault.ErrorRate {
+			effects[s.Service] = faultEffect{fault: s.Fault, intensity: s.Intensity}
+		}
+	}
+	return effects
+}
+
+// applyFault меняет запись отказавшего сервиса
+func applyFault(rnd *rand.Rand, entry models.LogEntry, effect faultEffect) models.LogEntry {
+	f := effect.fault
+	entry.Stack = ""
+
+	switch f.Type {
+	case "latency":
+		entry.Duration += f.LatencyMs * int64(80+rnd.Intn(41)) / 100
+		if entry.Level != "ERROR" {
+			entry.Level = "WARN"
+			entry.Message = fmt.Sprintf("Slow request: %dms", entry.Duration)
+		}
+		return entry
+	case "database_down":
+		// Запрос ждет таймаута подключения к базе
+		entry.Duration = int64(1000 + rnd.Intn(1000))
+		entry.Status = http.StatusInternalServerError
+	case "unavailable":
+		// Сервис не отвечает; запись пишет балансировщик перед ним
+		entry.Duration = int64(rnd.Intn(3) + 1)
+		entry.Status = http.StatusServiceUnavailable
+	default:
+		entry.Status = http.StatusInternalServerError
+	}
+
+	entry.Level = "ERROR"
+	entry.Error = f.Error
+	entry.Message = f.Description
+	if entry.Message == "" {
+		entry.Message = fmt.Sprintf("Request failed: %s", f.Error)
+	}
+	return entry
+}
+
+// callEdge - вызов сервиса callee сервисом caller
+type callEdge struct {
+	caller string
+	callee string
+}
+
+// circuitBreaker размыкается после breakerThreshold неудач подряд и отклоняет
+// вызовы; каждый breakerOpenCalls-й вызов пропускается как пробный
+type circuitBreaker struct {
+	failures int
+	rejected int
+	open     bool
+}
+
+func (cb *circuitBreaker) allow() bool {
+	if !cb.open {
+		return true
+	}
+	if cb.rejected >= breakerOpenCalls {
+		cb.rejected = 0
+		return true
+	}
+	cb.rejected++
+	return false
+}
+
+func (cb *circuitBreaker) record(ok bool) {
+	if ok {
+		cb.failures = 0
+		cb.open = false
+		return
+	}
+	cb.failures++
+	if cb.failures >= breakerThreshold {
+		cb.open = true
+	}
+}
+
+func (r *run) breaker(edge callEdge) *circuitBreaker {
+	cb, ok := r.breakers[edge]
+	if !ok {
+		cb = &circuitBreaker{}
+		r.breakers[edge] = cb
+	}
+	return cb
+}
+
+// callFailure - неудачный вызов зависимости с точки зрения вызывающего сервиса
+type callFailure struct {
+	kind     string // error, timeout, refused, circuit_open
+	callee   string
+	status   int
+	err      string
+	attempts int
+}
+
+func (f *callFailure) retryable() bool {
+	switch f.kind {
+	case "timeout", "refused":
+		return true
+	case "error":
+		return f.status >= 500
+	default:
+		return false
+	}
+}
+
+// apply переносит неудачный вызов в запись вызывающего сервиса: 4xx, 503 и 504
+// передаются как есть, остальные ошибки 5xx - как 502, таймаут - 504,
+// недоступность и разомкнутый circuit breaker - 503
+func (f *callFailure) apply(entry models.LogEntry) models.LogEntry {
+	entry.Level = "ERROR"
+	entry.Stack = ""
+
+	switch f.kind {
+	case "timeout":
+		entry.Status = http.StatusGatewayTimeout
+		entry.Message = fmt.Sprintf("Timeout calling %s after %dms", f.callee, upstreamTimeout)
+		entry.Error = fmt.Sprintf("upstream %s: context deadline exceeded", f.callee)
+	case "refused":
+		entry.Status = http.StatusServiceUnavailable
+		entry.Message = fmt.Sprintf("Connection refused by %s", f.callee)
+		entry.Error = fmt.Sprintf("upstream %s: connection refused", f.callee)
+	case "circuit_open":
+		entry.Status = http.StatusServiceUnavailable
+		entry.Message = fmt.Sprintf("Circuit breaker open for %s, failing fast", f.callee)
+		entry.Error = fmt.Sprintf("upstream %s: circuit breaker open", f.callee)
+	default:
+		entry.Status = f.status
+		switch {
+		case entry.Status == http.StatusServiceUnavailable, entry.Status == http.StatusGatewayTimeout:
+		case entry.Status >= 500 || entry.Status < 400:
+			entry.Status = http.StatusBadGateway
+		}
+		entry.Message = fmt.Sprintf("Request to %s failed", f.callee)
+		entry.Error = fmt.Sprintf("upstream %s: %s", f.callee, f.err)
+	}
+
+	if f.attempts > 1 {
+		entry.Message += fmt.Sprintf(" after %d attempts", f.attempts)
+	}
+	return entry
+}
+
+// applyFaultsToLog применяет отказы к независимому логу: отказ самого сервиса
+// или последствия отказа сервиса ниже по графу зависимостей
+func applyFaultsToLog(r *run, entry models.LogEntry) models.LogEntry {
+	if len(r.faults) == 0 {
+		return entry
+	}
+	rnd := r.rnd
+
+	if effect, ok := r.faults[entry.Service]; ok && effect.hit(rnd) {
+		// Недоступный сервис не пишет логов: запись о нем оставляет вызывающий
+		if effect.fault.Type == "unavailable" {
+			if callers := r.callers(entry.Service); len(callers) > 0 {
+				callee := entry.Service
+				entry.Service = callers[rnd.Intn(len(callers))]
+				return r.failCall(entry, callee, effect, "")
+			}
+		}
+		return applyFault(rnd, entry, effect)
+	}
+
+	// Ближайший по графу отказавший сервис и первый шаг пути к нему
+	next, faulty, ok := r.nearestFault(entry.Service)
+	if !ok {
+		return entry
+	}
+	effect := r.faults[faulty]
+	if rnd.Float64() >= downstreamShare || !effect.hit(rnd) {
+		return entry
+	}
+	if next != faulty {
+		// Промежуточный сервис уже вернул 5xx
+		return r.failCall(entry, next, effect, faulty)
+	}
+	return r.failCall(entry, faulty, effect, "")
+}
+
+// failCall моделирует неудачный вызов callee в независимом логе. via - отказавший
+// сервис за callee, если отказ не прямой.
+func (r *run) failCall(entry models.LogEntry, callee string, effect faultEffect, via string) models.LogEntry {
+	rnd := r.rnd
+	edge := callEdge{caller: entry.Service, callee: callee}
+	failure := &callFailure{callee: callee, attempts: 1}
+
+	switch {
+	case via != "":
+		failure.kind = "error"
+		failure.status = http.StatusBadGateway
+		failure.err = fmt.Sprintf("upstream %s: %s", via, effect.fault.Error)
+	case effect.fault.Type == "latency":
+		if effect.fault.LatencyMs+entry.Duration < upstreamTimeout {
+			// Зависимость отвечает медленно, но укладывается в таймаут
+			entry.Duration += effect.fault.LatencyMs
+			if entry.Level == "INFO" {
+				entry.Level = "WARN"
+				entry.Message = fmt.Sprintf("Slow response from %s: %dms", callee, entry.Duration)
+			}
+			return entry
+		}
+		failure.kind = "timeout"
+	case effect.fault.Type == "unavailable":
+		failure.kind = "refused"
+	default:
+		failure.kind = "error"
+		failure.status = http.StatusInternalServerError
+		failure.err = effect.fault.Error
+	}
+
+	// При сильном отказе часть вызовов уже отклоняет разомкнутый circuit breaker
+	if rnd.Float64() < effect.intensity*0.5 {
+		failure.kind = "circuit_open"
+		r.breaker(edge).open = true
+		entry.Duration = int64(rnd.Intn(3) + 1)
+		return failure.apply(entry)
+	}
+
+	if failure.retryable() {
+		failure.attempts = maxCallRetries + 1
+		r.retries[edge] += maxCallRetries
+	}
+	if failure.kind == "timeout" {
+		entry.Duration += int64(upstreamTimeout * failure.attempts)
+	}
+	return failure.apply(entry)
+}
+
+// loadFaults фиксирует отказы, действующие в момент at, на время запуска
+func (r *run) loadFaults(at time.Time) {
+	r.faultStatuses = faultStatuses(at)
+	r.faults = activeFaultEffects(r.faultStatuses)
+	r.breakers = make(map[callEdge]*circuitBreaker)
+	r.retries = make(map[callEdge]int)
+}
+
+// dependencies возвращает сервисы, которые вызывает service
+func (r *run) dependencies(service string) []string {
+	if r.topology != nil {
+		return r.topology.dependencies[service]
+	}
+	return builtinDependencies[service]
+}
+
+// callers возвращает сервисы, которые вызывают service
+func (r *run) callers(service string) []string {
+	graph := builtinDependencies
+	if r.topology != nil {
+		graph = r.topology.dependencies
+	}
+
+	var result []string
+	for caller, deps := range graph {
+		for _, dep := range deps {
+			if dep == service {
+				result = append(result, caller)
+				break
+			}
+		}
+	}
+	sort.Strings(result)
+	return result
+}
+
+// nearestFault ищет обходом в ширину ближайший отказавший сервис ниже по графу
+func (r *run) nearestFault(service string) (next, faulty string, ok bool) {
+	type step struct{ service, first string }
+	visited := map[string]bool{service: true}
+	queue := []step{}
+	for _, dep := range r.dependencies(service) {
+		queue = append(queue, step{dep, dep})
+	}
+
+	for len(queue) > 0 {
+		s := queue[0]
+		queue = queue[1:]
+		if visited[s.service] {
+			continue
+		}
+		visited[s.service] = true
+
+		if _, faultyService := r.faults[s.service]; faultyService {
+			return s.first, s.service, true
+		}
+		for _, dep := range r.dependencies(s.service) {
+			queue = append(queue, step{dep, s.first})
+		}
+	}
+	return "", "", false
+}
+
+// builtinDependencies - граф вызовов встроенной топологии, построенный по потокам трассировок
+var builtinDependencies = func() map[string][]string {
+	graph := make(map[string][]string)
+	var walk func(node callNode)
+	walk = func(node callNode) {
+		for _, child := range node.children {
+			if !containsString(graph[node.service], child.service) {
+				graph[node.service] = append(graph[node.service], child.service)
+			}
+			walk(child)
+		}
+	}
+	for _, flow := range traceFlows {
+		walk(flow.root)
+	}
+	return graph
+}()
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// knownService проверяет, что сервис есть в топологии
+func knownService(topology *topologyModel, service string) bool {
+	if topology != nil {
+		_, ok := topology.dependencies[service]
+		return ok
+	}
+	return containsString(services, service) || builtinDependencies[service] != nil
+}
+
+// sortedEdges возвращает вызовы в стабильном порядке
+func sortedEdges[V any](m map[callEdge]V) []callEdge {
+	edges := make([]callEdge, 0, len(m))
+	for e := range m {
+		edges = append(edges, e)
+	}
+	sort.Slice(edges, func(i, j int) bool {
+		if edges[i].caller != edges[j].caller {
+			return edges[i].caller < edges[j].caller
+		}
+		return edges[i].callee < edges[j].callee
+	})
+	return edges
+}
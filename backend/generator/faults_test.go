@@ -0,0 +1,298 @@
+package generator
+
+import (
+	"math"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"log-metrics-simulator/models"
+)
+
+// useFaults снимает внедренные тестом отказы после его завершения
+func useFaults(t *testing.T) {
+	t.Cleanup(func() {
+		faultsMutex.Lock()
+		faults = nil
+		faultsMutex.Unlock()
+	})
+}
+
+// useChainTopology загружает цепочку вызовов gateway -> orders -> db
+func useChainTopology(t *testing.T) {
+	useTopology(t, models.Topology{Services: []models.TopologyService{
+		{Name: "gateway", Dependencies: []string{"orders"}},
+		{Name: "orders", Dependencies: []string{"db"}},
+		{Name: "db"},
+	}})
+}
+
+// addTestFault внедряет отказ и возвращает запуск с seed, начатый через
+// elapsed после начала отказа
+func addTestFault(t *testing.T, fault models.Fault, elapsed time.Duration) (*run, models.Fault) {
+	t.Helper()
+	fault, err := AddFault(fault)
+	if err != nil {
+		t.Fatal(err)
+	}
+	seed := int64(7)
+	return newRun(Options{Seed: &seed, StartTime: fault.StartAt.Add(elapsed)}), fault
+}
+
+func TestFaultPhase(t *testing.T) {
+	start := testTime
+	fault := models.Fault{StartAt: start, OnsetSeconds: 10, DurationSeconds: 20, RecoverySeconds: 40}
+	for _, tc := range []struct {
+		elapsed   time.Duration
+		phase     string
+		intensity float64
+	}{
+		{-time.Second, "pending", 0},
+		{0, "onset", 0},
+		{5 * time.Second, "onset", 0.5},
+		{10 * time.Second, "active", 1},
+		{29 * time.Second, "active", 1},
+		{30 * time.Second, "recovery", 1},
+		{40 * time.Second, "recovery", 0.75},
+		{70 * time.Second, "recovered", 0},
+	} {
+		phase, intensity := faultPhase(fault, start.Add(tc.elapsed))
+		if phase != tc.phase || math.Abs(intensity-tc.intensity) > 1e-9 {
+			t.Errorf("через %v: %s %.2f, ожидалось %s %.2f", tc.elapsed, phase, intensity, tc.phase, tc.intensity)
+		}
+	}
+
+	// Без длительности отказ действует до удаления, без onset - сразу в полную силу
+	endless := models.Fault{StartAt: start}
+	if phase, intensity := faultPhase(endless, start.Add(24*time.Hour)); phase != "active" || intensity != 1 {
+		t.Errorf("бессрочный отказ: %s %.2f", phase, intensity)
+	}
+}
+
+func TestAddFault(t *testing.T) {
+	useFaults(t)
+	useChainTopology(t)
+
+	for _, tc := range []struct {
+		fault models.Fault
+		want  string
+	}{
+		{models.Fault{}, "не задан сервис"},
+		{models.Fault{Service: "payments"}, "неизвестный сервис"},
+		{models.Fault{Service: "db", Type: "meteor"}, "неизвестный тип отказа"},
+		{models.Fault{Service: "db", ErrorRate: 1.5}, "error_rate"},
+		{models.Fault{Service: "db", RecoverySeconds: -1}, "не могут быть отрицательными"},
+	} {
+		if _, err := AddFault(tc.fault); err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("%+v: ошибка %v, ожидалась с %q", tc.fault, err, tc.want)
+		}
+	}
+
+	fault, err := AddFault(models.Fault{Service: "db", Type: "latency", DelaySeconds: 60})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fault.ErrorRate != 1 || fault.LatencyMs != 5000 || fault.StartAt.Sub(fault.CreatedAt) != time.Minute {
+		t.Errorf("значения по умолчанию %+v", fault)
+	}
+	if fault, _ := AddFault(models.Fault{Service: "orders"}); fault.Type != "unavailable" || fault.Error != "Service unavailable" {
+		t.Errorf("тип и ошибка по умолчанию: %s, %q", fault.Type, fault.Error)
+	}
+
+	statuses := ListFaults()
+	if len(statuses) != 2 || statuses[0].Phase != "pending" || statuses[1].Phase != "active" {
+		t.Fatalf("состояния отказов %+v", statuses)
+	}
+	if err := RemoveFault(fault.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := RemoveFault(fault.ID); err == nil {
+		t.Error("повторное удаление отказа без ошибки")
+	}
+	if statuses := ListFaults(); len(statuses) != 1 || statuses[0].Service != "orders" {
+		t.Errorf("после удаления остались %+v", statuses)
+	}
+}
+
+func TestActiveFaultEffects(t *testing.T) {
+	statuses := []models.FaultStatus{
+		{Fault: models.Fault{ID: "weak", Service: "db", ErrorRate: 1}, Intensity: 0.3},
+		{Fault: models.Fault{ID: "strong", Service: "db", ErrorRate: 0.5}, Intensity: 1},
+		{Fault: models.Fault{ID: "over", Service: "orders", ErrorRate: 1}, Intensity: 0},
+	}
+	effects := activeFaultEffects(statuses)
+	if len(effects) != 1 || effects["db"].fault.ID != "strong" {
+		t.Errorf("действующие отказы %+v, ожидался только strong", effects)
+	}
+}
+
+// TestFaultIntensity проверяет, что доля затронутых запросов следует
+// интенсивности отказа при нарастании и восстановлении
+func TestFaultIntensity(t *testing.T) {
+	useRegistry(t)
+	useFaults(t)
+	useChainTopology(t)
+	fault := models.Fault{Service: "db", Type: "errors", OnsetSeconds: 100, DurationSeconds: 60, RecoverySeconds: 100}
+
+	for _, tc := range []struct {
+		elapsed time.Duration
+		want    float64
+	}{
+		{25 * time.Second, 0.25},
+		{100 * time.Second, 1},
+		{235 * time.Second, 0.25},
+		{300 * time.Second, 0},
+	} {
+		r, added := addTestFault(t, fault, tc.elapsed)
+		RemoveFault(added.ID)
+
+		failed := 0
+		const n = 4000
+		for i := 0; i < n; i++ {
+			entry := applyFaultsToLog(r, models.LogEntry{Service: "db", Level: "INFO", Status: http.StatusOK, Duration: 10})
+			if entry.Level == "ERROR" {
+				failed++
+				if entry.Status != http.StatusInternalServerError || entry.Error != "Internal server error" {
+					t.Fatalf("запись отказавшего сервиса %+v", entry)
+				}
+			}
+		}
+		if share := float64(failed) / n; math.Abs(share-tc.want) > 0.03 {
+			t.Errorf("через %v после начала отказа ошибок %.3f, ожидалось %.2f", tc.elapsed, share, tc.want)
+		}
+	}
+}
+
+func TestFaultCascadesUpstream(t *testing.T) {
+	useRegistry(t)
+	useFaults(t)
+	useChainTopology(t)
+	r, _ := addTestFault(t, models.Fault{Service: "db", Type: "errors", Error: "disk full"}, time.Minute)
+
+	// Независимые логи: половина запросов сервиса доходит до отказавшей зависимости
+	kinds := make(map[string]int)
+	const n = 4000
+	for i := 0; i < n; i++ {
+		entry := applyFaultsToLog(r, models.LogEntry{Service: "gateway", Level: "INFO", Status: http.StatusOK, Duration: 10})
+		switch {
+		case entry.Level != "ERROR":
+			kinds["ok"]++
+		case entry.Error == "upstream orders: upstream db: disk full" && entry.Status == http.StatusBadGateway:
+			if !strings.HasSuffix(entry.Message, "after 3 attempts") {
+				t.Fatalf("повторы 5xx не отражены в сообщении: %q", entry.Message)
+			}
+			kinds["error"]++
+		case entry.Error == "upstream orders: circuit breaker open" && entry.Status == http.StatusServiceUnavailable:
+			kinds["circuit_open"]++
+		default:
+			t.Fatalf("неожиданная запись gateway %+v", entry)
+		}
+	}
+	if share := float64(kinds["ok"]) / n; math.Abs(share-downstreamShare) > 0.03 {
+		t.Errorf("доля незатронутых запросов %.3f, ожидалось %.2f", share, downstreamShare)
+	}
+	if kinds["error"] == 0 || kinds["circuit_open"] == 0 {
+		t.Errorf("исходы вызовов %v, ожидались ошибки и разомкнутый circuit breaker", kinds)
+	}
+	edge := callEdge{caller: "gateway", callee: "orders"}
+	if r.retries[edge] != kinds["error"]*maxCallRetries || !r.breakers[edge].open {
+		t.Errorf("повторов %d при %d ошибках, circuit breaker %+v", r.retries[edge], kinds["error"], r.breakers[edge])
+	}
+
+	// В трассировке ошибка db поднимается через orders к gateway
+	for n := 0; n < 50; n++ {
+		trace := generateTrace(r, "normal_load")
+		root := trace[0]
+		if root.Level != "ERROR" {
+			continue
+		}
+		if root.Status != http.StatusBadGateway && root.Status != http.StatusServiceUnavailable {
+			t.Fatalf("статус корня %d", root.Status)
+		}
+		if !strings.HasPrefix(root.Error, "upstream orders: ") {
+			t.Fatalf("ошибка корня %q", root.Error)
+		}
+	}
+}
+
+func TestFaultRetriesAndBreaker(t *testing.T) {
+	useRegistry(t)
+	useFaults(t)
+	// Корень вызывает отказавший сервис напрямую: вызывающего его повторы не мешают
+	useTopology(t, models.Topology{Services: []models.TopologyService{
+		{Name: "orders", Dependencies: []string{"db"}},
+		{Name: "db"},
+	}})
+	r, _ := addTestFault(t, models.Fault{Service: "db", Type: "unavailable"}, time.Minute)
+	edge := callEdge{caller: "orders", callee: "db"}
+
+	// Первый вызов: три попытки получают отказ в соединении
+	trace := generateTrace(r, "normal_load")
+	orders := trace[0]
+	if orders.Status != http.StatusServiceUnavailable || orders.Message != "Connection refused by db after 3 attempts" {
+		t.Fatalf("запись orders %+v", orders)
+	}
+	if len(trace) != 1 {
+		t.Errorf("недоступный db записал %d логов", len(trace)-1)
+	}
+	if r.retries[edge] != maxCallRetries || r.breakers[edge].failures != maxCallRetries+1 {
+		t.Errorf("повторов %d, неудач подряд %d", r.retries[edge], r.breakers[edge].failures)
+	}
+
+	// После breakerThreshold неудач подряд вызовы отклоняются без попыток
+	trace = generateTrace(r, "normal_load")
+	if !r.breakers[edge].open || trace[0].Message != "Connection refused by db after 2 attempts" {
+		t.Fatalf("circuit breaker %+v, запись orders %q", r.breakers[edge], trace[0].Message)
+	}
+	for i := 0; i < breakerOpenCalls; i++ {
+		trace = generateTrace(r, "normal_load")
+		if trace[0].Error != "upstream db: circuit breaker open" {
+			t.Fatalf("вызов %d при разомкнутом circuit breaker: %q", i, trace[0].Error)
+		}
+	}
+	// Пробный вызов снова неудачен, повторов при разомкнутом circuit breaker нет
+	retries := r.retries[edge]
+	trace = generateTrace(r, "normal_load")
+	if trace[0].Message != "Connection refused by db" || r.retries[edge] != retries {
+		t.Errorf("пробный вызов %q, повторов %d -> %d", trace[0].Message, retries, r.retries[edge])
+	}
+
+	updateEcommerceMetrics(r, nil)
+	labels := map[string]string{"app": "app", "service": "orders", "upstream": "db"}
+	if v := value(t, metricRegistry, "ecommerce_circuit_breaker_open", labels); v != 1 {
+		t.Errorf("ecommerce_circuit_breaker_open = %v", v)
+	}
+	if v := value(t, metricRegistry, "ecommerce_upstream_retries_total", labels); v != float64(retries) {
+		t.Errorf("ecommerce_upstream_retries_total = %v, ожидалось %d", v, retries)
+	}
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	var cb circuitBreaker
+	for i := 0; i < breakerThreshold-1; i++ {
+		cb.record(false)
+	}
+	cb.record(true)
+	if cb.open || cb.failures != 0 {
+		t.Fatalf("успех не сбросил счетчик неудач: %+v", cb)
+	}
+	for i := 0; i < breakerThreshold; i++ {
+		if !cb.allow() {
+			t.Fatal("замкнутый circuit breaker отклонил вызов")
+		}
+		cb.record(false)
+	}
+	for i := 0; i < breakerOpenCalls; i++ {
+		if cb.allow() {
+			t.Fatalf("вызов %d пропущен разомкнутым circuit breaker", i)
+		}
+	}
+	if !cb.allow() {
+		t.Fatal("пробный вызов отклонен")
+	}
+	cb.record(true)
+	if cb.open || !cb.allow() {
+		t.Errorf("успешный пробный вызов не замкнул circuit breaker: %+v", cb)
+	}
+}
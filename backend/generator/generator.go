@@ -6,21 +6,22 @@ import (
 	"log"
 	"math/rand"
 	"net/http"
+	"sort"
 	"strings"
 	"sync"
 	"time"
 
 	"log-metrics-simulator/models"
+	"log-metrics-simulator/sinks"
+	"log-metrics-simulator/storage"
 )
 
 var (
-	logs         []models.LogEntry
-	metrics      []models.Metric
-	logsMutex    sync.RWMutex
-	metricsMutex sync.RWMutex
-	// Собственные счетчики приложения (кумулятивные)
-	appGeneratedLogsTotal    int64
-	appGeneratedMetricsTotal int64
+	// Хранилище сгенерированных логов для выдачи через API
+	logStore      storage.LogStore = storage.NewMemoryLogStore(storage.DefaultLogCapacity, 0)
+	logStoreMutex sync.RWMutex
+	// Реестр метрик: счетчики кумулятивны между запусками генерации
+	metricRegistry = newRegistry()
 )
 
 // Сервисы приложения интернет-магазина
@@ -84,117 +85,285 @@ var ipAddresses = []string{
 	"91.200.12.34", "188.162.45.78", "37.139.56.89", "85.26.234.12",
 }
 
-func GenerateLogs(logCount int, scenario string) []models.LogEntry {
-	generatedLogs := make([]models.LogEntry, logCount)
-
-	for i := 0; i < logCount; i++ {
-		generatedLogs[i] = generateRealisticLog(scenario)
-		// Реалистичная задержка между запросами
-		time.Sleep(time.Millisecond * time.Duration(rand.Intn(50)+10))
+// Options задает параметры одного запуска генерации
+type Options struct {
+	// Seed фиксирует источник случайных чисел запуска. Одинаковый seed
+	// дает побайтно одинаковые логи и метрики.
+	Seed *int64
+	// StartTime - опорное время запуска. Для запусков с seed по умолчанию
+	// используется SeedEpoch, иначе текущее время. Сценарии передают модельное
+	// время запуска и с seed.
+	StartTime time.Time
+	// Labels - метки активного сценария, передаются в приемники
+	Labels map[string]string
+	// Sink - приемник запуска в дополнение к глобальным приемникам
+	Sink sinks.Sink
+	// Traces включает генерацию связанных трассировок: запрос проходит через
+	// граф сервисов, и на каждый спан приходится один лог с общим trace_id
+	Traces bool
+	// Profile задает пресет поведения сервисов, долю ошибок, смесь уровней и
+	// время отклика. Без профиля пресетом служит имя сценария.
+	Profile *models.GenerationProfile
+	// Rate - скорость потока событий в секунду. Метки времени логов идут
+	// равномерно от StartTime; без Rate и interval_ms профиля логи разбросаны
+	// по последнему часу.
+	Rate float64
+	// Workers - число параллельных генераторов. Каждый получает свою часть
+	// логов, свой источник случайных чисел и свой отрезок времени.
+	Workers int
+
+	// Моделирование прошлого периода: метрики пишутся в отдельный реестр,
+	// отказы берутся на момент StartTime
+	history *registry
+}
+
+// SeedEpoch - опорное время для запусков с фиксированным seed
+var SeedEpoch = time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+// run хранит состояние одного запуска генерации
+type run struct {
+	rnd      *rand.Rand
+	now      time.Time
+	metrics  *registry      // Реестр, в который пишутся метрики запуска
+	topology *topologyModel // Пользовательская топология; nil - встроенная
+	profile  *models.GenerationProfile
+	levels   []float64     // Накопленные вероятности уровней логов профиля
+	interval time.Duration // Шаг меток времени; 0 - разброс по последнему часу
+	seq      int           // Номер очередного события при равномерных метках времени
+
+	// Внедренные отказы и состояние вызовов между сервисами в пределах запуска
+	faults        map[string]faultEffect
+	faultStatuses []models.FaultStatus
+	breakers      map[callEdge]*circuitBreaker
+	retries       map[callEdge]int
+}
+
+func newRun(opts Options) *run {
+	r := &run{
+		now:      opts.StartTime,
+		metrics:  metricRegistry,
+		topology: currentTopology(),
+		profile:  opts.Profile,
+		levels:   levelThresholds(opts.Profile),
+		interval: eventInterval(opts),
+	}
+	if opts.history != nil {
+		r.metrics = opts.history
+	}
+
+	if opts.Seed != nil {
+		r.rnd = rand.New(rand.NewSource(*opts.Seed))
+		if r.now.IsZero() {
+			r.now = SeedEpoch
+		}
+	} else {
+		r.rnd = rand.New(rand.NewSource(time.Now().UnixNano()))
+		if r.now.IsZero() {
+			r.now = time.Now()
+		}
 	}
 
-	// Сохраняем логи
-	logsMutex.Lock()
-	logs = append(logs, generatedLogs...)
-	if len(logs) > 50000 {
-		logs = logs[len(logs)-50000:]
+	// Запуски с seed и моделирование прошлого берут отказы на момент начала
+	// запуска, чтобы результат зависел только от seed и времени начала.
+	// Остальные запуски видят отказы, действующие сейчас.
+	if opts.Seed != nil || opts.history != nil {
+		r.loadFaults(r.now)
+	} else {
+		r.loadFaults(time.Now())
 	}
-	logsMutex.Unlock()
 
-	// Обновляем метрики
-	updateEcommerceMetrics(generatedLogs)
+	return r
+}
+
+func GenerateLogs(logCount int, scenario string) []models.LogEntry {
+	return GenerateLogsWithOptions(logCount, scenario, Options{})
+}
 
-	// Инкрементируем собственные счетчики приложения
-	// Общее количество сгенерированных логов (кумулятивно)
-	appGeneratedLogsTotal += int64(len(generatedLogs))
-	// Сохраняем текущий размер набора метрик как суммарно сгенерированные метрики
-	metricsMutex.RLock()
-	currentMetrics := len(metrics)
-	metricsMutex.RUnlock()
-	appGeneratedMetricsTotal += int64(currentMetrics)
+func GenerateLogsWithOptions(logCount int, scenario string, opts Options) []models.LogEntry {
+	generatedLogs := generate(logCount, scenario, opts)
+	publish(scenario, opts, generatedLogs)
 
 	// Пишем краткую запись в лог приложения
-	logsMutex.RLock()
-	totalLogs := len(logs)
-	logsMutex.RUnlock()
-	log.Printf("[simulator] generated=%d scenario=%s total_logs=%d metrics_now=%d", len(generatedLogs), scenario, totalLogs, currentMetrics)
+	totalLogs := currentLogStore().Len()
+	log.Printf("[simulator] generated=%d scenario=%s total_logs=%d metrics_now=%d", len(generatedLogs), scenario, totalLogs, metricRegistry.len())
 
-	for _, log := range logs {
-		logJson, _ := json.Marshal(log)
-		fmt.Println(string(logJson))
+	return generatedLogs
+}
+
+// generate генерирует logCount логов и обновляет по ним метрики. Логи не
+// сохраняются и не уходят в приемники.
+func generate(logCount int, scenario string, opts Options) []models.LogEntry {
+	// Пресет поведения сервисов: из профиля или по имени сценария
+	preset := scenario
+	if opts.Profile != nil && opts.Profile.Preset != "" {
+		preset = opts.Profile.Preset
+	}
+
+	runs, counts := workerRuns(logCount, opts)
+	parts := make([][]models.LogEntry, len(runs))
+	var wg sync.WaitGroup
+	for i := range runs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			parts[i] = generateWith(runs[i], counts[i], preset, opts.Traces)
+		}(i)
+	}
+	wg.Wait()
+
+	// Метрики обновляются в порядке генераторов, чтобы запуск с seed был воспроизводим
+	generatedLogs := make([]models.LogEntry, 0, logCount)
+	for i, r := range runs {
+		updateEcommerceMetrics(r, parts[i])
+		generatedLogs = append(generatedLogs, parts[i]...)
 	}
-	
 	return generatedLogs
 }
 
-func generateRealisticLog(scenario string) models.LogEntry {
-	service := services[rand.Intn(len(services))]
-	level := getLogLevel()
-	traceID := generateTraceID()
-	spanID := generateSpanID()
+// generateWith генерирует logCount логов в рамках одного запуска
+func generateWith(r *run, logCount int, preset string, traces bool) []models.LogEntry {
+	generatedLogs := make([]models.LogEntry, 0, logCount)
+	if traces {
+		for len(generatedLogs) < logCount {
+			trace := generateTrace(r, preset)
+			// Последняя трассировка обрезается до logCount; логи идут от родителя
+			// к дочерним, поэтому у оставшихся спанов родитель всегда есть
+			if rest := logCount - len(generatedLogs); len(trace) > rest {
+				trace = trace[:rest]
+			}
+			generatedLogs = append(generatedLogs, trace...)
+			// Спаны трассировки тоже события потока
+			r.seq += len(trace) - 1
+		}
+		return generatedLogs
+	}
+
+	for i := 0; i < logCount; i++ {
+		generatedLogs = append(generatedLogs, generateRealisticLog(r, preset))
+	}
+	return generatedLogs
+}
+
+// publish сохраняет логи и отправляет их в приемники
+func publish(scenario string, opts Options, generatedLogs []models.LogEntry) {
+	if err := currentLogStore().Append(generatedLogs); err != nil {
+		log.Printf("❌ Ошибка сохранения логов: %v", err)
+	}
+
+	writeSinks(scenario, opts, generatedLogs)
+}
+
+// writeSinks отправляет логи в глобальные приемники и приемник запуска
+func writeSinks(scenario string, opts Options, generatedLogs []models.LogEntry) {
+	batch := sinks.Batch{Scenario: scenario, Labels: opts.Labels, Logs: generatedLogs}
+	if err := sinks.WriteGlobal(batch); err != nil {
+		log.Printf("❌ Ошибка записи в приемники: %v", err)
+	}
+	if opts.Sink != nil {
+		if err := opts.Sink.Write(batch); err != nil {
+			log.Printf("❌ Ошибка записи в приемники сценария: %v", err)
+		}
+	}
+}
+
+func generateRealisticLog(r *run, scenario string) models.LogEntry {
+	rnd := r.rnd
+	if r.topology != nil {
+		return generateTopologyLog(r, scenario)
+	}
+
+	service := services[rnd.Intn(len(services))]
+	level := r.logLevel()
+	traceID := generateTraceID(rnd)
+	spanID := generateSpanID(rnd)
 
 	logEntry := models.LogEntry{
-		Timestamp: time.Now().Add(-time.Duration(rand.Intn(3600)) * time.Second), // Логи за последний час
+		Timestamp: r.timestamp(),
 		Level:     level,
 		Service:   service,
 		TraceID:   traceID,
 		SpanID:    spanID,
-		UserID:    fmt.Sprintf("user-%d", rand.Intn(50000)+1),
-		SessionID: generateSessionID(),
-		IP:        ipAddresses[rand.Intn(len(ipAddresses))],
-		UserAgent: userAgents[rand.Intn(len(userAgents))],
+		UserID:    fmt.Sprintf("user-%d", rnd.Intn(50000)+1),
+		SessionID: generateSessionID(rnd),
+		IP:        ipAddresses[rnd.Intn(len(ipAddresses))],
+		UserAgent: userAgents[rnd.Intn(len(userAgents))],
+	}
+
+	logEntry = applyServiceLog(rnd, logEntry, scenario, level)
+	return applyFaultsToLog(r, r.applyProfile(logEntry))
+}
+
+// generateTopologyLog генерирует независимый лог сервиса пользовательской топологии
+func generateTopologyLog(r *run, scenario string) models.LogEntry {
+	rnd := r.rnd
+	service := r.topology.pickService(rnd)
+
+	logEntry := models.LogEntry{
+		Timestamp: r.timestamp(),
+		TraceID:   generateTraceID(rnd),
+		SpanID:    generateSpanID(rnd),
+		UserID:    fmt.Sprintf("user-%d", rnd.Intn(50000)+1),
+		SessionID: generateSessionID(rnd),
+		IP:        ipAddresses[rnd.Intn(len(ipAddresses))],
+		UserAgent: userAgents[rnd.Intn(len(userAgents))],
 	}
 
+	logEntry, _ = service.fillLog(rnd, logEntry, scenario)
+	return applyFaultsToLog(r, r.applyProfile(logEntry))
+}
+
+// applyServiceLog применяет сценарий и заполняет запись в зависимости от сервиса
+func applyServiceLog(rnd *rand.Rand, logEntry models.LogEntry, scenario, level string) models.LogEntry {
 	// Применяем сценарий
 	switch scenario {
 	case "black_friday":
-		logEntry = applyBlackFridayScenario(logEntry)
+		logEntry = applyBlackFridayScenario(rnd, logEntry)
 	case "normal_load":
-		logEntry = applyNormalLoadScenario(logEntry)
+		logEntry = applyNormalLoadScenario(rnd, logEntry)
 	case "high_load":
-		logEntry = applyHighLoadScenario(logEntry)
+		logEntry = applyHighLoadScenario(rnd, logEntry)
 	case "payment_issues":
-		logEntry = applyPaymentIssuesScenario(logEntry)
+		logEntry = applyPaymentIssuesScenario(rnd, logEntry)
 	default:
-		logEntry = applyNormalLoadScenario(logEntry)
+		logEntry = applyNormalLoadScenario(rnd, logEntry)
 	}
 
 	// Генерируем лог в зависимости от сервиса
-	switch service {
+	switch logEntry.Service {
 	case "api-gateway":
-		logEntry = generateApiGatewayLog(logEntry, level)
+		logEntry = generateApiGatewayLog(rnd, logEntry, level)
 	case "auth-service":
-		logEntry = generateAuthLog(logEntry, level)
+		logEntry = generateAuthLog(rnd, logEntry, level)
 	case "user-service":
-		logEntry = generateUserLog(logEntry, level)
+		logEntry = generateUserLog(rnd, logEntry, level)
 	case "product-service":
-		logEntry = generateProductLog(logEntry, level)
+		logEntry = generateProductLog(rnd, logEntry, level)
 	case "cart-service":
-		logEntry = generateCartLog(logEntry, level)
+		logEntry = generateCartLog(rnd, logEntry, level)
 	case "order-service":
-		logEntry = generateOrderLog(logEntry, level)
+		logEntry = generateOrderLog(rnd, logEntry, level)
 	case "payment-service":
-		logEntry = generatePaymentLog(logEntry, level)
+		logEntry = generatePaymentLog(rnd, logEntry, level)
 	case "inventory-service":
-		logEntry = generateInventoryLog(logEntry, level)
+		logEntry = generateInventoryLog(rnd, logEntry, level)
 	case "search-service":
-		logEntry = generateSearchLog(logEntry, level)
+		logEntry = generateSearchLog(rnd, logEntry, level)
 	case "recommendation-service":
-		logEntry = generateRecommendationLog(logEntry, level)
+		logEntry = generateRecommendationLog(rnd, logEntry, level)
 	default:
-		logEntry = generateGenericLog(logEntry, level)
+		logEntry = generateGenericLog(rnd, logEntry, level)
 	}
 
 	return logEntry
 }
 
-func generateAuthLog(logEntry models.LogEntry, level string) models.LogEntry {
+func generateAuthLog(rnd *rand.Rand, logEntry models.LogEntry, level string) models.LogEntry {
 	actions := []string{"login", "register", "logout", "token_refresh", "password_reset"}
-	action := actions[rand.Intn(len(actions))]
+	action := actions[rnd.Intn(len(actions))]
 
 	logEntry.Method = "POST"
 	logEntry.Path = fmt.Sprintf("/api/v1/auth/%s", action)
-	logEntry.Duration = int64(rand.Intn(200) + 50)
+	logEntry.Duration = int64(rnd.Intn(200) + 50)
 
 	switch level {
 	case "INFO":
@@ -215,16 +384,16 @@ func generateAuthLog(logEntry models.LogEntry, level string) models.LogEntry {
 	return logEntry
 }
 
-func generateUserLog(logEntry models.LogEntry, level string) models.LogEntry {
+func generateUserLog(rnd *rand.Rand, logEntry models.LogEntry, level string) models.LogEntry {
 	actions := []string{"get_profile", "update_profile", "get_preferences", "update_preferences"}
-	action := actions[rand.Intn(len(actions))]
+	action := actions[rnd.Intn(len(actions))]
 
 	logEntry.Method = "GET"
 	if action[:6] == "update" {
 		logEntry.Method = "PUT"
 	}
 	logEntry.Path = fmt.Sprintf("/api/v1/users/%s", action)
-	logEntry.Duration = int64(rand.Intn(150) + 30)
+	logEntry.Duration = int64(rnd.Intn(150) + 30)
 
 	switch level {
 	case "INFO":
@@ -242,9 +411,9 @@ func generateUserLog(logEntry models.LogEntry, level string) models.LogEntry {
 	return logEntry
 }
 
-func generateProductLog(logEntry models.LogEntry, level string) models.LogEntry {
+func generateProductLog(rnd *rand.Rand, logEntry models.LogEntry, level string) models.LogEntry {
 	actions := []string{"list", "get", "search", "create", "update"}
-	action := actions[rand.Intn(len(actions))]
+	action := actions[rnd.Intn(len(actions))]
 
 	logEntry.Method = "GET"
 	if action == "create" {
@@ -253,13 +422,13 @@ func generateProductLog(logEntry models.LogEntry, level string) models.LogEntry
 		logEntry.Method = "PUT"
 	}
 
-	productID := fmt.Sprintf("prod-%d", rand.Intn(1000)+1)
+	productID := fmt.Sprintf("prod-%d", rnd.Intn(1000)+1)
 	logEntry.Path = fmt.Sprintf("/api/v1/products/%s", productID)
 	if action == "list" || action == "search" {
 		logEntry.Path = "/api/v1/products"
 	}
 
-	logEntry.Duration = int64(rand.Intn(300) + 100)
+	logEntry.Duration = int64(rnd.Intn(300) + 100)
 
 	switch level {
 	case "INFO":
@@ -277,16 +446,16 @@ func generateProductLog(logEntry models.LogEntry, level string) models.LogEntry
 	return logEntry
 }
 
-func generateCartLog(logEntry models.LogEntry, level string) models.LogEntry {
+func generateCartLog(rnd *rand.Rand, logEntry models.LogEntry, level string) models.LogEntry {
 	actions := []string{"get", "add_item", "remove_item", "update_quantity", "clear"}
-	action := actions[rand.Intn(len(actions))]
+	action := actions[rnd.Intn(len(actions))]
 
 	logEntry.Method = "GET"
 	if action != "get" {
 		logEntry.Method = "POST"
 	}
 	logEntry.Path = "/api/v1/cart/items"
-	logEntry.Duration = int64(rand.Intn(200) + 50)
+	logEntry.Duration = int64(rnd.Intn(200) + 50)
 
 	switch level {
 	case "INFO":
@@ -304,16 +473,16 @@ func generateCartLog(logEntry models.LogEntry, level string) models.LogEntry {
 	return logEntry
 }
 
-func generateOrderLog(logEntry models.LogEntry, level string) models.LogEntry {
+func generateOrderLog(rnd *rand.Rand, logEntry models.LogEntry, level string) models.LogEntry {
 	actions := []string{"create", "get", "list", "cancel", "update_status"}
-	action := actions[rand.Intn(len(actions))]
+	action := actions[rnd.Intn(len(actions))]
 
 	logEntry.Method = "POST"
 	if action == "get" || action == "list" {
 		logEntry.Method = "GET"
 	}
 	logEntry.Path = "/api/v1/orders"
-	logEntry.Duration = int64(rand.Intn(500) + 200)
+	logEntry.Duration = int64(rnd.Intn(500) + 200)
 
 	switch level {
 	case "INFO":
@@ -331,16 +500,16 @@ func generateOrderLog(logEntry models.LogEntry, level string) models.LogEntry {
 	return logEntry
 }
 
-func generatePaymentLog(logEntry models.LogEntry, level string) models.LogEntry {
+func generatePaymentLog(rnd *rand.Rand, logEntry models.LogEntry, level string) models.LogEntry {
 	actions := []string{"process", "refund", "get_status", "create_intent"}
-	action := actions[rand.Intn(len(actions))]
+	action := actions[rnd.Intn(len(actions))]
 
 	logEntry.Method = "POST"
 	if action == "get_status" {
 		logEntry.Method = "GET"
 	}
 	logEntry.Path = "/api/v1/payments"
-	logEntry.Duration = int64(rand.Intn(1000) + 500)
+	logEntry.Duration = int64(rnd.Intn(1000) + 500)
 
 	switch level {
 	case "INFO":
@@ -359,13 +528,13 @@ func generatePaymentLog(logEntry models.LogEntry, level string) models.LogEntry
 	return logEntry
 }
 
-func generateGenericLog(logEntry models.LogEntry, level string) models.LogEntry {
+func generateGenericLog(rnd *rand.Rand, logEntry models.LogEntry, level string) models.LogEntry {
 	methods := []string{"health_check", "metrics", "config_reload", "cache_clear"}
-	method := methods[rand.Intn(len(methods))]
+	method := methods[rnd.Intn(len(methods))]
 
 	logEntry.Method = "GET"
 	logEntry.Path = fmt.Sprintf("/internal/%s", method)
-	logEntry.Duration = int64(rand.Intn(100) + 10)
+	logEntry.Duration = int64(rnd.Intn(100) + 10)
 
 	switch level {
 	case "INFO":
@@ -383,269 +552,82 @@ func generateGenericLog(logEntry models.LogEntry, level string) models.LogEntry
 	return logEntry
 }
 
-func getLogLevel() string {
-	r := rand.Float64()
-	switch {
-	case r < 0.65:
-		return "INFO"
-	case r < 0.85:
-		return "WARN"
-	case r < 0.95:
-		return "ERROR"
-	default:
-		return "DEBUG"
-	}
+// Вспомогательные функции
+// generateTraceID возвращает 128-битный trace_id из 32 hex-символов, как в W3C Trace Context
+func generateTraceID(rnd *rand.Rand) string {
+	return fmt.Sprintf("%016x%016x", rnd.Uint64(), rnd.Uint64())
 }
 
-func updateMetrics(logs []models.LogEntry) {
-	metricsMutex.Lock()
-	defer metricsMutex.Unlock()
-
-	metrics = []models.Metric{}
-
-	totalRequests := len(logs)
-	statusCount := make(map[int]int)
-	serviceCount := make(map[string]int)
-	levelCount := make(map[string]int)
-	totalDuration := int64(0)
-	errorCount := 0
-
-	for _, log := range logs {
-		statusCount[log.Status]++
-		serviceCount[log.Service]++
-		levelCount[log.Level]++
-		totalDuration += log.Duration
-
-		if log.Level == "ERROR" {
-			errorCount++
-		}
-	}
-
-	now := time.Now()
-
-	// HTTP метрики
-	metrics = append(metrics, models.Metric{
-		Name:      "http_requests_total",
-		Value:     float64(totalRequests),
-		Type:      "counter",
-		Labels:    map[string]string{},
-		Timestamp: now,
-	})
-
-	metrics = append(metrics, models.Metric{
-		Name:  "http_request_duration_ms",
-		Value: float64(totalDuration) / float64(totalRequests),
-		Type:  "gauge",
-		Labels: map[string]string{
-			"quantile": "0.5",
-		},
-		Timestamp: now,
-	})
-
-	metrics = append(metrics, models.Metric{
-		Name:      "http_errors_total",
-		Value:     float64(errorCount),
-		Type:      "counter",
-		Labels:    map[string]string{},
-		Timestamp: now,
-	})
-
-	// Метрики по сервисам
-	for service, count := range serviceCount {
-		metrics = append(metrics, models.Metric{
-			Name:  "http_requests_total",
-			Value: float64(count),
-			Type:  "counter",
-			Labels: map[string]string{
-				"service": service,
-			},
-			Timestamp: now,
-		})
-	}
-
-	// Метрики по статусам
-	for status, count := range statusCount {
-		metrics = append(metrics, models.Metric{
-			Name:  "http_responses_total",
-			Value: float64(count),
-			Type:  "counter",
-			Labels: map[string]string{
-				"status": fmt.Sprintf("%d", status),
-			},
-			Timestamp: now,
-		})
-	}
-
-	// Бизнес метрики
-	metrics = append(metrics, models.Metric{
-		Name:      "active_users",
-		Value:     float64(rand.Intn(5000) + 1000),
-		Type:      "gauge",
-		Labels:    map[string]string{},
-		Timestamp: now,
-	})
-
-	metrics = append(metrics, models.Metric{
-		Name:      "orders_processed_total",
-		Value:     float64(rand.Intn(10000) + 5000),
-		Type:      "counter",
-		Labels:    map[string]string{},
-		Timestamp: now,
-	})
-
-	metrics = append(metrics, models.Metric{
-		Name:      "revenue_total",
-		Value:     float64(rand.Intn(1000000) + 500000),
-		Type:      "counter",
-		Labels:    map[string]string{"currency": "USD"},
-		Timestamp: now,
-	})
+// generateSpanID возвращает 64-битный span_id из 16 hex-символов
+func generateSpanID(rnd *rand.Rand) string {
+	return fmt.Sprintf("%016x", rnd.Uint64())
 }
 
-// Вспомогательные функции
-func generateTraceID() string {
-	return fmt.Sprintf("%x", rand.Uint64())
+func generateSessionID(rnd *rand.Rand) string {
+	return fmt.Sprintf("session-%x", rnd.Uint64())
 }
 
-func generateSpanID() string {
-	return fmt.Sprintf("%x", rand.Uint32())
+// Ключи карт сортируются, чтобы порядок метрик не зависел от обхода map
+func sortedIntKeys(m map[int]int) []int {
+	keys := make([]int, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Ints(keys)
+	return keys
 }
 
-func generateSessionID() string {
-	return fmt.Sprintf("session-%x", rand.Uint64())
+func sortedStringKeys(m map[string]int) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
 }
 
-func GetLogs(limit int, service, level string) []models.LogEntry {
-	logsMutex.RLock()
-	defer logsMutex.RUnlock()
-
-	var filtered []models.LogEntry
-	count := 0
+// GetLogs возвращает последние логи, подходящие под query, в порядке записи
+func GetLogs(query storage.LogQuery) ([]models.LogEntry, error) {
+	return currentLogStore().Query(query)
+}
 
-	for i := len(logs) - 1; i >= 0 && count < limit; i-- {
-		logEntry := logs[i]
+// SetLogStore заменяет хранилище логов и возвращает прежнее
+func SetLogStore(store storage.LogStore) storage.LogStore {
+	logStoreMutex.Lock()
+	defer logStoreMutex.Unlock()
 
-		if service != "" && logEntry.Service != service {
-			continue
-		}
-		if level != "" && logEntry.Level != level {
-			continue
-		}
-
-		filtered = append([]models.LogEntry{logEntry}, filtered...)
-		count++
-	}
+	previous := logStore
+	logStore = store
+	return previous
+}
 
-	return filtered
+func currentLogStore() storage.LogStore {
+	logStoreMutex.RLock()
+	defer logStoreMutex.RUnlock()
+	return logStore
 }
 
 func GetMetrics() []models.Metric {
-	metricsMutex.RLock()
-	defer metricsMutex.RUnlock()
-
-	result := make([]models.Metric, len(metrics))
-	copy(result, metrics)
-	return result
+	return metricRegistry.snapshot()
 }
 
-func GetMetricsPrometheus() string {
-	metricsMutex.RLock()
-	defer metricsMutex.RUnlock()
-
-	var result string
-
-	// Добавляем HELP и TYPE комментарии
-	metricTypes := make(map[string]string)
-	metricHelp := make(map[string]string)
-
-	// Собираем уникальные метрики
-	uniqueMetrics := make(map[string]bool)
-	for _, metric := range metrics {
-		if !uniqueMetrics[metric.Name] {
-			uniqueMetrics[metric.Name] = true
-			metricTypes[metric.Name] = metric.Type
-
-			// Добавляем описания метрик
-			switch metric.Name {
-			case "ecommerce_http_requests_total":
-				metricHelp[metric.Name] = "Total number of HTTP requests"
-			case "ecommerce_http_responses_total":
-				metricHelp[metric.Name] = "Total number of HTTP responses by status code"
-			case "ecommerce_service_requests_total":
-				metricHelp[metric.Name] = "Total number of requests by service"
-			case "ecommerce_http_request_duration_ms":
-				metricHelp[metric.Name] = "Average HTTP request duration in milliseconds"
-			case "ecommerce_orders_total":
-				metricHelp[metric.Name] = "Total number of orders processed"
-			case "ecommerce_revenue_total":
-				metricHelp[metric.Name] = "Total revenue generated"
-			case "ecommerce_payments_processed":
-				metricHelp[metric.Name] = "Total number of payments processed"
-			case "ecommerce_search_queries":
-				metricHelp[metric.Name] = "Total number of search queries"
-			case "ecommerce_cart_actions":
-				metricHelp[metric.Name] = "Total number of cart actions"
-			case "ecommerce_auth_actions":
-				metricHelp[metric.Name] = "Total number of authentication actions"
-			case "ecommerce_error_rate":
-				metricHelp[metric.Name] = "Error rate as a percentage"
-			case "ecommerce_active_users":
-				metricHelp[metric.Name] = "Current number of active users"
-			case "ecommerce_inventory_items_low_stock":
-				metricHelp[metric.Name] = "Number of items with low stock"
-			default:
-				metricHelp[metric.Name] = "Application metric"
-			}
-		}
-	}
-
-	// Добавляем HELP и TYPE для каждой метрики
-	for metricName, help := range metricHelp {
-		result += fmt.Sprintf("# HELP %s %s\n", metricName, help)
-		result += fmt.Sprintf("# TYPE %s %s\n", metricName, metricTypes[metricName])
-
-		// Добавляем значения метрик
-		for _, metric := range metrics {
-			if metric.Name == metricName {
-				labels := ""
-				for k, v := range metric.Labels {
-					if labels != "" {
-						labels += ","
-					}
-					labels += fmt.Sprintf("%s=\"%s\"", k, v)
-				}
-
-				if labels != "" {
-					result += fmt.Sprintf("%s{%s} %.2f\n", metric.Name, labels, metric.Value)
-				} else {
-					result += fmt.Sprintf("%s %.2f\n", metric.Name, metric.Value)
-				}
-			}
-		}
-		result += "\n"
-	}
-
-	return result
+// GetMetricFamilies возвращает семейства метрик с корзинами гистограмм и квантилями summary
+func GetMetricFamilies() []models.MetricFamily {
+	return metricRegistry.exportFamilies()
 }
 
 func GetLogStatistics() map[string]interface{} {
-	logsMutex.RLock()
-	defer logsMutex.RUnlock()
-
-	stats := map[string]interface{}{
-		"total_logs": len(logs),
-		"services":   make(map[string]int),
-		"levels":     make(map[string]int),
-		"statuses":   make(map[int]int),
+	stats, err := currentLogStore().Stats()
+	if err != nil {
+		log.Printf("❌ Ошибка чтения логов: %v", err)
 	}
 
-	for _, log := range logs {
-		stats["services"].(map[string]int)[log.Service]++
-		stats["levels"].(map[string]int)[log.Level]++
-		stats["statuses"].(map[int]int)[log.Status]++
+	return map[string]interface{}{
+		"total_logs": stats.Total,
+		"services":   stats.Services,
+		"levels":     stats.Levels,
+		"statuses":   stats.Statuses,
 	}
-
-	return stats
 }
 
 // Форматирование логов для вывода
@@ -670,15 +652,15 @@ func FormatLogsAsText(logs []models.LogEntry) string {
 	return result
 }
 
-func generateApiGatewayLog(logEntry models.LogEntry, level string) models.LogEntry {
+func generateApiGatewayLog(rnd *rand.Rand, logEntry models.LogEntry, level string) models.LogEntry {
 	paths := []string{
 		"/api/v1/auth/login", "/api/v1/products", "/api/v1/cart",
 		"/api/v1/orders", "/api/v1/search", "/api/v1/users/profile",
 	}
 
-	logEntry.Method = []string{"GET", "POST", "PUT", "DELETE"}[rand.Intn(4)]
-	logEntry.Path = paths[rand.Intn(len(paths))]
-	logEntry.Duration = int64(rand.Intn(50) + 10)
+	logEntry.Method = []string{"GET", "POST", "PUT", "DELETE"}[rnd.Intn(4)]
+	logEntry.Path = paths[rnd.Intn(len(paths))]
+	logEntry.Duration = int64(rnd.Intn(50) + 10)
 
 	switch level {
 	case "INFO":
@@ -699,18 +681,18 @@ func generateApiGatewayLog(logEntry models.LogEntry, level string) models.LogEnt
 	return logEntry
 }
 
-func generateSearchLog(logEntry models.LogEntry, level string) models.LogEntry {
+func generateSearchLog(rnd *rand.Rand, logEntry models.LogEntry, level string) models.LogEntry {
 	searchQueries := []string{
 		"iphone 15", "samsung galaxy", "nike shoes", "winter jacket",
 		"laptop gaming", "wireless headphones", "kitchen appliances",
 	}
 
-	query := searchQueries[rand.Intn(len(searchQueries))]
+	query := searchQueries[rnd.Intn(len(searchQueries))]
 	logEntry.Method = "GET"
 	logEntry.Path = fmt.Sprintf("/api/v1/search?q=%s", strings.ReplaceAll(query, " ", "%20"))
-	logEntry.Duration = int64(rand.Intn(300) + 100)
+	logEntry.Duration = int64(rnd.Intn(300) + 100)
 
-	resultsCount := rand.Intn(1000) + 1
+	resultsCount := rnd.Intn(1000) + 1
 
 	switch level {
 	case "INFO":
@@ -731,19 +713,19 @@ func generateSearchLog(logEntry models.LogEntry, level string) models.LogEntry {
 	return logEntry
 }
 
-func generateInventoryLog(logEntry models.LogEntry, level string) models.LogEntry {
-	productID := fmt.Sprintf("prod-%d", rand.Intn(10000)+1)
+func generateInventoryLog(rnd *rand.Rand, logEntry models.LogEntry, level string) models.LogEntry {
+	productID := fmt.Sprintf("prod-%d", rnd.Intn(10000)+1)
 	actions := []string{"check_stock", "reserve_item", "release_reservation", "update_stock"}
-	action := actions[rand.Intn(len(actions))]
+	action := actions[rnd.Intn(len(actions))]
 
 	logEntry.Method = "POST"
 	if action == "check_stock" {
 		logEntry.Method = "GET"
 	}
 	logEntry.Path = fmt.Sprintf("/api/v1/inventory/%s", action)
-	logEntry.Duration = int64(rand.Intn(200) + 50)
+	logEntry.Duration = int64(rnd.Intn(200) + 50)
 
-	quantity := rand.Intn(100) + 1
+	quantity := rnd.Intn(100) + 1
 
 	switch level {
 	case "INFO":
@@ -764,15 +746,15 @@ func generateInventoryLog(logEntry models.LogEntry, level string) models.LogEntr
 	return logEntry
 }
 
-func generateRecommendationLog(logEntry models.LogEntry, level string) models.LogEntry {
+func generateRecommendationLog(rnd *rand.Rand, logEntry models.LogEntry, level string) models.LogEntry {
 	algorithms := []string{"collaborative_filtering", "content_based", "hybrid", "trending"}
-	algorithm := algorithms[rand.Intn(len(algorithms))]
+	algorithm := algorithms[rnd.Intn(len(algorithms))]
 
 	logEntry.Method = "GET"
 	logEntry.Path = fmt.Sprintf("/api/v1/recommendations?user_id=%s&type=%s", logEntry.UserID, algorithm)
-	logEntry.Duration = int64(rand.Intn(500) + 200)
+	logEntry.Duration = int64(rnd.Intn(500) + 200)
 
-	recommendationsCount := rand.Intn(20) + 5
+	recommendationsCount := rnd.Intn(20) + 5
 
 	switch level {
 	case "INFO":
@@ -793,20 +775,54 @@ func generateRecommendationLog(logEntry models.LogEntry, level string) models.Lo
 	return logEntry
 }
 
-func updateEcommerceMetrics(logs []models.LogEntry) {
-	metricsMutex.Lock()
-	defer metricsMutex.Unlock()
-
-	// Сбрасываем метрики для обновления
-	metrics = []models.Metric{}
+// Описания метрик для строк HELP
+var metricDescriptions = map[string]string{
+	"ecommerce_http_requests_total":       "Total number of HTTP requests",
+	"ecommerce_http_responses_total":      "Total number of HTTP responses by status code",
+	"ecommerce_service_requests_total":    "Total number of requests by service",
+	durationHistogramName:                 "HTTP request duration in milliseconds",
+	durationSummaryName:                   "HTTP request duration quantiles in milliseconds",
+	"ecommerce_orders_total":              "Total number of orders processed",
+	"ecommerce_revenue_total":             "Total revenue generated",
+	"ecommerce_payments_processed":        "Total number of payments processed",
+	"ecommerce_search_queries":            "Total number of search queries",
+	"ecommerce_cart_actions":              "Total number of cart actions",
+	"ecommerce_auth_actions":              "Total number of authentication actions",
+	"ecommerce_error_rate":                "Share of ERROR logs in the last generated batch from 0 to 1",
+	"ecommerce_active_users":              "Current number of active users",
+	"ecommerce_inventory_items_low_stock": "Number of items with low stock",
+	"app_generated_logs_total":            "Total number of logs generated by the simulator",
+	"app_generated_metrics_total":         "Total number of metric series updates made by the simulator",
+	"app_sink_documents_indexed_total":    "Total number of documents accepted by log sinks",
+	"app_sink_indexing_errors_total":      "Total number of documents rejected by log sinks by error type",
+	"app_fault_intensity":                 "Current intensity of injected faults from 0 to 1",
+	"ecommerce_upstream_retries_total":    "Total number of retried calls between services",
+	"ecommerce_circuit_breaker_open":      "Whether the circuit breaker between two services is open",
+	"app_generation_target_rate":          "Target event rate of the rate-controlled generator per second",
+	"app_generation_achieved_rate":        "Event rate achieved by the rate-controlled generator per second",
+	"app_generation_dropped_total":        "Total number of events skipped because the generator fell behind the target rate",
+}
+
+func init() {
+	for name, help := range metricDescriptions {
+		metricRegistry.describe(name, help)
+	}
+
+	// Счетчики приемников логов попадают в общий реестр
+	sinks.SetCounterHook(func(name string, labels map[string]string, delta float64) {
+		if err := metricRegistry.addCounter(name, labels, delta, time.Now()); err != nil {
+			log.Printf("❌ Ошибка обновления метрики приемника: %v", err)
+		}
+	})
+}
 
+func updateEcommerceMetrics(r *run, logs []models.LogEntry) {
 	// Счетчики
 	totalRequests := len(logs)
 	statusCount := make(map[int]int)
 	serviceCount := make(map[string]int)
 	levelCount := make(map[string]int)
 	methodCount := make(map[string]int)
-	totalDuration := int64(0)
 	errorCount := 0
 
 	// Бизнес-метрики
@@ -822,7 +838,6 @@ func updateEcommerceMetrics(logs []models.LogEntry) {
 		serviceCount[log.Service]++
 		levelCount[log.Level]++
 		methodCount[log.Method]++
-		totalDuration += log.Duration
 
 		if log.Level == "ERROR" {
 			errorCount++
@@ -833,7 +848,7 @@ func updateEcommerceMetrics(logs []models.LogEntry) {
 		case "order-service":
 			orderCount++
 			if log.Status == http.StatusOK && strings.Contains(log.Message, "create") {
-				totalRevenue += float64(rand.Intn(5000) + 100) // Случайная сумма заказа
+				totalRevenue += float64(r.rnd.Intn(5000) + 100) // Случайная сумма заказа
 			}
 		case "payment-service":
 			paymentCount++
@@ -846,164 +861,124 @@ func updateEcommerceMetrics(logs []models.LogEntry) {
 		}
 	}
 
-	now := time.Now()
+	now := r.now
+	// Метка app - имя пользовательской топологии, для встроенной - ecommerce
+	appName := "ecommerce"
+	if r.topology != nil {
+		appName = r.topology.name
+	}
+	app := map[string]string{"app": appName}
+
+	// Ошибка одной метрики (например, имя занято метрикой другого типа) не
+	// мешает обновить остальные
+	check := func(err error) {
+		if err != nil {
+			log.Printf("❌ Ошибка обновления метрики: %v", err)
+		}
+	}
 
 	// HTTP метрики
-	metrics = append(metrics, models.Metric{
-		Name:      "ecommerce_http_requests_total",
-		Value:     float64(totalRequests),
-		Type:      "counter",
-		Labels:    map[string]string{"app": "ecommerce"},
-		Timestamp: now,
-	})
+	check(r.metrics.addCounter("ecommerce_http_requests_total", app, float64(totalRequests), now))
 
 	// Метрики по статус-кодам
-	for status, count := range statusCount {
-		metrics = append(metrics, models.Metric{
-			Name:      "ecommerce_http_responses_total",
-			Value:     float64(count),
-			Type:      "counter",
-			Labels:    map[string]string{"status": fmt.Sprintf("%d", status), "app": "ecommerce"},
-			Timestamp: now,
-		})
+	for _, status := range sortedIntKeys(statusCount) {
+		check(r.metrics.addCounter("ecommerce_http_responses_total",
+			map[string]string{"status": fmt.Sprintf("%d", status), "app": appName},
+			float64(statusCount[status]), now))
 	}
 
 	// Метрики по сервисам
-	for service, count := range serviceCount {
-		metrics = append(metrics, models.Metric{
-			Name:      "ecommerce_service_requests_total",
-			Value:     float64(count),
-			Type:      "counter",
-			Labels:    map[string]string{"service": service, "app": "ecommerce"},
-			Timestamp: now,
-		})
+	for _, service := range sortedStringKeys(serviceCount) {
+		check(r.metrics.addCounter("ecommerce_service_requests_total",
+			map[string]string{"service": service, "app": appName},
+			float64(serviceCount[service]), now))
 	}
 
-	// Метрики времени отклика
+	// Распределение времени отклика по сервису, методу и статусу
+	summaryEnabled := r.metrics.hasFamily(durationSummaryName)
+	for _, log := range logs {
+		labels := map[string]string{
+			"app":     appName,
+			"service": log.Service,
+			"method":  log.Method,
+			"status":  fmt.Sprintf("%d", log.Status),
+		}
+		r.metrics.observe(durationHistogramName, labels, float64(log.Duration), log.Timestamp, log.TraceID)
+		if summaryEnabled {
+			r.metrics.observe(durationSummaryName, labels, float64(log.Duration), log.Timestamp, "")
+		}
+	}
+
+	// Доля ошибок последнего пакета
 	if totalRequests > 0 {
-		avgDuration := float64(totalDuration) / float64(totalRequests)
-		metrics = append(metrics, models.Metric{
-			Name:      "ecommerce_http_request_duration_ms",
-			Value:     avgDuration,
-			Type:      "gauge",
-			Labels:    map[string]string{"app": "ecommerce"},
-			Timestamp: now,
-		})
-	}
-
-	// Бизнес-метрики интернет-магазина
-	metrics = append(metrics, []models.Metric{
-		{
-			Name:      "ecommerce_orders_total",
-			Value:     float64(orderCount),
-			Type:      "counter",
-			Labels:    map[string]string{"app": "ecommerce"},
-			Timestamp: now,
-		},
-		{
-			Name:      "ecommerce_revenue_total",
-			Value:     totalRevenue,
-			Type:      "counter",
-			Labels:    map[string]string{"currency": "RUB", "app": "ecommerce"},
-			Timestamp: now,
-		},
-		{
-			Name:      "ecommerce_payments_processed",
-			Value:     float64(paymentCount),
-			Type:      "counter",
-			Labels:    map[string]string{"app": "ecommerce"},
-			Timestamp: now,
-		},
-		{
-			Name:      "ecommerce_search_queries",
-			Value:     float64(searchCount),
-			Type:      "counter",
-			Labels:    map[string]string{"app": "ecommerce"},
-			Timestamp: now,
-		},
-		{
-			Name:      "ecommerce_cart_actions",
-			Value:     float64(cartActions),
-			Type:      "counter",
-			Labels:    map[string]string{"app": "ecommerce"},
-			Timestamp: now,
-		},
-		{
-			Name:      "ecommerce_auth_actions",
-			Value:     float64(authActions),
-			Type:      "counter",
-			Labels:    map[string]string{"app": "ecommerce"},
-			Timestamp: now,
-		},
-		{
-			Name:      "ecommerce_error_rate",
-			Value:     float64(errorCount) / float64(totalRequests),
-			Type:      "gauge",
-			Labels:    map[string]string{"app": "ecommerce"},
-			Timestamp: now,
-		},
-		{
-			Name:      "ecommerce_active_users",
-			Value:     float64(rand.Intn(1000) + 100), // Симуляция активных пользователей
-			Type:      "gauge",
-			Labels:    map[string]string{"app": "ecommerce"},
-			Timestamp: now,
-		},
-		{
-			Name:      "ecommerce_inventory_items_low_stock",
-			Value:     float64(rand.Intn(50) + 5), // Симуляция товаров с низким остатком
-			Type:      "gauge",
-			Labels:    map[string]string{"app": "ecommerce"},
-			Timestamp: now,
-		},
-	}...)
+		check(r.metrics.setGauge("ecommerce_error_rate", app, float64(errorCount)/float64(totalRequests), now))
+	}
+
+	// Бизнес-метрики интернет-магазина есть только у встроенной топологии
+	if r.topology == nil {
+		check(r.metrics.addCounter("ecommerce_orders_total", app, float64(orderCount), now))
+		check(r.metrics.addCounter("ecommerce_revenue_total",
+			map[string]string{"currency": "RUB", "app": appName}, totalRevenue, now))
+		check(r.metrics.addCounter("ecommerce_payments_processed", app, float64(paymentCount), now))
+		check(r.metrics.addCounter("ecommerce_search_queries", app, float64(searchCount), now))
+		check(r.metrics.addCounter("ecommerce_cart_actions", app, float64(cartActions), now))
+		check(r.metrics.addCounter("ecommerce_auth_actions", app, float64(authActions), now))
+		check(r.metrics.setGauge("ecommerce_active_users", app, float64(r.rnd.Intn(1000)+100), now))          // Симуляция активных пользователей
+		check(r.metrics.setGauge("ecommerce_inventory_items_low_stock", app, float64(r.rnd.Intn(50)+5), now)) // Симуляция товаров с низким остатком
+	}
+
+	// Последствия внедренных отказов: повторы вызовов и состояние circuit breaker
+	for _, edge := range sortedEdges(r.retries) {
+		check(r.metrics.addCounter("ecommerce_upstream_retries_total",
+			map[string]string{"app": appName, "service": edge.caller, "upstream": edge.callee},
+			float64(r.retries[edge]), now))
+	}
+	for _, edge := range sortedEdges(r.breakers) {
+		open := 0.0
+		if r.breakers[edge].open {
+			open = 1
+		}
+		check(r.metrics.setGauge("ecommerce_circuit_breaker_open",
+			map[string]string{"app": appName, "service": edge.caller, "upstream": edge.callee}, open, now))
+	}
+	for _, fault := range r.faultStatuses {
+		check(r.metrics.setGauge("app_fault_intensity",
+			map[string]string{"app": "simulator", "fault_id": fault.ID, "service": fault.Service, "type": fault.Type},
+			fault.Intensity, now))
+	}
 
 	// Добавляем собственные метрики приложения
 	// Эти счетчики кумулятивны за время работы процесса
-	now = time.Now()
-	metrics = append(metrics,
-		models.Metric{
-			Name:      "app_generated_logs_total",
-			Value:     float64(appGeneratedLogsTotal),
-			Type:      "counter",
-			Labels:    map[string]string{"app": "simulator"},
-			Timestamp: now,
-		},
-		models.Metric{
-			Name:      "app_generated_metrics_total",
-			Value:     float64(appGeneratedMetricsTotal),
-			Type:      "counter",
-			Labels:    map[string]string{"app": "simulator"},
-			Timestamp: now,
-		},
-	)
+	simulator := map[string]string{"app": "simulator"}
+	check(r.metrics.addCounter("app_generated_logs_total", simulator, float64(totalRequests), now))
+	check(r.metrics.addCounter("app_generated_metrics_total", simulator, float64(r.metrics.len()), now))
 }
 
 // Сценарии нагрузки
-func applyBlackFridayScenario(logEntry models.LogEntry) models.LogEntry {
+func applyBlackFridayScenario(rnd *rand.Rand, logEntry models.LogEntry) models.LogEntry {
 	// Увеличиваем вероятность ошибок и времени отклика
-	if rand.Float64() < 0.3 {
+	if rnd.Float64() < 0.3 {
 		logEntry.Level = "ERROR"
-		logEntry.Duration = int64(rand.Intn(5000) + 1000) // Высокое время отклика
+		logEntry.Duration = int64(rnd.Intn(5000) + 1000) // Высокое время отклика
 	}
 	return logEntry
 }
 
-func applyPaymentIssuesScenario(logEntry models.LogEntry) models.LogEntry {
-	if logEntry.Service == "payment-service" && rand.Float64() < 0.4 {
+func applyPaymentIssuesScenario(rnd *rand.Rand, logEntry models.LogEntry) models.LogEntry {
+	if logEntry.Service == "payment-service" && rnd.Float64() < 0.4 {
 		logEntry.Level = "ERROR"
 		logEntry.Error = "Payment gateway unavailable"
 	}
 	return logEntry
 }
 
-func applyHighLoadScenario(logEntry models.LogEntry) models.LogEntry {
+func applyHighLoadScenario(rnd *rand.Rand, logEntry models.LogEntry) models.LogEntry {
 	// Увеличиваем время отклика
 	logEntry.Duration = int64(float64(logEntry.Duration) * 1.5)
 	return logEntry
 }
 
-func applyNormalLoadScenario(logEntry models.LogEntry) models.LogEntry {
+func applyNormalLoadScenario(rnd *rand.Rand, logEntry models.LogEntry) models.LogEntry {
 	// Нормальная работа
 	return logEntry
 }
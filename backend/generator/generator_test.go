@@ -0,0 +1,92 @@
+package generator
+
+import (
+	"bytes"
+	"encoding/json"
+	"log"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"log-metrics-simulator/models"
+)
+
+// seededRun выполняет запуск с seed на чистом реестре метрик и возвращает
+// логи в JSON и снимок метрик
+func seededRun(t *testing.T, seed int64, traces bool) ([]byte, string) {
+	t.Helper()
+	saved := metricRegistry
+	metricRegistry = saved.cloneDefinitions()
+	t.Cleanup(func() { metricRegistry = saved })
+
+	logs := GenerateLogsWithOptions(200, "black_friday", Options{Seed: &seed, Traces: traces, Workers: 4})
+	data, err := json.Marshal(logs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data, GetMetricsPrometheus()
+}
+
+func TestSeededRunIsReproducible(t *testing.T) {
+	for _, traces := range []bool{false, true} {
+		firstLogs, firstMetrics := seededRun(t, 42, traces)
+		secondLogs, secondMetrics := seededRun(t, 42, traces)
+
+		if !bytes.Equal(firstLogs, secondLogs) {
+			t.Fatalf("traces=%v: логи запусков с одним seed различаются", traces)
+		}
+		if firstMetrics != secondMetrics {
+			t.Fatalf("traces=%v: метрики запусков с одним seed различаются:\n%s\n---\n%s", traces, firstMetrics, secondMetrics)
+		}
+
+		otherLogs, _ := seededRun(t, 43, traces)
+		if bytes.Equal(firstLogs, otherLogs) {
+			t.Fatalf("traces=%v: разные seed дали одинаковые логи", traces)
+		}
+	}
+}
+
+func TestSeededRunStartsAtSeedEpoch(t *testing.T) {
+	saved := metricRegistry
+	metricRegistry = saved.cloneDefinitions()
+	defer func() { metricRegistry = saved }()
+
+	seed := int64(7)
+	first := GenerateLogsWithOptions(50, "normal_load", Options{Seed: &seed})
+	second := GenerateLogsWithOptions(50, "normal_load", Options{Seed: &seed})
+	if !reflect.DeepEqual(first, second) {
+		t.Fatal("запуски с одним seed дали разные логи")
+	}
+	for _, entry := range first {
+		if entry.Timestamp.After(SeedEpoch) || entry.Timestamp.Before(SeedEpoch.Add(-time.Hour)) {
+			t.Fatalf("метка времени %v вне часа до SeedEpoch", entry.Timestamp)
+		}
+	}
+}
+
+func TestEcommerceMetricErrorsLogged(t *testing.T) {
+	r := useRegistry(t)
+	// Имя счетчика занято gauge: его обновление не удается
+	r.setGauge("ecommerce_http_requests_total", nil, 1, testTime)
+
+	var output bytes.Buffer
+	log.SetOutput(&output)
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+
+	seed := int64(1)
+	logs := []models.LogEntry{
+		{Service: "cart-service", Level: "INFO", Status: 200},
+		{Service: "cart-service", Level: "ERROR", Status: 500},
+	}
+	updateEcommerceMetrics(newRun(Options{Seed: &seed}), logs)
+
+	if !strings.Contains(output.String(), "Ошибка обновления метрики: метрика ecommerce_http_requests_total") {
+		t.Errorf("ошибка обновления не записана в лог: %q", output.String())
+	}
+	// Остальные метрики обновлены; доля ошибок - от 0 до 1, а не в процентах
+	if v := value(t, r, "ecommerce_error_rate", map[string]string{"app": "ecommerce"}); v != 0.5 {
+		t.Errorf("ecommerce_error_rate = %v, ожидалось 0.5", v)
+	}
+}
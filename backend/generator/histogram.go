@@ -0,0 +1,94 @@
+package generator
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+// Имена метрик распределения времени отклика
+const (
+	durationHistogramName = "ecommerce_http_request_duration_ms"
+	durationSummaryName   = "ecommerce_http_request_duration_summary_ms"
+)
+
+// DefaultDurationBuckets - границы корзин гистограммы времени отклика по умолчанию (мс)
+var DefaultDurationBuckets = []float64{5, 10, 25, 50, 100, 250, 500, 1000, 2500, 5000, 10000}
+
+// MetricsConfig задает параметры метрик распределения времени отклика
+type MetricsConfig struct {
+	// DurationBuckets - границы корзин гистограммы в миллисекундах
+	DurationBuckets []float64
+	// SummaryQuantiles - квантили summary; пустой список отключает summary
+	SummaryQuantiles []float64
+}
+
+func init() {
+	metricRegistry.defineHistogram(durationHistogramName, DefaultDurationBuckets)
+}
+
+// ParseMetricsConfig разбирает конфигурацию из строк вида "5,10,25" и "0.5,0.9,0.99".
+// Пустая строка корзин означает границы по умолчанию.
+func ParseMetricsConfig(buckets, quantiles string) (MetricsConfig, error) {
+	cfg := MetricsConfig{DurationBuckets: DefaultDurationBuckets}
+
+	if buckets != "" {
+		parsed, err := parseBounds(buckets)
+		if err != nil {
+			return cfg, fmt.Errorf("границы корзин гистограммы: %v", err)
+		}
+		cfg.DurationBuckets = parsed
+	}
+
+	if quantiles != "" {
+		parsed, err := parseBounds(quantiles)
+		if err != nil {
+			return cfg, fmt.Errorf("квантили summary: %v", err)
+		}
+		cfg.SummaryQuantiles = parsed
+	}
+
+	return cfg, nil
+}
+
+// ConfigureMetrics применяет конфигурацию метрик распределения.
+// Накопленные значения гистограммы и summary сбрасываются.
+func ConfigureMetrics(cfg MetricsConfig) error {
+	buckets := make([]float64, 0, len(cfg.DurationBuckets))
+	for _, b := range cfg.DurationBuckets {
+		if math.IsNaN(b) {
+			return fmt.Errorf("граница корзины не может быть NaN")
+		}
+		// +Inf добавляется автоматически
+		if math.IsInf(b, 1) {
+			continue
+		}
+		buckets = append(buckets, b)
+	}
+	if len(buckets) == 0 {
+		return fmt.Errorf("нужна хотя бы одна граница корзины")
+	}
+	if !sort.Float64sAreSorted(buckets) {
+		return fmt.Errorf("границы корзин должны идти по возрастанию")
+	}
+	for i := 1; i < len(buckets); i++ {
+		if buckets[i] == buckets[i-1] {
+			return fmt.Errorf("повторяющаяся граница корзины: %v", buckets[i])
+		}
+	}
+
+	for _, q := range cfg.SummaryQuantiles {
+		if math.IsNaN(q) || q <= 0 || q > 1 {
+			return fmt.Errorf("квантиль должен быть в диапазоне (0, 1]: %v", q)
+		}
+	}
+
+	if err := metricRegistry.defineHistogram(durationHistogramName, buckets); err != nil {
+		return err
+	}
+	if len(cfg.SummaryQuantiles) > 0 {
+		return metricRegistry.defineSummary(durationSummaryName, cfg.SummaryQuantiles)
+	}
+	metricRegistry.removeFamily(durationSummaryName)
+	return nil
+}
@@ -0,0 +1,177 @@
+package generator
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestHistogramBuckets(t *testing.T) {
+	r := newRegistry()
+	if err := r.defineHistogram("latency_ms", []float64{10, 50, 100}); err != nil {
+		t.Fatal(err)
+	}
+	labels := map[string]string{"service": "cart"}
+	// Граница корзины входит в нее (le - меньше или равно)
+	for _, v := range []float64{1, 10, 11, 50, 99, 100, 101, 5000} {
+		r.observe("latency_ms", labels, v, testTime, "")
+	}
+
+	got := make(map[string]float64)
+	for _, m := range r.snapshot() {
+		key := m.Name
+		if le, ok := m.Labels["le"]; ok {
+			key += "/" + le
+		}
+		got[key] = m.Value
+	}
+	want := map[string]float64{
+		"latency_ms_bucket/10":   2,
+		"latency_ms_bucket/50":   4,
+		"latency_ms_bucket/100":  6,
+		"latency_ms_bucket/+Inf": 8,
+		"latency_ms_sum":         5372,
+		"latency_ms_count":       8,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("выборки гистограммы %v, ожидались %v", got, want)
+	}
+}
+
+func TestObserveIgnoresUndefinedFamily(t *testing.T) {
+	r := newRegistry()
+	r.observe("latency_ms", nil, 1, testTime, "")
+	if r.len() != 0 {
+		t.Fatal("наблюдение без объявленной гистограммы создало серию")
+	}
+
+	r.addCounter("requests_total", nil, 1, testTime)
+	r.observe("requests_total", nil, 100, testTime, "")
+	if got := value(t, r, "requests_total", nil); got != 1 {
+		t.Fatalf("наблюдение изменило счетчик: %v", got)
+	}
+}
+
+func TestDefineHistogramResetsSeries(t *testing.T) {
+	r := newRegistry()
+	r.defineHistogram("latency_ms", []float64{10})
+	r.observe("latency_ms", nil, 5, testTime, "")
+	r.defineHistogram("latency_ms", []float64{10, 20})
+	if r.len() != 0 {
+		t.Fatal("серии со старыми границами не сброшены")
+	}
+}
+
+func TestQuantile(t *testing.T) {
+	sorted := []float64{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}
+	tests := []struct {
+		q    float64
+		want float64
+	}{
+		{0.01, 1},
+		{0.1, 1},
+		{0.5, 5},
+		{0.9, 9},
+		{0.95, 10},
+		{0.99, 10},
+		{1, 10},
+	}
+	for _, tt := range tests {
+		if got := quantile(sorted, tt.q); got != tt.want {
+			t.Errorf("quantile(%v) = %v, ожидалось %v", tt.q, got, tt.want)
+		}
+	}
+	if got := quantile(nil, 0.5); !math.IsNaN(got) {
+		t.Errorf("квантиль пустой выборки %v, ожидался NaN", got)
+	}
+}
+
+func TestSummaryWindow(t *testing.T) {
+	r := newRegistry()
+	if err := r.defineSummary("latency_summary_ms", []float64{0.5, 0.99}); err != nil {
+		t.Fatal(err)
+	}
+	// Первые наблюдения вытесняются из окна, но остаются в _sum и _count
+	for i := 0; i < summaryWindowSize; i++ {
+		r.observe("latency_summary_ms", nil, 1000, testTime, "")
+	}
+	for i := 1; i <= summaryWindowSize; i++ {
+		r.observe("latency_summary_ms", nil, float64(i), testTime, "")
+	}
+
+	got := make(map[string]float64)
+	for _, m := range r.snapshot() {
+		got[m.Name+"/"+m.Labels["quantile"]] = m.Value
+	}
+	want := map[string]float64{
+		"latency_summary_ms/0.5":    512,
+		"latency_summary_ms/0.99":   1014,
+		"latency_summary_ms_sum/":   1000*summaryWindowSize + summaryWindowSize*(summaryWindowSize+1)/2,
+		"latency_summary_ms_count/": 2 * summaryWindowSize,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("выборки summary %v, ожидались %v", got, want)
+	}
+}
+
+func TestParseMetricsConfig(t *testing.T) {
+	cfg, err := ParseMetricsConfig("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(cfg.DurationBuckets, DefaultDurationBuckets) || cfg.SummaryQuantiles != nil {
+		t.Fatalf("конфигурация по умолчанию %+v", cfg)
+	}
+
+	cfg, err = ParseMetricsConfig(" 5, 10,25 ", "0.5,0.99")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(cfg.DurationBuckets, []float64{5, 10, 25}) || !reflect.DeepEqual(cfg.SummaryQuantiles, []float64{0.5, 0.99}) {
+		t.Fatalf("разобрана конфигурация %+v", cfg)
+	}
+
+	if _, err := ParseMetricsConfig("5,abc", ""); err == nil {
+		t.Fatal("ожидалась ошибка разбора границ")
+	}
+}
+
+func TestConfigureMetricsValidation(t *testing.T) {
+	saved := metricRegistry
+	metricRegistry = newRegistry()
+	defer func() { metricRegistry = saved }()
+
+	tests := []struct {
+		name    string
+		cfg     MetricsConfig
+		wantErr bool
+	}{
+		{"по умолчанию", MetricsConfig{DurationBuckets: DefaultDurationBuckets}, false},
+		{"+Inf отбрасывается", MetricsConfig{DurationBuckets: []float64{1, math.Inf(1)}}, false},
+		{"с квантилями", MetricsConfig{DurationBuckets: []float64{1}, SummaryQuantiles: []float64{0.5, 1}}, false},
+		{"пусто", MetricsConfig{}, true},
+		{"только +Inf", MetricsConfig{DurationBuckets: []float64{math.Inf(1)}}, true},
+		{"NaN", MetricsConfig{DurationBuckets: []float64{math.NaN()}}, true},
+		{"не по возрастанию", MetricsConfig{DurationBuckets: []float64{10, 5}}, true},
+		{"повтор", MetricsConfig{DurationBuckets: []float64{5, 5}}, true},
+		{"квантиль 0", MetricsConfig{DurationBuckets: []float64{1}, SummaryQuantiles: []float64{0}}, true},
+		{"квантиль больше 1", MetricsConfig{DurationBuckets: []float64{1}, SummaryQuantiles: []float64{1.5}}, true},
+	}
+	for _, tt := range tests {
+		err := ConfigureMetrics(tt.cfg)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: ошибка %v, ожидалась ошибка: %v", tt.name, err, tt.wantErr)
+		}
+	}
+
+	// Конфигурация без квантилей удаляет summary
+	if err := ConfigureMetrics(MetricsConfig{DurationBuckets: []float64{1}, SummaryQuantiles: []float64{0.5}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := ConfigureMetrics(MetricsConfig{DurationBuckets: []float64{1}}); err != nil {
+		t.Fatal(err)
+	}
+	if metricRegistry.hasFamily(durationSummaryName) {
+		t.Fatal("summary не удален")
+	}
+}
@@ -0,0 +1,191 @@
+package generator
+
+import (
+	"log"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"log-metrics-simulator/models"
+	"log-metrics-simulator/storage"
+)
+
+// Как часто реестр метрик очищается и сохраняется в хранилище
+const metricSaveInterval = 15 * time.Second
+
+var (
+	// Хранилище состояния реестра метрик; nil - метрики живут только в памяти без ограничений
+	metricStore      storage.MetricStore
+	metricStoreMutex sync.Mutex
+	metricStoreStop  chan struct{}
+	metricStoreDone  chan struct{}
+)
+
+// SetMetricStore подключает хранилище метрик: восстанавливает из него
+// реестр и раз в metricSaveInterval удаляет серии сверх емкости и с
+// истекшим сроком хранения, после чего сохраняет снимок. Гистограммы и
+// summary должны быть уже настроены через ConfigureMetrics.
+func SetMetricStore(store storage.MetricStore) error {
+	StopMetricStore()
+
+	families, err := store.Load()
+	if err != nil {
+		return err
+	}
+	restored := metricRegistry.restore(families)
+	capacity, retention := store.Limits()
+	metricRegistry.prune(time.Now(), capacity, retention)
+	if restored > 0 {
+		log.Printf("📈 Восстановлено серий метрик: %d", restored)
+	}
+
+	metricStoreMutex.Lock()
+	defer metricStoreMutex.Unlock()
+	metricStore = store
+	metricStoreStop = make(chan struct{})
+	metricStoreDone = make(chan struct{})
+	go saveMetrics(store, metricStoreStop, metricStoreDone)
+	return nil
+}
+
+// StopMetricStore останавливает периодическое сохранение и сохраняет
+// последний снимок метрик. Хранилище не закрывается.
+func StopMetricStore() error {
+	metricStoreMutex.Lock()
+	defer metricStoreMutex.Unlock()
+
+	if metricStore == nil {
+		return nil
+	}
+	close(metricStoreStop)
+	<-metricStoreDone
+	err := persistMetrics(metricStore)
+	metricStore = nil
+	return err
+}
+
+// saveMetrics сохраняет снимок метрик, пока не закрыт stop
+func saveMetrics(store storage.MetricStore, stop, done chan struct{}) {
+	defer close(done)
+	ticker := time.NewTicker(metricSaveInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+		if err := persistMetrics(store); err != nil {
+			log.Printf("❌ Ошибка сохранения метрик: %v", err)
+		}
+	}
+}
+
+// persistMetrics очищает реестр по ограничениям хранилища и сохраняет снимок
+func persistMetrics(store storage.MetricStore) error {
+	capacity, retention := store.Limits()
+	metricRegistry.prune(time.Now(), capacity, retention)
+	return store.Save(metricRegistry.exportFamilies())
+}
+
+// restore загружает сохраненные серии в реестр и возвращает их число.
+// Счетчики и gauge восстанавливаются полностью. Гистограммы - только если
+// границы корзин совпадают с текущими, summary - только сумма и количество:
+// окно наблюдений для квантилей и exemplars не сохраняются, поэтому до
+// новых наблюдений квантили восстановленной summary равны NaN. Семейства
+// гистограмм и summary, не объявленные в реестре, и семейства с другим
+// типом пропускаются. Срок хранения восстановленной серии отсчитывается
+// от момента восстановления.
+func (r *registry) restore(families []models.MetricFamily) int {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	now := time.Now()
+	restored := 0
+	for _, mf := range families {
+		f, ok := r.families[mf.Name]
+		switch mf.Type {
+		case metricTypeCounter, metricTypeGauge:
+			var err error
+			if f, err = r.getFamily(mf.Name, mf.Type); err != nil {
+				continue
+			}
+		case metricTypeHistogram, metricTypeSummary:
+			if !ok || f.typ != mf.Type {
+				continue
+			}
+		default:
+			continue
+		}
+		if _, ok := r.help[mf.Name]; !ok && mf.Help != "" && mf.Help != defaultMetricHelp {
+			r.help[mf.Name] = mf.Help
+		}
+
+		for _, ms := range mf.Series {
+			if f.typ == metricTypeHistogram &&
+				(!slices.Equal(ms.Bounds, f.buckets) || len(ms.BucketCounts) != len(f.buckets)+1) {
+				continue
+			}
+			s := f.getSeries(ms.Labels, ms.Created)
+			s.created = ms.Created
+			s.timestamp = ms.Timestamp
+			s.updated = now
+			switch f.typ {
+			case metricTypeCounter, metricTypeGauge:
+				s.value = ms.Value
+			case metricTypeHistogram:
+				copy(s.bucketCounts, ms.BucketCounts)
+				s.sum, s.count = ms.Sum, ms.Count
+			case metricTypeSummary:
+				s.sum, s.count = ms.Sum, ms.Count
+			}
+			restored++
+		}
+	}
+	return restored
+}
+
+// prune удаляет серии, не обновлявшиеся дольше retention, а затем самые
+// давно обновленные сверх capacity; возвращает число удаленных. Опустевшие
+// счетчики и gauge удаляются вместе с семейством, объявления гистограмм и
+// summary остаются.
+func (r *registry) prune(now time.Time, capacity int, retention time.Duration) int {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	type ref struct {
+		family *family
+		key    string
+		at     time.Time
+	}
+	cutoff := now.Add(-retention)
+	removed := 0
+	var live []ref
+	for _, f := range r.families {
+		for key, s := range f.series {
+			if retention > 0 && s.updated.Before(cutoff) {
+				delete(f.series, key)
+				removed++
+				continue
+			}
+			live = append(live, ref{family: f, key: key, at: s.updated})
+		}
+	}
+
+	if capacity > 0 && len(live) > capacity {
+		sort.Slice(live, func(i, j int) bool { return live[i].at.Before(live[j].at) })
+		for _, stale := range live[:len(live)-capacity] {
+			delete(stale.family.series, stale.key)
+			removed++
+		}
+	}
+
+	for name, f := range r.families {
+		if len(f.series) == 0 && (f.typ == metricTypeCounter || f.typ == metricTypeGauge) {
+			delete(r.families, name)
+		}
+	}
+	return removed
+}
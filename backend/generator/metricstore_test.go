@@ -0,0 +1,168 @@
+package generator
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"log-metrics-simulator/storage"
+)
+
+func TestMetricStoreRestoresAfterRestart(t *testing.T) {
+	useRegistry(t)
+	if err := metricRegistry.defineHistogram("latency_ms", []float64{10, 100}); err != nil {
+		t.Fatal(err)
+	}
+	if err := metricRegistry.defineSummary("latency_summary_ms", []float64{0.5}); err != nil {
+		t.Fatal(err)
+	}
+	labels := map[string]string{"service": "cart"}
+	metricRegistry.describe("requests_total", "Total requests")
+	if err := metricRegistry.addCounter("requests_total", labels, 5, testTime); err != nil {
+		t.Fatal(err)
+	}
+	if err := metricRegistry.setGauge("active_users", labels, 7, testTime); err != nil {
+		t.Fatal(err)
+	}
+	for _, v := range []float64{5, 50, 500} {
+		metricRegistry.observe("latency_ms", labels, v, testTime, "")
+		metricRegistry.observe("latency_summary_ms", labels, v, testTime, "")
+	}
+
+	path := filepath.Join(t.TempDir(), "metrics.db")
+	store, err := storage.NewBoltMetricStore(path, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := SetMetricStore(store); err != nil {
+		t.Fatal(err)
+	}
+	if err := StopMetricStore(); err != nil {
+		t.Fatal(err)
+	}
+	store.Close()
+	want := GetMetricsPrometheus()
+
+	// После перезапуска реестр содержит только объявления из ConfigureMetrics
+	restarted := metricRegistry.cloneDefinitions()
+	restarted.help = make(map[string]string)
+	metricRegistry = restarted
+	store, err = storage.NewBoltMetricStore(path, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	if err := SetMetricStore(store); err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := StopMetricStore(); err != nil {
+			t.Errorf("сохранение при остановке: %v", err)
+		}
+	}()
+
+	// Окно наблюдений summary не сохраняется: до новых наблюдений квантили
+	// равны NaN, остальные выборки совпадают
+	got := GetMetricsPrometheus()
+	if !strings.Contains(got, `latency_summary_ms{quantile="0.5",service="cart"} NaN`) {
+		t.Errorf("квантиль восстановленной summary не NaN:\n%s", got)
+	}
+	if got, want := withoutQuantiles(got), withoutQuantiles(want); got != want {
+		t.Fatalf("после перезапуска /metrics:\n%s\nожидалось:\n%s", got, want)
+	}
+
+	// Снимок с пустым окном summary сохраняется
+	if err := persistMetrics(store); err != nil {
+		t.Fatalf("сохранение после восстановления: %v", err)
+	}
+
+	if err := metricRegistry.addCounter("requests_total", labels, 1, testTime); err != nil {
+		t.Fatal(err)
+	}
+	if got := value(t, metricRegistry, "requests_total", labels); got != 6 {
+		t.Errorf("счетчик после перезапуска %v, ожидалось 6", got)
+	}
+}
+
+func TestRestoreSkipsIncompatibleFamilies(t *testing.T) {
+	source := newRegistry()
+	if err := source.defineHistogram("latency_ms", []float64{10, 100}); err != nil {
+		t.Fatal(err)
+	}
+	source.observe("latency_ms", map[string]string{"service": "cart"}, 5, testTime, "")
+	if err := source.addCounter("events", nil, 1, testTime); err != nil {
+		t.Fatal(err)
+	}
+
+	r := newRegistry()
+	// Границы корзин изменились, а events теперь gauge
+	if err := r.defineHistogram("latency_ms", []float64{10, 50, 100}); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.setGauge("events", nil, 3, testTime); err != nil {
+		t.Fatal(err)
+	}
+	if restored := r.restore(source.exportFamilies()); restored != 0 {
+		t.Fatalf("восстановлено %d серий, ожидалось 0", restored)
+	}
+	if got := value(t, r, "events", nil); got != 3 {
+		t.Errorf("gauge %v, ожидалось 3", got)
+	}
+	if len(r.families["latency_ms"].series) != 0 {
+		t.Error("восстановлена гистограмма с другими границами корзин")
+	}
+}
+
+func TestPruneRetentionAndCapacity(t *testing.T) {
+	r := newRegistry()
+	if err := r.defineHistogram("latency_ms", []float64{10}); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"a", "b", "c", "d"} {
+		if err := r.addCounter("requests_total", map[string]string{"service": name}, 1, testTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := r.setGauge("stale", nil, 1, testTime); err != nil {
+		t.Fatal(err)
+	}
+	r.observe("latency_ms", nil, 5, testTime, "")
+
+	now := time.Now()
+	setUpdated := func(name string, labels map[string]string, at time.Time) {
+		r.families[name].series[seriesKey(name, labels)].updated = at
+	}
+	setUpdated("stale", nil, now.Add(-2*time.Hour))
+	setUpdated("latency_ms", nil, now.Add(-2*time.Hour))
+	for i, name := range []string{"a", "b", "c", "d"} {
+		setUpdated("requests_total", map[string]string{"service": name}, now.Add(time.Duration(i-4)*time.Minute))
+	}
+
+	if removed := r.prune(now, 2, time.Hour); removed != 4 {
+		t.Fatalf("удалено %d серий, ожидалось 4", removed)
+	}
+	if r.hasFamily("stale") {
+		t.Error("опустевший gauge не удален")
+	}
+	if !r.hasFamily("latency_ms") {
+		t.Error("удалено объявление гистограммы")
+	}
+	for _, name := range []string{"c", "d"} {
+		value(t, r, "requests_total", map[string]string{"service": name})
+	}
+	if r.len() != 2 {
+		t.Errorf("осталось %d серий, ожидалось 2", r.len())
+	}
+}
+
+// withoutQuantiles убирает из вывода /metrics строки квантилей summary
+func withoutQuantiles(exposition string) string {
+	var lines []string
+	for _, line := range strings.Split(exposition, "\n") {
+		if !strings.Contains(line, "quantile=") {
+			lines = append(lines, line)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,73 @@
+package generator
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// OpenMetricsContentType - Content-Type ответа в формате OpenMetrics
+const OpenMetricsContentType = "application/openmetrics-text; version=1.0.0; charset=utf-8"
+
+// GetMetricsOpenMetrics возвращает метрики в формате OpenMetrics 1.0:
+// с сериями _created, exemplar'ами корзин гистограмм и завершающим # EOF
+func GetMetricsOpenMetrics() string {
+	var b strings.Builder
+
+	for _, family := range metricRegistry.snapshotFamilies() {
+		// Имя семейства счетчика в OpenMetrics не содержит суффикс _total
+		name := family.name
+		if family.typ == metricTypeCounter {
+			name = strings.TrimSuffix(name, "_total")
+		}
+
+		b.WriteString("# TYPE " + name + " " + family.typ + "\n")
+		// В OpenMetrics HELP экранируется так же, как значение метки
+		b.WriteString("# HELP " + name + " " + escapeLabelValue(family.help) + "\n")
+
+		for _, s := range family.series {
+			for _, smp := range s.samples {
+				sampleName := smp.metric.Name
+				if family.typ == metricTypeCounter {
+					sampleName = name + "_total"
+				}
+				writeOpenMetricsSample(&b, sampleName, smp.metric.Labels, smp.metric.Value)
+				if smp.exemplar != nil {
+					writeExemplar(&b, smp.exemplar)
+				}
+				b.WriteByte('\n')
+			}
+
+			if family.typ != metricTypeGauge && !s.created.IsZero() {
+				b.WriteString(name + "_created")
+				writeLabels(&b, s.labels)
+				b.WriteString(" " + formatTimestamp(s.created) + "\n")
+			}
+		}
+	}
+
+	b.WriteString("# EOF\n")
+	return b.String()
+}
+
+func writeOpenMetricsSample(b *strings.Builder, name string, labels map[string]string, value float64) {
+	b.WriteString(name)
+	writeLabels(b, labels)
+	b.WriteByte(' ')
+	b.WriteString(formatFloat(value))
+}
+
+// writeExemplar дописывает exemplar к выборке корзины: # {trace_id="..."} value timestamp
+func writeExemplar(b *strings.Builder, ex *exemplar) {
+	b.WriteString(" # ")
+	writeLabels(b, map[string]string{"trace_id": ex.traceID})
+	b.WriteByte(' ')
+	b.WriteString(formatFloat(ex.value))
+	b.WriteByte(' ')
+	b.WriteString(formatTimestamp(ex.timestamp))
+}
+
+// formatTimestamp форматирует время как секунды Unix с миллисекундами
+func formatTimestamp(t time.Time) string {
+	return strconv.FormatFloat(float64(t.UnixMilli())/1000, 'f', 3, 64)
+}
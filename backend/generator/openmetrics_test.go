@@ -0,0 +1,103 @@
+package generator
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// useRegistry подменяет глобальный реестр метрик пустым на время теста
+func useRegistry(t *testing.T) *registry {
+	t.Helper()
+	saved := metricRegistry
+	metricRegistry = newRegistry()
+	t.Cleanup(func() { metricRegistry = saved })
+	return metricRegistry
+}
+
+// fillExpositionRegistry заполняет реестр счетчиком с метками, требующими
+// экранирования, gauge и гистограммой с exemplar'ами
+func fillExpositionRegistry(t *testing.T, r *registry) {
+	t.Helper()
+	r.describe("http_requests_total", "Total requests")
+	r.describe("latency_ms", "Latency\nin ms")
+
+	if err := r.addCounter("http_requests_total", map[string]string{"service": "cart", "path": "a\"b\\c\nd"}, 3, testTime); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.addCounter("http_requests_total", map[string]string{"service": "auth"}, 1, testTime); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.setGauge("active_users", nil, 2.5, testTime); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.defineHistogram("latency_ms", []float64{10, 100}); err != nil {
+		t.Fatal(err)
+	}
+	labels := map[string]string{"service": "cart"}
+	r.observe("latency_ms", labels, 5, testTime, "abc123")
+	r.observe("latency_ms", labels, 50, testTime, "")
+	r.observe("latency_ms", labels, 500, testTime.Add(time.Second), "def456")
+}
+
+func TestGetMetricsOpenMetrics(t *testing.T) {
+	fillExpositionRegistry(t, useRegistry(t))
+
+	want := `# TYPE active_users gauge
+# HELP active_users Application metric
+active_users 2.5
+# TYPE http_requests counter
+# HELP http_requests Total requests
+http_requests_total{path="a\"b\\c\nd",service="cart"} 3
+http_requests_created{path="a\"b\\c\nd",service="cart"} 1709294400.000
+http_requests_total{service="auth"} 1
+http_requests_created{service="auth"} 1709294400.000
+# TYPE latency_ms histogram
+# HELP latency_ms Latency\nin ms
+latency_ms_bucket{le="10",service="cart"} 1 # {trace_id="abc123"} 5 1709294400.000
+latency_ms_bucket{le="100",service="cart"} 2
+latency_ms_bucket{le="+Inf",service="cart"} 3 # {trace_id="def456"} 500 1709294401.000
+latency_ms_sum{service="cart"} 555
+latency_ms_count{service="cart"} 3
+latency_ms_created{service="cart"} 1709294400.000
+# EOF
+`
+	if got := GetMetricsOpenMetrics(); got != want {
+		t.Fatalf("вывод OpenMetrics:\n%s\nожидался:\n%s", got, want)
+	}
+}
+
+func TestGetMetricsOpenMetricsEmpty(t *testing.T) {
+	useRegistry(t)
+	if got := GetMetricsOpenMetrics(); got != "# EOF\n" {
+		t.Fatalf("пустой реестр дал %q", got)
+	}
+}
+
+func TestOpenMetricsExemplarsFromGeneratedLogs(t *testing.T) {
+	r := useRegistry(t)
+	r.defineHistogram(durationHistogramName, DefaultDurationBuckets)
+
+	seed := int64(1)
+	logs := GenerateLogsWithOptions(100, "normal_load", Options{Seed: &seed})
+	traceIDs := make(map[string]bool, len(logs))
+	for _, entry := range logs {
+		traceIDs[entry.TraceID] = true
+	}
+
+	exemplars := 0
+	for _, line := range strings.Split(GetMetricsOpenMetrics(), "\n") {
+		_, ex, ok := strings.Cut(line, ` # {trace_id="`)
+		if !ok {
+			continue
+		}
+		traceID, _, _ := strings.Cut(ex, `"`)
+		if !traceIDs[traceID] {
+			t.Fatalf("exemplar ссылается на неизвестную трассировку %s", traceID)
+		}
+		exemplars++
+	}
+	if exemplars == 0 {
+		t.Fatal("в выводе нет exemplar'ов")
+	}
+}
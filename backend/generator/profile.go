@@ -0,0 +1,151 @@
+package generator
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"log-metrics-simulator/models"
+)
+
+// Пресеты поведения сервисов (см. applyServiceLog)
+var profilePresets = []string{"normal_load", "black_friday", "high_load", "payment_issues"}
+
+// Уровни логов и их веса по умолчанию
+var (
+	logLevels       = []string{"INFO", "WARN", "ERROR", "DEBUG"}
+	defaultLevelMix = []float64{0.65, 0.20, 0.10, 0.05}
+	errorLevelIndex = 2
+)
+
+// ParseProfile собирает профиль генерации из параметров сценария. Ключи, не
+// относящиеся к профилю, игнорируются.
+func ParseProfile(params map[string]interface{}) (*models.GenerationProfile, error) {
+	if len(params) == 0 {
+		return nil, nil
+	}
+
+	data, err := json.Marshal(params)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка разбора параметров: %v", err)
+	}
+	var profile models.GenerationProfile
+	if err := json.Unmarshal(data, &profile); err != nil {
+		return nil, fmt.Errorf("ошибка разбора параметров: %v", err)
+	}
+	if err := ValidateProfile(&profile); err != nil {
+		return nil, err
+	}
+	return &profile, nil
+}
+
+// ValidateProfile проверяет профиль и приводит уровни level_mix к верхнему регистру
+func ValidateProfile(profile *models.GenerationProfile) error {
+	if profile.Preset != "" && !containsString(profilePresets, profile.Preset) {
+		return fmt.Errorf("неизвестный preset: %s (%s)", profile.Preset, strings.Join(profilePresets, ", "))
+	}
+	if profile.ErrorRate != nil && (*profile.ErrorRate < 0 || *profile.ErrorRate > 1) {
+		return fmt.Errorf("error_rate должен быть от 0 до 1")
+	}
+	if profile.LatencyMultiplier < 0 || profile.ResponseDelay < 0 || profile.IntervalMs < 0 {
+		return fmt.Errorf("latency_multiplier, response_delay и interval_ms не могут быть отрицательными")
+	}
+
+	if len(profile.LevelMix) > 0 {
+		mix := make(map[string]float64, len(profile.LevelMix))
+		total := 0.0
+		for level, weight := range profile.LevelMix {
+			level = strings.ToUpper(level)
+			if !containsString(logLevels, level) {
+				return fmt.Errorf("неизвестный уровень в level_mix: %s", level)
+			}
+			if weight < 0 {
+				return fmt.Errorf("вес уровня %s не может быть отрицательным", level)
+			}
+			mix[level] = weight
+			total += weight
+		}
+		if total == 0 {
+			return fmt.Errorf("сумма весов level_mix должна быть положительной")
+		}
+		profile.LevelMix = mix
+	}
+	return nil
+}
+
+// levelThresholds возвращает накопленные вероятности уровней logLevels.
+// error_rate задает долю ERROR, остальные уровни делят остаток по своим весам.
+func levelThresholds(profile *models.GenerationProfile) []float64 {
+	if profile == nil || (len(profile.LevelMix) == 0 && profile.ErrorRate == nil) {
+		return []float64{0.65, 0.85, 0.95, 1}
+	}
+
+	weights := append([]float64(nil), defaultLevelMix...)
+	if len(profile.LevelMix) > 0 {
+		for i, level := range logLevels {
+			weights[i] = profile.LevelMix[level]
+		}
+	}
+
+	total := 0.0
+	for _, w := range weights {
+		total += w
+	}
+	for i := range weights {
+		weights[i] /= total
+	}
+
+	if profile.ErrorRate != nil {
+		rest := 1 - weights[errorLevelIndex]
+		for i := range weights {
+			switch {
+			case i == errorLevelIndex:
+				weights[i] = *profile.ErrorRate
+			case rest > 0:
+				weights[i] = weights[i] / rest * (1 - *profile.ErrorRate)
+			}
+		}
+	}
+
+	thresholds := make([]float64, len(weights))
+	sum := 0.0
+	for i, w := range weights {
+		sum += w
+		thresholds[i] = sum
+	}
+	return thresholds
+}
+
+// logLevel выбирает уровень лога по профилю запуска
+func (r *run) logLevel() string {
+	x := r.rnd.Float64()
+	for i, threshold := range r.levels {
+		if x < threshold {
+			return logLevels[i]
+		}
+	}
+	return logLevels[len(logLevels)-1]
+}
+
+// applyProfile применяет к записи множитель и добавочное время отклика профиля.
+// Для пользовательской топологии уровни задают режимы ошибок эндпоинтов,
+// поэтому error_rate профиля добавляет ошибки сверх них.
+func (r *run) applyProfile(entry models.LogEntry) models.LogEntry {
+	p := r.profile
+	if p == nil {
+		return entry
+	}
+
+	if r.topology != nil && p.ErrorRate != nil && entry.Level != "ERROR" && r.rnd.Float64() < *p.ErrorRate {
+		entry.Level = "ERROR"
+		entry.Status = 500
+		entry.Error = "Internal server error"
+		entry.Message = fmt.Sprintf("%s %s failed", entry.Method, entry.Path)
+	}
+
+	if p.LatencyMultiplier > 0 {
+		entry.Duration = int64(float64(entry.Duration) * p.LatencyMultiplier)
+	}
+	entry.Duration += p.ResponseDelay
+	return entry
+}
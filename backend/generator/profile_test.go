@@ -0,0 +1,144 @@
+package generator
+
+import (
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+
+	"log-metrics-simulator/models"
+)
+
+func TestParseProfile(t *testing.T) {
+	// Параметры сценария приходят из JSON API и YAML: числа как float64 и int,
+	// вложенные объекты как map[string]interface{}
+	profile, err := ParseProfile(map[string]interface{}{
+		"preset":             "black_friday",
+		"error_rate":         0.2,
+		"latency_multiplier": 1.5,
+		"response_delay":     100,
+		"interval_ms":        int64(500),
+		"level_mix":          map[string]interface{}{"info": 3, "Warn": 1.0},
+		"duration":           "10m", // Не относится к профилю
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	errorRate := 0.2
+	want := &models.GenerationProfile{
+		Preset:            "black_friday",
+		ErrorRate:         &errorRate,
+		LatencyMultiplier: 1.5,
+		ResponseDelay:     100,
+		IntervalMs:        500,
+		LevelMix:          map[string]float64{"INFO": 3, "WARN": 1},
+	}
+	if !reflect.DeepEqual(profile, want) {
+		t.Errorf("профиль %+v, ожидалось %+v", profile, want)
+	}
+
+	// Явный error_rate 0 отличается от отсутствующего
+	if profile, err := ParseProfile(map[string]interface{}{"error_rate": 0}); err != nil || profile.ErrorRate == nil || *profile.ErrorRate != 0 {
+		t.Errorf("error_rate 0: %+v, %v", profile, err)
+	}
+	if profile, err := ParseProfile(nil); profile != nil || err != nil {
+		t.Errorf("без параметров: %+v, %v", profile, err)
+	}
+	if profile, err := ParseProfile(map[string]interface{}{"duration": "10m"}); err != nil || !reflect.DeepEqual(profile, &models.GenerationProfile{}) {
+		t.Errorf("без ключей профиля: %+v, %v", profile, err)
+	}
+
+	for _, tc := range []struct {
+		params map[string]interface{}
+		want   string
+	}{
+		{map[string]interface{}{"error_rate": "high"}, "ошибка разбора параметров"},
+		{map[string]interface{}{"level_mix": []interface{}{"INFO"}}, "ошибка разбора параметров"},
+		{map[string]interface{}{"preset": "cyber_monday"}, "неизвестный preset: cyber_monday"},
+		{map[string]interface{}{"error_rate": 1.5}, "error_rate должен быть от 0 до 1"},
+		{map[string]interface{}{"response_delay": -1}, "не могут быть отрицательными"},
+		{map[string]interface{}{"level_mix": map[string]interface{}{"trace": 1}}, "неизвестный уровень в level_mix: TRACE"},
+		{map[string]interface{}{"level_mix": map[string]interface{}{"info": -1, "error": 2}}, "вес уровня INFO"},
+		{map[string]interface{}{"level_mix": map[string]interface{}{"info": 0}}, "должна быть положительной"},
+	} {
+		if _, err := ParseProfile(tc.params); err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("%v: ошибка %v, ожидалась с %q", tc.params, err, tc.want)
+		}
+	}
+}
+
+func TestLevelThresholds(t *testing.T) {
+	rate := func(v float64) *float64 { return &v }
+	for _, tc := range []struct {
+		name    string
+		profile *models.GenerationProfile
+		want    []float64
+	}{
+		{"без профиля", nil, []float64{0.65, 0.85, 0.95, 1}},
+		{"без уровней", &models.GenerationProfile{LatencyMultiplier: 2}, []float64{0.65, 0.85, 0.95, 1}},
+		{"level_mix", &models.GenerationProfile{LevelMix: map[string]float64{"INFO": 3, "ERROR": 1}}, []float64{0.75, 0.75, 1, 1}},
+		// Остальные уровни делят 1 - error_rate в пропорции весов по умолчанию
+		{"error_rate", &models.GenerationProfile{ErrorRate: rate(0.55)}, []float64{0.325, 0.425, 0.975, 1}},
+		{"level_mix и error_rate", &models.GenerationProfile{ErrorRate: rate(0.2), LevelMix: map[string]float64{"INFO": 1, "DEBUG": 1, "ERROR": 8}}, []float64{0.4, 0.4, 0.6, 1}},
+	} {
+		got := levelThresholds(tc.profile)
+		for i := range tc.want {
+			if len(got) != len(tc.want) || math.Abs(got[i]-tc.want[i]) > 1e-9 {
+				t.Errorf("%s: пороги %v, ожидалось %v", tc.name, got, tc.want)
+				break
+			}
+		}
+	}
+}
+
+func TestProfileLogLevels(t *testing.T) {
+	seed := int64(4)
+	errorRate := 0.3
+	r := newRun(Options{Seed: &seed, Profile: &models.GenerationProfile{
+		ErrorRate: &errorRate,
+		LevelMix:  map[string]float64{"INFO": 1, "WARN": 1},
+	}})
+
+	counts := make(map[string]int)
+	const n = 10000
+	for i := 0; i < n; i++ {
+		counts[r.logLevel()]++
+	}
+	want := map[string]float64{"INFO": 0.35, "WARN": 0.35, "ERROR": 0.3, "DEBUG": 0}
+	for level, share := range want {
+		if got := float64(counts[level]) / n; math.Abs(got-share) > 0.02 {
+			t.Errorf("доля %s %.3f, ожидалось %.2f", level, got, share)
+		}
+	}
+}
+
+func TestApplyProfile(t *testing.T) {
+	seed := int64(4)
+	entry := models.LogEntry{Level: "INFO", Method: "GET", Path: "/cart", Status: 200, Duration: 40}
+	r := newRun(Options{Seed: &seed, Profile: &models.GenerationProfile{LatencyMultiplier: 2.5, ResponseDelay: 15}})
+	if got := r.applyProfile(entry); got.Duration != 115 || got.Level != "INFO" {
+		t.Errorf("запись %+v, ожидалась длительность 40*2.5+15", got)
+	}
+	if got := newRun(Options{Seed: &seed}).applyProfile(entry); got != entry {
+		t.Errorf("без профиля запись изменена: %+v", got)
+	}
+
+	// Для пользовательской топологии error_rate добавляет ошибки сверх режимов эндпоинтов
+	useTopology(t, models.Topology{Services: []models.TopologyService{{Name: "cart"}}})
+	errorRate := 0.4
+	r = newRun(Options{Seed: &seed, Profile: &models.GenerationProfile{ErrorRate: &errorRate}})
+	failed := 0
+	const n = 5000
+	for i := 0; i < n; i++ {
+		got := r.applyProfile(entry)
+		if got.Level == "ERROR" {
+			failed++
+			if got.Status != 500 || got.Message != "GET /cart failed" {
+				t.Fatalf("запись с ошибкой %+v", got)
+			}
+		}
+	}
+	if share := float64(failed) / n; math.Abs(share-errorRate) > 0.02 {
+		t.Errorf("доля ошибок %.3f, ожидалось %.2f", share, errorRate)
+	}
+}
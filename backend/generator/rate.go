@@ -0,0 +1,391 @@
+package generator
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"sync"
+	"time"
+
+	"log-metrics-simulator/models"
+	"log-metrics-simulator/sinks"
+)
+
+// Ограничения генерации с заданной скоростью
+const (
+	rateTick        = 100 * time.Millisecond // Период выпуска пакетов
+	rateWindow      = time.Second            // Окно расчета достигнутой скорости
+	maxRateBacklog  = time.Second            // Отставание, после которого события пропускаются
+	maxEventsPerSec = 1000000
+	maxWorkers      = 64
+	minWorkerBatch  = 500 // Меньшие пакеты не делятся между генераторами
+	rateQueue       = 4   // Пакетов в очереди на выпуск; при переполнении события пропускаются
+)
+
+// eventInterval возвращает шаг меток времени запуска
+func eventInterval(opts Options) time.Duration {
+	switch {
+	case opts.Rate > 0:
+		return time.Duration(float64(time.Second) / opts.Rate)
+	case opts.Profile != nil && opts.Profile.IntervalMs > 0:
+		return time.Duration(opts.Profile.IntervalMs) * time.Millisecond
+	}
+	return 0
+}
+
+// timestamp возвращает время очередного запроса: при заданном шаге запросы идут
+// друг за другом от опорного времени, иначе разбросаны по последнему часу
+func (r *run) timestamp() time.Time {
+	if r.interval > 0 {
+		ts := r.now.Add(time.Duration(r.seq) * r.interval)
+		r.seq++
+		return ts
+	}
+	return r.now.Add(-time.Duration(r.rnd.Intn(3600)) * time.Second)
+}
+
+// workerRuns делит logCount логов между генераторами. Первый генератор совпадает
+// с однопоточным запуском, остальные получают seed со сдвигом на свой номер и
+// продолжают поток меток времени с первого своего события.
+func workerRuns(logCount int, opts Options) ([]*run, []int) {
+	workers := opts.Workers
+	if workers > logCount/minWorkerBatch {
+		workers = logCount / minWorkerBatch
+	}
+	if workers < 1 {
+		workers = 1
+	}
+
+	first := newRun(opts)
+	runs := []*run{first}
+	counts := make([]int, workers)
+	offset := 0
+	for i := range counts {
+		counts[i] = logCount / workers
+		if i < logCount%workers {
+			counts[i]++
+		}
+		if i > 0 {
+			workerOpts := opts
+			workerOpts.StartTime = first.now.Add(time.Duration(offset) * first.interval)
+			seed := time.Now().UnixNano() + int64(i)
+			if opts.Seed != nil {
+				seed = *opts.Seed + int64(i)
+			}
+			workerOpts.Seed = &seed
+			runs = append(runs, newRun(workerOpts))
+		}
+		offset += counts[i]
+	}
+	return runs, counts
+}
+
+// rateEngine выпускает события с заданной скоростью: каждые rateTick ставит в
+// очередь пакет из причитающихся событий, метки времени которых равномерно
+// распределены по прошедшему интервалу. Генерацию и отправку пакетов выполняет
+// отдельная горутина, поэтому медленный приемник не сбивает отсчет тиков.
+// Паузы между отдельными логами нет.
+type rateEngine struct {
+	request models.RateRequest
+	opts    Options
+	shape   *TrafficShape
+	queue   chan rateBatch
+	stop    chan struct{}
+	done    chan struct{}
+
+	mutex      sync.RWMutex
+	started    time.Time
+	stopped    *time.Time
+	last       time.Time // Момент предыдущего пакета
+	expected   float64   // Событий по форме нагрузки с момента запуска
+	target     float64   // Текущая заданная скорость
+	scheduled  int64     // События, время которых уже наступило: выпущенные и пропущенные
+	generated  int64
+	dropped    int64
+	batches    int64
+	achieved   float64
+	windowFrom time.Time
+	windowLogs int64
+}
+
+// rateBatch - пакет событий в очереди на выпуск
+type rateBatch struct {
+	opts  Options
+	count int
+}
+
+var (
+	activeRate *rateEngine
+	rateMutex  sync.Mutex
+)
+
+// StartRate запускает генерацию с заданной скоростью, останавливая предыдущую
+func StartRate(req models.RateRequest) (models.RateStatus, error) {
+	spec := models.TrafficShape{BaseRate: req.EventsPerSecond}
+	if req.Shape != nil {
+		spec = *req.Shape
+		if spec.BaseRate == 0 {
+			spec.BaseRate = req.EventsPerSecond
+		}
+	}
+	if spec.BaseRate <= 0 || spec.BaseRate > maxEventsPerSec {
+		return models.RateStatus{}, fmt.Errorf("events_per_second должен быть больше 0 и не больше %d", maxEventsPerSec)
+	}
+	if req.Workers < 0 || req.Workers > maxWorkers {
+		return models.RateStatus{}, fmt.Errorf("workers должен быть от 0 до %d (0 - по умолчанию)", maxWorkers)
+	}
+	if req.DurationSeconds < 0 {
+		return models.RateStatus{}, fmt.Errorf("duration_seconds не может быть отрицательным")
+	}
+	if req.Workers == 0 {
+		req.Workers = 1
+	}
+	profile, err := ParseProfile(req.Config)
+	if err != nil {
+		return models.RateStatus{}, err
+	}
+
+	now := time.Now()
+	seed := now.UnixNano()
+	if req.Seed != nil {
+		seed = *req.Seed
+	}
+	shape, err := NewTrafficShape(spec, now, seed)
+	if err != nil {
+		return models.RateStatus{}, err
+	}
+
+	// Приемники создаются последними: после ошибки проверки их некому закрыть
+	var out sinks.Sink
+	if len(req.Sinks) > 0 {
+		fanout, err := sinks.NewFanout(req.Sinks)
+		if err != nil {
+			return models.RateStatus{}, fmt.Errorf("ошибка создания приемников: %v", err)
+		}
+		out = fanout
+	}
+
+	e := &rateEngine{
+		request: req,
+		opts: Options{
+			Seed:    req.Seed,
+			Labels:  req.Labels,
+			Sink:    out,
+			Traces:  req.Traces,
+			Profile: profile,
+			Workers: req.Workers,
+		},
+		shape:      shape,
+		queue:      make(chan rateBatch, rateQueue),
+		stop:       make(chan struct{}),
+		done:       make(chan struct{}),
+		started:    now,
+		last:       now,
+		target:     shape.Rate(now),
+		windowFrom: now,
+	}
+	// Предыдущая генерация останавливается вне блокировки: пока она
+	// дописывает очередь, состояние уже отдает новую
+	rateMutex.Lock()
+	previous := activeRate
+	activeRate = e
+	rateMutex.Unlock()
+	if previous != nil {
+		previous.halt()
+	}
+	go e.loop()
+
+	log.Printf("🚀 Генерация со скоростью %.0f событий/с запущена (генераторов: %d, компонентов формы: %d)",
+		spec.BaseRate, req.Workers, len(spec.Components))
+	return e.status(), nil
+}
+
+// StopRate останавливает генерацию с заданной скоростью
+func StopRate() (models.RateStatus, error) {
+	rateMutex.Lock()
+	e := activeRate
+	rateMutex.Unlock()
+
+	if e == nil {
+		return models.RateStatus{}, fmt.Errorf("генерация с заданной скоростью не запущена")
+	}
+	// Очередь дописывается без блокировки, чтобы не задерживать GetRateStatus
+	e.halt()
+	return e.status(), nil
+}
+
+// GetRateStatus возвращает состояние последней генерации с заданной скоростью
+func GetRateStatus() models.RateStatus {
+	rateMutex.Lock()
+	defer rateMutex.Unlock()
+
+	if activeRate == nil {
+		return models.RateStatus{}
+	}
+	return activeRate.status()
+}
+
+// halt останавливает цикл и дожидается выпуска пакетов из очереди
+func (e *rateEngine) halt() {
+	select {
+	case <-e.stop:
+	default:
+		close(e.stop)
+	}
+	<-e.done
+}
+
+func (e *rateEngine) loop() {
+	defer close(e.done)
+	defer e.finish()
+
+	published := make(chan struct{})
+	go e.publisher(published)
+	defer func() {
+		close(e.queue)
+		<-published
+	}()
+
+	ticker := time.NewTicker(rateTick)
+	defer ticker.Stop()
+
+	var deadline time.Time
+	if e.request.DurationSeconds > 0 {
+		deadline = e.started.Add(time.Duration(e.request.DurationSeconds) * time.Second)
+	}
+
+	for {
+		select {
+		case <-e.stop:
+			return
+		case now := <-ticker.C:
+			if !deadline.IsZero() && now.After(deadline) {
+				e.tick(deadline)
+				return
+			}
+			e.tick(now)
+		}
+	}
+}
+
+// publisher генерирует и отправляет пакеты из очереди, пока она не закрыта
+func (e *rateEngine) publisher(done chan<- struct{}) {
+	defer close(done)
+	for batch := range e.queue {
+		publish(e.request.Scenario, batch.opts, generate(batch.count, e.request.Scenario, batch.opts))
+
+		e.mutex.Lock()
+		e.generated += int64(batch.count)
+		e.windowLogs += int64(batch.count)
+		e.mutex.Unlock()
+	}
+}
+
+// tick ставит в очередь события, время которых наступило к моменту now
+func (e *rateEngine) tick(now time.Time) {
+	from := e.last
+	expected := e.expected + e.shape.Events(from, now)
+	due := int64(expected) - e.scheduled
+
+	// Генерация не успевает: события старше maxRateBacklog пропускаются,
+	// чтобы отставание не копилось
+	var dropped int64
+	if backlog := now.Add(-maxRateBacklog); from.Before(backlog) {
+		if limit := int64(math.Ceil(e.shape.Events(backlog, now))); due > limit {
+			dropped = due - limit
+			due = limit
+		}
+		from = backlog
+	}
+
+	if due > 0 {
+		// Метки времени пакета равномерно распределены по его интервалу
+		opts := e.opts
+		opts.StartTime = from
+		opts.Rate = float64(due) / now.Sub(from).Seconds()
+		if opts.Seed != nil {
+			seed := *opts.Seed + e.batches*maxWorkers
+			opts.Seed = &seed
+		}
+		// Очередь заполнена: выпуск не успевает за тиками, пакет пропускается
+		select {
+		case e.queue <- rateBatch{opts: opts, count: int(due)}:
+		default:
+			dropped += due
+			due = 0
+		}
+	}
+	target := e.shape.Rate(now)
+
+	e.mutex.Lock()
+	e.last = now
+	e.expected = expected
+	e.target = target
+	e.scheduled += due + dropped
+	e.dropped += dropped
+	e.batches++
+	if elapsed := now.Sub(e.windowFrom); elapsed >= rateWindow {
+		e.achieved = float64(e.windowLogs) / elapsed.Seconds()
+		e.windowFrom = now
+		e.windowLogs = 0
+	}
+	achieved := e.achieved
+	e.mutex.Unlock()
+
+	if dropped > 0 {
+		log.Printf("⚠️ Генерация отстает от заданной скорости, пропущено событий: %d", dropped)
+	}
+	e.record(target, achieved, dropped, now)
+}
+
+// record обновляет собственные метрики генерации
+func (e *rateEngine) record(target, achieved float64, dropped int64, now time.Time) {
+	simulator := map[string]string{"app": "simulator"}
+	metricRegistry.setGauge("app_generation_target_rate", simulator, target, now)
+	metricRegistry.setGauge("app_generation_achieved_rate", simulator, achieved, now)
+	metricRegistry.addCounter("app_generation_dropped_total", simulator, float64(dropped), now)
+}
+
+func (e *rateEngine) finish() {
+	now := time.Now()
+	e.mutex.Lock()
+	e.stopped = &now
+	generated := e.generated
+	e.mutex.Unlock()
+
+	e.record(0, 0, 0, now)
+	if e.opts.Sink != nil {
+		if err := e.opts.Sink.Close(); err != nil {
+			log.Printf("❌ Ошибка закрытия приемников: %v", err)
+		}
+	}
+	log.Printf("🛑 Генерация с заданной скоростью остановлена, событий: %d", generated)
+}
+
+func (e *rateEngine) status() models.RateStatus {
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+
+	end := time.Now()
+	if e.stopped != nil {
+		end = *e.stopped
+	}
+	average := 0.0
+	if elapsed := end.Sub(e.started).Seconds(); elapsed > 0 {
+		average = math.Round(float64(e.generated)/elapsed*100) / 100
+	}
+
+	return models.RateStatus{
+		Running:      e.stopped == nil,
+		Scenario:     e.request.Scenario,
+		TargetRate:   math.Round(e.target*100) / 100,
+		BaseRate:     e.shape.BaseRate(),
+		AchievedRate: math.Round(e.achieved*100) / 100,
+		AverageRate:  average,
+		Workers:      e.request.Workers,
+		Generated:    e.generated,
+		Dropped:      e.dropped,
+		StartedAt:    e.started,
+		StoppedAt:    e.stopped,
+	}
+}
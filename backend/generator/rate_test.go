@@ -0,0 +1,201 @@
+package generator
+
+import (
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+
+	"log-metrics-simulator/models"
+	"log-metrics-simulator/storage"
+)
+
+// newTestRateEngine создает движок без запущенного цикла: тики вызываются
+// тестом, а очередь никто не разбирает
+func newTestRateEngine(t testing.TB, rate float64, seed *int64, start time.Time) *rateEngine {
+	t.Helper()
+	shape, err := NewTrafficShape(models.TrafficShape{BaseRate: rate}, start, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &rateEngine{
+		request:    models.RateRequest{Scenario: "normal_load"},
+		opts:       Options{Seed: seed, Workers: 1},
+		shape:      shape,
+		queue:      make(chan rateBatch, rateQueue),
+		stop:       make(chan struct{}),
+		done:       make(chan struct{}),
+		started:    start,
+		last:       start,
+		windowFrom: start,
+	}
+}
+
+// useLogStore подменяет хранилище логов на время теста
+func useLogStore(t testing.TB) {
+	t.Helper()
+	previous := SetLogStore(storage.NewMemoryLogStore(0, 0))
+	t.Cleanup(func() { SetLogStore(previous) })
+}
+
+func TestRateAchievesTarget(t *testing.T) {
+	useRegistry(t)
+	useLogStore(t)
+
+	seed := int64(3)
+	if _, err := StartRate(models.RateRequest{
+		EventsPerSecond: 2000,
+		DurationSeconds: 1,
+		Scenario:        "normal_load",
+		Seed:            &seed,
+	}); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	status := GetRateStatus()
+	for status.Running {
+		if time.Now().After(deadline) {
+			StopRate()
+			t.Fatal("генерация не завершилась за 5с")
+		}
+		time.Sleep(20 * time.Millisecond)
+		status = GetRateStatus()
+	}
+	// Состояние завершенной генерации; StopRate дожидается ее цикла
+	status, err := StopRate()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Последний тик приходится на срок окончания, поэтому выпущено
+	// ровно заданное число событий с точностью до округления
+	if status.Generated+status.Dropped < 1995 || status.Generated+status.Dropped > 2000 {
+		t.Errorf("событий %d (+%d пропущено), ожидалось 2000", status.Generated, status.Dropped)
+	}
+	if status.Dropped != 0 {
+		t.Errorf("пропущено %d событий без отставания", status.Dropped)
+	}
+	if status.TargetRate != 2000 {
+		t.Errorf("заданная скорость %v, ожидалось 2000", status.TargetRate)
+	}
+	if status.AverageRate < 1800 || status.AverageRate > 2100 {
+		t.Errorf("средняя скорость %v, ожидалось около 2000", status.AverageRate)
+	}
+	logs, err := GetLogs(storage.LogQuery{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if int64(len(logs)) != status.Generated {
+		t.Errorf("в хранилище %d логов, выпущено %d", len(logs), status.Generated)
+	}
+}
+
+func TestRateTickDropsBacklog(t *testing.T) {
+	useRegistry(t)
+	start := time.Now()
+	e := newTestRateEngine(t, 1000, nil, start)
+
+	// Тик опоздал на 5с: в пакет попадает только последняя секунда
+	now := start.Add(5 * time.Second)
+	e.tick(now)
+	batch := <-e.queue
+	if batch.count != 1000 {
+		t.Errorf("в пакете %d событий, ожидалось 1000", batch.count)
+	}
+	if want := now.Add(-maxRateBacklog); !batch.opts.StartTime.Equal(want) {
+		t.Errorf("пакет начинается с %v, ожидалось %v", batch.opts.StartTime, want)
+	}
+	if batch.opts.Rate != 1000 {
+		t.Errorf("шаг пакета %v событий/с, ожидалось 1000", batch.opts.Rate)
+	}
+	if e.dropped != 4000 {
+		t.Errorf("пропущено %d событий, ожидалось 4000", e.dropped)
+	}
+
+	// Очередь заполнена: пакеты пропускаются целиком, отсчет не сбивается
+	for range rateQueue {
+		e.queue <- rateBatch{}
+	}
+	e.tick(now.Add(100 * time.Millisecond))
+	if e.dropped != 4100 {
+		t.Errorf("пропущено %d событий, ожидалось 4100", e.dropped)
+	}
+	if e.scheduled != 5100 {
+		t.Errorf("учтено %d событий, ожидалось 5100", e.scheduled)
+	}
+	if got := value(t, metricRegistry, "app_generation_dropped_total", map[string]string{"app": "simulator"}); got != 4100 {
+		t.Errorf("app_generation_dropped_total %v, ожидалось 4100", got)
+	}
+}
+
+func TestRateBatchSeeds(t *testing.T) {
+	useRegistry(t)
+	start := time.Now()
+	batches := func(seed int64) []rateBatch {
+		e := newTestRateEngine(t, 1000, &seed, start)
+		var out []rateBatch
+		for i := 1; i <= 3; i++ {
+			e.tick(start.Add(time.Duration(i) * rateTick))
+			out = append(out, <-e.queue)
+		}
+		return out
+	}
+
+	first := batches(42)
+	for i, batch := range first {
+		// Сдвиг на maxWorkers не дает seed генераторов соседних пакетов совпасть
+		if want := 42 + int64(i)*maxWorkers; *batch.opts.Seed != want {
+			t.Errorf("пакет %d: seed %d, ожидалось %d", i, *batch.opts.Seed, want)
+		}
+	}
+
+	second := batches(42)
+	for i := range first {
+		a := generate(first[i].count, "normal_load", first[i].opts)
+		b := generate(second[i].count, "normal_load", second[i].opts)
+		if !reflect.DeepEqual(a, b) {
+			t.Fatalf("пакет %d: запуски с одним seed дали разные логи", i)
+		}
+	}
+	a := generate(first[0].count, "normal_load", first[0].opts)
+	b := generate(first[1].count, "normal_load", first[1].opts)
+	if a[0].Message == b[0].Message && a[0].TraceID == b[0].TraceID {
+		t.Error("соседние пакеты сгенерированы с одним seed")
+	}
+}
+
+func TestStartRateValidation(t *testing.T) {
+	for _, req := range []models.RateRequest{
+		{EventsPerSecond: 0},
+		{EventsPerSecond: -1},
+		{EventsPerSecond: maxEventsPerSec + 1},
+		{EventsPerSecond: 10, Workers: maxWorkers + 1},
+		{EventsPerSecond: 10, DurationSeconds: -1},
+	} {
+		if _, err := StartRate(req); err == nil {
+			t.Errorf("%+v: ожидалась ошибка", req)
+		}
+	}
+}
+
+// BenchmarkRateBatch измеряет пропускную способность выпуска пакетов
+// генераторами движка: генерация и сохранение в хранилище в памяти
+func BenchmarkRateBatch(b *testing.B) {
+	saved := metricRegistry
+	metricRegistry = saved.cloneDefinitions()
+	b.Cleanup(func() { metricRegistry = saved })
+	useLogStore(b)
+
+	const count = 10000
+	for _, workers := range []int{1, 4, 16} {
+		b.Run("workers="+strconv.Itoa(workers), func(b *testing.B) {
+			seed := int64(1)
+			opts := Options{Seed: &seed, Workers: workers, StartTime: time.Now(), Rate: count}
+			start := time.Now()
+			for range b.N {
+				publish("normal_load", opts, generate(count, "normal_load", opts))
+			}
+			b.ReportMetric(float64(b.N*count)/time.Since(start).Seconds(), "events/s")
+		})
+	}
+}
@@ -0,0 +1,535 @@
+package generator
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"log-metrics-simulator/models"
+)
+
+// Типы метрик в реестре
+const (
+	metricTypeCounter   = "counter"
+	metricTypeGauge     = "gauge"
+	metricTypeHistogram = "histogram"
+	metricTypeSummary   = "summary"
+)
+
+// Размер скользящего окна наблюдений, по которому считаются квантили summary
+const summaryWindowSize = 1024
+
+// family объединяет серии одной метрики
+type family struct {
+	name   string
+	typ    string
+	series map[string]*series
+	// Границы корзин гистограммы (по возрастанию, без +Inf)
+	buckets []float64
+	// Квантили summary
+	quantiles []float64
+}
+
+// series хранит состояние одной серии метрики
+type series struct {
+	labels    map[string]string
+	value     float64
+	timestamp time.Time
+	created   time.Time // Время появления серии (для _created в OpenMetrics)
+	updated   time.Time // Реальное время последнего обновления (для срока хранения)
+
+	// Состояние гистограммы и summary
+	bucketCounts []uint64   // Некумулятивные счетчики корзин, последняя - +Inf
+	exemplars    []exemplar // Последний пример наблюдения для каждой корзины
+	sum          float64
+	count        uint64
+	window       []float64 // Последние наблюдения для расчета квантилей
+	windowPos    int
+}
+
+// exemplar связывает наблюдение гистограммы с трассировкой
+type exemplar struct {
+	traceID   string
+	value     float64
+	timestamp time.Time
+}
+
+// registry хранит серии метрик между запусками генерации.
+// Счетчики только растут, gauge хранят последнее значение.
+// Серия идентифицируется именем и отсортированным набором меток.
+type registry struct {
+	mutex    sync.RWMutex
+	families map[string]*family
+	help     map[string]string // Описания семейств для строк HELP
+}
+
+// Описание семейства, для которого не задан текст HELP
+const defaultMetricHelp = "Application metric"
+
+func newRegistry() *registry {
+	return &registry{
+		families: make(map[string]*family),
+		help:     make(map[string]string),
+	}
+}
+
+// describe задает текст HELP для семейства метрик
+func (r *registry) describe(name, help string) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.help[name] = help
+}
+
+// seriesKey строит ключ серии из имени и отсортированных меток. Значения
+// экранируются, чтобы разные наборы меток не давали один ключ.
+func seriesKey(name string, labels map[string]string) string {
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	b.WriteString(name)
+	b.WriteByte('{')
+	for i, k := range keys {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(k)
+		b.WriteByte('=')
+		b.WriteString(strconv.Quote(labels[k]))
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+// getFamily возвращает семейство метрики, создавая его при первом обращении.
+// Метрику, уже зарегистрированную с другим типом, получить нельзя. Вызывается под блокировкой.
+func (r *registry) getFamily(name, metricType string) (*family, error) {
+	f, ok := r.families[name]
+	if !ok {
+		f = &family{name: name, typ: metricType, series: make(map[string]*series)}
+		r.families[name] = f
+	}
+	if f.typ != metricType {
+		return nil, fmt.Errorf("метрика %s уже зарегистрирована с типом %s, а не %s", name, f.typ, metricType)
+	}
+	return f, nil
+}
+
+// getSeries возвращает серию семейства, создавая ее при первом обращении. Вызывается под блокировкой.
+func (f *family) getSeries(labels map[string]string, ts time.Time) *series {
+	key := seriesKey(f.name, labels)
+	if s, ok := f.series[key]; ok {
+		return s
+	}
+
+	copied := make(map[string]string, len(labels))
+	for k, v := range labels {
+		copied[k] = v
+	}
+
+	s := &series{labels: copied, created: ts}
+	switch f.typ {
+	case metricTypeHistogram:
+		s.bucketCounts = make([]uint64, len(f.buckets)+1)
+		s.exemplars = make([]exemplar, len(f.buckets)+1)
+	case metricTypeSummary:
+		s.window = make([]float64, 0, summaryWindowSize)
+	}
+	f.series[key] = s
+	return s
+}
+
+// addCounter увеличивает счетчик. Отрицательные приращения игнорируются,
+// чтобы счетчик никогда не уменьшался.
+func (r *registry) addCounter(name string, labels map[string]string, delta float64, ts time.Time) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	f, err := r.getFamily(name, metricTypeCounter)
+	if err != nil {
+		return err
+	}
+	s := f.getSeries(labels, ts)
+	if delta > 0 {
+		s.value += delta
+	}
+	s.timestamp = ts
+	s.updated = time.Now()
+	return nil
+}
+
+// setGauge устанавливает текущее значение gauge
+func (r *registry) setGauge(name string, labels map[string]string, value float64, ts time.Time) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	f, err := r.getFamily(name, metricTypeGauge)
+	if err != nil {
+		return err
+	}
+	s := f.getSeries(labels, ts)
+	s.value = value
+	s.timestamp = ts
+	s.updated = time.Now()
+	return nil
+}
+
+// defineHistogram задает границы корзин гистограммы. Существующие серии
+// семейства сбрасываются, так как несовместимы с новыми границами.
+func (r *registry) defineHistogram(name string, buckets []float64) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	f, err := r.getFamily(name, metricTypeHistogram)
+	if err != nil {
+		return err
+	}
+	f.buckets = append([]float64(nil), buckets...)
+	f.series = make(map[string]*series)
+	return nil
+}
+
+// defineSummary задает квантили summary
+func (r *registry) defineSummary(name string, quantiles []float64) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	f, err := r.getFamily(name, metricTypeSummary)
+	if err != nil {
+		return err
+	}
+	f.quantiles = append([]float64(nil), quantiles...)
+	f.series = make(map[string]*series)
+	return nil
+}
+
+// removeFamily удаляет семейство вместе со всеми сериями
+func (r *registry) removeFamily(name string) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	delete(r.families, name)
+}
+
+// cloneDefinitions возвращает пустой реестр с теми же описаниями,
+// границами гистограмм и квантилями summary
+func (r *registry) cloneDefinitions() *registry {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	clone := newRegistry()
+	for name, help := range r.help {
+		clone.help[name] = help
+	}
+	for name, f := range r.families {
+		if f.typ != metricTypeHistogram && f.typ != metricTypeSummary {
+			continue
+		}
+		clone.families[name] = &family{
+			name:      f.name,
+			typ:       f.typ,
+			series:    make(map[string]*series),
+			buckets:   append([]float64(nil), f.buckets...),
+			quantiles: append([]float64(nil), f.quantiles...),
+		}
+	}
+	return clone
+}
+
+// hasFamily сообщает, зарегистрировано ли семейство
+func (r *registry) hasFamily(name string) bool {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	_, ok := r.families[name]
+	return ok
+}
+
+// observe добавляет наблюдение в гистограмму или summary.
+// Семейство должно быть заранее объявлено через defineHistogram/defineSummary.
+// Непустой traceID сохраняется как exemplar корзины гистограммы.
+func (r *registry) observe(name string, labels map[string]string, value float64, ts time.Time, traceID string) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	f, ok := r.families[name]
+	if !ok || (f.typ != metricTypeHistogram && f.typ != metricTypeSummary) {
+		return
+	}
+
+	s := f.getSeries(labels, ts)
+	s.sum += value
+	s.count++
+	s.timestamp = ts
+	s.updated = time.Now()
+
+	switch f.typ {
+	case metricTypeHistogram:
+		idx := sort.SearchFloat64s(f.buckets, value)
+		s.bucketCounts[idx]++
+		if traceID != "" {
+			s.exemplars[idx] = exemplar{traceID: traceID, value: value, timestamp: ts}
+		}
+	case metricTypeSummary:
+		if len(s.window) < summaryWindowSize {
+			s.window = append(s.window, value)
+		} else {
+			s.window[s.windowPos] = value
+			s.windowPos = (s.windowPos + 1) % summaryWindowSize
+		}
+	}
+}
+
+// len возвращает количество серий в реестре
+func (r *registry) len() int {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	total := 0
+	for _, f := range r.families {
+		total += len(f.series)
+	}
+	return total
+}
+
+// sample - одна выборка серии с необязательным exemplar
+type sample struct {
+	metric   models.Metric
+	exemplar *exemplar
+}
+
+// seriesSnapshot - копия серии для вывода
+type seriesSnapshot struct {
+	labels  map[string]string
+	created time.Time
+	samples []sample
+}
+
+// familySnapshot - копия семейства для вывода
+type familySnapshot struct {
+	name   string
+	typ    string
+	help   string
+	series []seriesSnapshot
+}
+
+// snapshotFamilies возвращает копии всех семейств, отсортированные по имени.
+// Гистограммы и summary разворачиваются в серии _bucket/_sum/_count и квантили.
+func (r *registry) snapshotFamilies() []familySnapshot {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	names := make([]string, 0, len(r.families))
+	for name := range r.families {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	result := make([]familySnapshot, 0, len(names))
+	for _, name := range names {
+		f := r.families[name]
+
+		keys := make([]string, 0, len(f.series))
+		for k := range f.series {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		help, ok := r.help[f.name]
+		if !ok {
+			help = defaultMetricHelp
+		}
+
+		fs := familySnapshot{name: f.name, typ: f.typ, help: help}
+		for _, k := range keys {
+			s := f.series[k]
+			labels := make(map[string]string, len(s.labels))
+			for lk, lv := range s.labels {
+				labels[lk] = lv
+			}
+			fs.series = append(fs.series, seriesSnapshot{labels: labels, created: s.created, samples: f.samples(s)})
+		}
+		result = append(result, fs)
+	}
+	return result
+}
+
+// samples разворачивает серию в набор выборок
+func (f *family) samples(s *series) []sample {
+	newSample := func(name string, value float64, extra ...string) sample {
+		labels := make(map[string]string, len(s.labels)+1)
+		for k, v := range s.labels {
+			labels[k] = v
+		}
+		for i := 0; i+1 < len(extra); i += 2 {
+			labels[extra[i]] = extra[i+1]
+		}
+		return sample{metric: models.Metric{Name: name, Value: value, Type: f.typ, Labels: labels, Timestamp: s.timestamp}}
+	}
+
+	switch f.typ {
+	case metricTypeHistogram:
+		result := make([]sample, 0, len(s.bucketCounts)+2)
+		var cumulative uint64
+		for i, c := range s.bucketCounts {
+			cumulative += c
+			le := "+Inf"
+			if i < len(f.buckets) {
+				le = formatBound(f.buckets[i])
+			}
+			bucket := newSample(f.name+"_bucket", float64(cumulative), "le", le)
+			if s.exemplars[i].traceID != "" {
+				ex := s.exemplars[i]
+				bucket.exemplar = &ex
+			}
+			result = append(result, bucket)
+		}
+		result = append(result,
+			newSample(f.name+"_sum", s.sum),
+			newSample(f.name+"_count", float64(s.count)),
+		)
+		return result
+	case metricTypeSummary:
+		result := make([]sample, 0, len(f.quantiles)+2)
+		sorted := append([]float64(nil), s.window...)
+		sort.Float64s(sorted)
+		for _, q := range f.quantiles {
+			result = append(result, newSample(f.name, quantile(sorted, q), "quantile", formatBound(q)))
+		}
+		result = append(result,
+			newSample(f.name+"_sum", s.sum),
+			newSample(f.name+"_count", float64(s.count)),
+		)
+		return result
+	default:
+		return []sample{newSample(f.name, s.value)}
+	}
+}
+
+// flatten возвращает все выборки семейства подряд
+func (f familySnapshot) flatten() []models.Metric {
+	var result []models.Metric
+	for _, s := range f.series {
+		for _, smp := range s.samples {
+			result = append(result, smp.metric)
+		}
+	}
+	return result
+}
+
+// snapshot возвращает плоскую копию всех выборок в детерминированном порядке
+func (r *registry) snapshot() []models.Metric {
+	var result []models.Metric
+	for _, f := range r.snapshotFamilies() {
+		result = append(result, f.flatten()...)
+	}
+	return result
+}
+
+// exportFamilies возвращает копии всех семейств со структурой гистограмм и summary
+func (r *registry) exportFamilies() []models.MetricFamily {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	names := make([]string, 0, len(r.families))
+	for name := range r.families {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	result := make([]models.MetricFamily, 0, len(names))
+	for _, name := range names {
+		f := r.families[name]
+
+		help, ok := r.help[f.name]
+		if !ok {
+			help = defaultMetricHelp
+		}
+		mf := models.MetricFamily{Name: f.name, Type: f.typ, Help: help}
+
+		keys := make([]string, 0, len(f.series))
+		for k := range f.series {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		for _, k := range keys {
+			s := f.series[k]
+			ms := models.MetricSeries{
+				Labels:    make(map[string]string, len(s.labels)),
+				Value:     s.value,
+				Created:   s.created,
+				Timestamp: s.timestamp,
+				Sum:       s.sum,
+				Count:     s.count,
+			}
+			for lk, lv := range s.labels {
+				ms.Labels[lk] = lv
+			}
+
+			switch f.typ {
+			case metricTypeHistogram:
+				ms.Bounds = append([]float64(nil), f.buckets...)
+				ms.BucketCounts = append([]uint64(nil), s.bucketCounts...)
+			case metricTypeSummary:
+				// Без наблюдений в окне квантили не определены (NaN не кодируется в JSON)
+				if len(s.window) == 0 {
+					break
+				}
+				sorted := append([]float64(nil), s.window...)
+				sort.Float64s(sorted)
+				for _, q := range f.quantiles {
+					ms.Quantiles = append(ms.Quantiles, models.QuantileValue{Quantile: q, Value: quantile(sorted, q)})
+				}
+			}
+			mf.Series = append(mf.Series, ms)
+		}
+		result = append(result, mf)
+	}
+	return result
+}
+
+// quantile вычисляет квантиль по отсортированной выборке (метод ближайшего ранга)
+func quantile(sorted []float64, q float64) float64 {
+	if len(sorted) == 0 {
+		return math.NaN()
+	}
+	idx := int(math.Ceil(q*float64(len(sorted)))) - 1
+	if idx < 0 {
+		idx = 0
+	}
+	if idx >= len(sorted) {
+		idx = len(sorted) - 1
+	}
+	return sorted[idx]
+}
+
+// formatBound форматирует границу корзины или квантиль для метки
+func formatBound(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// parseBounds разбирает список границ через запятую ("5,10,25")
+func parseBounds(value string) ([]float64, error) {
+	var result []float64
+	for _, part := range strings.Split(value, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		v, err := strconv.ParseFloat(part, 64)
+		if err != nil {
+			return nil, fmt.Errorf("неверное значение %q: %v", part, err)
+		}
+		result = append(result, v)
+	}
+	return result, nil
+}
@@ -0,0 +1,106 @@
+package generator
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+var testTime = time.Date(2024, time.March, 1, 12, 0, 0, 0, time.UTC)
+
+// value возвращает значение серии name с метками labels
+func value(t *testing.T, r *registry, name string, labels map[string]string) float64 {
+	t.Helper()
+	f, ok := r.families[name]
+	if !ok {
+		t.Fatalf("нет семейства %s", name)
+	}
+	s, ok := f.series[seriesKey(name, labels)]
+	if !ok {
+		t.Fatalf("нет серии %s", seriesKey(name, labels))
+	}
+	return s.value
+}
+
+func TestCounterIsCumulative(t *testing.T) {
+	r := newRegistry()
+	labels := map[string]string{"service": "cart", "status": "200"}
+
+	for _, delta := range []float64{3, 2, -10, 0, 1} {
+		if err := r.addCounter("requests_total", labels, delta, testTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := value(t, r, "requests_total", labels); got != 6 {
+		t.Fatalf("счетчик %v, ожидалось 6: отрицательные приращения игнорируются", got)
+	}
+}
+
+func TestGaugeKeepsLastValue(t *testing.T) {
+	r := newRegistry()
+	labels := map[string]string{"service": "cart"}
+
+	for _, v := range []float64{10, 3, 7} {
+		if err := r.setGauge("active_users", labels, v, testTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := value(t, r, "active_users", labels); got != 7 {
+		t.Fatalf("gauge %v, ожидалось 7", got)
+	}
+}
+
+func TestSeriesKeyIsOrderIndependentAndEscaped(t *testing.T) {
+	a := seriesKey("m", map[string]string{"a": "1", "b": "2"})
+	b := seriesKey("m", map[string]string{"b": "2", "a": "1"})
+	if a != b {
+		t.Fatalf("ключ зависит от порядка меток: %s и %s", a, b)
+	}
+	if want := `m{a="1",b="2"}`; a != want {
+		t.Fatalf("ключ %s, ожидался %s", a, want)
+	}
+
+	// Значение с разделителями не должно совпадать с другим набором меток
+	joined := seriesKey("m", map[string]string{"a": `1",b="2`})
+	if joined == a {
+		t.Fatalf("разные наборы меток дали один ключ %s", joined)
+	}
+	if want := `m{a="1\",b=\"2"}`; joined != want {
+		t.Fatalf("ключ %s, ожидался %s", joined, want)
+	}
+}
+
+func TestMetricTypeConflict(t *testing.T) {
+	r := newRegistry()
+	if err := r.addCounter("requests_total", nil, 1, testTime); err != nil {
+		t.Fatal(err)
+	}
+
+	err := r.setGauge("requests_total", nil, 5, testTime)
+	if err == nil || !strings.Contains(err.Error(), "counter") {
+		t.Fatalf("ожидалась ошибка конфликта типов, получено %v", err)
+	}
+	if err := r.defineHistogram("requests_total", []float64{1}); err == nil {
+		t.Fatal("ожидалась ошибка конфликта типов для гистограммы")
+	}
+	if got := value(t, r, "requests_total", nil); got != 1 {
+		t.Fatalf("конфликт типов изменил счетчик: %v", got)
+	}
+}
+
+func TestSnapshotIsSorted(t *testing.T) {
+	r := newRegistry()
+	for _, service := range []string{"user", "auth", "cart"} {
+		r.addCounter("b_total", map[string]string{"service": service}, 1, testTime)
+		r.setGauge("a_gauge", map[string]string{"service": service}, 1, testTime)
+	}
+
+	var got []string
+	for _, m := range r.snapshot() {
+		got = append(got, m.Name+"/"+m.Labels["service"])
+	}
+	want := []string{"a_gauge/auth", "a_gauge/cart", "a_gauge/user", "b_total/auth", "b_total/cart", "b_total/user"}
+	if strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Fatalf("порядок снимка %v, ожидался %v", got, want)
+	}
+}
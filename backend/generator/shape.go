@@ -0,0 +1,237 @@
+package generator
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+
+	"log-metrics-simulator/models"
+)
+
+// Шаг численного интегрирования скорости
+const shapeStep = time.Second
+
+// TrafficShape вычисляет скорость потока событий по форме нагрузки.
+// Всплески bursts разыгрываются по мере продвижения времени, поэтому форма
+// с одним seed дает одну и ту же нагрузку.
+type TrafficShape struct {
+	spec      models.TrafficShape
+	start     time.Time
+	locations []*time.Location
+
+	mutex  sync.Mutex
+	rnd    *rand.Rand
+	bursts [][]time.Time // Начала всплесков каждого компонента, по возрастанию
+	until  []time.Time   // До какого момента разыграны всплески
+}
+
+// ValidateTrafficShape проверяет форму нагрузки и заполняет значения по умолчанию
+func ValidateTrafficShape(spec *models.TrafficShape) error {
+	if spec.BaseRate < 0 {
+		return fmt.Errorf("base_rate не может быть отрицательным")
+	}
+
+	for i := range spec.Components {
+		c := &spec.Components[i]
+		if c.StartSeconds < 0 || c.DurationSeconds < 0 || c.BurstSeconds < 0 || c.RiseSeconds < 0 || c.DecaySeconds < 0 {
+			return fmt.Errorf("компонент %d (%s): длительности не могут быть отрицательными", i, c.Type)
+		}
+
+		switch c.Type {
+		case "ramp":
+			if c.From < 0 || c.To < 0 {
+				return fmt.Errorf("компонент %d (ramp): from и to не могут быть отрицательными", i)
+			}
+		case "step":
+			if len(c.Steps) == 0 {
+				return fmt.Errorf("компонент %d (step): не заданы ступени", i)
+			}
+			for _, step := range c.Steps {
+				if step.AtSeconds < 0 || step.Factor < 0 {
+					return fmt.Errorf("компонент %d (step): at_seconds и factor не могут быть отрицательными", i)
+				}
+			}
+			sort.SliceStable(c.Steps, func(a, b int) bool { return c.Steps[a].AtSeconds < c.Steps[b].AtSeconds })
+		case "diurnal":
+			if c.Amplitude < 0 || c.Amplitude > 1 {
+				return fmt.Errorf("компонент %d (diurnal): amplitude должен быть от 0 до 1", i)
+			}
+			if c.PeakHour == 0 {
+				c.PeakHour = 14
+			}
+			if c.PeakHour < 0 || c.PeakHour >= 24 {
+				return fmt.Errorf("компонент %d (diurnal): peak_hour должен быть от 0 до 24", i)
+			}
+		case "weekly":
+			if c.WeekendFactor < 0 {
+				return fmt.Errorf("компонент %d (weekly): weekend_factor не может быть отрицательным", i)
+			}
+		case "bursts":
+			if c.BurstsPerHour <= 0 {
+				return fmt.Errorf("компонент %d (bursts): bursts_per_hour должен быть положительным", i)
+			}
+			if c.BurstFactor == 0 {
+				c.BurstFactor = 5
+			}
+			if c.BurstSeconds == 0 {
+				c.BurstSeconds = 30
+			}
+		case "flash_sale":
+			if c.PeakFactor < 1 {
+				return fmt.Errorf("компонент %d (flash_sale): peak_factor должен быть не меньше 1", i)
+			}
+			if c.DecaySeconds == 0 {
+				c.DecaySeconds = 600
+			}
+		default:
+			return fmt.Errorf("компонент %d: неизвестный тип %q (ramp, step, diurnal, weekly, bursts, flash_sale)", i, c.Type)
+		}
+
+		if c.Timezone != "" {
+			if _, err := time.LoadLocation(c.Timezone); err != nil {
+				return fmt.Errorf("компонент %d (%s): неизвестный часовой пояс %s", i, c.Type, c.Timezone)
+			}
+		}
+	}
+	return nil
+}
+
+// NewTrafficShape проверяет форму и отсчитывает ее время от start
+func NewTrafficShape(spec models.TrafficShape, start time.Time, seed int64) (*TrafficShape, error) {
+	spec.Components = append([]models.ShapeComponent(nil), spec.Components...)
+	if err := ValidateTrafficShape(&spec); err != nil {
+		return nil, err
+	}
+
+	s := &TrafficShape{
+		spec:      spec,
+		start:     start,
+		locations: make([]*time.Location, len(spec.Components)),
+		rnd:       rand.New(rand.NewSource(seed)),
+		bursts:    make([][]time.Time, len(spec.Components)),
+		until:     make([]time.Time, len(spec.Components)),
+	}
+	for i, c := range spec.Components {
+		s.locations[i] = time.UTC
+		if c.Timezone != "" {
+			s.locations[i], _ = time.LoadLocation(c.Timezone)
+		}
+		s.until[i] = start
+	}
+	return s, nil
+}
+
+// BaseRate возвращает базовую скорость формы
+func (s *TrafficShape) BaseRate() float64 {
+	return s.spec.BaseRate
+}
+
+// Rate возвращает скорость в событиях в секунду в момент at
+func (s *TrafficShape) Rate(at time.Time) float64 {
+	rate := s.spec.BaseRate
+	for i := range s.spec.Components {
+		rate *= s.factor(i, at)
+	}
+	return rate
+}
+
+// Events возвращает ожидаемое число событий на интервале [from, to)
+func (s *TrafficShape) Events(from, to time.Time) float64 {
+	total := 0.0
+	for t := from; t.Before(to); t = t.Add(shapeStep) {
+		dt := shapeStep
+		if rest := to.Sub(t); rest < dt {
+			dt = rest
+		}
+		total += s.Rate(t.Add(dt/2)) * dt.Seconds()
+	}
+	return total
+}
+
+// factor возвращает множитель компонента i в момент at
+func (s *TrafficShape) factor(i int, at time.Time) float64 {
+	c := s.spec.Components[i]
+	elapsed := at.Sub(s.start).Seconds()
+
+	switch c.Type {
+	case "ramp":
+		progress := 1.0
+		if c.DurationSeconds > 0 {
+			progress = (elapsed - float64(c.StartSeconds)) / float64(c.DurationSeconds)
+		} else if elapsed < float64(c.StartSeconds) {
+			progress = 0
+		}
+		progress = math.Max(0, math.Min(1, progress))
+		return c.From + (c.To-c.From)*progress
+	case "step":
+		factor := 1.0
+		for _, step := range c.Steps {
+			if elapsed < float64(step.AtSeconds) {
+				break
+			}
+			factor = step.Factor
+		}
+		return factor
+	case "diurnal":
+		local := at.In(s.locations[i])
+		hour := float64(local.Hour()) + float64(local.Minute())/60 + float64(local.Second())/3600
+		return 1 + c.Amplitude*math.Cos(2*math.Pi*(hour-c.PeakHour)/24)
+	case "weekly":
+		switch at.In(s.locations[i]).Weekday() {
+		case time.Saturday, time.Sunday:
+			return c.WeekendFactor
+		}
+		return 1
+	case "bursts":
+		if s.inBurst(i, at) {
+			return c.BurstFactor
+		}
+		return 1
+	case "flash_sale":
+		since := elapsed - float64(c.StartSeconds)
+		switch {
+		case since < 0:
+			return 1
+		case since < float64(c.RiseSeconds):
+			return 1 + (c.PeakFactor-1)*since/float64(c.RiseSeconds)
+		default:
+			return 1 + (c.PeakFactor-1)*math.Exp(-(since-float64(c.RiseSeconds))/float64(c.DecaySeconds))
+		}
+	}
+	return 1
+}
+
+// inBurst разыгрывает начала всплесков компонента i до момента at и проверяет,
+// идет ли в этот момент всплеск
+func (s *TrafficShape) inBurst(i int, at time.Time) bool {
+	c := s.spec.Components[i]
+	length := time.Duration(c.BurstSeconds) * time.Second
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	// Интервалы между началами пуассоновского потока распределены экспоненциально
+	for !s.until[i].After(at) {
+		gap := time.Duration(s.rnd.ExpFloat64() / c.BurstsPerHour * float64(time.Hour))
+		s.until[i] = s.until[i].Add(gap)
+		s.bursts[i] = append(s.bursts[i], s.until[i])
+	}
+
+	// Всплески, закончившиеся давно, больше не понадобятся
+	for len(s.bursts[i]) > 0 && s.bursts[i][0].Add(length).Before(at.Add(-time.Hour)) {
+		s.bursts[i] = s.bursts[i][1:]
+	}
+
+	for _, begin := range s.bursts[i] {
+		if begin.After(at) {
+			break
+		}
+		if at.Before(begin.Add(length)) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,171 @@
+package generator
+
+import (
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"log-metrics-simulator/models"
+)
+
+func newTestShape(t *testing.T, base float64, components ...models.ShapeComponent) *TrafficShape {
+	t.Helper()
+	shape, err := NewTrafficShape(models.TrafficShape{BaseRate: base, Components: components}, testTime, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return shape
+}
+
+func TestShapeFactors(t *testing.T) {
+	// testTime - пятница, 12:00 UTC
+	for _, tc := range []struct {
+		name      string
+		component models.ShapeComponent
+		at        time.Duration
+		want      float64
+	}{
+		{"ramp до начала", models.ShapeComponent{Type: "ramp", From: 1, To: 3, StartSeconds: 60, DurationSeconds: 120}, 30 * time.Second, 1},
+		{"ramp в середине", models.ShapeComponent{Type: "ramp", From: 1, To: 3, StartSeconds: 60, DurationSeconds: 120}, 2 * time.Minute, 2},
+		{"ramp после конца", models.ShapeComponent{Type: "ramp", From: 1, To: 3, StartSeconds: 60, DurationSeconds: 120}, time.Hour, 3},
+		{"ramp без длительности", models.ShapeComponent{Type: "ramp", From: 2, To: 0.5, StartSeconds: 60}, time.Minute, 0.5},
+		{"step до первой ступени", models.ShapeComponent{Type: "step", Steps: []models.ShapeStep{{AtSeconds: 60, Factor: 2}, {AtSeconds: 120, Factor: 4}}}, 59 * time.Second, 1},
+		{"step", models.ShapeComponent{Type: "step", Steps: []models.ShapeStep{{AtSeconds: 120, Factor: 4}, {AtSeconds: 60, Factor: 2}}}, 90 * time.Second, 2},
+		{"step последняя", models.ShapeComponent{Type: "step", Steps: []models.ShapeStep{{AtSeconds: 60, Factor: 2}, {AtSeconds: 120, Factor: 4}}}, time.Hour, 4},
+		{"diurnal пик", models.ShapeComponent{Type: "diurnal", Amplitude: 0.5}, 2 * time.Hour, 1.5},
+		{"diurnal спад", models.ShapeComponent{Type: "diurnal", Amplitude: 0.5}, 14 * time.Hour, 0.5},
+		{"diurnal середина", models.ShapeComponent{Type: "diurnal", Amplitude: 0.5, PeakHour: 18}, 0, 1},
+		{"diurnal в часовом поясе", models.ShapeComponent{Type: "diurnal", Amplitude: 0.5, Timezone: "Europe/Moscow"}, -time.Hour, 1.5},
+		{"weekly будни", models.ShapeComponent{Type: "weekly", WeekendFactor: 0.3}, 11 * time.Hour, 1},
+		{"weekly выходные", models.ShapeComponent{Type: "weekly", WeekendFactor: 0.3}, 12 * time.Hour, 0.3},
+		{"weekly в часовом поясе", models.ShapeComponent{Type: "weekly", WeekendFactor: 0.3, Timezone: "Asia/Tokyo"}, 3 * time.Hour, 0.3},
+		{"flash_sale до начала", models.ShapeComponent{Type: "flash_sale", PeakFactor: 5, StartSeconds: 60, RiseSeconds: 60}, 0, 1},
+		{"flash_sale рост", models.ShapeComponent{Type: "flash_sale", PeakFactor: 5, StartSeconds: 60, RiseSeconds: 60}, 90 * time.Second, 3},
+		{"flash_sale пик", models.ShapeComponent{Type: "flash_sale", PeakFactor: 5, StartSeconds: 60, RiseSeconds: 60}, 2 * time.Minute, 5},
+		{"flash_sale спад", models.ShapeComponent{Type: "flash_sale", PeakFactor: 5, StartSeconds: 60, RiseSeconds: 60}, 12 * time.Minute, 1 + 4/math.E},
+	} {
+		shape := newTestShape(t, 10, tc.component)
+		if got := shape.Rate(testTime.Add(tc.at)); math.Abs(got-10*tc.want) > 1e-9 {
+			t.Errorf("%s: скорость %v, ожидалось %v", tc.name, got, 10*tc.want)
+		}
+	}
+
+	// Множители компонентов перемножаются
+	shape := newTestShape(t, 100,
+		models.ShapeComponent{Type: "ramp", From: 1, To: 2, DurationSeconds: 100},
+		models.ShapeComponent{Type: "weekly", WeekendFactor: 0.5},
+	)
+	if got := shape.Rate(testTime.Add(50 * time.Second)); math.Abs(got-150) > 1e-9 {
+		t.Errorf("скорость %v, ожидалось 100 * 1.5", got)
+	}
+	if got := shape.Rate(testTime.Add(24 * time.Hour)); got != 100 {
+		t.Errorf("скорость в субботу %v, ожидалось 100 * 2 * 0.5", got)
+	}
+}
+
+func TestShapeEvents(t *testing.T) {
+	constant := newTestShape(t, 10)
+	if got := constant.Events(testTime, testTime.Add(90500*time.Millisecond)); math.Abs(got-905) > 1e-9 {
+		t.Errorf("событий при постоянной скорости %v, ожидалось 905", got)
+	}
+	if got := constant.Events(testTime, testTime); got != 0 {
+		t.Errorf("событий на пустом интервале %v", got)
+	}
+
+	// Интеграл линейного роста от 0 до 10 событий/с за 100 с - 500 событий,
+	// после конца роста - по 10 в секунду
+	ramp := newTestShape(t, 10, models.ShapeComponent{Type: "ramp", From: 0, To: 1, DurationSeconds: 100})
+	if got := ramp.Events(testTime, testTime.Add(100*time.Second)); math.Abs(got-500) > 1e-9 {
+		t.Errorf("событий за рост %v, ожидалось 500", got)
+	}
+	if got := ramp.Events(testTime.Add(50*time.Second), testTime.Add(150*time.Second)); math.Abs(got-875) > 1e-9 {
+		t.Errorf("событий на интервале %v, ожидалось 375 + 500", got)
+	}
+
+	// Суточный цикл за целые сутки в среднем не меняет скорость
+	diurnal := newTestShape(t, 1, models.ShapeComponent{Type: "diurnal", Amplitude: 0.8})
+	if got := diurnal.Events(testTime, testTime.Add(24*time.Hour)); math.Abs(got-86400) > 1 {
+		t.Errorf("событий за сутки %v, ожидалось 86400", got)
+	}
+}
+
+func TestShapeBursts(t *testing.T) {
+	component := models.ShapeComponent{Type: "bursts", BurstsPerHour: 6}
+	shape := newTestShape(t, 1, component)
+	same := newTestShape(t, 1, component)
+
+	// Доля времени во всплесках пуассоновского потока: 1 - exp(-λ·длительность)
+	inBurst := 0
+	const samples = 100 * 360
+	for i := 0; i < samples; i++ {
+		at := testTime.Add(time.Duration(i) * 10 * time.Second)
+		rate := shape.Rate(at)
+		if rate != same.Rate(at) {
+			t.Fatalf("формы с одним seed разошлись в %v", at)
+		}
+		switch rate {
+		case 5:
+			inBurst++
+		case 1:
+		default:
+			t.Fatalf("скорость %v, ожидалось 1 или 5 (burst_factor по умолчанию)", rate)
+		}
+	}
+	want := 1 - math.Exp(-6*30.0/3600)
+	if share := float64(inBurst) / samples; math.Abs(share-want) > 0.01 {
+		t.Errorf("доля времени во всплесках %.4f, ожидалось %.4f", share, want)
+	}
+
+	// Повторный запрос недавнего момента дает тот же результат
+	for i := samples - 300; i < samples; i++ {
+		at := testTime.Add(time.Duration(i) * 10 * time.Second)
+		if shape.Rate(at) != same.Rate(at) {
+			t.Fatalf("повторный запрос %v дал другую скорость", at)
+		}
+	}
+}
+
+func TestValidateTrafficShape(t *testing.T) {
+	for _, tc := range []struct {
+		spec models.TrafficShape
+		want string
+	}{
+		{models.TrafficShape{BaseRate: -1}, "base_rate"},
+		{models.TrafficShape{Components: []models.ShapeComponent{{Type: "sine"}}}, "неизвестный тип \"sine\""},
+		{models.TrafficShape{Components: []models.ShapeComponent{{Type: "ramp", StartSeconds: -1}}}, "длительности не могут быть отрицательными"},
+		{models.TrafficShape{Components: []models.ShapeComponent{{Type: "ramp", From: -1}}}, "from и to"},
+		{models.TrafficShape{Components: []models.ShapeComponent{{Type: "step"}}}, "не заданы ступени"},
+		{models.TrafficShape{Components: []models.ShapeComponent{{Type: "diurnal", Amplitude: 1.5}}}, "amplitude"},
+		{models.TrafficShape{Components: []models.ShapeComponent{{Type: "diurnal", PeakHour: 24}}}, "peak_hour"},
+		{models.TrafficShape{Components: []models.ShapeComponent{{Type: "bursts"}}}, "bursts_per_hour"},
+		{models.TrafficShape{Components: []models.ShapeComponent{{Type: "flash_sale", PeakFactor: 0.5}}}, "peak_factor"},
+		{models.TrafficShape{Components: []models.ShapeComponent{{Type: "weekly", Timezone: "Mars/Olympus"}}}, "неизвестный часовой пояс"},
+	} {
+		if err := ValidateTrafficShape(&tc.spec); err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("%+v: ошибка %v, ожидалась с %q", tc.spec, err, tc.want)
+		}
+	}
+
+	spec := models.TrafficShape{Components: []models.ShapeComponent{
+		{Type: "diurnal"},
+		{Type: "bursts", BurstsPerHour: 1},
+		{Type: "flash_sale", PeakFactor: 2},
+	}}
+	if err := ValidateTrafficShape(&spec); err != nil {
+		t.Fatal(err)
+	}
+	c := spec.Components
+	if c[0].PeakHour != 14 || c[1].BurstFactor != 5 || c[1].BurstSeconds != 30 || c[2].DecaySeconds != 600 {
+		t.Errorf("значения по умолчанию не заполнены: %+v", c)
+	}
+
+	// NewTrafficShape не меняет компоненты вызывающего
+	components := []models.ShapeComponent{{Type: "bursts", BurstsPerHour: 1}}
+	if _, err := NewTrafficShape(models.TrafficShape{Components: components}, testTime, 1); err != nil {
+		t.Fatal(err)
+	}
+	if components[0].BurstFactor != 0 {
+		t.Error("NewTrafficShape изменил переданные компоненты")
+	}
+}
@@ -0,0 +1,414 @@
+package generator
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"net/http"
+	"strings"
+	"sync"
+
+	"log-metrics-simulator/models"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Пользовательская топология заменяет встроенный набор сервисов интернет-магазина.
+// nil - используется встроенная топология.
+var (
+	activeTopology *topologyModel
+	topologyMutex  sync.RWMutex
+)
+
+// topologyModel - проверенная топология с заполненными значениями по умолчанию
+type topologyModel struct {
+	source       models.Topology
+	name         string
+	services     []*topologyService
+	weights      []float64
+	entrypoints  []*topologyService
+	dependencies map[string][]string // Граф вызовов: объединение вызовов всех эндпоинтов сервиса
+}
+
+type topologyService struct {
+	name      string
+	endpoints []*topologyEndpoint
+	weights   []float64
+}
+
+type topologyEndpoint struct {
+	method  string
+	path    string
+	message string
+	latency models.TopologyLatency
+	errors  []models.TopologyError
+	calls   []*topologyService
+}
+
+// ParseTopology разбирает топологию в формате YAML или JSON
+func ParseTopology(data []byte) (models.Topology, error) {
+	var topology models.Topology
+	// JSON - подмножество YAML, поэтому один разборщик подходит для обоих форматов
+	if err := yaml.Unmarshal(data, &topology); err != nil {
+		return topology, fmt.Errorf("ошибка разбора топологии: %v", err)
+	}
+	return topology, nil
+}
+
+// LoadTopology проверяет топологию и делает ее активной для новых запусков генерации
+func LoadTopology(topology models.Topology) error {
+	model, err := compileTopology(topology)
+	if err != nil {
+		return err
+	}
+
+	topologyMutex.Lock()
+	activeTopology = model
+	topologyMutex.Unlock()
+	return nil
+}
+
+// ResetTopology возвращает встроенную топологию интернет-магазина
+func ResetTopology() {
+	topologyMutex.Lock()
+	activeTopology = nil
+	topologyMutex.Unlock()
+}
+
+// GetTopology возвращает активную пользовательскую топологию или nil для встроенной
+func GetTopology() *models.Topology {
+	topologyMutex.RLock()
+	defer topologyMutex.RUnlock()
+
+	if activeTopology == nil {
+		return nil
+	}
+	topology := activeTopology.source
+	return &topology
+}
+
+func currentTopology() *topologyModel {
+	topologyMutex.RLock()
+	defer topologyMutex.RUnlock()
+	return activeTopology
+}
+
+func compileTopology(topology models.Topology) (*topologyModel, error) {
+	if len(topology.Services) == 0 {
+		return nil, fmt.Errorf("топология должна содержать хотя бы один сервис")
+	}
+
+	model := &topologyModel{source: topology, name: topology.Name, dependencies: make(map[string][]string)}
+	if model.name == "" {
+		model.name = "app"
+	}
+
+	byName := make(map[string]*topologyService)
+	for _, svc := range topology.Services {
+		if svc.Name == "" {
+			return nil, fmt.Errorf("у сервиса не задано имя")
+		}
+		if _, exists := byName[svc.Name]; exists {
+			return nil, fmt.Errorf("сервис %s описан дважды", svc.Name)
+		}
+		if svc.Weight < 0 {
+			return nil, fmt.Errorf("сервис %s: weight не может быть отрицательным", svc.Name)
+		}
+		s := &topologyService{name: svc.Name}
+		byName[svc.Name] = s
+		model.dependencies[svc.Name] = nil
+		model.services = append(model.services, s)
+		model.weights = append(model.weights, weightOrDefault(svc.Weight))
+	}
+
+	// Второй проход: эндпоинты и ссылки на зависимости
+	called := make(map[string]bool)
+	for i, svc := range topology.Services {
+		s := model.services[i]
+
+		endpoints := svc.Endpoints
+		if len(endpoints) == 0 {
+			endpoints = []models.TopologyEndpoint{{Method: "GET", Path: "/"}}
+		}
+		for _, ep := range endpoints {
+			if ep.Path == "" {
+				return nil, fmt.Errorf("сервис %s: у эндпоинта не задан path", svc.Name)
+			}
+			if ep.Weight < 0 {
+				return nil, fmt.Errorf("сервис %s, %s: weight не может быть отрицательным", svc.Name, ep.Path)
+			}
+			if err := validateLatency(ep.Latency); err != nil {
+				return nil, fmt.Errorf("сервис %s, %s: %v", svc.Name, ep.Path, err)
+			}
+
+			e := &topologyEndpoint{
+				method:  strings.ToUpper(ep.Method),
+				path:    ep.Path,
+				message: ep.Message,
+				latency: ep.Latency,
+			}
+			if e.method == "" {
+				e.method = "GET"
+			}
+
+			// Режимы ошибок эндпоинта дополняются режимами сервиса
+			errorRate := 0.0
+			for _, mode := range append(append([]models.TopologyError{}, ep.Errors...), svc.Errors...) {
+				mode, err := normalizeErrorMode(mode)
+				if err != nil {
+					return nil, fmt.Errorf("сервис %s, %s: %v", svc.Name, ep.Path, err)
+				}
+				errorRate += mode.Rate
+				e.errors = append(e.errors, mode)
+			}
+			if errorRate > 1 {
+				return nil, fmt.Errorf("сервис %s, %s: суммарная вероятность ошибок больше 1", svc.Name, ep.Path)
+			}
+
+			calls := svc.Dependencies
+			if ep.Calls != nil {
+				calls = *ep.Calls
+			}
+			for _, name := range calls {
+				dep, ok := byName[name]
+				if !ok {
+					return nil, fmt.Errorf("сервис %s вызывает неизвестный сервис %s", svc.Name, name)
+				}
+				e.calls = append(e.calls, dep)
+				called[name] = true
+				if !containsString(model.dependencies[svc.Name], name) {
+					model.dependencies[svc.Name] = append(model.dependencies[svc.Name], name)
+				}
+			}
+
+			s.endpoints = append(s.endpoints, e)
+			s.weights = append(s.weights, weightOrDefault(ep.Weight))
+		}
+	}
+
+	if err := checkTopologyCycles(model.services); err != nil {
+		return nil, err
+	}
+
+	// Корни трассировок: явно отмеченные сервисы, иначе сервисы, которые никто не вызывает
+	for i, svc := range topology.Services {
+		if svc.Entrypoint {
+			model.entrypoints = append(model.entrypoints, model.services[i])
+		}
+	}
+	if len(model.entrypoints) == 0 {
+		for _, s := range model.services {
+			if !called[s.name] {
+				model.entrypoints = append(model.entrypoints, s)
+			}
+		}
+	}
+
+	return model, nil
+}
+
+// checkTopologyCycles проверяет, что граф вызовов не содержит циклов
+func checkTopologyCycles(services []*topologyService) error {
+	const (
+		visiting = 1
+		done     = 2
+	)
+	state := make(map[*topologyService]int)
+
+	var visit func(s *topologyService, path []string) error
+	visit = func(s *topologyService, path []string) error {
+		path = append(path, s.name)
+		switch state[s] {
+		case visiting:
+			return fmt.Errorf("цикл в зависимостях сервисов: %s", strings.Join(path, " → "))
+		case done:
+			return nil
+		}
+
+		state[s] = visiting
+		for _, e := range s.endpoints {
+			for _, dep := range e.calls {
+				if err := visit(dep, path); err != nil {
+					return err
+				}
+			}
+		}
+		state[s] = done
+		return nil
+	}
+
+	for _, s := range services {
+		if err := visit(s, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func weightOrDefault(weight float64) float64 {
+	if weight == 0 {
+		return 1
+	}
+	return weight
+}
+
+func validateLatency(l models.TopologyLatency) error {
+	if l.Min < 0 || l.Max < 0 || l.Mean < 0 || l.StdDev < 0 {
+		return fmt.Errorf("параметры задержки не могут быть отрицательными")
+	}
+	if l.Max > 0 && l.Min > l.Max {
+		return fmt.Errorf("min_ms больше max_ms")
+	}
+
+	switch l.Distribution {
+	case "", "uniform", "constant", "normal", "lognormal", "exponential":
+		return nil
+	default:
+		return fmt.Errorf("неизвестное распределение задержки: %s", l.Distribution)
+	}
+}
+
+func normalizeErrorMode(mode models.TopologyError) (models.TopologyError, error) {
+	if mode.Rate < 0 || mode.Rate > 1 {
+		return mode, fmt.Errorf("rate ошибки должен быть от 0 до 1")
+	}
+
+	mode.Level = strings.ToUpper(mode.Level)
+	switch mode.Level {
+	case "":
+		mode.Level = "ERROR"
+	case "ERROR", "WARN", "FATAL":
+	default:
+		return mode, fmt.Errorf("неизвестный уровень ошибки: %s", mode.Level)
+	}
+
+	if mode.Status == 0 {
+		mode.Status = http.StatusInternalServerError
+	}
+	if mode.Error == "" && mode.Level != "WARN" {
+		mode.Error = http.StatusText(mode.Status)
+	}
+	if mode.LatencyFactor <= 0 {
+		mode.LatencyFactor = 1
+	}
+	return mode, nil
+}
+
+// pickWeighted выбирает индекс по весам
+func pickWeighted(rnd *rand.Rand, weights []float64) int {
+	total := 0.0
+	for _, w := range weights {
+		total += w
+	}
+
+	x := rnd.Float64() * total
+	for i, w := range weights {
+		if x < w {
+			return i
+		}
+		x -= w
+	}
+	return len(weights) - 1
+}
+
+// pickService выбирает сервис для независимого лога
+func (t *topologyModel) pickService(rnd *rand.Rand) *topologyService {
+	return t.services[pickWeighted(rnd, t.weights)]
+}
+
+// pickEntrypoint выбирает корневой сервис трассировки
+func (t *topologyModel) pickEntrypoint(rnd *rand.Rand) *topologyService {
+	return t.entrypoints[rnd.Intn(len(t.entrypoints))]
+}
+
+// fillLog заполняет запись запросом к случайному эндпоинту сервиса и возвращает эндпоинт
+func (s *topologyService) fillLog(rnd *rand.Rand, logEntry models.LogEntry, scenario string) (models.LogEntry, *topologyEndpoint) {
+	e := s.endpoints[pickWeighted(rnd, s.weights)]
+
+	logEntry.Service = s.name
+	logEntry.Method = e.method
+	logEntry.Path = e.path
+	logEntry.Level = "INFO"
+	logEntry.Status = http.StatusOK
+	logEntry.Message = e.message
+	if logEntry.Message == "" {
+		logEntry.Message = fmt.Sprintf("%s %s completed", e.method, e.path)
+	}
+
+	latency := sampleLatency(rnd, e.latency)
+
+	// Один бросок на все режимы ошибок: их вероятности складываются
+	x := rnd.Float64()
+	for _, mode := range e.errors {
+		if x >= mode.Rate {
+			x -= mode.Rate
+			continue
+		}
+		logEntry.Level = mode.Level
+		logEntry.Status = mode.Status
+		logEntry.Error = mode.Error
+		logEntry.Message = mode.Message
+		if logEntry.Message == "" {
+			logEntry.Message = fmt.Sprintf("%s %s failed", e.method, e.path)
+		}
+		latency *= mode.LatencyFactor
+		break
+	}
+	logEntry.Duration = int64(math.Round(latency))
+
+	// Сценарий нагрузки применяется поверх поведения эндпоинта
+	switch scenario {
+	case "black_friday":
+		logEntry = applyBlackFridayScenario(rnd, logEntry)
+	case "high_load":
+		logEntry = applyHighLoadScenario(rnd, logEntry)
+	case "payment_issues":
+		logEntry = applyPaymentIssuesScenario(rnd, logEntry)
+	}
+	if logEntry.Level == "ERROR" && logEntry.Status < 400 {
+		logEntry.Status = http.StatusInternalServerError
+		if logEntry.Error == "" {
+			logEntry.Error = http.StatusText(logEntry.Status)
+		}
+	}
+
+	return logEntry, e
+}
+
+// sampleLatency возвращает время отклика в миллисекундах по распределению эндпоинта
+func sampleLatency(rnd *rand.Rand, l models.TopologyLatency) float64 {
+	var v float64
+	switch l.Distribution {
+	case "constant":
+		v = l.Mean
+	case "normal":
+		v = l.Mean + rnd.NormFloat64()*l.StdDev
+	case "lognormal":
+		// Параметры логнормального распределения по среднему и отклонению
+		if l.Mean > 0 {
+			sigma2 := math.Log(1 + (l.StdDev*l.StdDev)/(l.Mean*l.Mean))
+			mu := math.Log(l.Mean) - sigma2/2
+			v = math.Exp(mu + rnd.NormFloat64()*math.Sqrt(sigma2))
+		}
+	case "exponential":
+		v = rnd.ExpFloat64() * l.Mean
+	default:
+		// uniform; без параметров - 10-100 мс, как у встроенных сервисов
+		lo, hi := l.Min, l.Max
+		if hi == 0 {
+			lo, hi = 10, 100
+		}
+		v = lo + rnd.Float64()*(hi-lo)
+	}
+
+	if v < l.Min {
+		v = l.Min
+	}
+	if l.Max > 0 && v > l.Max {
+		v = l.Max
+	}
+	if v < 1 {
+		v = 1
+	}
+	return v
+}
@@ -0,0 +1,251 @@
+package generator
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"log-metrics-simulator/models"
+)
+
+const testTopologyYAML = `
+name: shop
+services:
+  - name: gateway
+    entrypoint: true
+    endpoints:
+      - method: post
+        path: /checkout
+        latency: {distribution: constant, mean_ms: 20}
+      - path: /health
+        weight: 0.1
+        calls: []
+  - name: orders
+    weight: 2
+    dependencies: [payments]
+    errors:
+      - rate: 0.1
+        status: 503
+  - name: payments
+    endpoints:
+      - path: /charge
+        latency: {distribution: uniform, min_ms: 50, max_ms: 80}
+        errors:
+          - {rate: 0.2, level: warn, message: slow bank, latency_factor: 3}
+`
+
+const testTopologyJSON = `{
+  "name": "shop",
+  "services": [
+    {"name": "gateway", "entrypoint": true, "endpoints": [
+      {"method": "post", "path": "/checkout", "latency": {"distribution": "constant", "mean_ms": 20}},
+      {"path": "/health", "weight": 0.1, "calls": []}
+    ]},
+    {"name": "orders", "weight": 2, "dependencies": ["payments"], "errors": [{"rate": 0.1, "status": 503}]},
+    {"name": "payments", "endpoints": [
+      {"path": "/charge", "latency": {"distribution": "uniform", "min_ms": 50, "max_ms": 80},
+       "errors": [{"rate": 0.2, "level": "warn", "message": "slow bank", "latency_factor": 3}]}
+    ]}
+  ]
+}`
+
+// useTopology делает топологию активной на время теста
+func useTopology(t *testing.T, topology models.Topology) {
+	t.Helper()
+	if err := LoadTopology(topology); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(ResetTopology)
+}
+
+func parseTestTopology(t *testing.T, data string) models.Topology {
+	t.Helper()
+	topology, err := ParseTopology([]byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return topology
+}
+
+func TestParseTopologyYAMLAndJSON(t *testing.T) {
+	fromYAML := parseTestTopology(t, testTopologyYAML)
+	fromJSON := parseTestTopology(t, testTopologyJSON)
+	if !reflect.DeepEqual(fromYAML, fromJSON) {
+		t.Fatalf("YAML и JSON разобраны по-разному:\n%+v\n%+v", fromYAML, fromJSON)
+	}
+
+	gateway := fromYAML.Services[0]
+	if !gateway.Entrypoint || len(gateway.Endpoints) != 2 || gateway.Endpoints[0].Latency.Mean != 20 {
+		t.Errorf("gateway %+v", gateway)
+	}
+	// Пустой список вызовов отличается от отсутствующего: эндпоинт ничего не вызывает
+	if calls := gateway.Endpoints[1].Calls; calls == nil || len(*calls) != 0 {
+		t.Errorf("calls эндпоинта /health %v, ожидался пустой список", calls)
+	}
+	if gateway.Endpoints[0].Calls != nil {
+		t.Error("calls эндпоинта /checkout задан, хотя отсутствует")
+	}
+	if mode := fromYAML.Services[2].Endpoints[0].Errors[0]; mode.Rate != 0.2 || mode.LatencyFactor != 3 || mode.Level != "warn" {
+		t.Errorf("режим ошибки %+v", mode)
+	}
+
+	if _, err := ParseTopology([]byte("services: {name: [")); err == nil {
+		t.Error("неверный YAML разобран без ошибки")
+	}
+}
+
+func TestCompileTopology(t *testing.T) {
+	topology := parseTestTopology(t, testTopologyYAML)
+	// Без явных корней ими становятся сервисы, которые никто не вызывает
+	topology.Services[0].Entrypoint = false
+	topology.Services[0].Dependencies = []string{"orders"}
+	model, err := compileTopology(topology)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if model.name != "shop" || !reflect.DeepEqual(model.weights, []float64{1, 2, 1}) {
+		t.Errorf("модель %s, веса %v", model.name, model.weights)
+	}
+	if len(model.entrypoints) != 1 || model.entrypoints[0].name != "gateway" {
+		t.Errorf("корни %v, ожидался gateway", model.entrypoints)
+	}
+	wantDeps := map[string][]string{"gateway": {"orders"}, "orders": {"payments"}, "payments": nil}
+	if !reflect.DeepEqual(model.dependencies, wantDeps) {
+		t.Errorf("граф вызовов %v, ожидалось %v", model.dependencies, wantDeps)
+	}
+
+	gateway, orders, payments := model.services[0], model.services[1], model.services[2]
+	if checkout := gateway.endpoints[0]; checkout.method != "POST" || len(checkout.calls) != 1 || checkout.calls[0] != orders {
+		t.Errorf("эндпоинт /checkout %+v", checkout)
+	}
+	if health := gateway.endpoints[1]; len(health.calls) != 0 || gateway.weights[1] != 0.1 {
+		t.Errorf("эндпоинт /health вызывает %d сервисов, вес %v", len(health.calls), gateway.weights[1])
+	}
+
+	// Сервис без эндпоинтов получает GET / и ошибки по умолчанию
+	if len(orders.endpoints) != 1 || orders.endpoints[0].method != "GET" || orders.endpoints[0].path != "/" {
+		t.Errorf("эндпоинты orders %+v", orders.endpoints)
+	}
+	wantMode := models.TopologyError{Rate: 0.1, Level: "ERROR", Status: 503, Error: "Service Unavailable", LatencyFactor: 1}
+	if modes := orders.endpoints[0].errors; len(modes) != 1 || modes[0] != wantMode {
+		t.Errorf("режимы ошибок orders %+v, ожидалось %+v", modes, wantMode)
+	}
+	// Предупреждение без текста ошибки остается без него
+	if mode := payments.endpoints[0].errors[0]; mode.Level != "WARN" || mode.Status != 500 || mode.Error != "" {
+		t.Errorf("режим ошибки payments %+v", mode)
+	}
+}
+
+func TestCompileTopologyErrors(t *testing.T) {
+	service := func(name string, deps ...string) models.TopologyService {
+		return models.TopologyService{Name: name, Dependencies: deps}
+	}
+	endpoint := func(ep models.TopologyEndpoint) models.TopologyService {
+		if ep.Path == "" && ep.Method == "" {
+			ep.Path = "/"
+		}
+		return models.TopologyService{Name: "a", Endpoints: []models.TopologyEndpoint{ep}}
+	}
+	calls := []string{"b"}
+
+	for _, tc := range []struct {
+		name     string
+		services []models.TopologyService
+		want     string
+	}{
+		{"нет сервисов", nil, "хотя бы один сервис"},
+		{"без имени", []models.TopologyService{{}}, "не задано имя"},
+		{"дубликат", []models.TopologyService{service("a"), service("a")}, "описан дважды"},
+		{"отрицательный вес", []models.TopologyService{{Name: "a", Weight: -1}}, "weight не может быть отрицательным"},
+		{"неизвестная зависимость", []models.TopologyService{service("a", "missing")}, "вызывает неизвестный сервис missing"},
+		{"неизвестный вызов эндпоинта", []models.TopologyService{{Name: "a", Endpoints: []models.TopologyEndpoint{{Path: "/", Calls: &calls}}}}, "неизвестный сервис b"},
+		{"цикл", []models.TopologyService{service("a", "b"), service("b", "c"), service("c", "a")}, "цикл в зависимостях сервисов: a → b → c → a"},
+		{"вызов самого себя", []models.TopologyService{service("a", "a")}, "цикл в зависимостях сервисов: a → a"},
+		{"эндпоинт без пути", []models.TopologyService{endpoint(models.TopologyEndpoint{Method: "GET"})}, "не задан path"},
+		{"отрицательный вес эндпоинта", []models.TopologyService{endpoint(models.TopologyEndpoint{Weight: -1})}, "weight не может быть отрицательным"},
+		{"неизвестное распределение", []models.TopologyService{endpoint(models.TopologyEndpoint{Latency: models.TopologyLatency{Distribution: "pareto"}})}, "неизвестное распределение"},
+		{"min больше max", []models.TopologyService{endpoint(models.TopologyEndpoint{Latency: models.TopologyLatency{Min: 10, Max: 5}})}, "min_ms больше max_ms"},
+		{"отрицательная задержка", []models.TopologyService{endpoint(models.TopologyEndpoint{Latency: models.TopologyLatency{Mean: -1}})}, "не могут быть отрицательными"},
+		{"rate больше 1", []models.TopologyService{endpoint(models.TopologyEndpoint{Errors: []models.TopologyError{{Rate: 1.5}}})}, "rate ошибки"},
+		{"сумма rate больше 1", []models.TopologyService{{
+			Name:      "a",
+			Endpoints: []models.TopologyEndpoint{{Path: "/", Errors: []models.TopologyError{{Rate: 0.6}}}},
+			Errors:    []models.TopologyError{{Rate: 0.6}},
+		}}, "суммарная вероятность ошибок больше 1"},
+		{"неизвестный уровень", []models.TopologyService{endpoint(models.TopologyEndpoint{Errors: []models.TopologyError{{Rate: 0.1, Level: "debug"}}})}, "неизвестный уровень ошибки: DEBUG"},
+	} {
+		_, err := compileTopology(models.Topology{Services: tc.services})
+		if err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("%s: ошибка %v, ожидалась с %q", tc.name, err, tc.want)
+		}
+	}
+
+	// Ошибка проверки не меняет активную топологию
+	if err := LoadTopology(models.Topology{Services: []models.TopologyService{service("a", "a")}}); err == nil {
+		t.Error("топология с циклом загружена")
+	}
+	if GetTopology() != nil {
+		t.Error("после ошибки загрузки активна пользовательская топология")
+	}
+}
+
+func TestTopologyTraces(t *testing.T) {
+	useRegistry(t)
+	topology := parseTestTopology(t, testTopologyYAML)
+	topology.Services[0].Dependencies = []string{"orders"}
+	useTopology(t, topology)
+	if got := GetTopology(); got == nil || got.Name != "shop" {
+		t.Fatalf("активная топология %+v", got)
+	}
+
+	seed := int64(3)
+	r := newRun(Options{Seed: &seed})
+	callers := map[string]string{"orders": "gateway", "payments": "orders"}
+	for n := 0; n < 200; n++ {
+		trace := generateTrace(r, "normal_load")
+		root := trace[0]
+		if root.Service != "gateway" || root.ParentSpanID != "" {
+			t.Fatalf("корень трассировки %+v", root)
+		}
+		// /health ничего не вызывает, /checkout вызывает orders
+		if root.Path == "/health" && len(trace) != 1 {
+			t.Fatalf("эндпоинт /health вызвал %d сервисов", len(trace)-1)
+		}
+
+		services := map[string]string{root.SpanID: root.Service}
+		for _, entry := range trace[1:] {
+			if caller := services[entry.ParentSpanID]; caller != callers[entry.Service] {
+				t.Fatalf("%s вызван из %q, ожидался %s", entry.Service, caller, callers[entry.Service])
+			}
+			services[entry.SpanID] = entry.Service
+			if entry.Service == "payments" && entry.Path != "/charge" {
+				t.Fatalf("вызов payments на %s", entry.Path)
+			}
+		}
+		if root.Path == "/checkout" && root.Level == "INFO" && root.Duration < 20 {
+			t.Fatalf("длительность корня %dмс меньше собственной задержки", root.Duration)
+		}
+	}
+}
+
+func TestSampleLatency(t *testing.T) {
+	seed := int64(1)
+	r := newRun(Options{Seed: &seed})
+	for _, tc := range []struct {
+		latency  models.TopologyLatency
+		min, max float64
+	}{
+		{models.TopologyLatency{Distribution: "constant", Mean: 42}, 42, 42},
+		{models.TopologyLatency{}, 10, 100},
+		{models.TopologyLatency{Min: 50, Max: 80}, 50, 80},
+		{models.TopologyLatency{Distribution: "normal", Mean: 100, StdDev: 500, Min: 20, Max: 300}, 20, 300},
+		{models.TopologyLatency{Distribution: "exponential", Mean: 0.1}, 1, 1},
+	} {
+		for i := 0; i < 1000; i++ {
+			if v := sampleLatency(r.rnd, tc.latency); v < tc.min || v > tc.max {
+				t.Fatalf("%+v: задержка %v вне [%v, %v]", tc.latency, v, tc.min, tc.max)
+			}
+		}
+	}
+}
@@ -0,0 +1,283 @@
+package generator
+
+import (
+	"log"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"log-metrics-simulator/models"
+	"log-metrics-simulator/storage"
+)
+
+// GetTrace возвращает логи-спаны трассировки из хранимых логов
+func GetTrace(traceID string) []models.LogEntry {
+	spans, err := currentLogStore().Query(storage.LogQuery{TraceID: traceID})
+	if err != nil {
+		log.Printf("❌ Ошибка чтения логов трассировки %s: %v", traceID, err)
+	}
+	return spans
+}
+
+// GetRecentTraces возвращает последние limit трассировок, начиная с самой новой.
+// Учитываются только трассировки из нескольких спанов; их логи хранятся подряд.
+func GetRecentTraces(limit int) [][]models.LogEntry {
+	var traces [][]models.LogEntry
+	var spans []models.LogEntry // Спаны текущей трассировки, от новых к старым
+	flush := func() {
+		if len(spans) > 1 && spans[0].TraceID != "" {
+			slices.Reverse(spans)
+			traces = append(traces, spans)
+		}
+		spans = nil
+	}
+
+	err := currentLogStore().Range(func(entry models.LogEntry) bool {
+		if len(spans) > 0 && entry.TraceID != spans[0].TraceID {
+			flush()
+			if len(traces) >= limit {
+				return false
+			}
+		}
+		spans = append(spans, entry)
+		return true
+	})
+	if err != nil {
+		log.Printf("❌ Ошибка чтения логов: %v", err)
+	}
+	if len(traces) < limit {
+		flush()
+	}
+	return traces
+}
+
+// ===== Jaeger JSON (формат ответа Jaeger Query API, принимается импортом Jaeger UI) =====
+
+type JaegerResponse struct {
+	Data []JaegerTrace `json:"data"`
+}
+
+type JaegerTrace struct {
+	TraceID   string                   `json:"traceID"`
+	Spans     []JaegerSpan             `json:"spans"`
+	Processes map[string]JaegerProcess `json:"processes"`
+}
+
+type JaegerSpan struct {
+	TraceID       string            `json:"traceID"`
+	SpanID        string            `json:"spanID"`
+	OperationName string            `json:"operationName"`
+	References    []JaegerReference `json:"references"`
+	StartTime     int64             `json:"startTime"` // Микросекунды
+	Duration      int64             `json:"duration"`  // Микросекунды
+	Tags          []JaegerKeyValue  `json:"tags"`
+	Logs          []JaegerLog       `json:"logs"`
+	ProcessID     string            `json:"processID"`
+}
+
+type JaegerReference struct {
+	RefType string `json:"refType"`
+	TraceID string `json:"traceID"`
+	SpanID  string `json:"spanID"`
+}
+
+type JaegerKeyValue struct {
+	Key   string      `json:"key"`
+	Type  string      `json:"type"` // string, bool, int64
+	Value interface{} `json:"value"`
+}
+
+type JaegerLog struct {
+	Timestamp int64            `json:"timestamp"`
+	Fields    []JaegerKeyValue `json:"fields"`
+}
+
+type JaegerProcess struct {
+	ServiceName string           `json:"serviceName"`
+	Tags        []JaegerKeyValue `json:"tags"`
+}
+
+// ToJaeger переводит трассировки в формат Jaeger. Лог спана становится событием спана.
+func ToJaeger(traces [][]models.LogEntry) JaegerResponse {
+	resp := JaegerResponse{Data: make([]JaegerTrace, 0, len(traces))}
+	for _, spans := range traces {
+		if len(spans) == 0 {
+			continue
+		}
+
+		trace := JaegerTrace{
+			TraceID:   padID(spans[0].TraceID, 32),
+			Processes: make(map[string]JaegerProcess),
+		}
+		processIDs := make(map[string]string)
+		for _, entry := range spans {
+			processID, ok := processIDs[entry.Service]
+			if !ok {
+				processID = "p" + strconv.Itoa(len(processIDs)+1)
+				processIDs[entry.Service] = processID
+				trace.Processes[processID] = JaegerProcess{ServiceName: entry.Service, Tags: []JaegerKeyValue{}}
+			}
+
+			start, end := spanBounds(entry)
+			span := JaegerSpan{
+				TraceID:       trace.TraceID,
+				SpanID:        padID(entry.SpanID, 16),
+				OperationName: spanName(entry),
+				References:    []JaegerReference{},
+				StartTime:     start.UnixMicro(),
+				Duration:      end.Sub(start).Microseconds(),
+				Tags:          jaegerTags(entry),
+				Logs: []JaegerLog{{
+					Timestamp: end.UnixMicro(),
+					Fields: []JaegerKeyValue{
+						{Key: "event", Type: "string", Value: entry.Message},
+						{Key: "level", Type: "string", Value: entry.Level},
+					},
+				}},
+				ProcessID: processID,
+			}
+			if entry.ParentSpanID != "" {
+				span.References = append(span.References, JaegerReference{
+					RefType: "CHILD_OF",
+					TraceID: trace.TraceID,
+					SpanID:  padID(entry.ParentSpanID, 16),
+				})
+			}
+			trace.Spans = append(trace.Spans, span)
+		}
+		resp.Data = append(resp.Data, trace)
+	}
+	return resp
+}
+
+func jaegerTags(entry models.LogEntry) []JaegerKeyValue {
+	tags := []JaegerKeyValue{{Key: "span.kind", Type: "string", Value: "server"}}
+	if entry.Method != "" {
+		tags = append(tags, JaegerKeyValue{Key: "http.method", Type: "string", Value: entry.Method})
+	}
+	if entry.Path != "" {
+		tags = append(tags, JaegerKeyValue{Key: "http.target", Type: "string", Value: entry.Path})
+	}
+	if entry.Status != 0 {
+		tags = append(tags, JaegerKeyValue{Key: "http.status_code", Type: "int64", Value: entry.Status})
+	}
+	if entry.UserID != "" {
+		tags = append(tags, JaegerKeyValue{Key: "user.id", Type: "string", Value: entry.UserID})
+	}
+	if isErrorSpan(entry) {
+		tags = append(tags, JaegerKeyValue{Key: "error", Type: "bool", Value: true})
+		if entry.Error != "" {
+			tags = append(tags, JaegerKeyValue{Key: "error.message", Type: "string", Value: entry.Error})
+		}
+	}
+	return tags
+}
+
+// ===== Zipkin JSON v2 =====
+
+type ZipkinSpan struct {
+	TraceID        string             `json:"traceId"`
+	ID             string             `json:"id"`
+	ParentID       string             `json:"parentId,omitempty"`
+	Name           string             `json:"name"`
+	Kind           string             `json:"kind"`
+	Timestamp      int64              `json:"timestamp"` // Микросекунды
+	Duration       int64              `json:"duration"`  // Микросекунды
+	LocalEndpoint  ZipkinEndpoint     `json:"localEndpoint"`
+	RemoteEndpoint *ZipkinEndpoint    `json:"remoteEndpoint,omitempty"`
+	Annotations    []ZipkinAnnotation `json:"annotations,omitempty"`
+	Tags           map[string]string  `json:"tags,omitempty"`
+}
+
+type ZipkinEndpoint struct {
+	ServiceName string `json:"serviceName,omitempty"`
+	IPv4        string `json:"ipv4,omitempty"`
+}
+
+type ZipkinAnnotation struct {
+	Timestamp int64  `json:"timestamp"`
+	Value     string `json:"value"`
+}
+
+// ToZipkin переводит трассировки в плоский список спанов Zipkin v2
+// (формат POST /api/v2/spans). Лог спана становится аннотацией.
+func ToZipkin(traces [][]models.LogEntry) []ZipkinSpan {
+	result := []ZipkinSpan{}
+	for _, spans := range traces {
+		for _, entry := range spans {
+			start, end := spanBounds(entry)
+			span := ZipkinSpan{
+				TraceID:       padID(entry.TraceID, 32),
+				ID:            padID(entry.SpanID, 16),
+				Name:          strings.ToLower(spanName(entry)),
+				Kind:          "SERVER",
+				Timestamp:     start.UnixMicro(),
+				Duration:      end.Sub(start).Microseconds(),
+				LocalEndpoint: ZipkinEndpoint{ServiceName: entry.Service},
+				Annotations:   []ZipkinAnnotation{{Timestamp: end.UnixMicro(), Value: entry.Message}},
+				Tags:          zipkinTags(entry),
+			}
+			if entry.ParentSpanID != "" {
+				span.ParentID = padID(entry.ParentSpanID, 16)
+			} else if entry.IP != "" {
+				// Клиент виден только корневому спану
+				span.RemoteEndpoint = &ZipkinEndpoint{IPv4: entry.IP}
+			}
+			result = append(result, span)
+		}
+	}
+	return result
+}
+
+func zipkinTags(entry models.LogEntry) map[string]string {
+	tags := map[string]string{"level": entry.Level}
+	if entry.Method != "" {
+		tags["http.method"] = entry.Method
+	}
+	if entry.Path != "" {
+		tags["http.path"] = entry.Path
+	}
+	if entry.Status != 0 {
+		tags["http.status_code"] = strconv.Itoa(entry.Status)
+	}
+	if entry.UserID != "" {
+		tags["user.id"] = entry.UserID
+	}
+	if isErrorSpan(entry) {
+		// В Zipkin значение тега error - описание ошибки
+		tags["error"] = entry.Error
+		if tags["error"] == "" {
+			tags["error"] = entry.Message
+		}
+	}
+	return tags
+}
+
+// ===== Общие функции =====
+
+// spanBounds восстанавливает начало спана: лог пишется в момент его завершения
+func spanBounds(entry models.LogEntry) (time.Time, time.Time) {
+	end := entry.Timestamp
+	return end.Add(-time.Duration(entry.Duration) * time.Millisecond), end
+}
+
+// spanName - имя операции без строки запроса, чтобы не плодить уникальные имена
+func spanName(entry models.LogEntry) string {
+	if path, _, _ := strings.Cut(entry.Path, "?"); entry.Method != "" && path != "" {
+		return entry.Method + " " + path
+	}
+	return entry.Service
+}
+
+func isErrorSpan(entry models.LogEntry) bool {
+	return entry.Level == "ERROR" || entry.Level == "FATAL" || entry.Status >= 500
+}
+
+// padID дополняет hex-идентификатор нулями слева до нужной длины
+func padID(id string, size int) string {
+	if len(id) >= size {
+		return id
+	}
+	return strings.Repeat("0", size-len(id)) + id
+}
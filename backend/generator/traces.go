@@ -0,0 +1,248 @@
+package generator
+
+import (
+	"fmt"
+	"math/rand"
+	"time"
+
+	"log-metrics-simulator/models"
+)
+
+// callNode - вызов сервиса в графе обработки запроса. Дочерние вызовы
+// выполняются последовательно; после ошибки дочернего вызова остальные
+// не выполняются, а ошибка поднимается к вызывающему сервису.
+type callNode struct {
+	service  string
+	children []callNode
+	topology *topologyService // Сервис пользовательской топологии; вызовы определяет его эндпоинт
+}
+
+func call(service string, children ...callNode) callNode {
+	return callNode{service: service, children: children}
+}
+
+// traceFlow - сценарий запроса пользователя, проходящего через граф сервисов
+// встроенной топологии
+type traceFlow struct {
+	method string
+	path   string
+	root   callNode
+}
+
+// Потоки запросов интернет-магазина. Корень всегда api-gateway.
+var traceFlows = []traceFlow{
+	{
+		// Оформление заказа: api-gateway → auth-service → cart-service → inventory-service → payment-service
+		method: "POST",
+		path:   "/api/v1/checkout",
+		root: call("api-gateway",
+			call("auth-service"),
+			call("cart-service",
+				call("inventory-service",
+					call("payment-service"),
+				),
+			),
+		),
+	},
+	{
+		method: "GET",
+		path:   "/api/v1/products",
+		root: call("api-gateway",
+			call("product-service",
+				call("inventory-service"),
+			),
+			call("recommendation-service"),
+		),
+	},
+	{
+		method: "GET",
+		path:   "/api/v1/search",
+		root: call("api-gateway",
+			call("search-service",
+				call("product-service"),
+			),
+		),
+	},
+	{
+		method: "POST",
+		path:   "/api/v1/orders",
+		root: call("api-gateway",
+			call("auth-service"),
+			call("order-service",
+				call("inventory-service"),
+				call("payment-service"),
+				call("notification-service"),
+			),
+		),
+	},
+}
+
+// traceBuilder собирает логи одной трассировки
+type traceBuilder struct {
+	run      *run
+	rnd      *rand.Rand
+	scenario string
+	base     models.LogEntry // Общие поля запроса: trace_id, пользователь, сессия, клиент
+	logs     []models.LogEntry
+}
+
+// generateTrace моделирует один запрос, проходящий через граф сервисов.
+// Каждый вызов сервиса - спан с собственным span_id и parent_span_id
+// вызывающего сервиса; на каждый спан приходится один лог с общим trace_id.
+// Логи идут в порядке обхода дерева: родитель всегда раньше дочерних.
+func generateTrace(r *run, scenario string) []models.LogEntry {
+	rnd := r.rnd
+	var flow traceFlow
+	if r.topology != nil {
+		root := r.topology.pickEntrypoint(rnd)
+		flow.root = callNode{service: root.name, topology: root}
+	} else {
+		flow = traceFlows[rnd.Intn(len(traceFlows))]
+	}
+
+	b := &traceBuilder{
+		run:      r,
+		rnd:      rnd,
+		scenario: scenario,
+		base: models.LogEntry{
+			TraceID:   generateTraceID(rnd),
+			UserID:    fmt.Sprintf("user-%d", rnd.Intn(50000)+1),
+			SessionID: generateSessionID(rnd),
+			IP:        ipAddresses[rnd.Intn(len(ipAddresses))],
+			UserAgent: userAgents[rnd.Intn(len(userAgents))],
+		},
+	}
+
+	start := r.timestamp()
+	idx, _ := b.span(flow.root, "", start)
+
+	// Во встроенной топологии путь запроса задает поток, а не случайный эндпоинт шлюза
+	if flow.path != "" {
+		root := &b.logs[idx]
+		root.Method = flow.method
+		root.Path = flow.path
+		if root.Level == "INFO" {
+			root.Message = fmt.Sprintf("Request routed to %s", flow.root.children[0].service)
+		}
+	}
+	return b.logs
+}
+
+// span добавляет лог вызова и рекурсивно - логи дочерних вызовов.
+// Возвращает индекс лога вызова и время его завершения.
+func (b *traceBuilder) span(node callNode, parentSpanID string, start time.Time) (int, time.Time) {
+	rnd := b.rnd
+
+	entry := b.base
+	entry.SpanID = generateSpanID(rnd)
+	entry.ParentSpanID = parentSpanID
+
+	children := node.children
+	if node.topology != nil {
+		var endpoint *topologyEndpoint
+		entry, endpoint = node.topology.fillLog(rnd, entry, b.scenario)
+		children = make([]callNode, len(endpoint.calls))
+		for i, dep := range endpoint.calls {
+			children[i] = callNode{service: dep.name, topology: dep}
+		}
+	} else {
+		level := b.run.logLevel()
+		entry.Level = level
+		entry.Service = node.service
+		entry = applyServiceLog(rnd, entry, b.scenario, level)
+	}
+
+	entry = b.run.applyProfile(entry)
+
+	// Отказ самого сервиса. Недоступный сервис не отвечает вовсе, это учитывает
+	// вызывающий; у корня запись пишет балансировщик.
+	if effect, ok := b.run.faults[entry.Service]; ok && (effect.fault.Type != "unavailable" || parentSpanID == "") && effect.hit(rnd) {
+		entry = applyFault(rnd, entry, effect)
+	}
+
+	idx := len(b.logs)
+	b.logs = append(b.logs, entry)
+
+	// Сервис, завершившийся ошибкой сам, не вызывает дочерние сервисы
+	if entry.Level == "ERROR" || len(children) == 0 {
+		end := start.Add(time.Duration(entry.Duration) * time.Millisecond)
+		b.logs[idx].Timestamp = end
+		return idx, end
+	}
+
+	// Собственное время сервиса делится поровну до первого вызова и после последнего
+	own := time.Duration(entry.Duration) * time.Millisecond
+	cursor := start.Add(own / 2)
+	var failure *callFailure
+	for _, child := range children {
+		var attempts int
+		failure, attempts, cursor = b.call(entry, child, cursor)
+		if failure != nil {
+			break
+		}
+		if attempts > 1 && entry.Level == "INFO" {
+			entry.Level = "WARN"
+			entry.Message = fmt.Sprintf("Call to %s succeeded after %d attempts", child.service, attempts)
+		}
+	}
+	end := cursor.Add(own - own/2)
+
+	entry.Timestamp = end
+	entry.Duration = end.Sub(start).Milliseconds()
+
+	// Неудачный вызов зависимости поднимается вверх по дереву
+	if failure != nil {
+		entry = failure.apply(entry)
+	}
+
+	b.logs[idx] = entry
+	return idx, end
+}
+
+// call моделирует вызов дочернего сервиса с повторами и circuit breaker.
+// Возвращает неудачу вызова (nil - успех), число попыток и время завершения вызова.
+func (b *traceBuilder) call(caller models.LogEntry, child callNode, start time.Time) (*callFailure, int, time.Time) {
+	rnd := b.rnd
+	edge := callEdge{caller: caller.Service, callee: child.service}
+	breaker := b.run.breaker(edge)
+	if !breaker.allow() {
+		return &callFailure{kind: "circuit_open", callee: child.service, attempts: 1}, 1, start.Add(time.Millisecond)
+	}
+
+	cursor := start
+	for attempt := 1; ; attempt++ {
+		cursor = cursor.Add(time.Duration(rnd.Intn(3)+1) * time.Millisecond) // Сетевая задержка
+		failure, end := b.attempt(caller.SpanID, child, cursor)
+		// Ответы 4xx - ошибка клиента, circuit breaker их не учитывает
+		breaker.record(failure == nil || !failure.retryable())
+		if failure == nil {
+			return nil, attempt, end
+		}
+
+		failure.attempts = attempt
+		if attempt > maxCallRetries || !failure.retryable() || breaker.open {
+			return failure, attempt, end
+		}
+		b.run.retries[edge]++
+		cursor = end.Add(time.Duration(50<<(attempt-1)) * time.Millisecond) // Экспоненциальная пауза перед повтором
+	}
+}
+
+// attempt выполняет одну попытку вызова: недоступный сервис отвечает отказом
+// в соединении, медленный - таймаутом у вызывающего
+func (b *traceBuilder) attempt(parentSpanID string, child callNode, start time.Time) (*callFailure, time.Time) {
+	if effect, ok := b.run.faults[child.service]; ok && effect.fault.Type == "unavailable" && effect.hit(b.rnd) {
+		return &callFailure{kind: "refused", callee: child.service}, start.Add(time.Duration(b.rnd.Intn(3)+1) * time.Millisecond)
+	}
+
+	idx, end := b.span(child, parentSpanID, start)
+	entry := b.logs[idx]
+	switch {
+	case entry.Duration > upstreamTimeout:
+		// Дочерний сервис продолжает обработку, но вызывающий уже не ждет
+		return &callFailure{kind: "timeout", callee: child.service}, start.Add(upstreamTimeout * time.Millisecond)
+	case entry.Level == "ERROR":
+		return &callFailure{kind: "error", callee: child.service, status: entry.Status, err: entry.Error}, end
+	}
+	return nil, end
+}
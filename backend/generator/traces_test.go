@@ -0,0 +1,279 @@
+package generator
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"log-metrics-simulator/models"
+)
+
+// flowEdges возвращает вызовы между сервисами встроенных потоков запросов
+func flowEdges() map[callEdge]bool {
+	edges := make(map[callEdge]bool)
+	var walk func(node callNode)
+	walk = func(node callNode) {
+		for _, child := range node.children {
+			edges[callEdge{caller: node.service, callee: child.service}] = true
+			walk(child)
+		}
+	}
+	for _, flow := range traceFlows {
+		walk(flow.root)
+	}
+	return edges
+}
+
+func TestTraceSpansConsistent(t *testing.T) {
+	useRegistry(t)
+	seed := int64(11)
+	r := newRun(Options{Seed: &seed})
+	edges := flowEdges()
+
+	failed := 0
+	for n := 0; n < 300; n++ {
+		trace := generateTrace(r, "black_friday")
+		if trace[0].Level == "ERROR" {
+			failed++
+		}
+		spans := make(map[string]models.LogEntry, len(trace))
+		lastChild := make(map[string]map[string]models.LogEntry) // Родитель -> сервис -> последняя попытка
+
+		for i, entry := range trace {
+			if entry.TraceID != trace[0].TraceID || entry.UserID != trace[0].UserID || entry.SessionID != trace[0].SessionID {
+				t.Fatalf("трассировка %d, спан %d: общие поля запроса различаются", n, i)
+			}
+			if _, ok := spans[entry.SpanID]; ok {
+				t.Fatalf("трассировка %d: повторный span_id %s", n, entry.SpanID)
+			}
+			if len(entry.TraceID) != 32 || len(entry.SpanID) != 16 {
+				t.Fatalf("трассировка %d: идентификаторы %q/%q не той длины", n, entry.TraceID, entry.SpanID)
+			}
+
+			if i == 0 {
+				if entry.ParentSpanID != "" || entry.Service != "api-gateway" || entry.Method == "" || entry.Path == "" {
+					t.Fatalf("трассировка %d: корень %+v", n, entry)
+				}
+				spans[entry.SpanID] = entry
+				continue
+			}
+			// Родитель всегда раньше дочерних
+			parent, ok := spans[entry.ParentSpanID]
+			if !ok {
+				t.Fatalf("трассировка %d, спан %d: родитель %s не найден среди предыдущих", n, i, entry.ParentSpanID)
+			}
+			if !edges[callEdge{caller: parent.Service, callee: entry.Service}] {
+				t.Fatalf("трассировка %d: вызов %s -> %s не из потока запросов", n, parent.Service, entry.Service)
+			}
+
+			start, end := spanBounds(entry)
+			parentStart, parentEnd := spanBounds(parent)
+			if start.Before(parentStart) {
+				t.Fatalf("трассировка %d: %s начался (%v) раньше родителя %s (%v)", n, entry.Service, start, parent.Service, parentStart)
+			}
+			// Дочерний сервис после таймаута продолжает работу, вызывающий - нет
+			if entry.Duration <= upstreamTimeout && end.After(parentEnd) {
+				t.Fatalf("трассировка %d: %s завершился (%v) позже родителя %s (%v)", n, entry.Service, end, parent.Service, parentEnd)
+			}
+
+			spans[entry.SpanID] = entry
+			if lastChild[entry.ParentSpanID] == nil {
+				lastChild[entry.ParentSpanID] = make(map[string]models.LogEntry)
+			}
+			lastChild[entry.ParentSpanID][entry.Service] = entry
+		}
+
+		// Неудачный вызов зависимости поднимается к вызывающему
+		for parentID, children := range lastChild {
+			parent := spans[parentID]
+			if parent.Level == "ERROR" {
+				continue
+			}
+			for service, child := range children {
+				if child.Level == "ERROR" || child.Duration > upstreamTimeout {
+					t.Fatalf("трассировка %d: %s завершился успешно (%s), хотя вызов %s не удался", n, parent.Service, parent.Level, service)
+				}
+			}
+		}
+	}
+	if failed == 0 {
+		t.Error("ни один запрос не завершился ошибкой: распространение ошибок не проверено")
+	}
+}
+
+func TestTracesTruncatedToLogCount(t *testing.T) {
+	useRegistry(t)
+	seed := int64(5)
+	logs := generateWith(newRun(Options{Seed: &seed}), 101, "normal_load", true)
+	if len(logs) != 101 {
+		t.Fatalf("логов %d, ожидалось 101", len(logs))
+	}
+	// У обрезанной последней трассировки родитель каждого спана есть
+	seen := make(map[string]bool)
+	for _, entry := range logs {
+		if entry.ParentSpanID != "" && !seen[entry.ParentSpanID] {
+			t.Fatalf("спан %s без родителя %s", entry.SpanID, entry.ParentSpanID)
+		}
+		seen[entry.SpanID] = true
+	}
+}
+
+// testTrace - трассировка из корня и дочернего спана с ошибкой
+func testTrace() []models.LogEntry {
+	end := time.Date(2024, 1, 1, 12, 0, 1, 0, time.UTC)
+	return []models.LogEntry{
+		{
+			Timestamp: end, Level: "ERROR", Service: "api-gateway", Message: "Request to payment-service failed",
+			Method: "POST", Path: "/api/v1/checkout?step=2", Status: 502, Duration: 500,
+			TraceID: "abc", SpanID: "1", UserID: "user-1", IP: "10.0.0.1",
+			Error: "upstream payment-service: declined",
+		},
+		{
+			Timestamp: end.Add(-100 * time.Millisecond), Level: "ERROR", Service: "payment-service", Message: "Payment declined",
+			Status: 500, Duration: 200, TraceID: "abc", SpanID: "2", ParentSpanID: "1",
+		},
+	}
+}
+
+func TestToJaeger(t *testing.T) {
+	resp := ToJaeger([][]models.LogEntry{testTrace(), nil})
+	if len(resp.Data) != 1 {
+		t.Fatalf("трассировок %d, ожидалась 1: пустая пропускается", len(resp.Data))
+	}
+	trace := resp.Data[0]
+	if trace.TraceID != "00000000000000000000000000000abc" || len(trace.Spans) != 2 {
+		t.Fatalf("трассировка %s из %d спанов", trace.TraceID, len(trace.Spans))
+	}
+	want := map[string]JaegerProcess{
+		"p1": {ServiceName: "api-gateway", Tags: []JaegerKeyValue{}},
+		"p2": {ServiceName: "payment-service", Tags: []JaegerKeyValue{}},
+	}
+	if !reflect.DeepEqual(trace.Processes, want) {
+		t.Errorf("процессы %+v", trace.Processes)
+	}
+
+	root, child := trace.Spans[0], trace.Spans[1]
+	if root.OperationName != "POST /api/v1/checkout" || root.SpanID != "0000000000000001" || len(root.References) != 0 {
+		t.Errorf("корневой спан %+v", root)
+	}
+	end := testTrace()[0].Timestamp
+	if root.StartTime != end.Add(-500*time.Millisecond).UnixMicro() || root.Duration != 500000 {
+		t.Errorf("корневой спан: начало %d, длительность %d", root.StartTime, root.Duration)
+	}
+	if root.Logs[0].Timestamp != end.UnixMicro() || root.Logs[0].Fields[0].Value != testTrace()[0].Message {
+		t.Errorf("событие корневого спана %+v", root.Logs[0])
+	}
+	wantRef := JaegerReference{RefType: "CHILD_OF", TraceID: trace.TraceID, SpanID: "0000000000000001"}
+	if len(child.References) != 1 || child.References[0] != wantRef || child.ProcessID != "p2" {
+		t.Errorf("дочерний спан %+v", child)
+	}
+	if child.OperationName != "payment-service" {
+		t.Errorf("имя операции без пути %q", child.OperationName)
+	}
+
+	tags := make(map[string]interface{})
+	for _, tag := range root.Tags {
+		tags[tag.Key] = tag.Value
+	}
+	for key, value := range map[string]interface{}{
+		"span.kind":        "server",
+		"http.method":      "POST",
+		"http.target":      "/api/v1/checkout?step=2",
+		"http.status_code": 502,
+		"error":            true,
+		"error.message":    "upstream payment-service: declined",
+	} {
+		if tags[key] != value {
+			t.Errorf("тег %s = %v, ожидалось %v", key, tags[key], value)
+		}
+	}
+
+	// Пустые массивы кодируются как [], а не null: импорт Jaeger UI их требует
+	data, err := json.Marshal(ToJaeger(nil))
+	if err != nil || string(data) != `{"data":[]}` {
+		t.Errorf("пустой ответ %s: %v", data, err)
+	}
+	if data, _ := json.Marshal(child); strings.Contains(string(data), "null") {
+		t.Errorf("спан содержит null: %s", data)
+	}
+}
+
+func TestToZipkin(t *testing.T) {
+	spans := ToZipkin([][]models.LogEntry{testTrace()})
+	if len(spans) != 2 {
+		t.Fatalf("спанов %d, ожидалось 2", len(spans))
+	}
+	root, child := spans[0], spans[1]
+	if root.TraceID != "00000000000000000000000000000abc" || root.ID != "0000000000000001" || root.ParentID != "" {
+		t.Errorf("идентификаторы корневого спана %+v", root)
+	}
+	if root.Name != "post /api/v1/checkout" || root.Kind != "SERVER" || root.LocalEndpoint.ServiceName != "api-gateway" {
+		t.Errorf("корневой спан %+v", root)
+	}
+	// Клиент виден только корневому спану
+	if root.RemoteEndpoint == nil || root.RemoteEndpoint.IPv4 != "10.0.0.1" || child.RemoteEndpoint != nil {
+		t.Errorf("remoteEndpoint корня %+v, дочернего %+v", root.RemoteEndpoint, child.RemoteEndpoint)
+	}
+	end := testTrace()[0].Timestamp
+	if root.Timestamp != end.Add(-500*time.Millisecond).UnixMicro() || root.Duration != 500000 {
+		t.Errorf("корневой спан: начало %d, длительность %d", root.Timestamp, root.Duration)
+	}
+	if child.ParentID != "0000000000000001" {
+		t.Errorf("parentId %q", child.ParentID)
+	}
+
+	wantRoot := map[string]string{
+		"level":            "ERROR",
+		"http.method":      "POST",
+		"http.path":        "/api/v1/checkout?step=2",
+		"http.status_code": "502",
+		"user.id":          "user-1",
+		"error":            "upstream payment-service: declined",
+	}
+	if !reflect.DeepEqual(root.Tags, wantRoot) {
+		t.Errorf("теги корня %v, ожидалось %v", root.Tags, wantRoot)
+	}
+	// Без текста ошибки значение тега error - сообщение лога
+	if child.Tags["error"] != "Payment declined" {
+		t.Errorf("тег error дочернего спана %q", child.Tags["error"])
+	}
+
+	if data, err := json.Marshal(ToZipkin(nil)); err != nil || string(data) != "[]" {
+		t.Errorf("пустой список %s: %v", data, err)
+	}
+}
+
+func TestGetRecentTraces(t *testing.T) {
+	useLogStore(t)
+	first := testTrace()
+	second := testTrace()
+	for i := range second {
+		second[i].TraceID = "def"
+	}
+	single := models.LogEntry{Service: "cart-service", TraceID: "solo", SpanID: "9"}
+	untraced := models.LogEntry{Service: "cart-service"}
+	var logs []models.LogEntry
+	logs = append(logs, first...)
+	logs = append(logs, single, untraced, untraced)
+	logs = append(logs, second...)
+	if err := currentLogStore().Append(logs); err != nil {
+		t.Fatal(err)
+	}
+
+	// Трассировки из одного спана и логи без trace_id пропускаются
+	traces := GetRecentTraces(5)
+	if len(traces) != 2 || traces[0][0].TraceID != "def" || traces[1][0].TraceID != "abc" {
+		t.Fatalf("трассировки %v", traces)
+	}
+	if traces[0][0].SpanID != "1" || traces[0][1].SpanID != "2" {
+		t.Errorf("спаны не в порядке записи: %v", traces[0])
+	}
+	if traces := GetRecentTraces(1); len(traces) != 1 || traces[0][0].TraceID != "def" {
+		t.Errorf("с limit 1: %v", traces)
+	}
+	if spans := GetTrace("abc"); len(spans) != 2 {
+		t.Errorf("спанов трассировки abc %d, ожидалось 2", len(spans))
+	}
+}
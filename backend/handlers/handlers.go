@@ -1,26 +1,38 @@
 package handlers
 
 import (
+	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
 
 	"log-metrics-simulator/generator"
 	"log-metrics-simulator/models"
+	"log-metrics-simulator/remotewrite"
 	"log-metrics-simulator/scenarios"
+	"log-metrics-simulator/sinks"
+	"log-metrics-simulator/storage"
 )
 
 var (
 	scenarioManager *scenarios.ScenarioManager
+	remoteWriter    *remotewrite.Sender
 )
 
 func SetScenarioManager(sm *scenarios.ScenarioManager) {
 	scenarioManager = sm
 }
 
+func SetRemoteWriter(s *remotewrite.Sender) {
+	remoteWriter = s
+}
+
 func GenerateLogsAndMetrics(c *gin.Context) {
 	var req models.GenerateRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -33,7 +45,18 @@ func GenerateLogsAndMetrics(c *gin.Context) {
 		return
 	}
 
-	logs := generator.GenerateLogs(req.LogCount, req.Scenario)
+	profile, err := generator.ParseProfile(req.Config)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Неверный config: " + err.Error()})
+		return
+	}
+
+	logs := generator.GenerateLogsWithOptions(req.LogCount, req.Scenario, generator.Options{
+		Seed:    req.Seed,
+		Traces:  req.Traces,
+		Profile: profile,
+		Workers: req.Workers,
+	})
 
 	// Защита от потенциально пустого результата на случай будущих изменений генератора
 	var sample any = nil
@@ -61,6 +84,13 @@ func GetMetrics(c *gin.Context) {
 		return
 	}
 
+	// Согласование формата: OpenMetrics по заголовку Accept или параметру format
+	if format == "openmetrics" || strings.Contains(c.GetHeader("Accept"), "application/openmetrics-text") {
+		c.Header("Content-Type", generator.OpenMetricsContentType)
+		c.String(http.StatusOK, generator.GetMetricsOpenMetrics())
+		return
+	}
+
 	metricsText := generator.GetMetricsPrometheus()
 	c.Header("Content-Type", "text/plain; version=0.0.4")
 	c.String(http.StatusOK, metricsText)
@@ -70,6 +100,7 @@ func GetLogs(c *gin.Context) {
 	limitStr := c.Query("limit")
 	service := c.Query("service")
 	level := c.Query("level")
+	traceID := c.Query("trace_id")
 	format := c.Query("format")
 
 	limit := 100
@@ -79,7 +110,27 @@ func GetLogs(c *gin.Context) {
 		}
 	}
 
-	logs := generator.GetLogs(limit, service, level)
+	status := 0
+	if statusStr := c.Query("status"); statusStr != "" {
+		s, err := strconv.Atoi(statusStr)
+		if err != nil || s <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Неверный статус: " + statusStr})
+			return
+		}
+		status = s
+	}
+
+	logs, err := generator.GetLogs(storage.LogQuery{
+		Limit:   limit,
+		Service: service,
+		Level:   level,
+		Status:  status,
+		TraceID: traceID,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ошибка чтения логов: " + err.Error()})
+		return
+	}
 
 	if format == "text" {
 		logsText := generator.FormatLogsAsText(logs)
@@ -92,9 +143,11 @@ func GetLogs(c *gin.Context) {
 		"logs":  logs,
 		"count": len(logs),
 		"filters": gin.H{
-			"service": service,
-			"level":   level,
-			"limit":   limit,
+			"service":  service,
+			"level":    level,
+			"status":   status,
+			"trace_id": traceID,
+			"limit":    limit,
 		},
 	})
 }
@@ -104,11 +157,24 @@ func StartScenario(c *gin.Context) {
 		Type   string                 `json:"type" binding:"required"`
 		Config map[string]interface{} `json:"config,omitempty"`
 	}
+	// Seed читается отдельно как json.Number: через float64 большие seed теряют точность
+	var seedReq struct {
+		Config struct {
+			Seed json.Number `json:"seed"`
+		} `json:"config"`
+	}
 
-	if err := c.ShouldBindJSON(&req); err != nil {
+	if err := c.ShouldBindBodyWith(&req, binding.JSON); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Неверный запрос: " + err.Error()})
 		return
 	}
+	if err := c.ShouldBindBodyWith(&seedReq, binding.JSON); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Неверный seed: " + err.Error()})
+		return
+	}
+	if seed := seedReq.Config.Seed; seed != "" {
+		req.Config["seed"] = seed
+	}
 
 	if scenarioManager == nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Scenario manager not initialized"})
@@ -190,6 +256,7 @@ func CreateSchedule(c *gin.Context) {
 		CronExpr     string     `json:"cron_expr" binding:"required"`
 		Timezone     string     `json:"timezone,omitempty"`
 		Enabled      bool       `json:"enabled"`
+		TimeScale    float64    `json:"time_scale,omitempty"`
 		StartDate    *time.Time `json:"start_date,omitempty"`
 		EndDate      *time.Time `json:"end_date,omitempty"`
 	}
@@ -204,6 +271,7 @@ func CreateSchedule(c *gin.Context) {
 		ScenarioType: req.ScenarioType,
 		CronExpr:     req.CronExpr,
 		Enabled:      req.Enabled,
+		TimeScale:    req.TimeScale,
 		StartDate:    req.StartDate,
 		EndDate:      req.EndDate,
 	}
@@ -375,6 +443,285 @@ func GetLogStatistics(c *gin.Context) {
 	})
 }
 
+// ===== Приемники логов =====
+
+func ListSinks(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"status": "success",
+		"sinks":  sinks.ListGlobal(),
+		"types":  sinks.Types(),
+	})
+}
+
+func CreateSink(c *gin.Context) {
+	var req models.SinkConfig
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Неверный запрос: " + err.Error()})
+		return
+	}
+
+	if req.Name == "" {
+		req.Name = req.Type
+	}
+
+	if err := sinks.AddGlobal(req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":  "success",
+		"message": "Приемник подключен",
+		"sink":    sinks.Redact(req),
+	})
+}
+
+func DeleteSink(c *gin.Context) {
+	name := c.Param("name")
+
+	if err := sinks.RemoveGlobal(name); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":  "success",
+		"message": "Приемник отключен",
+	})
+}
+
+// ===== Топология сервисов =====
+
+func GetTopology(c *gin.Context) {
+	topology := generator.GetTopology()
+	c.JSON(http.StatusOK, gin.H{
+		"status":   "success",
+		"builtin":  topology == nil,
+		"topology": topology,
+	})
+}
+
+// LoadTopology принимает топологию в YAML или JSON и применяет ее к новым запускам генерации
+func LoadTopology(c *gin.Context) {
+	body, err := c.GetRawData()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Неверный запрос: " + err.Error()})
+		return
+	}
+
+	topology, err := generator.ParseTopology(body)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := generator.LoadTopology(topology); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":   "success",
+		"message":  "Топология загружена",
+		"services": len(topology.Services),
+	})
+}
+
+func ResetTopology(c *gin.Context) {
+	generator.ResetTopology()
+	c.JSON(http.StatusOK, gin.H{
+		"status":  "success",
+		"message": "Восстановлена встроенная топология",
+	})
+}
+
+// ===== Внедрение отказов =====
+
+func ListFaults(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"status": "success",
+		"faults": generator.ListFaults(),
+	})
+}
+
+// CreateFault внедряет отказ в сервис; он распространяется на вызывающие сервисы
+func CreateFault(c *gin.Context) {
+	var req models.Fault
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Неверный запрос: " + err.Error()})
+		return
+	}
+
+	fault, err := generator.AddFault(req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":  "success",
+		"message": "Отказ внедрен",
+		"fault":   fault,
+	})
+}
+
+func DeleteFault(c *gin.Context) {
+	if err := generator.RemoveFault(c.Param("id")); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":  "success",
+		"message": "Отказ снят",
+	})
+}
+
+// ===== Генерация с заданной скоростью =====
+
+// StartRate запускает поток событий с заданной скоростью в секунду
+func StartRate(c *gin.Context) {
+	var req models.RateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Неверный запрос: " + err.Error()})
+		return
+	}
+
+	status, err := generator.StartRate(req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":  "success",
+		"message": "Генерация запущена",
+		"rate":    status,
+	})
+}
+
+func GetRateStatus(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"status": "success",
+		"rate":   generator.GetRateStatus(),
+	})
+}
+
+func StopRate(c *gin.Context) {
+	status, err := generator.StopRate()
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":  "success",
+		"message": "Генерация остановлена",
+		"rate":    status,
+	})
+}
+
+// ===== Генерация истории =====
+
+// StartBackfill запускает генерацию прошлого периода по модельным часам
+func StartBackfill(c *gin.Context) {
+	var req models.BackfillJobRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Неверный запрос: " + err.Error()})
+		return
+	}
+	if err := scenarios.ResolveBackfill(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var metrics generator.MetricHistoryWriter
+	if req.RemoteWrite {
+		if remoteWriter == nil {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Remote-write не настроен (REMOTE_WRITE_URL)"})
+			return
+		}
+		metrics = remoteWriter.NewHistoryWriter()
+	}
+
+	job, err := generator.StartBackfill(req, metrics)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"status":  "success",
+		"message": "Генерация истории запущена",
+		"job":     job,
+	})
+}
+
+func ListBackfillJobs(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"status": "success",
+		"jobs":   generator.ListBackfillJobs(),
+	})
+}
+
+func GetBackfillJob(c *gin.Context) {
+	job, err := generator.GetBackfillJob(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "success",
+		"job":    job,
+	})
+}
+
+func CancelBackfill(c *gin.Context) {
+	job, err := generator.CancelBackfill(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":  "success",
+		"message": "Генерация истории будет остановлена после текущего шага",
+		"job":     job,
+	})
+}
+
+// ===== Remote-write =====
+
+func BackfillRemoteWrite(c *gin.Context) {
+	if remoteWriter == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Remote-write не настроен (REMOTE_WRITE_URL)"})
+		return
+	}
+
+	var req remotewrite.BackfillRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Неверный запрос: " + err.Error()})
+		return
+	}
+	jobReq, err := req.JobRequest()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	job, err := generator.StartBackfill(jobReq, remoteWriter.NewHistoryWriter())
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"status":  "success",
+		"message": "Догрузка истории метрик запущена",
+		"job":     job,
+	})
+}
+
 // ===== Цепочки сценариев =====
 
 func ListChains(c *gin.Context) {
@@ -395,6 +742,7 @@ func CreateChain(c *gin.Context) {
 		Name        string             `json:"name" binding:"required"`
 		Description string             `json:"description"`
 		Steps       []models.ChainStep `json:"steps" binding:"required"`
+		TimeScale   float64            `json:"time_scale,omitempty"`
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -421,6 +769,7 @@ func CreateChain(c *gin.Context) {
 		Description: req.Description,
 		Steps:       req.Steps,
 		Status:      "pending",
+		TimeScale:   req.TimeScale,
 		CreatedAt:   time.Now(),
 	}
 
@@ -460,7 +809,18 @@ func GetChain(c *gin.Context) {
 func StartChain(c *gin.Context) {
 	chainID := c.Param("id")
 
-	if err := scenarioManager.StartChain(chainID); err != nil {
+	// Ускорение времени выполнения, например ?time_scale=60
+	var timeScale float64
+	if raw := c.Query("time_scale"); raw != "" {
+		parsed, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Неверный time_scale: " + raw})
+			return
+		}
+		timeScale = parsed
+	}
+
+	if err := scenarioManager.StartChain(chainID, timeScale); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
@@ -529,6 +889,7 @@ func CreateChainSchedule(c *gin.Context) {
 		ChainName string     `json:"chain_name" binding:"required"`
 		CronExpr  string     `json:"cron_expr" binding:"required"`
 		Enabled   bool       `json:"enabled"`
+		TimeScale float64    `json:"time_scale,omitempty"`
 		StartDate *time.Time `json:"start_date,omitempty"`
 		EndDate   *time.Time `json:"end_date,omitempty"`
 	}
@@ -543,6 +904,7 @@ func CreateChainSchedule(c *gin.Context) {
 		ChainName: req.ChainName,
 		CronExpr:  req.CronExpr,
 		Enabled:   req.Enabled,
+		TimeScale: req.TimeScale,
 		StartDate: req.StartDate,
 		EndDate:   req.EndDate,
 	}
@@ -671,6 +1033,27 @@ func DeleteChainSchedule(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"status": "success"})
 }
 
+// StreamStorageEvents передает изменения общего хранилища как Server-Sent Events:
+// имя события - тип записи (schedule, chain, chain_schedule, ...), данные - уведомление
+func StreamStorageEvents(c *gin.Context) {
+	events, err := scenarioManager.SubscribeChanges(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusNotImplemented, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Header("Cache-Control", "no-cache")
+	c.Header("X-Accel-Buffering", "no")
+	c.Stream(func(w io.Writer) bool {
+		event, ok := <-events
+		if !ok {
+			return false
+		}
+		c.SSEvent(event.Entity, event)
+		return true
+	})
+}
+
 func GetActiveChainExecutions(c *gin.Context) {
 	// Получаем все активные выполнения цепочек
 	// Этот метод нужно будет добавить в ScenarioManager
@@ -681,3 +1064,35 @@ func GetActiveChainExecutions(c *gin.Context) {
 		"count":      0,
 	})
 }
+
+// GetTrace возвращает трассировку по trace_id в формате Jaeger (по умолчанию) или Zipkin
+func GetTrace(c *gin.Context) {
+	spans := generator.GetTrace(c.Param("id"))
+	if len(spans) == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Трассировка не найдена"})
+		return
+	}
+
+	writeTraces(c, [][]models.LogEntry{spans})
+}
+
+// ListTraces возвращает последние трассировки в формате Jaeger (по умолчанию) или Zipkin
+func ListTraces(c *gin.Context) {
+	limit := 20
+	if l, err := strconv.Atoi(c.Query("limit")); err == nil && l > 0 {
+		limit = l
+	}
+
+	writeTraces(c, generator.GetRecentTraces(limit))
+}
+
+func writeTraces(c *gin.Context, traces [][]models.LogEntry) {
+	switch c.DefaultQuery("format", "jaeger") {
+	case "jaeger":
+		c.JSON(http.StatusOK, generator.ToJaeger(traces))
+	case "zipkin":
+		c.JSON(http.StatusOK, generator.ToZipkin(traces))
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "format должен быть jaeger или zipkin"})
+	}
+}
@@ -1,12 +1,21 @@
 package main
 
 import (
+	"encoding/json"
 	"log"
 	"os"
 	"strconv"
+	"strings"
+	"time"
 
+	"log-metrics-simulator/clock"
+	"log-metrics-simulator/generator"
 	"log-metrics-simulator/handlers"
+	"log-metrics-simulator/models"
+	"log-metrics-simulator/otlp"
+	"log-metrics-simulator/remotewrite"
 	"log-metrics-simulator/scenarios"
+	"log-metrics-simulator/sinks"
 	"log-metrics-simulator/storage"
 
 	"github.com/gin-gonic/gin"
@@ -23,15 +32,166 @@ func main() {
 		gin.SetMode(gin.ReleaseMode)
 	}
 
-	// Инициализация хранилища
-	storage := storage.NewMemoryStorage()
+	// Файл конфигурации: переменные окружения переопределяют его значения
+	fileCfg, err := loadFileConfig(getEnv("CONFIG_FILE", "config.yaml"))
+	if err != nil {
+		log.Fatal("Ошибка чтения конфигурации:", err)
+	}
+	storageCfg := fileCfg.Storage
+
+	// Настройка метрик распределения времени отклика
+	metricsConfig, err := generator.ParseMetricsConfig(
+		getEnv("METRICS_DURATION_BUCKETS", ""),
+		getEnv("METRICS_SUMMARY_QUANTILES", ""),
+	)
+	if err != nil {
+		log.Fatal("Ошибка конфигурации метрик:", err)
+	}
+	if err := generator.ConfigureMetrics(metricsConfig); err != nil {
+		log.Fatal("Ошибка конфигурации метрик:", err)
+	}
+
+	// Пользовательская топология сервисов (YAML или JSON)
+	if path := getEnv("TOPOLOGY_FILE", ""); path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			log.Fatal("Ошибка чтения топологии:", err)
+		}
+		topology, err := generator.ParseTopology(data)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if err := generator.LoadTopology(topology); err != nil {
+			log.Fatal("Ошибка топологии:", err)
+		}
+		log.Printf("🗺️ Загружена топология %s: %d сервисов", path, len(topology.Services))
+	}
+
+	// Приемники логов: stdout по умолчанию и дополнительные из LOG_SINKS (JSON-массив)
+	if getEnv("LOG_STDOUT", "true") == "true" {
+		if err := sinks.AddGlobal(models.SinkConfig{Type: "stdout"}); err != nil {
+			log.Fatal("Ошибка настройки приемника stdout:", err)
+		}
+	}
+	if raw := getEnv("LOG_SINKS", ""); raw != "" {
+		var sinkConfigs []models.SinkConfig
+		if err := json.Unmarshal([]byte(raw), &sinkConfigs); err != nil {
+			log.Fatal("Ошибка разбора LOG_SINKS:", err)
+		}
+		for _, cfg := range sinkConfigs {
+			if err := sinks.AddGlobal(cfg); err != nil {
+				log.Fatal("Ошибка настройки приемника:", err)
+			}
+		}
+	}
+
+	// Экспорт OTLP/HTTP: логи и трассировки через приемник, метрики - периодически
+	if endpoint := getEnv("OTEL_EXPORTER_OTLP_ENDPOINT", ""); endpoint != "" {
+		otlpConfig := otlpClientConfig(endpoint)
+
+		err := sinks.AddGlobal(models.SinkConfig{
+			Type: "otlp",
+			Options: map[string]interface{}{
+				"endpoint":            otlpConfig.Endpoint,
+				"encoding":            otlpConfig.Encoding,
+				"headers":             otlpConfig.Headers,
+				"resource_attributes": otlpConfig.ResourceAttributes,
+			},
+		})
+		if err != nil {
+			log.Fatal("Ошибка настройки приемника OTLP:", err)
+		}
+
+		client, err := otlp.NewClient(otlpConfig)
+		if err != nil {
+			log.Fatal("Ошибка настройки экспорта OTLP:", err)
+		}
+		exporter := otlp.NewMetricExporter(client, getEnvDuration("OTLP_METRICS_INTERVAL", 15*time.Second), generator.GetMetricFamilies)
+		exporter.Start()
+		defer exporter.Stop()
+	}
+	defer sinks.CloseGlobal()
+
+	// Хранилище сгенерированных логов: по умолчанию индексированный буфер в
+	// памяти; с LOG_STORE_PATH история логов переживает перезапуск и не
+	// ограничена объемом памяти, но выборка и статистика читают весь файл
+	logStore, err := storage.NewLogStore(storage.LogStoreConfig{
+		Path:      getEnv("LOG_STORE_PATH", storageCfg.LogsPath),
+		Capacity:  getEnvInt("LOG_STORE_CAPACITY", valueOr(storageCfg.MaxLogs, storage.DefaultLogCapacity)),
+		Retention: getEnvDuration("LOG_STORE_RETENTION", valueOr(storageCfg.LogsRetention, 0)),
+	})
+	if err != nil {
+		log.Fatal("Ошибка инициализации хранилища логов:", err)
+	}
+	generator.SetLogStore(logStore)
+	defer logStore.Close()
+
+	// Хранилище метрик: реестр ограничен METRIC_STORE_CAPACITY сериями и
+	// METRIC_STORE_RETENTION с последнего обновления; с METRIC_STORE_PATH
+	// счетчики и гистограммы /metrics переживают перезапуск
+	metricStore, err := storage.NewMetricStore(storage.MetricStoreConfig{
+		Path:      getEnv("METRIC_STORE_PATH", storageCfg.MetricsPath),
+		Capacity:  getEnvInt("METRIC_STORE_CAPACITY", valueOr(storageCfg.MaxMetricSeries, storage.DefaultMetricCapacity)),
+		Retention: getEnvDuration("METRIC_STORE_RETENTION", valueOr(storageCfg.MetricsRetention, 0)),
+	})
+	if err != nil {
+		log.Fatal("Ошибка инициализации хранилища метрик:", err)
+	}
+	defer metricStore.Close()
+	if err := generator.SetMetricStore(metricStore); err != nil {
+		log.Fatal("Ошибка восстановления метрик:", err)
+	}
+	defer func() {
+		if err := generator.StopMetricStore(); err != nil {
+			log.Printf("❌ Ошибка сохранения метрик: %v", err)
+		}
+	}()
+
+	// Инициализация хранилища: bolt, postgres и redis сохраняют расписания и цепочки между перезапусками
+	storage, err := storage.New(storage.Config{
+		Type:      getEnv("STORAGE_TYPE", stringOr(storageCfg.Type, "memory")),
+		Path:      getEnv("STORAGE_PATH", stringOr(storageCfg.Path, "data/simulator.db")),
+		DSN:       getEnv("STORAGE_DSN", storageCfg.DSN),
+		Prefix:    getEnv("STORAGE_PREFIX", stringOr(storageCfg.Prefix, "simulator")),
+		Retention: getEnvDuration("STORAGE_RETENTION", valueOr(storageCfg.Retention, 7*24*time.Hour)),
+	})
+	if err != nil {
+		log.Fatal("Ошибка инициализации хранилища:", err)
+	}
 
 	// Инициализация менеджера сценариев
-	scenarioManager := scenarios.NewScenarioManager(storage)
+	scenarioManager := scenarios.NewScenarioManager(storage, clock.New())
 	defer scenarioManager.Stop()
+	defer generator.StopRate()
 
 	handlers.SetScenarioManager(scenarioManager)
 
+	// Отправка метрик по протоколу Prometheus remote-write
+	if url := getEnv("REMOTE_WRITE_URL", ""); url != "" {
+		sender, err := remotewrite.New(remoteWriteConfig(url))
+		if err != nil {
+			log.Fatal("Ошибка настройки remote-write:", err)
+		}
+		sender.Start()
+		defer sender.Stop()
+		handlers.SetRemoteWriter(sender)
+
+		// Догрузка истории за период REMOTE_WRITE_BACKFILL (например, 336h)
+		if backfill := getEnvDuration("REMOTE_WRITE_BACKFILL", 0); backfill > 0 {
+			req := remotewrite.BackfillRequest{
+				From: time.Now().Add(-backfill),
+				Step: getEnv("REMOTE_WRITE_BACKFILL_STEP", "1m"),
+			}
+			jobReq, err := req.JobRequest()
+			if err != nil {
+				log.Fatal("Ошибка догрузки remote-write:", err)
+			}
+			if _, err := generator.StartBackfill(jobReq, sender.NewHistoryWriter()); err != nil {
+				log.Fatal("Ошибка догрузки remote-write:", err)
+			}
+		}
+	}
+
 	router := gin.Default()
 	router.Use(gin.Recovery())
 
@@ -63,6 +223,23 @@ func main() {
 		api.GET("/metrics", handlers.GetMetrics) // Дублируем для API
 		api.GET("/logs", handlers.GetLogs)
 		api.GET("/logs/stats", handlers.GetLogStatistics)
+		api.GET("/traces", handlers.ListTraces)
+		api.GET("/traces/:id", handlers.GetTrace)
+
+		// Поток событий с заданной скоростью
+		api.GET("/rate", handlers.GetRateStatus)
+		api.POST("/rate", handlers.StartRate)
+		api.DELETE("/rate", handlers.StopRate)
+
+		// Топология сервисов
+		api.GET("/topology", handlers.GetTopology)
+		api.PUT("/topology", handlers.LoadTopology)
+		api.DELETE("/topology", handlers.ResetTopology)
+
+		// Внедрение отказов в граф сервисов
+		api.GET("/faults", handlers.ListFaults)
+		api.POST("/faults", handlers.CreateFault)
+		api.DELETE("/faults/:id", handlers.DeleteFault)
 
 		// Управление сценариями
 		scenarios := api.Group("/scenarios")
@@ -72,6 +249,26 @@ func main() {
 			scenarios.GET("/list", handlers.ListScenarios)
 		}
 
+		// Догрузка истории метрик через remote-write
+		api.POST("/remote-write/backfill", handlers.BackfillRemoteWrite)
+
+		// Генерация истории за прошлый период по модельным часам
+		backfill := api.Group("/backfill")
+		{
+			backfill.POST("", handlers.StartBackfill)
+			backfill.GET("", handlers.ListBackfillJobs)
+			backfill.GET("/:id", handlers.GetBackfillJob)
+			backfill.POST("/:id/cancel", handlers.CancelBackfill)
+		}
+
+		// Приемники логов
+		sinkRoutes := api.Group("/sinks")
+		{
+			sinkRoutes.GET("", handlers.ListSinks)
+			sinkRoutes.POST("", handlers.CreateSink)
+			sinkRoutes.DELETE("/:name", handlers.DeleteSink)
+		}
+
 		// Управление расписаниями
 		schedules := api.Group("/schedules")
 		{
@@ -85,6 +282,9 @@ func main() {
 			schedules.GET("/cron/examples", handlers.GetCronExamples)
 		}
 
+		// Изменения расписаний и цепочек в общем хранилище (Server-Sent Events)
+		api.GET("/events", handlers.StreamStorageEvents)
+
 		// Цепочки сценариев и их расписания
 		chains := api.Group("/chains")
 		{
@@ -138,3 +338,69 @@ func getEnvInt(key string, defaultValue int) int {
 	}
 	return defaultValue
 }
+
+func getEnvDuration(key string, defaultValue time.Duration) time.Duration {
+	if value := os.Getenv(key); value != "" {
+		if d, err := time.ParseDuration(value); err == nil {
+			return d
+		}
+	}
+	return defaultValue
+}
+
+// remoteWriteConfig собирает настройки remote-write из переменных окружения
+func remoteWriteConfig(url string) remotewrite.Config {
+	cfg := remotewrite.Config{
+		URL:        url,
+		Interval:   getEnvDuration("REMOTE_WRITE_INTERVAL", 15*time.Second),
+		Timeout:    getEnvDuration("REMOTE_WRITE_TIMEOUT", 30*time.Second),
+		MaxRetries: getEnvInt("REMOTE_WRITE_MAX_RETRIES", 5),
+		MinBackoff: getEnvDuration("REMOTE_WRITE_MIN_BACKOFF", 500*time.Millisecond),
+		MaxBackoff: getEnvDuration("REMOTE_WRITE_MAX_BACKOFF", 30*time.Second),
+	}
+
+	// Заголовки и внешние метки задаются JSON-объектами
+	if raw := getEnv("REMOTE_WRITE_HEADERS", ""); raw != "" {
+		if err := json.Unmarshal([]byte(raw), &cfg.Headers); err != nil {
+			log.Fatal("Ошибка разбора REMOTE_WRITE_HEADERS:", err)
+		}
+	}
+	if raw := getEnv("REMOTE_WRITE_LABELS", ""); raw != "" {
+		if err := json.Unmarshal([]byte(raw), &cfg.ExternalLabels); err != nil {
+			log.Fatal("Ошибка разбора REMOTE_WRITE_LABELS:", err)
+		}
+	}
+	return cfg
+}
+
+// otlpClientConfig собирает настройки OTLP из стандартных переменных OpenTelemetry
+func otlpClientConfig(endpoint string) otlp.Config {
+	encoding := "protobuf"
+	if getEnv("OTEL_EXPORTER_OTLP_PROTOCOL", "http/protobuf") == "http/json" {
+		encoding = "json"
+	}
+
+	attributes := parseKeyValueList(getEnv("OTEL_RESOURCE_ATTRIBUTES", ""))
+	if _, ok := attributes["service.name"]; !ok {
+		attributes["service.name"] = getEnv("OTEL_SERVICE_NAME", "log-metrics-simulator")
+	}
+
+	return otlp.Config{
+		Endpoint:           endpoint,
+		Encoding:           encoding,
+		Headers:            parseKeyValueList(getEnv("OTEL_EXPORTER_OTLP_HEADERS", "")),
+		ResourceAttributes: attributes,
+	}
+}
+
+// parseKeyValueList разбирает список вида "k1=v1,k2=v2"
+func parseKeyValueList(value string) map[string]string {
+	result := make(map[string]string)
+	for _, pair := range strings.Split(value, ",") {
+		k, v, ok := strings.Cut(pair, "=")
+		if ok && strings.TrimSpace(k) != "" {
+			result[strings.TrimSpace(k)] = strings.TrimSpace(v)
+		}
+	}
+	return result
+}
@@ -4,22 +4,23 @@ import "time"
 
 // LogEntry представляет лог приложения интернет-магазина
 type LogEntry struct {
-	Timestamp time.Time `json:"timestamp"`
-	Level     string    `json:"level"`
-	Service   string    `json:"service"`
-	Message   string    `json:"message"`
-	TraceID   string    `json:"trace_id,omitempty"`
-	SpanID    string    `json:"span_id,omitempty"`
-	UserID    string    `json:"user_id,omitempty"`
-	SessionID string    `json:"session_id,omitempty"`
-	IP        string    `json:"ip,omitempty"`
-	UserAgent string    `json:"user_agent,omitempty"`
-	Method    string    `json:"method,omitempty"`
-	Path      string    `json:"path,omitempty"`
-	Status    int       `json:"status,omitempty"`
-	Duration  int64     `json:"duration_ms,omitempty"`
-	Error     string    `json:"error,omitempty"`
-	Stack     string    `json:"stack,omitempty"`
+	Timestamp    time.Time `json:"timestamp"`
+	Level        string    `json:"level"`
+	Service      string    `json:"service"`
+	Message      string    `json:"message"`
+	TraceID      string    `json:"trace_id,omitempty"`
+	SpanID       string    `json:"span_id,omitempty"`
+	ParentSpanID string    `json:"parent_span_id,omitempty"` // span_id вызывающего сервиса, пустой у корневого спана
+	UserID       string    `json:"user_id,omitempty"`
+	SessionID    string    `json:"session_id,omitempty"`
+	IP           string    `json:"ip,omitempty"`
+	UserAgent    string    `json:"user_agent,omitempty"`
+	Method       string    `json:"method,omitempty"`
+	Path         string    `json:"path,omitempty"`
+	Status       int       `json:"status,omitempty"`
+	Duration     int64     `json:"duration_ms,omitempty"`
+	Error        string    `json:"error,omitempty"`
+	Stack        string    `json:"stack,omitempty"`
 }
 
 // Metric представляет метрику приложения
@@ -31,6 +32,37 @@ type Metric struct {
 	Timestamp time.Time         `json:"timestamp"`
 }
 
+// MetricFamily представляет семейство метрик со всеми сериями
+type MetricFamily struct {
+	Name   string         `json:"name"`
+	Type   string         `json:"type"` // counter, gauge, histogram, summary
+	Help   string         `json:"help"`
+	Series []MetricSeries `json:"series"`
+}
+
+// MetricSeries представляет состояние одной серии семейства
+type MetricSeries struct {
+	Labels    map[string]string `json:"labels"`
+	Value     float64           `json:"value,omitempty"` // counter, gauge
+	Created   time.Time         `json:"created"`
+	Timestamp time.Time         `json:"timestamp"`
+
+	// Гистограмма: границы корзин без +Inf и некумулятивные счетчики (последний - +Inf)
+	Bounds       []float64 `json:"bounds,omitempty"`
+	BucketCounts []uint64  `json:"bucket_counts,omitempty"`
+	// Summary: значения квантилей; пусто, пока в окне нет наблюдений
+	Quantiles []QuantileValue `json:"quantiles,omitempty"`
+	// Гистограмма и summary
+	Sum   float64 `json:"sum,omitempty"`
+	Count uint64  `json:"count,omitempty"`
+}
+
+// QuantileValue - значение квантиля summary
+type QuantileValue struct {
+	Quantile float64 `json:"quantile"`
+	Value    float64 `json:"value"`
+}
+
 // ScenarioConfig представляет конфигурацию сценария
 type ScenarioConfig struct {
 	Name        string                 `json:"name"`
@@ -38,6 +70,76 @@ type ScenarioConfig struct {
 	LogCount    int                    `json:"log_count"`
 	Parameters  map[string]interface{} `json:"parameters"`
 	Labels      map[string]string
+	Seed        *int64             `json:"seed,omitempty"`       // Фиксированный seed для воспроизводимой генерации
+	Sinks       []SinkConfig       `json:"sinks,omitempty"`      // Приемники логов сценария (дополнительно к глобальным)
+	Traces      bool               `json:"traces,omitempty"`     // Генерировать связанные трассировки вместо независимых логов
+	Profile     *GenerationProfile `json:"profile,omitempty"`    // Профиль генерации из Parameters и пользовательской конфигурации
+	Shape       *TrafficShape      `json:"shape,omitempty"`      // Форма нагрузки; без нее логи распределяются равномерно
+	TimeScale   float64            `json:"time_scale,omitempty"` // Ускорение модельного времени, например 60
+}
+
+// GenerationProfile - параметры генерации логов сценария. Ключи JSON совпадают
+// с ключами ScenarioConfig.Parameters.
+type GenerationProfile struct {
+	Preset            string             `json:"preset,omitempty"`             // Поведение сервисов: normal_load, black_friday, high_load, payment_issues
+	ErrorRate         *float64           `json:"error_rate,omitempty"`         // Доля логов уровня ERROR, перекрывает вес ERROR в level_mix
+	LatencyMultiplier float64            `json:"latency_multiplier,omitempty"` // Множитель времени отклика
+	ResponseDelay     int64              `json:"response_delay,omitempty"`     // Добавочное время отклика, мс
+	LevelMix          map[string]float64 `json:"level_mix,omitempty"`          // Веса уровней INFO, WARN, ERROR, DEBUG
+	IntervalMs        int                `json:"interval_ms,omitempty"`        // Интервал между метками времени логов, мс
+}
+
+// TrafficShape задает скорость потока событий во времени: базовая скорость
+// умножается на множители всех компонентов
+type TrafficShape struct {
+	BaseRate   float64          `json:"base_rate,omitempty"` // Событий в секунду
+	Components []ShapeComponent `json:"components"`
+}
+
+// ShapeComponent - множитель скорости одного вида. Время отсчитывается от
+// начала генерации, суточный и недельный циклы - по часам пояса Timezone.
+type ShapeComponent struct {
+	Type string `json:"type"` // ramp, step, diurnal, weekly, bursts, flash_sale
+
+	// ramp: линейное изменение множителя от From до To
+	From            float64 `json:"from,omitempty"`
+	To              float64 `json:"to,omitempty"`
+	StartSeconds    int     `json:"start_seconds,omitempty"` // Также начало flash_sale
+	DurationSeconds int     `json:"duration_seconds,omitempty"`
+
+	// step: множитель последней наступившей ступени, до первой - 1
+	Steps []ShapeStep `json:"steps,omitempty"`
+
+	// diurnal: синусоида с периодом сутки и пиком в PeakHour
+	Amplitude float64 `json:"amplitude,omitempty"` // От 0 до 1
+	PeakHour  float64 `json:"peak_hour,omitempty"` // По умолчанию 14
+	Timezone  string  `json:"timezone,omitempty"`  // По умолчанию UTC
+
+	// weekly: множитель субботы и воскресенья
+	WeekendFactor float64 `json:"weekend_factor,omitempty"`
+
+	// bursts: всплески с пуассоновским потоком начал
+	BurstsPerHour float64 `json:"bursts_per_hour,omitempty"`
+	BurstFactor   float64 `json:"burst_factor,omitempty"`  // По умолчанию 5
+	BurstSeconds  int     `json:"burst_seconds,omitempty"` // По умолчанию 30
+
+	// flash_sale: рост до PeakFactor за RiseSeconds и экспоненциальный спад
+	PeakFactor   float64 `json:"peak_factor,omitempty"`
+	RiseSeconds  int     `json:"rise_seconds,omitempty"`
+	DecaySeconds int     `json:"decay_seconds,omitempty"` // Постоянная времени спада, по умолчанию 600
+}
+
+// ShapeStep - ступень множителя скорости
+type ShapeStep struct {
+	AtSeconds int     `json:"at_seconds"`
+	Factor    float64 `json:"factor"`
+}
+
+// SinkConfig описывает приемник сгенерированных логов
+type SinkConfig struct {
+	Type    string                 `json:"type" binding:"required"` // stdout, file, syslog, loki, elasticsearch, otlp
+	Name    string                 `json:"name,omitempty"`
+	Options map[string]interface{} `json:"options,omitempty"`
 }
 
 // Scenario представляет запущенный сценарий
@@ -50,6 +152,9 @@ type Scenario struct {
 	Interval  time.Duration  `json:"interval,omitempty"`
 	StartDate *time.Time     `json:"start_date,omitempty"`
 	EndDate   *time.Time     `json:"end_date,omitempty"`
+	// Модельное время в момент Started; при ускорении длительность, интервал
+	// и даты сценария отсчитываются в модельном времени
+	SimulatedStart *time.Time `json:"simulated_start,omitempty"`
 }
 
 // Schedule представляет расписание
@@ -59,6 +164,7 @@ type Schedule struct {
 	ScenarioType string     `json:"scenario_type"`
 	CronExpr     string     `json:"cron_expr"`
 	Enabled      bool       `json:"enabled"`
+	TimeScale    float64    `json:"time_scale,omitempty"` // Расписание в модельном времени, идущем от первого запуска в time_scale раз быстрее
 	StartDate    *time.Time `json:"start_date,omitempty"`
 	EndDate      *time.Time `json:"end_date,omitempty"`
 	LastRun      *time.Time `json:"last_run,omitempty"`
@@ -80,6 +186,7 @@ type ChainSchedule struct {
 	ChainName string     `json:"chain_name"`
 	CronExpr  string     `json:"cron_expr"`
 	Enabled   bool       `json:"enabled"`
+	TimeScale float64    `json:"time_scale,omitempty"` // Расписание в модельном времени, идущем от первого запуска в time_scale раз быстрее
 	StartDate *time.Time `json:"start_date,omitempty"`
 	EndDate   *time.Time `json:"end_date,omitempty"`
 	LastRun   *time.Time `json:"last_run,omitempty"`
@@ -92,6 +199,75 @@ type GenerateRequest struct {
 	LogCount int                    `json:"log_count" binding:"required"`
 	Scenario string                 `json:"scenario,omitempty"`
 	Config   map[string]interface{} `json:"config,omitempty"`
+	Seed     *int64                 `json:"seed,omitempty"`    // Фиксированный seed для воспроизводимой генерации
+	Traces   bool                   `json:"traces,omitempty"`  // Генерировать связанные трассировки вместо независимых логов
+	Workers  int                    `json:"workers,omitempty"` // Число параллельных генераторов
+}
+
+// RateRequest запускает генерацию потока событий с заданной скоростью
+type RateRequest struct {
+	EventsPerSecond float64                `json:"events_per_second"`
+	Workers         int                    `json:"workers,omitempty"`          // Число параллельных генераторов
+	DurationSeconds int                    `json:"duration_seconds,omitempty"` // 0 - до остановки
+	Scenario        string                 `json:"scenario,omitempty"`
+	Config          map[string]interface{} `json:"config,omitempty"` // Параметры профиля генерации
+	Labels          map[string]string      `json:"labels,omitempty"`
+	Sinks           []SinkConfig           `json:"sinks,omitempty"`
+	Seed            *int64                 `json:"seed,omitempty"`
+	Traces          bool                   `json:"traces,omitempty"`
+	Shape           *TrafficShape          `json:"shape,omitempty"` // Форма нагрузки; base_rate по умолчанию - events_per_second
+}
+
+// RateStatus - состояние генерации с заданной скоростью
+type RateStatus struct {
+	Running      bool       `json:"running"`
+	Scenario     string     `json:"scenario,omitempty"`
+	BaseRate     float64    `json:"base_rate"`
+	TargetRate   float64    `json:"target_rate"`   // Текущая скорость по форме нагрузки
+	AchievedRate float64    `json:"achieved_rate"` // За последнюю секунду
+	AverageRate  float64    `json:"average_rate"`  // С момента запуска
+	Workers      int        `json:"workers"`
+	Generated    int64      `json:"generated"`
+	Dropped      int64      `json:"dropped"` // Пропущено из-за отставания от заданной скорости
+	StartedAt    time.Time  `json:"started_at"`
+	StoppedAt    *time.Time `json:"stopped_at,omitempty"`
+}
+
+// BackfillJobRequest запускает генерацию истории за прошлый период по модельным часам
+type BackfillJobRequest struct {
+	From            time.Time              `json:"from"`
+	To              time.Time              `json:"to"`                          // По умолчанию - текущее время
+	Period          string                 `json:"period,omitempty"`            // Длина периода до To вместо From, например 720h
+	Step            string                 `json:"step,omitempty"`              // Шаг модельных часов, по умолчанию 1m
+	EventsPerSecond float64                `json:"events_per_second,omitempty"` // По умолчанию 1
+	Shape           *TrafficShape          `json:"shape,omitempty"`
+	ScenarioType    string                 `json:"scenario_type,omitempty"` // Предопределенный сценарий: профиль, метки, форма нагрузки
+	Scenario        string                 `json:"scenario,omitempty"`
+	Config          map[string]interface{} `json:"config,omitempty"` // Пользовательская конфигурация сценария
+	Labels          map[string]string      `json:"labels,omitempty"`
+	Sinks           []SinkConfig           `json:"sinks,omitempty"`
+	Seed            *int64                 `json:"seed,omitempty"`
+	Traces          bool                   `json:"traces,omitempty"`
+	Workers         int                    `json:"workers,omitempty"`
+	RemoteWrite     bool                   `json:"remote_write,omitempty"` // Отправлять метрики периода через remote-write
+	Profile         *GenerationProfile     `json:"-"`
+}
+
+// BackfillJob - состояние генерации истории
+type BackfillJob struct {
+	ID         string     `json:"id"`
+	Status     string     `json:"status"` // running, completed, cancelled, failed
+	Scenario   string     `json:"scenario,omitempty"`
+	From       time.Time  `json:"from"`
+	To         time.Time  `json:"to"`
+	Current    time.Time  `json:"current"`  // Модельное время
+	Progress   float64    `json:"progress"` // Доля периода от 0 до 1
+	Generated  int64      `json:"generated"`
+	Rate       float64    `json:"rate"`                  // Событий в секунду реального времени
+	ETASeconds float64    `json:"eta_seconds,omitempty"` // Оценка оставшегося времени
+	Error      string     `json:"error,omitempty"`
+	StartedAt  time.Time  `json:"started_at"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
 }
 
 // ScheduleExecution представляет выполнение расписания
@@ -106,13 +282,23 @@ type ScheduleExecution struct {
 	LogsCount    int        `json:"logs_count,omitempty"`
 }
 
+// StorageEvent - уведомление об изменении записи общего хранилища
+type StorageEvent struct {
+	Entity string    `json:"entity"` // scenario, schedule, execution, chain, chain_execution, chain_schedule
+	Action string    `json:"action"` // saved, deleted
+	ID     string    `json:"id"`
+	Source string    `json:"source"` // Экземпляр симулятора, изменивший запись
+	Time   time.Time `json:"time"`
+}
+
 // ChainStep представляет шаг в цепочке сценариев
 type ChainStep struct {
 	ScenarioType string                 `json:"scenario_type" binding:"required"`
 	Name         string                 `json:"name"`
 	Config       map[string]interface{} `json:"config"`
-	DelayBefore  int                    `json:"delay_before"` // Задержка перед запуском в секундах
-	Order        int                    `json:"order"`        // Порядок выполнения
+	DelayBefore  int                    `json:"delay_before"`   // Задержка перед запуском в секундах
+	Order        int                    `json:"order"`          // Порядок выполнения
+	Seed         *int64                 `json:"seed,omitempty"` // Фиксированный seed для воспроизводимой генерации
 }
 
 // ScenarioChain представляет цепочку сценариев
@@ -121,7 +307,8 @@ type ScenarioChain struct {
 	Name        string      `json:"name" binding:"required"`
 	Description string      `json:"description"`
 	Steps       []ChainStep `json:"steps" binding:"required"`
-	Status      string      `json:"status"` // pending, running, completed, failed, stopped
+	Status      string      `json:"status"`               // pending, running, completed, failed, stopped
+	TimeScale   float64     `json:"time_scale,omitempty"` // Ускорение задержек и длительностей шагов
 	StartedAt   *time.Time  `json:"started_at,omitempty"`
 	CompletedAt *time.Time  `json:"completed_at,omitempty"`
 	CreatedAt   time.Time   `json:"created_at"`
@@ -133,6 +320,7 @@ type ChainExecution struct {
 	ID          string               `json:"id"`
 	ChainID     string               `json:"chain_id"`
 	Status      string               `json:"status"` // running, completed, failed, stopped
+	TimeScale   float64              `json:"time_scale,omitempty"`
 	StartedAt   time.Time            `json:"started_at"`
 	CompletedAt *time.Time           `json:"completed_at,omitempty"`
 	Error       string               `json:"error,omitempty"`
@@ -148,3 +336,76 @@ type ChainExecutionStep struct {
 	CompletedAt  *time.Time `json:"completed_at,omitempty"`
 	Error        string     `json:"error,omitempty"`
 }
+
+// Topology описывает архитектуру моделируемого приложения: сервисы, их
+// эндпоинты, задержки, режимы ошибок и зависимости между сервисами
+type Topology struct {
+	Name     string            `json:"name" yaml:"name"` // Значение метки app в метриках
+	Services []TopologyService `json:"services" yaml:"services"`
+}
+
+// TopologyService описывает сервис топологии
+type TopologyService struct {
+	Name         string             `json:"name" yaml:"name"`
+	Weight       float64            `json:"weight,omitempty" yaml:"weight,omitempty"`         // Доля логов сервиса относительно других (по умолчанию 1)
+	Entrypoint   bool               `json:"entrypoint,omitempty" yaml:"entrypoint,omitempty"` // Сервис принимает внешние запросы (корень трассировок)
+	Endpoints    []TopologyEndpoint `json:"endpoints,omitempty" yaml:"endpoints,omitempty"`
+	Errors       []TopologyError    `json:"errors,omitempty" yaml:"errors,omitempty"`             // Режимы ошибок всех эндпоинтов сервиса
+	Dependencies []string           `json:"dependencies,omitempty" yaml:"dependencies,omitempty"` // Сервисы, вызываемые при обработке запроса
+}
+
+// TopologyEndpoint описывает эндпоинт сервиса
+type TopologyEndpoint struct {
+	Method  string          `json:"method" yaml:"method"`
+	Path    string          `json:"path" yaml:"path"`
+	Weight  float64         `json:"weight,omitempty" yaml:"weight,omitempty"` // Доля запросов эндпоинта внутри сервиса (по умолчанию 1)
+	Latency TopologyLatency `json:"latency,omitempty" yaml:"latency,omitempty"`
+	Message string          `json:"message,omitempty" yaml:"message,omitempty"` // Сообщение успешного запроса
+	Errors  []TopologyError `json:"errors,omitempty" yaml:"errors,omitempty"`
+	Calls   *[]string       `json:"calls,omitempty" yaml:"calls,omitempty"` // Вызываемые сервисы вместо зависимостей сервиса
+}
+
+// TopologyLatency задает распределение времени отклика в миллисекундах
+type TopologyLatency struct {
+	Distribution string  `json:"distribution,omitempty" yaml:"distribution,omitempty"` // constant, uniform, normal, lognormal, exponential
+	Min          float64 `json:"min_ms,omitempty" yaml:"min_ms,omitempty"`
+	Max          float64 `json:"max_ms,omitempty" yaml:"max_ms,omitempty"`
+	Mean         float64 `json:"mean_ms,omitempty" yaml:"mean_ms,omitempty"`
+	StdDev       float64 `json:"stddev_ms,omitempty" yaml:"stddev_ms,omitempty"`
+}
+
+// TopologyError описывает режим ошибки эндпоинта
+type TopologyError struct {
+	Rate          float64 `json:"rate" yaml:"rate"`                                         // Вероятность ошибки на запрос
+	Level         string  `json:"level,omitempty" yaml:"level,omitempty"`                   // ERROR (по умолчанию) или WARN
+	Status        int     `json:"status,omitempty" yaml:"status,omitempty"`                 // HTTP-статус ответа (по умолчанию 500)
+	Message       string  `json:"message,omitempty" yaml:"message,omitempty"`               // Сообщение лога
+	Error         string  `json:"error,omitempty" yaml:"error,omitempty"`                   // Текст ошибки
+	LatencyFactor float64 `json:"latency_factor,omitempty" yaml:"latency_factor,omitempty"` // Множитель времени отклика, например для таймаутов
+}
+
+// Fault описывает отказ, внедренный в узел графа зависимостей сервисов.
+// Отказ распространяется к вызывающим сервисам: таймауты, повторы,
+// размыкание circuit breaker и 503 на шлюзе.
+type Fault struct {
+	ID              string    `json:"id"`
+	Service         string    `json:"service" binding:"required"`
+	Type            string    `json:"type"`                  // unavailable, database_down, latency, errors
+	Description     string    `json:"description,omitempty"` // Например, "inventory-service DB down"
+	Error           string    `json:"error,omitempty"`       // Текст ошибки отказавшего сервиса
+	ErrorRate       float64   `json:"error_rate,omitempty"`  // Доля затронутых запросов на пике (по умолчанию 1)
+	LatencyMs       int64     `json:"latency_ms,omitempty"`  // Добавочная задержка для latency (по умолчанию 5000)
+	DelaySeconds    int       `json:"delay_seconds,omitempty"`
+	OnsetSeconds    int       `json:"onset_seconds,omitempty"`    // Нарастание от нуля до пика
+	DurationSeconds int       `json:"duration_seconds,omitempty"` // Длительность пика, 0 - до удаления
+	RecoverySeconds int       `json:"recovery_seconds,omitempty"` // Восстановление от пика до нуля
+	StartAt         time.Time `json:"start_at"`
+	CreatedAt       time.Time `json:"created_at"`
+}
+
+// FaultStatus - состояние отказа на текущий момент
+type FaultStatus struct {
+	Fault
+	Phase     string  `json:"phase"`     // pending, onset, active, recovery, recovered
+	Intensity float64 `json:"intensity"` // Доля от пикового воздействия, 0..1
+}
@@ -0,0 +1,229 @@
+package otlp
+
+import (
+	"encoding/hex"
+	"hash/fnv"
+	"sort"
+	"strings"
+	"time"
+
+	"log-metrics-simulator/models"
+)
+
+// Размеры идентификаторов OTLP в байтах
+const (
+	traceIDSize = 16
+	spanIDSize  = 8
+)
+
+// ExportLogs отправляет записи как OTLP log records. Ресурсы группируются по
+// сервису: service.name берется из LogEntry.Service, остальные атрибуты ресурса -
+// из меток сценария.
+func (c *Client) ExportLogs(entries []models.LogEntry, scenario string, labels map[string]string, stop <-chan struct{}) error {
+	if len(entries) == 0 {
+		return nil
+	}
+
+	observed := uint64(time.Now().UnixNano())
+	req := logsRequest{}
+	for _, group := range groupByService(entries) {
+		records := make([]logRecord, 0, len(group.entries))
+		for _, entry := range group.entries {
+			severityNumber, severityText := severity(entry.Level)
+			records = append(records, logRecord{
+				TimeUnixNano:         uint64(entry.Timestamp.UnixNano()),
+				ObservedTimeUnixNano: observed,
+				SeverityNumber:       severityNumber,
+				SeverityText:         severityText,
+				Body:                 anyValue{StringValue: &entry.Message},
+				Attributes:           entryAttributes(entry, true),
+				TraceID:              idBytes(entry.TraceID, traceIDSize),
+				SpanID:               idBytes(entry.SpanID, spanIDSize),
+			})
+		}
+
+		req.ResourceLogs = append(req.ResourceLogs, resourceLogs{
+			Resource:  c.newResource(resourceLabels(group.service, scenario, labels)),
+			ScopeLogs: []scopeLogs{{Scope: instrumentationScope, LogRecords: records}},
+		})
+	}
+
+	return c.export(logsPath, req, stop)
+}
+
+// ExportTraces отправляет синтетические спаны, построенные по записям с trace_id и span_id.
+// Каждая запись дает серверный спан длительностью Duration, завершившийся в Timestamp.
+func (c *Client) ExportTraces(entries []models.LogEntry, scenario string, labels map[string]string, stop <-chan struct{}) error {
+	req := tracesRequest{}
+	for _, group := range groupByService(entries) {
+		var spans []span
+		for _, entry := range group.entries {
+			if entry.TraceID == "" || entry.SpanID == "" {
+				continue
+			}
+
+			end := entry.Timestamp
+			start := end.Add(-time.Duration(entry.Duration) * time.Millisecond)
+
+			// Имя спана без строки запроса, чтобы не плодить уникальные имена
+			name := entry.Service
+			if path, _, _ := strings.Cut(entry.Path, "?"); entry.Method != "" && path != "" {
+				name = entry.Method + " " + path
+			}
+
+			var status spanStatus
+			if entry.Level == "ERROR" || entry.Level == "FATAL" || entry.Status >= 500 {
+				status.Code = statusCodeError
+				status.Message = entry.Error
+				if status.Message == "" {
+					status.Message = entry.Message
+				}
+			}
+
+			spans = append(spans, span{
+				TraceID:           idBytes(entry.TraceID, traceIDSize),
+				SpanID:            idBytes(entry.SpanID, spanIDSize),
+				ParentSpanID:      idBytes(entry.ParentSpanID, spanIDSize),
+				Name:              name,
+				Kind:              spanKindServer,
+				StartTimeUnixNano: uint64(start.UnixNano()),
+				EndTimeUnixNano:   uint64(end.UnixNano()),
+				Attributes:        entryAttributes(entry, false),
+				Status:            status,
+			})
+		}
+		if len(spans) == 0 {
+			continue
+		}
+
+		req.ResourceSpans = append(req.ResourceSpans, resourceSpans{
+			Resource:   c.newResource(resourceLabels(group.service, scenario, labels)),
+			ScopeSpans: []scopeSpans{{Scope: instrumentationScope, Spans: spans}},
+		})
+	}
+
+	if len(req.ResourceSpans) == 0 {
+		return nil
+	}
+	return c.export(tracesPath, req, stop)
+}
+
+type serviceGroup struct {
+	service string
+	entries []models.LogEntry
+}
+
+// groupByService группирует записи по сервису в порядке имен сервисов
+func groupByService(entries []models.LogEntry) []serviceGroup {
+	index := make(map[string]int)
+	var groups []serviceGroup
+	for _, entry := range entries {
+		i, ok := index[entry.Service]
+		if !ok {
+			i = len(groups)
+			index[entry.Service] = i
+			groups = append(groups, serviceGroup{service: entry.Service})
+		}
+		groups[i].entries = append(groups[i].entries, entry)
+	}
+	sort.Slice(groups, func(a, b int) bool { return groups[a].service < groups[b].service })
+	return groups
+}
+
+func resourceLabels(service, scenario string, labels map[string]string) map[string]string {
+	result := make(map[string]string, len(labels)+2)
+	for k, v := range labels {
+		result[k] = v
+	}
+	if scenario != "" {
+		result["simulator.scenario"] = scenario
+	}
+	result["service.name"] = service
+	return result
+}
+
+// entryAttributes переводит поля записи в атрибуты по семантическим соглашениям OpenTelemetry
+func entryAttributes(entry models.LogEntry, withError bool) []keyValue {
+	var attrs []keyValue
+	if entry.Method != "" {
+		attrs = append(attrs, stringAttr("http.request.method", entry.Method))
+	}
+	if entry.Path != "" {
+		path, query, _ := strings.Cut(entry.Path, "?")
+		attrs = append(attrs, stringAttr("url.path", path))
+		if query != "" {
+			attrs = append(attrs, stringAttr("url.query", query))
+		}
+	}
+	if entry.Status != 0 {
+		attrs = append(attrs, intAttr("http.response.status_code", int64(entry.Status)))
+	}
+	if entry.Duration != 0 {
+		attrs = append(attrs, intAttr("duration_ms", entry.Duration))
+	}
+	if entry.UserID != "" {
+		attrs = append(attrs, stringAttr("user.id", entry.UserID))
+	}
+	if entry.SessionID != "" {
+		attrs = append(attrs, stringAttr("session.id", entry.SessionID))
+	}
+	if entry.IP != "" {
+		attrs = append(attrs, stringAttr("client.address", entry.IP))
+	}
+	if entry.UserAgent != "" {
+		attrs = append(attrs, stringAttr("user_agent.original", entry.UserAgent))
+	}
+	if withError && entry.Error != "" {
+		attrs = append(attrs, stringAttr("exception.message", entry.Error))
+	}
+	if withError && entry.Stack != "" {
+		attrs = append(attrs, stringAttr("exception.stacktrace", entry.Stack))
+	}
+	return attrs
+}
+
+// severity сопоставляет уровень лога и SeverityNumber OTLP
+func severity(level string) (int, string) {
+	switch strings.ToUpper(level) {
+	case "TRACE":
+		return 1, "TRACE"
+	case "DEBUG":
+		return 5, "DEBUG"
+	case "INFO":
+		return 9, "INFO"
+	case "WARN", "WARNING":
+		return 13, "WARN"
+	case "ERROR":
+		return 17, "ERROR"
+	case "FATAL":
+		return 21, "FATAL"
+	default:
+		return 0, level
+	}
+}
+
+// idBytes переводит hex-идентификатор в байты нужного размера. Короткие
+// идентификаторы дополняются нулями слева, длинные обрезаются слева.
+// Не-hex строки хешируются, чтобы одна строка всегда давала один идентификатор.
+func idBytes(id string, size int) hexBytes {
+	if id == "" {
+		return nil
+	}
+	if len(id)%2 == 1 {
+		id = "0" + id
+	}
+
+	decoded, err := hex.DecodeString(id)
+	if err != nil {
+		h := fnv.New64a()
+		h.Write([]byte(id))
+		decoded = h.Sum(nil)
+	}
+
+	result := make([]byte, size)
+	if len(decoded) > size {
+		decoded = decoded[len(decoded)-size:]
+	}
+	copy(result[size-len(decoded):], decoded)
+	return result
+}
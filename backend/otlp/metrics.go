@@ -0,0 +1,149 @@
+package otlp
+
+import (
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"log-metrics-simulator/models"
+)
+
+// ExportMetrics отправляет семейства метрик как OTLP-метрики с кумулятивной
+// темпоральностью: counter - монотонная сумма, gauge - gauge, histogram и
+// summary - одноименные типы OTLP. Метки серий становятся атрибутами точек.
+func (c *Client) ExportMetrics(families []models.MetricFamily, ts time.Time, stop <-chan struct{}) error {
+	if len(families) == 0 {
+		return nil
+	}
+
+	now := uint64(ts.UnixNano())
+	metrics := make([]metric, 0, len(families))
+	for _, f := range families {
+		m := metric{Name: f.Name, Description: f.Help}
+
+		switch f.Type {
+		case "counter":
+			m.Sum = &sum{AggregationTemporality: aggregationCumulative, IsMonotonic: true}
+			for _, s := range f.Series {
+				m.Sum.DataPoints = append(m.Sum.DataPoints, numberDataPoint{
+					Attributes:        labelAttributes(s.Labels),
+					StartTimeUnixNano: unixNano(s.Created),
+					TimeUnixNano:      now,
+					AsDouble:          jsonFloat(s.Value),
+				})
+			}
+		case "histogram":
+			m.Histogram = &histogram{AggregationTemporality: aggregationCumulative}
+			for _, s := range f.Series {
+				m.Histogram.DataPoints = append(m.Histogram.DataPoints, histogramDataPoint{
+					Attributes:        labelAttributes(s.Labels),
+					StartTimeUnixNano: unixNano(s.Created),
+					TimeUnixNano:      now,
+					Count:             s.Count,
+					Sum:               jsonFloat(s.Sum),
+					BucketCounts:      s.BucketCounts,
+					ExplicitBounds:    s.Bounds,
+				})
+			}
+		case "summary":
+			m.Summary = &summary{}
+			for _, s := range f.Series {
+				dp := summaryDataPoint{
+					Attributes:        labelAttributes(s.Labels),
+					StartTimeUnixNano: unixNano(s.Created),
+					TimeUnixNano:      now,
+					Count:             s.Count,
+					Sum:               jsonFloat(s.Sum),
+				}
+				for _, q := range s.Quantiles {
+					dp.QuantileValues = append(dp.QuantileValues, valueAtQuantile{Quantile: jsonFloat(q.Quantile), Value: jsonFloat(q.Value)})
+				}
+				m.Summary.DataPoints = append(m.Summary.DataPoints, dp)
+			}
+		default:
+			m.Gauge = &gauge{}
+			for _, s := range f.Series {
+				m.Gauge.DataPoints = append(m.Gauge.DataPoints, numberDataPoint{
+					Attributes:   labelAttributes(s.Labels),
+					TimeUnixNano: now,
+					AsDouble:     jsonFloat(s.Value),
+				})
+			}
+		}
+		metrics = append(metrics, m)
+	}
+
+	req := metricsRequest{ResourceMetrics: []resourceMetrics{{
+		Resource:     c.newResource(nil),
+		ScopeMetrics: []scopeMetrics{{Scope: instrumentationScope, Metrics: metrics}},
+	}}}
+	return c.export(metricsPath, req, stop)
+}
+
+func labelAttributes(labels map[string]string) []keyValue {
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	attrs := make([]keyValue, 0, len(keys))
+	for _, k := range keys {
+		attrs = append(attrs, stringAttr(k, labels[k]))
+	}
+	return attrs
+}
+
+func unixNano(t time.Time) uint64 {
+	if t.IsZero() {
+		return 0
+	}
+	return uint64(t.UnixNano())
+}
+
+// MetricExporter периодически отправляет метрики из source в коллектор
+type MetricExporter struct {
+	client   *Client
+	interval time.Duration
+	source   func() []models.MetricFamily
+	stop     chan struct{}
+	once     sync.Once
+}
+
+func NewMetricExporter(client *Client, interval time.Duration, source func() []models.MetricFamily) *MetricExporter {
+	if interval <= 0 {
+		interval = 15 * time.Second
+	}
+	return &MetricExporter{
+		client:   client,
+		interval: interval,
+		source:   source,
+		stop:     make(chan struct{}),
+	}
+}
+
+// Start запускает периодическую отправку
+func (e *MetricExporter) Start() {
+	go func() {
+		ticker := time.NewTicker(e.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case ts := <-ticker.C:
+				if err := e.client.ExportMetrics(e.source(), ts, e.stop); err != nil {
+					log.Printf("❌ Ошибка экспорта метрик OTLP: %v", err)
+				}
+			case <-e.stop:
+				return
+			}
+		}
+	}()
+	log.Printf("📤 Экспорт метрик OTLP включен: %s, интервал %s", e.client.config.Endpoint, e.interval)
+}
+
+// Stop останавливает периодическую отправку
+func (e *MetricExporter) Stop() {
+	e.once.Do(func() { close(e.stop) })
+}
@@ -0,0 +1,471 @@
+package otlp
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"math"
+	"strconv"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// Сообщения OTLP v1 (opentelemetry/proto). Каждый тип кодируется в protobuf
+// методом appendProto и в OTLP/JSON через теги json: идентификаторы
+// трассировок - hex-строки, 64-битные целые - строки, перечисления - числа.
+
+// ===== Общие типы =====
+
+type keyValue struct {
+	Key   string   `json:"key"`
+	Value anyValue `json:"value"`
+}
+
+type anyValue struct {
+	StringValue *string    `json:"stringValue,omitempty"`
+	BoolValue   *bool      `json:"boolValue,omitempty"`
+	IntValue    *int64     `json:"intValue,omitempty,string"`
+	DoubleValue *jsonFloat `json:"doubleValue,omitempty"`
+}
+
+type resource struct {
+	Attributes []keyValue `json:"attributes"`
+}
+
+type scope struct {
+	Name    string `json:"name"`
+	Version string `json:"version,omitempty"`
+}
+
+// hexBytes кодируется в JSON как hex-строка
+type hexBytes []byte
+
+func (b hexBytes) MarshalJSON() ([]byte, error) {
+	return json.Marshal(hex.EncodeToString(b))
+}
+
+// jsonFloat кодирует NaN и бесконечности строками, как требует JSON-отображение protobuf
+type jsonFloat float64
+
+func (f jsonFloat) MarshalJSON() ([]byte, error) {
+	v := float64(f)
+	switch {
+	case math.IsNaN(v):
+		return []byte(`"NaN"`), nil
+	case math.IsInf(v, 1):
+		return []byte(`"Infinity"`), nil
+	case math.IsInf(v, -1):
+		return []byte(`"-Infinity"`), nil
+	}
+	return []byte(strconv.FormatFloat(v, 'g', -1, 64)), nil
+}
+
+// uint64Strings кодирует массив 64-битных счетчиков строками
+type uint64Strings []uint64
+
+func (u uint64Strings) MarshalJSON() ([]byte, error) {
+	s := make([]string, len(u))
+	for i, v := range u {
+		s[i] = strconv.FormatUint(v, 10)
+	}
+	return json.Marshal(s)
+}
+
+func stringAttr(key, value string) keyValue {
+	return keyValue{Key: key, Value: anyValue{StringValue: &value}}
+}
+
+func intAttr(key string, value int64) keyValue {
+	return keyValue{Key: key, Value: anyValue{IntValue: &value}}
+}
+
+// ===== Логи =====
+
+type logsRequest struct {
+	ResourceLogs []resourceLogs `json:"resourceLogs"`
+}
+
+type resourceLogs struct {
+	Resource  resource    `json:"resource"`
+	ScopeLogs []scopeLogs `json:"scopeLogs"`
+}
+
+type scopeLogs struct {
+	Scope      scope       `json:"scope"`
+	LogRecords []logRecord `json:"logRecords"`
+}
+
+type logRecord struct {
+	TimeUnixNano         uint64     `json:"timeUnixNano,string"`
+	ObservedTimeUnixNano uint64     `json:"observedTimeUnixNano,string"`
+	SeverityNumber       int        `json:"severityNumber"`
+	SeverityText         string     `json:"severityText"`
+	Body                 anyValue   `json:"body"`
+	Attributes           []keyValue `json:"attributes,omitempty"`
+	TraceID              hexBytes   `json:"traceId,omitempty"`
+	SpanID               hexBytes   `json:"spanId,omitempty"`
+}
+
+// ===== Трассировки =====
+
+type tracesRequest struct {
+	ResourceSpans []resourceSpans `json:"resourceSpans"`
+}
+
+type resourceSpans struct {
+	Resource   resource     `json:"resource"`
+	ScopeSpans []scopeSpans `json:"scopeSpans"`
+}
+
+type scopeSpans struct {
+	Scope scope  `json:"scope"`
+	Spans []span `json:"spans"`
+}
+
+type span struct {
+	TraceID           hexBytes   `json:"traceId"`
+	SpanID            hexBytes   `json:"spanId"`
+	ParentSpanID      hexBytes   `json:"parentSpanId,omitempty"`
+	Name              string     `json:"name"`
+	Kind              int        `json:"kind"`
+	StartTimeUnixNano uint64     `json:"startTimeUnixNano,string"`
+	EndTimeUnixNano   uint64     `json:"endTimeUnixNano,string"`
+	Attributes        []keyValue `json:"attributes,omitempty"`
+	Status            spanStatus `json:"status"`
+}
+
+type spanStatus struct {
+	Message string `json:"message,omitempty"`
+	Code    int    `json:"code,omitempty"`
+}
+
+// Значения перечислений OTLP
+const (
+	spanKindInternal = 1
+	spanKindServer   = 2
+	spanKindClient   = 3
+
+	statusCodeOK    = 1
+	statusCodeError = 2
+
+	aggregationCumulative = 2
+)
+
+// ===== Метрики =====
+
+type metricsRequest struct {
+	ResourceMetrics []resourceMetrics `json:"resourceMetrics"`
+}
+
+type resourceMetrics struct {
+	Resource     resource       `json:"resource"`
+	ScopeMetrics []scopeMetrics `json:"scopeMetrics"`
+}
+
+type scopeMetrics struct {
+	Scope   scope    `json:"scope"`
+	Metrics []metric `json:"metrics"`
+}
+
+type metric struct {
+	Name        string     `json:"name"`
+	Description string     `json:"description,omitempty"`
+	Unit        string     `json:"unit,omitempty"`
+	Gauge       *gauge     `json:"gauge,omitempty"`
+	Sum         *sum       `json:"sum,omitempty"`
+	Histogram   *histogram `json:"histogram,omitempty"`
+	Summary     *summary   `json:"summary,omitempty"`
+}
+
+type gauge struct {
+	DataPoints []numberDataPoint `json:"dataPoints"`
+}
+
+type sum struct {
+	DataPoints             []numberDataPoint `json:"dataPoints"`
+	AggregationTemporality int               `json:"aggregationTemporality"`
+	IsMonotonic            bool              `json:"isMonotonic"`
+}
+
+type numberDataPoint struct {
+	Attributes        []keyValue `json:"attributes,omitempty"`
+	StartTimeUnixNano uint64     `json:"startTimeUnixNano,omitempty,string"`
+	TimeUnixNano      uint64     `json:"timeUnixNano,string"`
+	AsDouble          jsonFloat  `json:"asDouble"`
+}
+
+type histogram struct {
+	DataPoints             []histogramDataPoint `json:"dataPoints"`
+	AggregationTemporality int                  `json:"aggregationTemporality"`
+}
+
+type histogramDataPoint struct {
+	Attributes        []keyValue    `json:"attributes,omitempty"`
+	StartTimeUnixNano uint64        `json:"startTimeUnixNano,omitempty,string"`
+	TimeUnixNano      uint64        `json:"timeUnixNano,string"`
+	Count             uint64        `json:"count,string"`
+	Sum               jsonFloat     `json:"sum"`
+	BucketCounts      uint64Strings `json:"bucketCounts"`
+	ExplicitBounds    []float64     `json:"explicitBounds"`
+}
+
+type summary struct {
+	DataPoints []summaryDataPoint `json:"dataPoints"`
+}
+
+type summaryDataPoint struct {
+	Attributes        []keyValue        `json:"attributes,omitempty"`
+	StartTimeUnixNano uint64            `json:"startTimeUnixNano,omitempty,string"`
+	TimeUnixNano      uint64            `json:"timeUnixNano,string"`
+	Count             uint64            `json:"count,string"`
+	Sum               jsonFloat         `json:"sum"`
+	QuantileValues    []valueAtQuantile `json:"quantileValues"`
+}
+
+type valueAtQuantile struct {
+	Quantile jsonFloat `json:"quantile"`
+	Value    jsonFloat `json:"value"`
+}
+
+// ===== Кодирование protobuf =====
+
+func appendMessage(b []byte, num protowire.Number, msg []byte) []byte {
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendBytes(b, msg)
+}
+
+func appendString(b []byte, num protowire.Number, s string) []byte {
+	if s == "" {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendString(b, s)
+}
+
+func appendBytes(b []byte, num protowire.Number, v []byte) []byte {
+	if len(v) == 0 {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendBytes(b, v)
+}
+
+func appendVarint(b []byte, num protowire.Number, v uint64) []byte {
+	if v == 0 {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.VarintType)
+	return protowire.AppendVarint(b, v)
+}
+
+func appendFixed64(b []byte, num protowire.Number, v uint64) []byte {
+	if v == 0 {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.Fixed64Type)
+	return protowire.AppendFixed64(b, v)
+}
+
+func appendDouble(b []byte, num protowire.Number, v float64) []byte {
+	b = protowire.AppendTag(b, num, protowire.Fixed64Type)
+	return protowire.AppendFixed64(b, math.Float64bits(v))
+}
+
+func (v anyValue) appendProto(b []byte) []byte {
+	switch {
+	case v.StringValue != nil:
+		b = protowire.AppendTag(b, 1, protowire.BytesType)
+		return protowire.AppendString(b, *v.StringValue)
+	case v.BoolValue != nil:
+		b = protowire.AppendTag(b, 2, protowire.VarintType)
+		return protowire.AppendVarint(b, protowire.EncodeBool(*v.BoolValue))
+	case v.IntValue != nil:
+		b = protowire.AppendTag(b, 3, protowire.VarintType)
+		return protowire.AppendVarint(b, uint64(*v.IntValue))
+	case v.DoubleValue != nil:
+		return appendDouble(b, 4, float64(*v.DoubleValue))
+	}
+	return b
+}
+
+func (kv keyValue) appendProto(b []byte) []byte {
+	b = appendString(b, 1, kv.Key)
+	return appendMessage(b, 2, kv.Value.appendProto(nil))
+}
+
+func appendAttributes(b []byte, num protowire.Number, attrs []keyValue) []byte {
+	for _, kv := range attrs {
+		b = appendMessage(b, num, kv.appendProto(nil))
+	}
+	return b
+}
+
+func (r resource) appendProto(b []byte) []byte {
+	return appendAttributes(b, 1, r.Attributes)
+}
+
+func (s scope) appendProto(b []byte) []byte {
+	b = appendString(b, 1, s.Name)
+	return appendString(b, 2, s.Version)
+}
+
+func (req logsRequest) appendProto(b []byte) []byte {
+	for _, rl := range req.ResourceLogs {
+		var rb []byte
+		rb = appendMessage(rb, 1, rl.Resource.appendProto(nil))
+		for _, sl := range rl.ScopeLogs {
+			var sb []byte
+			sb = appendMessage(sb, 1, sl.Scope.appendProto(nil))
+			for _, lr := range sl.LogRecords {
+				sb = appendMessage(sb, 2, lr.appendProto(nil))
+			}
+			rb = appendMessage(rb, 2, sb)
+		}
+		b = appendMessage(b, 1, rb)
+	}
+	return b
+}
+
+func (lr logRecord) appendProto(b []byte) []byte {
+	b = appendFixed64(b, 1, lr.TimeUnixNano)
+	b = appendVarint(b, 2, uint64(lr.SeverityNumber))
+	b = appendString(b, 3, lr.SeverityText)
+	b = appendMessage(b, 5, lr.Body.appendProto(nil))
+	b = appendAttributes(b, 6, lr.Attributes)
+	b = appendBytes(b, 9, lr.TraceID)
+	b = appendBytes(b, 10, lr.SpanID)
+	return appendFixed64(b, 11, lr.ObservedTimeUnixNano)
+}
+
+func (req tracesRequest) appendProto(b []byte) []byte {
+	for _, rs := range req.ResourceSpans {
+		var rb []byte
+		rb = appendMessage(rb, 1, rs.Resource.appendProto(nil))
+		for _, ss := range rs.ScopeSpans {
+			var sb []byte
+			sb = appendMessage(sb, 1, ss.Scope.appendProto(nil))
+			for _, sp := range ss.Spans {
+				sb = appendMessage(sb, 2, sp.appendProto(nil))
+			}
+			rb = appendMessage(rb, 2, sb)
+		}
+		b = appendMessage(b, 1, rb)
+	}
+	return b
+}
+
+func (sp span) appendProto(b []byte) []byte {
+	b = appendBytes(b, 1, sp.TraceID)
+	b = appendBytes(b, 2, sp.SpanID)
+	b = appendBytes(b, 4, sp.ParentSpanID)
+	b = appendString(b, 5, sp.Name)
+	b = appendVarint(b, 6, uint64(sp.Kind))
+	b = appendFixed64(b, 7, sp.StartTimeUnixNano)
+	b = appendFixed64(b, 8, sp.EndTimeUnixNano)
+	b = appendAttributes(b, 9, sp.Attributes)
+
+	var status []byte
+	status = appendString(status, 2, sp.Status.Message)
+	status = appendVarint(status, 3, uint64(sp.Status.Code))
+	return appendMessage(b, 15, status)
+}
+
+func (req metricsRequest) appendProto(b []byte) []byte {
+	for _, rm := range req.ResourceMetrics {
+		var rb []byte
+		rb = appendMessage(rb, 1, rm.Resource.appendProto(nil))
+		for _, sm := range rm.ScopeMetrics {
+			var sb []byte
+			sb = appendMessage(sb, 1, sm.Scope.appendProto(nil))
+			for _, m := range sm.Metrics {
+				sb = appendMessage(sb, 2, m.appendProto(nil))
+			}
+			rb = appendMessage(rb, 2, sb)
+		}
+		b = appendMessage(b, 1, rb)
+	}
+	return b
+}
+
+func (m metric) appendProto(b []byte) []byte {
+	b = appendString(b, 1, m.Name)
+	b = appendString(b, 2, m.Description)
+	b = appendString(b, 3, m.Unit)
+
+	switch {
+	case m.Gauge != nil:
+		var gb []byte
+		for _, dp := range m.Gauge.DataPoints {
+			gb = appendMessage(gb, 1, dp.appendProto(nil))
+		}
+		b = appendMessage(b, 5, gb)
+	case m.Sum != nil:
+		var sb []byte
+		for _, dp := range m.Sum.DataPoints {
+			sb = appendMessage(sb, 1, dp.appendProto(nil))
+		}
+		sb = appendVarint(sb, 2, uint64(m.Sum.AggregationTemporality))
+		if m.Sum.IsMonotonic {
+			sb = appendVarint(sb, 3, 1)
+		}
+		b = appendMessage(b, 7, sb)
+	case m.Histogram != nil:
+		var hb []byte
+		for _, dp := range m.Histogram.DataPoints {
+			hb = appendMessage(hb, 1, dp.appendProto(nil))
+		}
+		hb = appendVarint(hb, 2, uint64(m.Histogram.AggregationTemporality))
+		b = appendMessage(b, 9, hb)
+	case m.Summary != nil:
+		var sb []byte
+		for _, dp := range m.Summary.DataPoints {
+			sb = appendMessage(sb, 1, dp.appendProto(nil))
+		}
+		b = appendMessage(b, 11, sb)
+	}
+	return b
+}
+
+func (dp numberDataPoint) appendProto(b []byte) []byte {
+	b = appendFixed64(b, 2, dp.StartTimeUnixNano)
+	b = appendFixed64(b, 3, dp.TimeUnixNano)
+	b = appendDouble(b, 4, float64(dp.AsDouble))
+	return appendAttributes(b, 7, dp.Attributes)
+}
+
+func (dp histogramDataPoint) appendProto(b []byte) []byte {
+	b = appendFixed64(b, 2, dp.StartTimeUnixNano)
+	b = appendFixed64(b, 3, dp.TimeUnixNano)
+	b = protowire.AppendTag(b, 4, protowire.Fixed64Type)
+	b = protowire.AppendFixed64(b, dp.Count)
+	b = appendDouble(b, 5, float64(dp.Sum))
+
+	// Повторяющиеся скалярные поля кодируются упакованными
+	var counts []byte
+	for _, c := range dp.BucketCounts {
+		counts = protowire.AppendFixed64(counts, c)
+	}
+	b = appendBytes(b, 6, counts)
+
+	var bounds []byte
+	for _, v := range dp.ExplicitBounds {
+		bounds = protowire.AppendFixed64(bounds, math.Float64bits(v))
+	}
+	b = appendBytes(b, 7, bounds)
+
+	return appendAttributes(b, 9, dp.Attributes)
+}
+
+func (dp summaryDataPoint) appendProto(b []byte) []byte {
+	b = appendFixed64(b, 2, dp.StartTimeUnixNano)
+	b = appendFixed64(b, 3, dp.TimeUnixNano)
+	b = protowire.AppendTag(b, 4, protowire.Fixed64Type)
+	b = protowire.AppendFixed64(b, dp.Count)
+	b = appendDouble(b, 5, float64(dp.Sum))
+	for _, q := range dp.QuantileValues {
+		var qb []byte
+		qb = appendDouble(qb, 1, float64(q.Quantile))
+		qb = appendDouble(qb, 2, float64(q.Value))
+		b = appendMessage(b, 6, qb)
+	}
+	return appendAttributes(b, 7, dp.Attributes)
+}
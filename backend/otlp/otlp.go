@@ -0,0 +1,161 @@
+package otlp
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Пути OTLP/HTTP относительно адреса коллектора
+const (
+	logsPath    = "/v1/logs"
+	tracesPath  = "/v1/traces"
+	metricsPath = "/v1/metrics"
+)
+
+// Инструментация, от имени которой отправляются данные
+var instrumentationScope = scope{Name: "log-metrics-simulator", Version: "1.0.0"}
+
+// Config задает параметры экспорта OTLP/HTTP
+type Config struct {
+	Endpoint           string            // Адрес коллектора, например http://otel-collector:4318
+	Encoding           string            // protobuf или json
+	Headers            map[string]string // Дополнительные заголовки (авторизация, tenant)
+	ResourceAttributes map[string]string // Атрибуты ресурса всех сигналов
+	Timeout            time.Duration
+	MaxRetries         int
+	MinBackoff         time.Duration
+	MaxBackoff         time.Duration
+}
+
+// Client отправляет логи, трассировки и метрики в коллектор OTLP/HTTP
+type Client struct {
+	config Config
+	client *http.Client
+}
+
+func NewClient(config Config) (*Client, error) {
+	if config.Endpoint == "" {
+		return nil, fmt.Errorf("не указан адрес коллектора OTLP")
+	}
+	if config.Encoding == "" {
+		config.Encoding = "protobuf"
+	}
+	if config.Encoding != "protobuf" && config.Encoding != "json" {
+		return nil, fmt.Errorf("неизвестная кодировка: %s", config.Encoding)
+	}
+	config.Endpoint = strings.TrimRight(config.Endpoint, "/")
+	if config.Timeout <= 0 {
+		config.Timeout = 10 * time.Second
+	}
+	if config.MinBackoff <= 0 {
+		config.MinBackoff = 500 * time.Millisecond
+	}
+	if config.MaxBackoff < config.MinBackoff {
+		config.MaxBackoff = 30 * time.Second
+	}
+
+	return &Client{
+		config: config,
+		client: &http.Client{Timeout: config.Timeout},
+	}, nil
+}
+
+// protoMessage - сообщение, которое умеет кодироваться в protobuf
+type protoMessage interface {
+	appendProto(b []byte) []byte
+}
+
+// export кодирует запрос и отправляет его с повторами. stop прерывает ожидание между попытками.
+func (c *Client) export(path string, req protoMessage, stop <-chan struct{}) error {
+	var body []byte
+	contentType := "application/x-protobuf"
+	if c.config.Encoding == "json" {
+		var err error
+		if body, err = json.Marshal(req); err != nil {
+			return err
+		}
+		contentType = "application/json"
+	} else {
+		body = req.appendProto(nil)
+	}
+
+	backoff := c.config.MinBackoff
+	for attempt := 0; ; attempt++ {
+		retry, err := c.post(c.config.Endpoint+path, contentType, body)
+		if err == nil || !retry || attempt >= c.config.MaxRetries {
+			return err
+		}
+
+		select {
+		case <-time.After(backoff):
+		case <-stop:
+			return err
+		}
+
+		backoff *= 2
+		if backoff > c.config.MaxBackoff {
+			backoff = c.config.MaxBackoff
+		}
+	}
+}
+
+// post выполняет один запрос и сообщает, можно ли его повторить.
+// По спецификации OTLP повторяются 429, 502, 503, 504 и сетевые ошибки.
+func (c *Client) post(url, contentType string, body []byte) (bool, error) {
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", contentType)
+	for k, v := range c.config.Headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		io.Copy(io.Discard, resp.Body)
+		return false, nil
+	}
+
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true, fmt.Errorf("HTTP %d: %s", resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+	return false, fmt.Errorf("HTTP %d: %s", resp.StatusCode, strings.TrimSpace(string(msg)))
+}
+
+// newResource собирает атрибуты ресурса: настройки клиента, затем extra.
+// Атрибуты сортируются, чтобы одинаковые ресурсы кодировались одинаково.
+func (c *Client) newResource(extra map[string]string) resource {
+	merged := make(map[string]string, len(c.config.ResourceAttributes)+len(extra))
+	for k, v := range c.config.ResourceAttributes {
+		merged[k] = v
+	}
+	for k, v := range extra {
+		merged[k] = v
+	}
+
+	keys := make([]string, 0, len(merged))
+	for k := range merged {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	r := resource{Attributes: make([]keyValue, 0, len(keys))}
+	for _, k := range keys {
+		r.Attributes = append(r.Attributes, stringAttr(k, merged[k]))
+	}
+	return r
+}
@@ -0,0 +1,466 @@
+package otlp
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"log-metrics-simulator/models"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// otlpRequest - запрос, полученный заглушкой коллектора
+type otlpRequest struct {
+	path   string
+	header http.Header
+	body   []byte
+}
+
+// otlpStub - HTTP-заглушка коллектора OTLP; respond возвращает код ответа
+// на запрос с номером n, nil - всегда 200
+type otlpStub struct {
+	*httptest.Server
+
+	mutex    sync.Mutex
+	requests []otlpRequest
+}
+
+func newOTLPStub(t *testing.T, respond func(n int) int) *otlpStub {
+	stub := &otlpStub{}
+	stub.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		stub.mutex.Lock()
+		n := len(stub.requests)
+		stub.requests = append(stub.requests, otlpRequest{path: r.URL.Path, header: r.Header.Clone(), body: body})
+		stub.mutex.Unlock()
+
+		if respond != nil {
+			if status := respond(n); status != http.StatusOK {
+				http.Error(w, "test", status)
+			}
+		}
+	}))
+	t.Cleanup(stub.Close)
+	return stub
+}
+
+func (s *otlpStub) received() []otlpRequest {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return append([]otlpRequest(nil), s.requests...)
+}
+
+func newTestClient(t *testing.T, endpoint, encoding string) *Client {
+	t.Helper()
+	c, err := NewClient(Config{
+		Endpoint:           endpoint + "/",
+		Encoding:           encoding,
+		Headers:            map[string]string{"Authorization": "Bearer test"},
+		ResourceAttributes: map[string]string{"deployment.environment": "test"},
+		MaxRetries:         2,
+		MinBackoff:         time.Millisecond,
+		MaxBackoff:         time.Millisecond,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+var testEntry = models.LogEntry{
+	Timestamp:    time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC),
+	Level:        "ERROR",
+	Service:      "cart",
+	Message:      "cart failed",
+	Method:       "GET",
+	Path:         "/api/cart?id=7",
+	Status:       500,
+	Duration:     250,
+	TraceID:      "4bf92f3577b34da6a3ce929d0e0e4736",
+	SpanID:       "00f067aa0ba902b7",
+	ParentSpanID: "a3ce929d0e0e4736",
+	Error:        "timeout",
+}
+
+// ===== Разбор protobuf =====
+
+// protoField - поле сообщения protobuf: число для varint и fixed64, байты для bytes
+type protoField struct {
+	number uint64
+	bytes  []byte
+}
+
+// decodeProto разбирает сообщение на поля по номерам
+func decodeProto(t *testing.T, b []byte) map[protowire.Number][]protoField {
+	t.Helper()
+	fields := make(map[protowire.Number][]protoField)
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			t.Fatalf("неверный тег: %v", protowire.ParseError(n))
+		}
+		b = b[n:]
+
+		var field protoField
+		switch typ {
+		case protowire.VarintType:
+			field.number, n = protowire.ConsumeVarint(b)
+		case protowire.Fixed64Type:
+			field.number, n = protowire.ConsumeFixed64(b)
+		case protowire.BytesType:
+			field.bytes, n = protowire.ConsumeBytes(b)
+		default:
+			t.Fatalf("поле %d: неожиданный тип %d", num, typ)
+		}
+		if n < 0 {
+			t.Fatalf("поле %d: %v", num, protowire.ParseError(n))
+		}
+		b = b[n:]
+		fields[num] = append(fields[num], field)
+	}
+	return fields
+}
+
+// message спускается по первым вхождениям вложенных сообщений
+func message(t *testing.T, b []byte, path ...protowire.Number) map[protowire.Number][]protoField {
+	t.Helper()
+	fields := decodeProto(t, b)
+	for _, num := range path {
+		if len(fields[num]) == 0 {
+			t.Fatalf("нет поля %d в пути %v", num, path)
+		}
+		fields = decodeProto(t, fields[num][0].bytes)
+	}
+	return fields
+}
+
+// protoAttributes разбирает повторяющиеся KeyValue: строки и целые числа
+func protoAttributes(t *testing.T, fields []protoField) map[string]interface{} {
+	t.Helper()
+	attrs := make(map[string]interface{})
+	for _, f := range fields {
+		kv := decodeProto(t, f.bytes)
+		value := decodeProto(t, kv[2][0].bytes)
+		key := string(kv[1][0].bytes)
+		switch {
+		case len(value[1]) > 0:
+			attrs[key] = string(value[1][0].bytes)
+		case len(value[3]) > 0:
+			attrs[key] = int64(value[3][0].number)
+		}
+	}
+	return attrs
+}
+
+// ===== Тесты =====
+
+func TestIDBytes(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		id   string
+		size int
+		want string
+	}{
+		{"trace_id", "4bf92f3577b34da6a3ce929d0e0e4736", traceIDSize, "4bf92f3577b34da6a3ce929d0e0e4736"},
+		{"span_id", "00f067aa0ba902b7", spanIDSize, "00f067aa0ba902b7"},
+		{"короткий", "abc", spanIDSize, "0000000000000abc"},
+		{"длинный", "4bf92f3577b34da6a3ce929d0e0e4736", spanIDSize, "a3ce929d0e0e4736"},
+		{"пустой", "", spanIDSize, ""},
+	} {
+		if got := hex.EncodeToString(idBytes(tc.id, tc.size)); got != tc.want {
+			t.Errorf("%s: %s, ожидалось %s", tc.name, got, tc.want)
+		}
+	}
+
+	// Не-hex идентификатор хешируется в идентификатор нужного размера
+	first := idBytes("req-42", traceIDSize)
+	if len(first) != traceIDSize || !bytes.Equal(first, idBytes("req-42", traceIDSize)) {
+		t.Errorf("хеш не-hex идентификатора %x нестабилен или не того размера", first)
+	}
+	if bytes.Equal(first, idBytes("req-43", traceIDSize)) {
+		t.Error("разные идентификаторы дали один хеш")
+	}
+}
+
+func TestJSONFloat(t *testing.T) {
+	for _, tc := range []struct {
+		value float64
+		want  string
+	}{
+		{math.NaN(), `"NaN"`},
+		{math.Inf(1), `"Infinity"`},
+		{math.Inf(-1), `"-Infinity"`},
+		{1.5, `1.5`},
+		{0, `0`},
+		{1e21, `1e+21`},
+	} {
+		data, err := json.Marshal(jsonFloat(tc.value))
+		if err != nil {
+			t.Fatalf("%v: %v", tc.value, err)
+		}
+		if string(data) != tc.want {
+			t.Errorf("%v: %s, ожидалось %s", tc.value, data, tc.want)
+		}
+	}
+}
+
+func TestExportLogsProtobuf(t *testing.T) {
+	stub := newOTLPStub(t, nil)
+	c := newTestClient(t, stub.URL, "protobuf")
+	if err := c.ExportLogs([]models.LogEntry{testEntry}, "black_friday", map[string]string{"env": "prod"}, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	requests := stub.received()
+	if len(requests) != 1 {
+		t.Fatalf("запросов %d, ожидался 1", len(requests))
+	}
+	req := requests[0]
+	if req.path != logsPath || req.header.Get("Content-Type") != "application/x-protobuf" || req.header.Get("Authorization") != "Bearer test" {
+		t.Errorf("запрос %s с заголовками %v", req.path, req.header)
+	}
+
+	resource := message(t, req.body, 1, 1)
+	wantResource := map[string]interface{}{
+		"deployment.environment": "test",
+		"env":                    "prod",
+		"service.name":           "cart",
+		"simulator.scenario":     "black_friday",
+	}
+	if got := protoAttributes(t, resource[1]); !reflect.DeepEqual(got, wantResource) {
+		t.Errorf("атрибуты ресурса %v, ожидалось %v", got, wantResource)
+	}
+	scope := message(t, req.body, 1, 2, 1)
+	if string(scope[1][0].bytes) != instrumentationScope.Name {
+		t.Errorf("инструментация %q", scope[1][0].bytes)
+	}
+
+	record := message(t, req.body, 1, 2, 2)
+	if got := record[1][0].number; got != uint64(testEntry.Timestamp.UnixNano()) {
+		t.Errorf("time_unix_nano %d", got)
+	}
+	if record[2][0].number != 17 || string(record[3][0].bytes) != "ERROR" {
+		t.Errorf("severity %d %q, ожидалось 17 ERROR", record[2][0].number, record[3][0].bytes)
+	}
+	if body := decodeProto(t, record[5][0].bytes); string(body[1][0].bytes) != testEntry.Message {
+		t.Errorf("тело %q", body[1][0].bytes)
+	}
+	if got := hex.EncodeToString(record[9][0].bytes); got != testEntry.TraceID {
+		t.Errorf("trace_id %s", got)
+	}
+	if got := hex.EncodeToString(record[10][0].bytes); got != testEntry.SpanID {
+		t.Errorf("span_id %s", got)
+	}
+	attrs := protoAttributes(t, record[6])
+	for key, want := range map[string]interface{}{
+		"http.request.method":       "GET",
+		"url.path":                  "/api/cart",
+		"url.query":                 "id=7",
+		"http.response.status_code": int64(500),
+		"duration_ms":               int64(250),
+		"exception.message":         "timeout",
+	} {
+		if attrs[key] != want {
+			t.Errorf("атрибут %s = %v, ожидалось %v", key, attrs[key], want)
+		}
+	}
+}
+
+func TestExportTracesJSON(t *testing.T) {
+	stub := newOTLPStub(t, nil)
+	c := newTestClient(t, stub.URL, "json")
+	noTrace := testEntry
+	noTrace.TraceID, noTrace.SpanID = "", ""
+	if err := c.ExportTraces([]models.LogEntry{testEntry, noTrace}, "", nil, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	requests := stub.received()
+	if len(requests) != 1 || requests[0].path != tracesPath || requests[0].header.Get("Content-Type") != "application/json" {
+		t.Fatalf("запросы %+v", requests)
+	}
+	var req struct {
+		ResourceSpans []struct {
+			ScopeSpans []struct {
+				Spans []map[string]interface{} `json:"spans"`
+			} `json:"scopeSpans"`
+		} `json:"resourceSpans"`
+	}
+	if err := json.Unmarshal(requests[0].body, &req); err != nil {
+		t.Fatal(err)
+	}
+	spans := req.ResourceSpans[0].ScopeSpans[0].Spans
+	if len(spans) != 1 {
+		t.Fatalf("спанов %d, ожидался 1: запись без trace_id пропускается", len(spans))
+	}
+	span := spans[0]
+	end := testEntry.Timestamp.UnixNano()
+	start := testEntry.Timestamp.Add(-250 * time.Millisecond).UnixNano()
+	for key, want := range map[string]interface{}{
+		"traceId":           testEntry.TraceID,
+		"spanId":            testEntry.SpanID,
+		"parentSpanId":      "a3ce929d0e0e4736",
+		"name":              "GET /api/cart",
+		"kind":              float64(spanKindServer),
+		"startTimeUnixNano": strconv.FormatInt(start, 10),
+		"endTimeUnixNano":   strconv.FormatInt(end, 10),
+	} {
+		if span[key] != want {
+			t.Errorf("%s = %v, ожидалось %v", key, span[key], want)
+		}
+	}
+	status := span["status"].(map[string]interface{})
+	if status["code"] != float64(statusCodeError) || status["message"] != "timeout" {
+		t.Errorf("статус %v", status)
+	}
+}
+
+func TestExportMetricsJSON(t *testing.T) {
+	stub := newOTLPStub(t, nil)
+	c := newTestClient(t, stub.URL, "json")
+	created := time.Date(2024, 1, 1, 11, 0, 0, 0, time.UTC)
+	families := []models.MetricFamily{
+		{Name: "requests_total", Type: "counter", Help: "Total requests", Series: []models.MetricSeries{
+			{Labels: map[string]string{"service": "cart"}, Value: 5, Created: created},
+		}},
+		{Name: "ratio", Type: "gauge", Series: []models.MetricSeries{{Value: math.Inf(1)}}},
+		{Name: "latency_ms", Type: "histogram", Series: []models.MetricSeries{
+			{Bounds: []float64{10, 100}, BucketCounts: []uint64{1, 2, 3}, Count: 6, Sum: 321},
+		}},
+		{Name: "latency_summary_ms", Type: "summary", Series: []models.MetricSeries{
+			{Count: 0, Sum: 0, Quantiles: []models.QuantileValue{{Quantile: 0.5, Value: math.NaN()}}},
+		}},
+	}
+	if err := c.ExportMetrics(families, testEntry.Timestamp, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	requests := stub.received()
+	if len(requests) != 1 || requests[0].path != metricsPath {
+		t.Fatalf("запросы %+v", requests)
+	}
+	var req struct {
+		ResourceMetrics []struct {
+			ScopeMetrics []struct {
+				Metrics []map[string]json.RawMessage `json:"metrics"`
+			} `json:"scopeMetrics"`
+		} `json:"resourceMetrics"`
+	}
+	if err := json.Unmarshal(requests[0].body, &req); err != nil {
+		t.Fatalf("тело не JSON: %v\n%s", err, requests[0].body)
+	}
+	metrics := req.ResourceMetrics[0].ScopeMetrics[0].Metrics
+	if len(metrics) != 4 {
+		t.Fatalf("метрик %d, ожидалось 4", len(metrics))
+	}
+	for i, want := range []struct{ field, contains string }{
+		{"sum", `"startTimeUnixNano":"` + strconv.FormatInt(created.UnixNano(), 10) + `","timeUnixNano":"` + strconv.FormatInt(testEntry.Timestamp.UnixNano(), 10) + `","asDouble":5`},
+		{"gauge", `"asDouble":"Infinity"`},
+		{"histogram", `"count":"6","sum":321,"bucketCounts":["1","2","3"],"explicitBounds":[10,100]`},
+		{"summary", `"quantileValues":[{"quantile":0.5,"value":"NaN"}]`},
+	} {
+		data, ok := metrics[i][want.field]
+		if !ok {
+			t.Errorf("метрика %d: нет поля %s", i, want.field)
+			continue
+		}
+		if !bytes.Contains(data, []byte(want.contains)) {
+			t.Errorf("метрика %d: %s не содержит %s", i, data, want.contains)
+		}
+	}
+	if !bytes.Contains(metrics[0]["sum"], []byte(`"aggregationTemporality":2,"isMonotonic":true`)) {
+		t.Errorf("счетчик не монотонная кумулятивная сумма: %s", metrics[0]["sum"])
+	}
+}
+
+func TestExportMetricsProtobufHistogram(t *testing.T) {
+	stub := newOTLPStub(t, nil)
+	c := newTestClient(t, stub.URL, "protobuf")
+	families := []models.MetricFamily{{Name: "latency_ms", Type: "histogram", Series: []models.MetricSeries{
+		{Labels: map[string]string{"service": "cart"}, Bounds: []float64{10, 100}, BucketCounts: []uint64{1, 2, 3}, Count: 6, Sum: 321},
+	}}}
+	if err := c.ExportMetrics(families, testEntry.Timestamp, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	body := stub.received()[0].body
+	metric := message(t, body, 1, 2, 2)
+	if string(metric[1][0].bytes) != "latency_ms" {
+		t.Errorf("имя метрики %q", metric[1][0].bytes)
+	}
+	hist := message(t, metric[9][0].bytes)
+	if hist[2][0].number != aggregationCumulative {
+		t.Errorf("темпоральность %d", hist[2][0].number)
+	}
+	point := message(t, hist[1][0].bytes)
+	if point[4][0].number != 6 || math.Float64frombits(point[5][0].number) != 321 {
+		t.Errorf("count %d sum %v", point[4][0].number, math.Float64frombits(point[5][0].number))
+	}
+
+	// Корзины и границы упакованы как fixed64
+	var counts []uint64
+	for b := point[6][0].bytes; len(b) > 0; b = b[8:] {
+		v, _ := protowire.ConsumeFixed64(b)
+		counts = append(counts, v)
+	}
+	var bounds []float64
+	for b := point[7][0].bytes; len(b) > 0; b = b[8:] {
+		v, _ := protowire.ConsumeFixed64(b)
+		bounds = append(bounds, math.Float64frombits(v))
+	}
+	if len(counts) != 3 || counts[2] != 3 || len(bounds) != 2 || bounds[1] != 100 {
+		t.Errorf("корзины %v, границы %v", counts, bounds)
+	}
+	if attrs := protoAttributes(t, point[9]); attrs["service"] != "cart" {
+		t.Errorf("атрибуты точки %v", attrs)
+	}
+}
+
+func TestExportRetries(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		status   int
+		requests int
+		fails    bool
+	}{
+		{"503 повторяется", http.StatusServiceUnavailable, 2, false},
+		{"429 до исчерпания попыток", http.StatusTooManyRequests, 3, true},
+		{"400 не повторяется", http.StatusBadRequest, 1, true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			stub := newOTLPStub(t, func(n int) int {
+				if tc.status == http.StatusServiceUnavailable && n > 0 {
+					return http.StatusOK
+				}
+				return tc.status
+			})
+			c := newTestClient(t, stub.URL, "json")
+			err := c.ExportLogs([]models.LogEntry{testEntry}, "", nil, nil)
+			if (err != nil) != tc.fails {
+				t.Errorf("ошибка %v", err)
+			}
+			if got := len(stub.received()); got != tc.requests {
+				t.Errorf("запросов %d, ожидалось %d", got, tc.requests)
+			}
+		})
+	}
+}
+
+func TestNewClientValidation(t *testing.T) {
+	if _, err := NewClient(Config{}); err == nil {
+		t.Error("клиент без адреса создан")
+	}
+	if _, err := NewClient(Config{Endpoint: "http://localhost:4318", Encoding: "xml"}); err == nil {
+		t.Error("клиент с неизвестной кодировкой создан")
+	}
+}
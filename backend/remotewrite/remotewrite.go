@@ -0,0 +1,345 @@
+package remotewrite
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/snappy"
+	"google.golang.org/protobuf/encoding/protowire"
+
+	"log-metrics-simulator/generator"
+	"log-metrics-simulator/models"
+)
+
+// Config задает параметры отправки метрик по протоколу Prometheus remote-write
+type Config struct {
+	URL            string            // Адрес приемника, например http://mimir:9009/api/v1/push
+	Interval       time.Duration     // Период отправки текущих значений
+	Timeout        time.Duration     // Таймаут HTTP-запроса
+	Headers        map[string]string // Дополнительные заголовки (X-Scope-OrgID, Authorization)
+	ExternalLabels map[string]string // Метки, добавляемые ко всем сериям
+	MaxRetries     int
+	MinBackoff     time.Duration
+	MaxBackoff     time.Duration
+	// MaxSamplesPerSend ограничивает размер запроса при догрузке истории
+	MaxSamplesPerSend int
+}
+
+// BackfillRequest описывает догрузку истории метрик с прошлыми метками
+// времени; выполняется как задание генерации истории (см. JobRequest)
+type BackfillRequest struct {
+	From        time.Time `json:"from" binding:"required"`
+	To          time.Time `json:"to"`             // По умолчанию - текущее время
+	Step        string    `json:"step"`           // Интервал между точками, по умолчанию 1m
+	LogsPerStep int       `json:"logs_per_step"`  // Логов на точку, по умолчанию 100
+	Scenario    string    `json:"scenario"`       // Сценарий генерации
+	Seed        *int64    `json:"seed,omitempty"` // Seed для воспроизводимой истории
+}
+
+type sample struct {
+	value     float64
+	timestamp int64 // Миллисекунды Unix
+}
+
+type timeSeries struct {
+	labels  []label // Отсортированы по имени, включая __name__
+	samples []sample
+}
+
+type label struct {
+	name, value string
+}
+
+// Sender периодически отправляет снимок реестра метрик по протоколу remote-write
+type Sender struct {
+	config Config
+	client *http.Client
+	stop   chan struct{}
+	once   sync.Once
+}
+
+func New(config Config) (*Sender, error) {
+	if config.URL == "" {
+		return nil, fmt.Errorf("не указан адрес remote-write")
+	}
+	if config.Interval <= 0 {
+		config.Interval = 15 * time.Second
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = 30 * time.Second
+	}
+	if config.MinBackoff <= 0 {
+		config.MinBackoff = 500 * time.Millisecond
+	}
+	if config.MaxBackoff < config.MinBackoff {
+		config.MaxBackoff = 30 * time.Second
+	}
+	if config.MaxSamplesPerSend <= 0 {
+		config.MaxSamplesPerSend = 5000
+	}
+
+	return &Sender{
+		config: config,
+		client: &http.Client{Timeout: config.Timeout},
+		stop:   make(chan struct{}),
+	}, nil
+}
+
+// Start запускает периодическую отправку
+func (s *Sender) Start() {
+	go func() {
+		ticker := time.NewTicker(s.config.Interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				if err := s.pushSnapshot(time.Now()); err != nil {
+					log.Printf("❌ Ошибка отправки метрик remote-write: %v", err)
+				}
+			case <-s.stop:
+				return
+			}
+		}
+	}()
+	log.Printf("📤 Remote-write включен: %s, интервал %s", s.config.URL, s.config.Interval)
+}
+
+// Stop останавливает периодическую отправку и прерывает догрузку истории
+func (s *Sender) Stop() {
+	s.once.Do(func() { close(s.stop) })
+}
+
+// pushSnapshot отправляет текущие значения всех серий с меткой времени ts
+func (s *Sender) pushSnapshot(ts time.Time) error {
+	metrics := generator.GetMetrics()
+	if len(metrics) == 0 {
+		return nil
+	}
+
+	series := make([]*timeSeries, 0, len(metrics))
+	for _, m := range metrics {
+		series = append(series, &timeSeries{
+			labels:  s.seriesLabels(m),
+			samples: []sample{{value: m.Value, timestamp: ts.UnixMilli()}},
+		})
+	}
+	return s.send(series)
+}
+
+// HistoryWriter накапливает снимки метрик с прошлыми метками времени и
+// отправляет их запросами до MaxSamplesPerSend выборок
+type HistoryWriter struct {
+	s       *Sender
+	pending map[string]*timeSeries
+	order   []string
+	samples int
+	total   int
+}
+
+func (s *Sender) NewHistoryWriter() *HistoryWriter {
+	return &HistoryWriter{s: s, pending: make(map[string]*timeSeries)}
+}
+
+// Write добавляет снимок метрик на момент ts
+func (w *HistoryWriter) Write(ts time.Time, metrics []models.Metric) error {
+	select {
+	case <-w.s.stop:
+		return fmt.Errorf("отправка остановлена")
+	default:
+	}
+
+	for _, m := range metrics {
+		labels := w.s.seriesLabels(m)
+		key := seriesKey(labels)
+		series, ok := w.pending[key]
+		if !ok {
+			series = &timeSeries{labels: labels}
+			w.pending[key] = series
+			w.order = append(w.order, key)
+		}
+		series.samples = append(series.samples, sample{value: m.Value, timestamp: ts.UnixMilli()})
+		w.samples++
+	}
+
+	if w.samples >= w.s.config.MaxSamplesPerSend {
+		return w.Flush()
+	}
+	return nil
+}
+
+// Flush отправляет накопленные выборки
+func (w *HistoryWriter) Flush() error {
+	if w.samples == 0 {
+		return nil
+	}
+	batch := make([]*timeSeries, 0, len(w.order))
+	for _, key := range w.order {
+		batch = append(batch, w.pending[key])
+	}
+	if err := w.s.send(batch); err != nil {
+		return err
+	}
+	w.total += w.samples
+	w.pending = make(map[string]*timeSeries)
+	w.order = w.order[:0]
+	w.samples = 0
+	return nil
+}
+
+// JobRequest преобразует запрос в задание генерации истории, которое
+// отправляет метрики периода через remote-write. Ход задания и отмена
+// доступны через API заданий генерации истории.
+func (req BackfillRequest) JobRequest() (models.BackfillJobRequest, error) {
+	step := time.Minute
+	if req.Step != "" {
+		parsed, err := time.ParseDuration(req.Step)
+		if err != nil || parsed <= 0 {
+			return models.BackfillJobRequest{}, fmt.Errorf("неверный шаг: %s", req.Step)
+		}
+		step = parsed
+	}
+	logsPerStep := req.LogsPerStep
+	if logsPerStep <= 0 {
+		logsPerStep = 100
+	}
+	scenario := req.Scenario
+	if scenario == "" {
+		scenario = "normal_load"
+	}
+
+	return models.BackfillJobRequest{
+		From:            req.From,
+		To:              req.To,
+		Step:            step.String(),
+		EventsPerSecond: float64(logsPerStep) / step.Seconds(),
+		Scenario:        scenario,
+		Seed:            req.Seed,
+		RemoteWrite:     true,
+	}, nil
+}
+
+// seriesLabels собирает отсортированные метки серии с __name__ и внешними метками
+func (s *Sender) seriesLabels(m models.Metric) []label {
+	labels := make([]label, 0, len(m.Labels)+len(s.config.ExternalLabels)+1)
+	labels = append(labels, label{"__name__", m.Name})
+	for k, v := range s.config.ExternalLabels {
+		if _, ok := m.Labels[k]; !ok {
+			labels = append(labels, label{k, v})
+		}
+	}
+	for k, v := range m.Labels {
+		labels = append(labels, label{k, v})
+	}
+	sort.Slice(labels, func(i, j int) bool { return labels[i].name < labels[j].name })
+	return labels
+}
+
+func seriesKey(labels []label) string {
+	var b strings.Builder
+	for _, l := range labels {
+		b.WriteString(l.name)
+		b.WriteByte('=')
+		b.WriteString(l.value)
+		b.WriteByte(0)
+	}
+	return b.String()
+}
+
+// send отправляет серии одним запросом с повторами при 429, 5xx и сетевых ошибках
+func (s *Sender) send(series []*timeSeries) error {
+	body := snappy.Encode(nil, encodeWriteRequest(series))
+
+	backoff := s.config.MinBackoff
+	for attempt := 0; ; attempt++ {
+		retry, err := s.post(body)
+		if err == nil || !retry || attempt >= s.config.MaxRetries {
+			return err
+		}
+
+		select {
+		case <-time.After(backoff):
+		case <-s.stop:
+			return err
+		}
+
+		backoff *= 2
+		if backoff > s.config.MaxBackoff {
+			backoff = s.config.MaxBackoff
+		}
+	}
+}
+
+// post выполняет один запрос и сообщает, можно ли его повторить
+func (s *Sender) post(body []byte) (bool, error) {
+	req, err := http.NewRequest(http.MethodPost, s.config.URL, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	req.Header.Set("Content-Encoding", "snappy")
+	req.Header.Set("X-Prometheus-Remote-Write-Version", "0.1.0")
+	req.Header.Set("User-Agent", "log-metrics-simulator")
+	for k, v := range s.config.Headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		io.Copy(io.Discard, resp.Body)
+		return false, nil
+	}
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	retry := resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+	return retry, fmt.Errorf("HTTP %d: %s", resp.StatusCode, strings.TrimSpace(string(msg)))
+}
+
+// encodeWriteRequest кодирует prometheus.WriteRequest:
+//
+//	WriteRequest { repeated TimeSeries timeseries = 1; }
+//	TimeSeries   { repeated Label labels = 1; repeated Sample samples = 2; }
+//	Label        { string name = 1; string value = 2; }
+//	Sample       { double value = 1; int64 timestamp = 2; }
+func encodeWriteRequest(series []*timeSeries) []byte {
+	var req []byte
+	for _, ts := range series {
+		var tb []byte
+		for _, l := range ts.labels {
+			var lb []byte
+			lb = protowire.AppendTag(lb, 1, protowire.BytesType)
+			lb = protowire.AppendString(lb, l.name)
+			lb = protowire.AppendTag(lb, 2, protowire.BytesType)
+			lb = protowire.AppendString(lb, l.value)
+
+			tb = protowire.AppendTag(tb, 1, protowire.BytesType)
+			tb = protowire.AppendBytes(tb, lb)
+		}
+		for _, smp := range ts.samples {
+			var sb []byte
+			sb = protowire.AppendTag(sb, 1, protowire.Fixed64Type)
+			sb = protowire.AppendFixed64(sb, math.Float64bits(smp.value))
+			sb = protowire.AppendTag(sb, 2, protowire.VarintType)
+			sb = protowire.AppendVarint(sb, uint64(smp.timestamp))
+
+			tb = protowire.AppendTag(tb, 2, protowire.BytesType)
+			tb = protowire.AppendBytes(tb, sb)
+		}
+
+		req = protowire.AppendTag(req, 1, protowire.BytesType)
+		req = protowire.AppendBytes(req, tb)
+	}
+	return req
+}
@@ -0,0 +1,356 @@
+package remotewrite
+
+import (
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang/snappy"
+	"google.golang.org/protobuf/encoding/protowire"
+
+	"log-metrics-simulator/generator"
+	"log-metrics-simulator/models"
+)
+
+// receiver - HTTP-заглушка приемника remote-write, отвечающая кодами из
+// statuses по очереди, после них - 204
+type receiver struct {
+	*httptest.Server
+
+	mutex    sync.Mutex
+	statuses []int
+	headers  []http.Header
+	bodies   [][]byte
+}
+
+func newReceiver(t *testing.T, statuses ...int) *receiver {
+	rcv := &receiver{statuses: statuses}
+	rcv.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+
+		rcv.mutex.Lock()
+		rcv.headers = append(rcv.headers, r.Header.Clone())
+		rcv.bodies = append(rcv.bodies, body)
+		status := http.StatusNoContent
+		if len(rcv.statuses) > 0 {
+			status, rcv.statuses = rcv.statuses[0], rcv.statuses[1:]
+		}
+		rcv.mutex.Unlock()
+
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(rcv.Close)
+	return rcv
+}
+
+func (r *receiver) requests() int {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return len(r.bodies)
+}
+
+func newTestSender(t *testing.T, url string, config Config) *Sender {
+	config.URL = url
+	config.MinBackoff = time.Millisecond
+	config.MaxBackoff = 5 * time.Millisecond
+	s, err := New(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(s.Stop)
+	return s
+}
+
+// decodeWriteRequest разбирает тело запроса: snappy и prometheus.WriteRequest
+func decodeWriteRequest(t *testing.T, body []byte) []timeSeries {
+	t.Helper()
+	data, err := snappy.Decode(nil, body)
+	if err != nil {
+		t.Fatalf("тело не сжато snappy: %v", err)
+	}
+
+	var result []timeSeries
+	forEachField(t, data, func(num protowire.Number, _ protowire.Type, ts []byte) {
+		if num != 1 {
+			t.Fatalf("неожиданное поле WriteRequest %d", num)
+		}
+		var series timeSeries
+		forEachField(t, ts, func(num protowire.Number, _ protowire.Type, value []byte) {
+			switch num {
+			case 1:
+				var l label
+				forEachField(t, value, func(num protowire.Number, _ protowire.Type, v []byte) {
+					if num == 1 {
+						l.name = string(v)
+					} else {
+						l.value = string(v)
+					}
+				})
+				series.labels = append(series.labels, l)
+			case 2:
+				var smp sample
+				forEachField(t, value, func(num protowire.Number, typ protowire.Type, v []byte) {
+					switch {
+					case num == 1 && typ == protowire.Fixed64Type:
+						bits, _ := protowire.ConsumeFixed64(v)
+						smp.value = math.Float64frombits(bits)
+					case num == 2 && typ == protowire.VarintType:
+						ts, _ := protowire.ConsumeVarint(v)
+						smp.timestamp = int64(ts)
+					default:
+						t.Fatalf("неожиданное поле Sample %d типа %d", num, typ)
+					}
+				})
+				series.samples = append(series.samples, smp)
+			default:
+				t.Fatalf("неожиданное поле TimeSeries %d", num)
+			}
+		})
+		result = append(result, series)
+	})
+	return result
+}
+
+// forEachField перебирает поля сообщения. Для полей с длиной передается
+// содержимое, для остальных - закодированное значение.
+func forEachField(t *testing.T, data []byte, fn func(num protowire.Number, typ protowire.Type, value []byte)) {
+	t.Helper()
+	for len(data) > 0 {
+		num, typ, n := protowire.ConsumeTag(data)
+		if n < 0 {
+			t.Fatalf("ошибка разбора тега: %v", protowire.ParseError(n))
+		}
+		data = data[n:]
+
+		var value []byte
+		if typ == protowire.BytesType {
+			v, m := protowire.ConsumeBytes(data)
+			if m < 0 {
+				t.Fatalf("ошибка разбора поля %d: %v", num, protowire.ParseError(m))
+			}
+			value, n = v, m
+		} else {
+			n = protowire.ConsumeFieldValue(num, typ, data)
+			if n < 0 {
+				t.Fatalf("ошибка разбора поля %d: %v", num, protowire.ParseError(n))
+			}
+			value = data[:n]
+		}
+		fn(num, typ, value)
+		data = data[n:]
+	}
+}
+
+func TestSendEncodesWriteRequest(t *testing.T) {
+	rcv := newReceiver(t)
+	s := newTestSender(t, rcv.URL, Config{
+		Headers:        map[string]string{"X-Scope-OrgID": "team-a"},
+		ExternalLabels: map[string]string{"env": "test", "service": "ignored"},
+	})
+
+	ts := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	w := s.NewHistoryWriter()
+	for i := 0; i < 2; i++ {
+		at := ts.Add(time.Duration(i) * time.Minute)
+		err := w.Write(at, []models.Metric{
+			{Name: "requests_total", Value: float64(10 + i), Labels: map[string]string{"service": "cart", "status": "200"}},
+			{Name: "temperature", Value: -1.5},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	if rcv.requests() != 1 {
+		t.Fatalf("запросов %d, ожидался один", rcv.requests())
+	}
+	header := rcv.headers[0]
+	for name, want := range map[string]string{
+		"Content-Type":                      "application/x-protobuf",
+		"Content-Encoding":                  "snappy",
+		"X-Prometheus-Remote-Write-Version": "0.1.0",
+		"X-Scope-Orgid":                     "team-a",
+	} {
+		if got := header.Get(name); got != want {
+			t.Errorf("заголовок %s = %q, ожидался %q", name, got, want)
+		}
+	}
+
+	want := []timeSeries{
+		{
+			labels:  []label{{"__name__", "requests_total"}, {"env", "test"}, {"service", "cart"}, {"status", "200"}},
+			samples: []sample{{10, ts.UnixMilli()}, {11, ts.Add(time.Minute).UnixMilli()}},
+		},
+		{
+			labels:  []label{{"__name__", "temperature"}, {"env", "test"}, {"service", "ignored"}},
+			samples: []sample{{-1.5, ts.UnixMilli()}, {-1.5, ts.Add(time.Minute).UnixMilli()}},
+		},
+	}
+	if got := decodeWriteRequest(t, rcv.bodies[0]); !reflect.DeepEqual(got, want) {
+		t.Fatalf("серии %+v, ожидались %+v", got, want)
+	}
+}
+
+func TestHistoryWriterSplitsRequests(t *testing.T) {
+	rcv := newReceiver(t)
+	s := newTestSender(t, rcv.URL, Config{MaxSamplesPerSend: 3})
+
+	w := s.NewHistoryWriter()
+	ts := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	for i := 0; i < 5; i++ {
+		if err := w.Write(ts.Add(time.Duration(i)*time.Minute), []models.Metric{{Name: "m", Value: float64(i)}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	var samples []int
+	for _, body := range rcv.bodies {
+		n := 0
+		for _, series := range decodeWriteRequest(t, body) {
+			n += len(series.samples)
+		}
+		samples = append(samples, n)
+	}
+	if !reflect.DeepEqual(samples, []int{3, 2}) {
+		t.Fatalf("выборок в запросах %v, ожидалось [3 2]", samples)
+	}
+}
+
+func TestSendRetries(t *testing.T) {
+	tests := []struct {
+		name         string
+		statuses     []int
+		maxRetries   int
+		wantRequests int
+		wantErr      bool
+	}{
+		{"успех", nil, 3, 1, false},
+		{"повтор после 429 и 5xx", []int{429, 503, 500}, 3, 4, false},
+		{"без повтора на 4xx", []int{400}, 3, 1, true},
+		{"без повтора на 404", []int{404}, 3, 1, true},
+		{"исчерпаны повторы", []int{500, 502, 503, 504}, 2, 3, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rcv := newReceiver(t, tt.statuses...)
+			s := newTestSender(t, rcv.URL, Config{MaxRetries: tt.maxRetries})
+
+			err := s.send([]*timeSeries{{labels: []label{{"__name__", "m"}}, samples: []sample{{1, 1}}}})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ошибка %v, ожидалась ошибка: %v", err, tt.wantErr)
+			}
+			if rcv.requests() != tt.wantRequests {
+				t.Fatalf("запросов %d, ожидалось %d", rcv.requests(), tt.wantRequests)
+			}
+			for i := 1; i < len(rcv.bodies); i++ {
+				if string(rcv.bodies[i]) != string(rcv.bodies[0]) {
+					t.Fatal("повтор отправил другое тело")
+				}
+			}
+		})
+	}
+}
+
+func TestSendStopsRetryingOnStop(t *testing.T) {
+	rcv := newReceiver(t, 503, 503, 503)
+	s := newTestSender(t, rcv.URL, Config{MaxRetries: 10})
+	s.config.MinBackoff = time.Hour
+	s.config.MaxBackoff = time.Hour
+	s.Stop()
+
+	if err := s.send([]*timeSeries{{labels: []label{{"__name__", "m"}}}}); err == nil {
+		t.Fatal("ожидалась ошибка 503")
+	}
+	if rcv.requests() != 1 {
+		t.Fatalf("запросов %d после остановки, ожидался один", rcv.requests())
+	}
+}
+
+func TestBackfillJobRequest(t *testing.T) {
+	from := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	req, err := BackfillRequest{From: from, Step: "30s", LogsPerStep: 60}.JobRequest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := models.BackfillJobRequest{
+		From:            from,
+		Step:            "30s",
+		EventsPerSecond: 2,
+		Scenario:        "normal_load",
+		RemoteWrite:     true,
+	}
+	if !reflect.DeepEqual(req, want) {
+		t.Fatalf("задание %+v, ожидалось %+v", req, want)
+	}
+
+	if req, _ := (BackfillRequest{From: from}).JobRequest(); req.Step != "1m0s" || math.Abs(req.EventsPerSecond-100.0/60) > 1e-9 {
+		t.Errorf("по умолчанию шаг %s и %v событий в секунду", req.Step, req.EventsPerSecond)
+	}
+	for _, step := range []string{"abc", "0s", "-1m"} {
+		if _, err := (BackfillRequest{From: from, Step: step}).JobRequest(); err == nil {
+			t.Errorf("шаг %q принят", step)
+		}
+	}
+}
+
+func TestBackfillRunsAsJob(t *testing.T) {
+	rcv := newReceiver(t)
+	s := newTestSender(t, rcv.URL, Config{})
+
+	seed := int64(1)
+	to := time.Date(2024, 1, 1, 0, 10, 0, 0, time.UTC)
+	req, err := BackfillRequest{From: to.Add(-10 * time.Minute), To: to, LogsPerStep: 10, Seed: &seed}.JobRequest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	job, err := generator.StartBackfill(req, s.NewHistoryWriter())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	deadline := time.Now().Add(10 * time.Second)
+	for job.Status == "running" {
+		if time.Now().After(deadline) {
+			t.Fatal("задание не завершилось")
+		}
+		time.Sleep(5 * time.Millisecond)
+		if job, err = generator.GetBackfillJob(job.ID); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// 10 логов на шаг: целые события накапливаются с точностью до одного
+	if job.Status != "completed" || job.Generated < 99 || job.Generated > 100 {
+		t.Fatalf("задание %+v", job)
+	}
+
+	// Выборки всех десяти шагов отправлены с метками времени периода
+	stamps := make(map[int64]bool)
+	rcv.mutex.Lock()
+	for _, body := range rcv.bodies {
+		for _, series := range decodeWriteRequest(t, body) {
+			for _, smp := range series.samples {
+				stamps[smp.timestamp] = true
+			}
+		}
+	}
+	rcv.mutex.Unlock()
+	if len(stamps) != 10 {
+		t.Fatalf("отправлены выборки на %d моментов, ожидалось 10", len(stamps))
+	}
+	for ts := range stamps {
+		if at := time.UnixMilli(ts); at.After(to) || !at.After(req.From) {
+			t.Errorf("выборка вне периода: %s", at.UTC())
+		}
+	}
+}
@@ -1,12 +1,17 @@
 package scenarios
 
 import (
+	"encoding/json"
 	"fmt"
 	"log"
+	"math"
 	"strconv"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"log-metrics-simulator/clock"
+	"log-metrics-simulator/sinks"
 	"log-metrics-simulator/storage"
 
 	"github.com/robfig/cron/v3"
@@ -20,23 +25,30 @@ type ScenarioManager struct {
 	activeScenarios  map[string]*models.Scenario
 	schedules        map[string]*models.Schedule
 	activeChains     map[string]*models.ChainExecution // Активные выполнения цепочек
-	cronScheduler    *cron.Cron
+	clock            clock.Clock
+	cronScheduler    *scheduler
 	cronEntries      map[string]cron.EntryID
 	chainSchedules   map[string]*models.ChainSchedule
 	chainCronEntries map[string]cron.EntryID
 	mutex            sync.RWMutex
 	stopChan         chan struct{}
+	lastID           atomic.Int64 // Последний выданный идентификатор, наносекунды
 }
 
-func NewScenarioManager(storage storage.Storage) *ScenarioManager {
-	c := cron.New(cron.WithSeconds())
+// NewScenarioManager создает менеджер, который отсчитывает даты, задержки и
+// расписания по часам clk; nil - системные часы
+func NewScenarioManager(storage storage.Storage, clk clock.Clock) *ScenarioManager {
+	if clk == nil {
+		clk = clock.New()
+	}
 
 	sm := &ScenarioManager{
 		storage:          storage,
+		clock:            clk,
 		activeScenarios:  make(map[string]*models.Scenario),
 		schedules:        make(map[string]*models.Schedule),
 		activeChains:     make(map[string]*models.ChainExecution),
-		cronScheduler:    c,
+		cronScheduler:    newScheduler(clk),
 		cronEntries:      make(map[string]cron.EntryID),
 		chainSchedules:   make(map[string]*models.ChainSchedule),
 		chainCronEntries: make(map[string]cron.EntryID),
@@ -45,6 +57,8 @@ func NewScenarioManager(storage storage.Storage) *ScenarioManager {
 
 	sm.restoreState()
 	sm.cronScheduler.Start()
+	go sm.watchStorage()
+	go sm.renewLeases()
 
 	log.Println("⏰ Scenario manager запущен с поддержкой цепочек")
 	return sm
@@ -57,7 +71,7 @@ var predefinedScenarios = map[string]models.ScenarioConfig{
 		Description: "Генерация высокой нагрузки",
 		LogCount:    1000,
 		Labels:      map[string]string{"test_type": "load", "environment": "testing"},
-		Parameters:  map[string]interface{}{"interval_ms": 10},
+		Parameters:  map[string]interface{}{"preset": "high_load", "interval_ms": 10},
 	},
 	"error_spike": {
 		Name:        "Error Spike",
@@ -78,7 +92,7 @@ var predefinedScenarios = map[string]models.ScenarioConfig{
 		Description: "Нормальная работа системы",
 		LogCount:    300,
 		Labels:      map[string]string{"environment": "production"},
-		Parameters:  map[string]interface{}{"error_rate": 0.05},
+		Parameters:  map[string]interface{}{"preset": "normal_load", "error_rate": 0.05},
 	},
 	"continuous_load": {
 		Name:        "Continuous Load",
@@ -89,6 +103,9 @@ var predefinedScenarios = map[string]models.ScenarioConfig{
 	},
 }
 
+// Параметры профиля генерации, которые можно задать на верхнем уровне конфигурации
+var profileKeys = []string{"preset", "error_rate", "latency_multiplier", "response_delay", "level_mix", "interval_ms"}
+
 // Предопределенные цепочки сценариев
 var predefinedChains = map[string]models.Chain{
 	"black_friday_rush": {
@@ -138,12 +155,102 @@ func (sm *ScenarioManager) Stop() {
 // ===== Методы для работы со сценариями =====
 
 func (sm *ScenarioManager) StartScenario(scenarioType string, customConfig map[string]interface{}) error {
+	return sm.startScenario(scenarioType, customConfig, nil)
+}
+
+// startScenario запускает сценарий в модельном времени warp цепочки или
+// расписания; nil - ускорение и модельное время задаются конфигурацией
+func (sm *ScenarioManager) startScenario(scenarioType string, customConfig map[string]interface{}, warp *timeWarp) error {
 	sm.mutex.Lock()
 	defer sm.mutex.Unlock()
 
+	scenarioConfig, err := buildScenarioConfig(scenarioType, customConfig)
+	if err != nil {
+		return err
+	}
+
+	// Применяем кастомную конфигурацию
+	duration := 0 * time.Second
+	interval := 0 * time.Second
+	var startDate, endDate, simulatedStart *time.Time
+
+	if customConfig != nil {
+		if dur, ok := customConfig["duration_minutes"].(float64); ok {
+			duration = time.Duration(dur) * time.Minute
+		}
+		if dur, ok := customConfig["duration_seconds"].(float64); ok {
+			duration = time.Duration(dur) * time.Second
+		}
+		if interv, ok := customConfig["interval_seconds"].(float64); ok {
+			interval = time.Duration(interv) * time.Second
+		}
+		if interv, ok := customConfig["interval_minutes"].(float64); ok {
+			interval = time.Duration(interv) * time.Minute
+		}
+		if start, ok := customConfig["start_date"].(string); ok {
+			if parsedStart, err := time.Parse(time.RFC3339, start); err == nil {
+				startDate = &parsedStart
+			}
+		}
+		if end, ok := customConfig["end_date"].(string); ok {
+			if parsedEnd, err := time.Parse(time.RFC3339, end); err == nil {
+				endDate = &parsedEnd
+			}
+		}
+		if start, ok := customConfig["simulated_start"].(string); ok {
+			parsedStart, err := time.Parse(time.RFC3339, start)
+			if err != nil {
+				return fmt.Errorf("неверный simulated_start: %v", err)
+			}
+			simulatedStart = &parsedStart
+		}
+	}
+
+	// Аренда сценария не дает другим репликам восстановить его из хранилища.
+	// Явный запуск выполняется, даже если аренду держит другая реплика.
+	sm.tryLock(scenarioLeaseKey(scenarioType), scenarioLeaseTTL)
+
+	started := sm.clock.Now()
+	if warp != nil {
+		scenarioConfig.TimeScale = warp.scale
+		simulated := warp.simulated(started)
+		simulatedStart = &simulated
+	}
+
+	out, err := sinks.NewFanout(scenarioConfig.Sinks)
+	if err != nil {
+		return fmt.Errorf("ошибка создания приемников сценария: %v", err)
+	}
+
+	scenario := &models.Scenario{
+		Type:           scenarioType,
+		Active:         true,
+		Config:         scenarioConfig,
+		Started:        started,
+		Duration:       duration,
+		Interval:       interval,
+		StartDate:      startDate,
+		EndDate:        endDate,
+		SimulatedStart: simulatedStart,
+	}
+
+	sm.activeScenarios[scenarioType] = scenario
+
+	if err := sm.storage.SaveScenario(scenario); err != nil {
+		log.Printf("❌ Ошибка сохранения сценария: %v", err)
+	}
+
+	go sm.executeScenario(scenario, out)
+
+	return nil
+}
+
+// buildScenarioConfig копирует конфигурацию предопределенного сценария и
+// применяет к ней пользовательскую конфигурацию
+func buildScenarioConfig(scenarioType string, customConfig map[string]interface{}) (models.ScenarioConfig, error) {
 	config, exists := predefinedScenarios[scenarioType]
 	if !exists {
-		return fmt.Errorf("сценарий не найден: %s", scenarioType)
+		return models.ScenarioConfig{}, fmt.Errorf("сценарий не найден: %s", scenarioType)
 	}
 
 	// Создаем копию конфигурации
@@ -153,6 +260,10 @@ func (sm *ScenarioManager) StartScenario(scenarioType string, customConfig map[s
 		LogCount:    config.LogCount,
 		Labels:      make(map[string]string),
 		Parameters:  make(map[string]interface{}),
+		Seed:        config.Seed,
+		Traces:      config.Traces,
+		Shape:       config.Shape,
+		TimeScale:   config.TimeScale,
 	}
 
 	for k, v := range config.Labels {
@@ -162,11 +273,6 @@ func (sm *ScenarioManager) StartScenario(scenarioType string, customConfig map[s
 		scenarioConfig.Parameters[k] = v
 	}
 
-	// Применяем кастомную конфигурацию
-	duration := 0 * time.Second
-	interval := 0 * time.Second
-	var startDate, endDate *time.Time
-
 	if customConfig != nil {
 		if logCount, ok := customConfig["log_count"].(float64); ok {
 			scenarioConfig.LogCount = int(logCount)
@@ -178,49 +284,86 @@ func (sm *ScenarioManager) StartScenario(scenarioType string, customConfig map[s
 				}
 			}
 		}
-		if dur, ok := customConfig["duration_minutes"].(float64); ok {
-			duration = time.Duration(dur) * time.Minute
+		if raw, ok := customConfig["seed"]; ok {
+			seed, err := parseSeed(raw)
+			if err != nil {
+				return scenarioConfig, err
+			}
+			scenarioConfig.Seed = &seed
 		}
-		if dur, ok := customConfig["duration_seconds"].(float64); ok {
-			duration = time.Duration(dur) * time.Second
+		if traces, ok := customConfig["traces"].(bool); ok {
+			scenarioConfig.Traces = traces
 		}
-		if interv, ok := customConfig["interval_seconds"].(float64); ok {
-			interval = time.Duration(interv) * time.Second
+		if scale, ok := customConfig["time_scale"].(float64); ok {
+			if err := validateTimeScale(scale); err != nil {
+				return scenarioConfig, err
+			}
+			scenarioConfig.TimeScale = scale
 		}
-		if interv, ok := customConfig["interval_minutes"].(float64); ok {
-			interval = time.Duration(interv) * time.Minute
+		if rawSinks, ok := customConfig["sinks"]; ok {
+			sinkConfigs, err := parseSinkConfigs(rawSinks)
+			if err != nil {
+				return scenarioConfig, err
+			}
+			scenarioConfig.Sinks = sinkConfigs
 		}
-		if start, ok := customConfig["start_date"].(string); ok {
-			if parsedStart, err := time.Parse(time.RFC3339, start); err == nil {
-				startDate = &parsedStart
+		if rawShape, ok := customConfig["shape"]; ok {
+			shape, err := parseTrafficShape(rawShape)
+			if err != nil {
+				return scenarioConfig, err
 			}
+			scenarioConfig.Shape = shape
 		}
-		if end, ok := customConfig["end_date"].(string); ok {
-			if parsedEnd, err := time.Parse(time.RFC3339, end); err == nil {
-				endDate = &parsedEnd
+		if params, ok := customConfig["parameters"].(map[string]interface{}); ok {
+			for k, v := range params {
+				scenarioConfig.Parameters[k] = v
+			}
+		}
+		for _, key := range profileKeys {
+			if v, ok := customConfig[key]; ok {
+				scenarioConfig.Parameters[key] = v
 			}
 		}
 	}
 
-	scenario := &models.Scenario{
-		Type:      scenarioType,
-		Active:    true,
-		Config:    scenarioConfig,
-		Started:   time.Now(),
-		Duration:  duration,
-		Interval:  interval,
-		StartDate: startDate,
-		EndDate:   endDate,
+	profile, err := generator.ParseProfile(scenarioConfig.Parameters)
+	if err != nil {
+		return scenarioConfig, fmt.Errorf("ошибка параметров сценария: %v", err)
 	}
+	scenarioConfig.Profile = profile
+	return scenarioConfig, nil
+}
 
-	sm.activeScenarios[scenarioType] = scenario
-
-	if err := sm.storage.SaveScenario(scenario); err != nil {
-		log.Printf("❌ Ошибка сохранения сценария: %v", err)
+// ResolveBackfill дополняет запрос генерации истории конфигурацией сценария
+// ScenarioType: профилем, метками, формой нагрузки, seed и приемниками.
+// Явно заданные в запросе значения сохраняются.
+func ResolveBackfill(req *models.BackfillJobRequest) error {
+	if req.ScenarioType == "" {
+		return nil
 	}
 
-	go sm.executeScenario(scenario)
+	config, err := buildScenarioConfig(req.ScenarioType, req.Config)
+	if err != nil {
+		return err
+	}
 
+	if req.Scenario == "" {
+		req.Scenario = config.Name
+	}
+	req.Profile = config.Profile
+	labels := config.Labels
+	for k, v := range req.Labels {
+		labels[k] = v
+	}
+	req.Labels = labels
+	if req.Shape == nil {
+		req.Shape = config.Shape
+	}
+	if req.Seed == nil {
+		req.Seed = config.Seed
+	}
+	req.Traces = req.Traces || config.Traces
+	req.Sinks = append(config.Sinks, req.Sinks...)
 	return nil
 }
 
@@ -243,21 +386,30 @@ func (sm *ScenarioManager) StopScenario(scenarioType string) error {
 	return nil
 }
 
-func (sm *ScenarioManager) executeScenario(scenario *models.Scenario) {
+func (sm *ScenarioManager) executeScenario(scenario *models.Scenario, out sinks.Fanout) {
 	config := scenario.Config
 
+	defer func() {
+		if err := out.Close(); err != nil {
+			log.Printf("❌ Ошибка закрытия приемников сценария: %v", err)
+		}
+	}()
+
 	log.Printf("🔧 Выполнение сценария %s", config.Name)
 
+	// Длительность, интервал и даты сценария заданы в модельном времени
+	warp := newTimeWarp(sm.clock, config.TimeScale, scenario.Started, scenario.SimulatedStart)
+
 	// Проверяем дату начала
 	if scenario.StartDate != nil {
-		now := time.Now()
+		now := warp.now()
 		if now.Before(*scenario.StartDate) {
 			waitTime := scenario.StartDate.Sub(now)
 			log.Printf("⏰ Ожидание до даты начала: %v (осталось: %v)",
-				scenario.StartDate.Format("2006-01-02 15:04:05"), waitTime)
+				scenario.StartDate.Format("2006-01-02 15:04:05"), warp.real(waitTime))
 
 			select {
-			case <-time.After(waitTime):
+			case <-warp.after(waitTime):
 				if !scenario.Active {
 					return
 				}
@@ -269,11 +421,11 @@ func (sm *ScenarioManager) executeScenario(scenario *models.Scenario) {
 
 	// Определяем режим выполнения
 	if scenario.Interval > 0 {
-		sm.executePeriodicScenario(scenario)
+		sm.executePeriodicScenario(scenario, out, warp)
 	} else if scenario.Duration > 0 {
-		sm.executeTimedScenario(scenario)
+		sm.executeTimedScenario(scenario, out, warp)
 	} else {
-		sm.executeSingleScenario(scenario)
+		sm.executeSingleScenario(scenario, out, warp)
 	}
 
 	sm.mutex.Lock()
@@ -288,74 +440,119 @@ func (sm *ScenarioManager) executeScenario(scenario *models.Scenario) {
 	if err := sm.storage.DeleteScenario(scenario.Type); err != nil {
 		log.Printf("❌ Ошибка удаления сценария: %v", err)
 	}
+	sm.unlock(scenarioLeaseKey(scenario.Type))
 
 	sm.mutex.Unlock()
 
 	log.Printf("✅ Завершен сценарий: %s", config.Name)
 }
 
-func (sm *ScenarioManager) executeSingleScenario(scenario *models.Scenario) {
-	generator.GenerateLogs(scenario.Config.LogCount, scenario.Config.Name)
+func (sm *ScenarioManager) executeSingleScenario(scenario *models.Scenario, out sinks.Fanout, warp *timeWarp) {
+	clk := newBatchClock(scenario, warp.now(), 0)
+	generator.GenerateLogsWithOptions(scenario.Config.LogCount, scenario.Config.Name,
+		generationOptions(scenario.Config, out, 0, clk.stamped(clk.begin)))
 }
 
-func (sm *ScenarioManager) executeTimedScenario(scenario *models.Scenario) {
+func (sm *ScenarioManager) executeTimedScenario(scenario *models.Scenario, out sinks.Fanout, warp *timeWarp) {
+	begin := warp.now()
 	var endTime time.Time
 	if scenario.EndDate != nil {
 		endTime = *scenario.EndDate
 	} else if scenario.Duration > 0 {
-		endTime = time.Now().Add(scenario.Duration)
+		endTime = begin.Add(scenario.Duration)
 	} else {
-		endTime = time.Now().Add(365 * 24 * time.Hour)
+		endTime = begin.Add(365 * 24 * time.Hour)
 	}
 
-	ticker := time.NewTicker(10 * time.Second)
+	tick := warp.tick(10 * time.Second)
+	ticker := sm.clock.NewTicker(warp.real(tick))
 	defer ticker.Stop()
 
-	for {
+	clk := newBatchClock(scenario, begin, tick)
+	load, err := newShapedLoad(scenario.Config, float64(scenario.Config.LogCount)/endTime.Sub(begin).Seconds(), clk.stamped(begin))
+	if err != nil {
+		log.Printf("❌ Ошибка формы нагрузки сценария: %v", err)
+		return
+	}
+	prev := begin
+
+	for batch := 0; ; batch++ {
 		select {
-		case <-ticker.C:
-			if !scenario.Active || time.Now().After(endTime) {
-				if time.Now().After(endTime) {
+		case wall := <-ticker.C():
+			now := clk.end(batch, warp.simulated(wall))
+			if !scenario.Active || now.After(endTime) {
+				if now.After(endTime) {
 					log.Printf("⏰ Достигнуто время окончания сценария: %v",
 						endTime.Format("2006-01-02 15:04:05"))
 				}
 				return
 			}
 
-			timeUntilEnd := time.Until(endTime).Seconds()
+			timeUntilEnd := endTime.Sub(now).Seconds()
 			if timeUntilEnd <= 0 {
 				return
 			}
 
-			batchSize := scenario.Config.LogCount / int(timeUntilEnd/10)
-			if batchSize < 1 {
-				batchSize = 1
+			opts := generationOptions(scenario.Config, out, batch, clk.at(prev, now))
+			var batchSize int
+			if load != nil {
+				batchSize = load.batch(&opts, clk.stamped(prev), clk.stamped(now))
+			} else {
+				ticksLeft := int(timeUntilEnd / tick.Seconds())
+				if ticksLeft < 1 {
+					ticksLeft = 1
+				}
+				batchSize = scenario.Config.LogCount / ticksLeft
+				if batchSize < 1 {
+					batchSize = 1
+				}
+			}
+			prev = now
+			if batchSize > 0 {
+				generator.GenerateLogsWithOptions(batchSize, scenario.Config.Name, opts)
 			}
-			generator.GenerateLogs(batchSize, scenario.Config.Name)
 		case <-sm.stopChan:
 			return
 		}
 	}
 }
 
-func (sm *ScenarioManager) executePeriodicScenario(scenario *models.Scenario) {
-	ticker := time.NewTicker(scenario.Interval)
+func (sm *ScenarioManager) executePeriodicScenario(scenario *models.Scenario, out sinks.Fanout, warp *timeWarp) {
+	ticker := sm.clock.NewTicker(warp.real(scenario.Interval))
 	defer ticker.Stop()
 
-	for {
+	begin := warp.now()
+	clk := newBatchClock(scenario, begin, scenario.Interval)
+	load, err := newShapedLoad(scenario.Config, float64(scenario.Config.LogCount)/scenario.Interval.Seconds(), clk.stamped(begin))
+	if err != nil {
+		log.Printf("❌ Ошибка формы нагрузки сценария: %v", err)
+		return
+	}
+	prev := begin
+
+	for batch := 0; ; batch++ {
 		select {
-		case <-ticker.C:
+		case wall := <-ticker.C():
 			if !scenario.Active {
 				return
 			}
 
-			if scenario.EndDate != nil && time.Now().After(*scenario.EndDate) {
+			now := clk.end(batch, warp.simulated(wall))
+			if scenario.EndDate != nil && now.After(*scenario.EndDate) {
 				log.Printf("⏰ Достигнута дата окончания сценария: %v",
 					scenario.EndDate.Format("2006-01-02 15:04:05"))
 				return
 			}
 
-			generator.GenerateLogs(scenario.Config.LogCount, scenario.Config.Name)
+			opts := generationOptions(scenario.Config, out, batch, clk.at(prev, now))
+			batchSize := scenario.Config.LogCount
+			if load != nil {
+				batchSize = load.batch(&opts, clk.stamped(prev), clk.stamped(now))
+			}
+			prev = now
+			if batchSize > 0 {
+				generator.GenerateLogsWithOptions(batchSize, scenario.Config.Name, opts)
+			}
 		case <-sm.stopChan:
 			return
 		}
@@ -369,12 +566,15 @@ func (sm *ScenarioManager) CreateSchedule(schedule *models.Schedule) error {
 	defer sm.mutex.Unlock()
 
 	if schedule.ID == "" {
-		schedule.ID = generateID()
+		schedule.ID = sm.generateID()
 	}
 
-	if _, err := cron.ParseStandard(schedule.CronExpr); err != nil {
+	if _, err := cronParser.Parse(schedule.CronExpr); err != nil {
 		return fmt.Errorf("неверное cron выражение: %v", err)
 	}
+	if err := validateTimeScale(schedule.TimeScale); err != nil {
+		return err
+	}
 
 	if schedule.StartDate != nil && schedule.EndDate != nil {
 		if schedule.EndDate.Before(*schedule.StartDate) {
@@ -382,7 +582,7 @@ func (sm *ScenarioManager) CreateSchedule(schedule *models.Schedule) error {
 		}
 	}
 
-	schedule.CreatedAt = time.Now()
+	schedule.CreatedAt = sm.clock.Now()
 	sm.schedules[schedule.ID] = schedule
 
 	if err := sm.storage.SaveSchedule(schedule); err != nil {
@@ -400,7 +600,11 @@ func (sm *ScenarioManager) CreateSchedule(schedule *models.Schedule) error {
 }
 
 func (sm *ScenarioManager) scheduleCronJob(schedule *models.Schedule) error {
-	now := time.Now()
+	warp, err := sm.newScheduleWarp(schedule.CronExpr, schedule.TimeScale)
+	if err != nil {
+		return fmt.Errorf("неверное cron выражение: %v", err)
+	}
+	now := warp.start
 	if schedule.StartDate != nil && now.Before(*schedule.StartDate) {
 		log.Printf("⏰ Расписание %s начнет действовать с: %v",
 			schedule.Name, schedule.StartDate.Format("2006-01-02 15:04:05"))
@@ -410,6 +614,9 @@ func (sm *ScenarioManager) scheduleCronJob(schedule *models.Schedule) error {
 		log.Printf("⏰ Расписание %s закончило действие: %v",
 			schedule.Name, schedule.EndDate.Format("2006-01-02 15:04:05"))
 		schedule.Enabled = false
+		if err := sm.storage.UpdateSchedule(schedule); err != nil {
+			log.Printf("❌ Ошибка обновления расписания: %v", err)
+		}
 		return nil
 	}
 
@@ -418,8 +625,8 @@ func (sm *ScenarioManager) scheduleCronJob(schedule *models.Schedule) error {
 		delete(sm.cronEntries, schedule.ID)
 	}
 
-	entryID, err := sm.cronScheduler.AddFunc(schedule.CronExpr, func() {
-		sm.executeScheduledScenario(schedule)
+	entryID, err := sm.addCronFunc(schedule.CronExpr, warp, func(at time.Time) {
+		sm.executeScheduledScenario(schedule, at, warp)
 	})
 
 	if err != nil {
@@ -437,8 +644,10 @@ func (sm *ScenarioManager) scheduleCronJob(schedule *models.Schedule) error {
 	return nil
 }
 
-func (sm *ScenarioManager) executeScheduledScenario(schedule *models.Schedule) {
-	now := time.Now()
+// executeScheduledScenario выполняет запуск расписания, назначенный на момент at,
+// в модельном времени расписания warp
+func (sm *ScenarioManager) executeScheduledScenario(schedule *models.Schedule, at time.Time, warp *timeWarp) {
+	now := warp.simulated(at)
 
 	if schedule.StartDate != nil && now.Before(*schedule.StartDate) {
 		return
@@ -462,12 +671,17 @@ func (sm *ScenarioManager) executeScheduledScenario(schedule *models.Schedule) {
 		return
 	}
 
+	if !sm.tryLock(scheduleRunKey("schedule", schedule.ID, at), scheduleRunLockTTL) {
+		log.Printf("⏭️ Запуск расписания %s выполняет другой экземпляр", schedule.Name)
+		return
+	}
+
 	execution := &models.ScheduleExecution{
-		ID:           generateID(),
+		ID:           sm.generateID(),
 		ScheduleID:   schedule.ID,
 		ScenarioType: schedule.ScenarioType,
 		Status:       "running",
-		StartedAt:    now,
+		StartedAt:    sm.clock.Now(),
 	}
 
 	if err := sm.storage.SaveExecution(execution); err != nil {
@@ -476,12 +690,12 @@ func (sm *ScenarioManager) executeScheduledScenario(schedule *models.Schedule) {
 
 	log.Printf("⏰ Запуск по расписанию: %s -> %s", schedule.Name, schedule.ScenarioType)
 
-	if err := sm.StartScenario(schedule.ScenarioType, nil); err != nil {
+	if err := sm.startScenario(schedule.ScenarioType, nil, warp); err != nil {
 		log.Printf("❌ Ошибка выполнения расписания %s: %v", schedule.Name, err)
 
 		execution.Status = "failed"
 		execution.Error = err.Error()
-		completedAt := time.Now()
+		completedAt := sm.clock.Now()
 		execution.CompletedAt = &completedAt
 
 		if err := sm.storage.SaveExecution(execution); err != nil {
@@ -491,7 +705,7 @@ func (sm *ScenarioManager) executeScheduledScenario(schedule *models.Schedule) {
 	}
 
 	execution.Status = "completed"
-	completedAt := time.Now()
+	completedAt := sm.clock.Now()
 	execution.CompletedAt = &completedAt
 
 	if err := sm.storage.SaveExecution(execution); err != nil {
@@ -499,7 +713,7 @@ func (sm *ScenarioManager) executeScheduledScenario(schedule *models.Schedule) {
 	}
 
 	sm.mutex.Lock()
-	lastRun := time.Now()
+	lastRun := sm.clock.Now()
 	schedule.LastRun = &lastRun
 
 	if entryID, exists := sm.cronEntries[schedule.ID]; exists {
@@ -529,7 +743,7 @@ func (sm *ScenarioManager) UpdateSchedule(scheduleID string, updates map[string]
 		schedule.Name = name
 	}
 	if cronExpr, ok := updates["cron_expr"].(string); ok {
-		if _, err := cron.ParseStandard(cronExpr); err != nil {
+		if _, err := cronParser.Parse(cronExpr); err != nil {
 			return fmt.Errorf("неверное cron выражение: %v", err)
 		}
 		schedule.CronExpr = cronExpr
@@ -566,6 +780,10 @@ func (sm *ScenarioManager) UpdateSchedule(scheduleID string, updates map[string]
 		}
 	}
 
+	if err := sm.storage.UpdateSchedule(schedule); err != nil {
+		return fmt.Errorf("ошибка сохранения расписания: %v", err)
+	}
+
 	log.Printf("✏️ Обновлено расписание: %s", schedule.Name)
 	return nil
 }
@@ -604,6 +822,9 @@ func (sm *ScenarioManager) DeleteSchedule(scheduleID string) error {
 	}
 
 	delete(sm.schedules, scheduleID)
+	if err := sm.storage.DeleteSchedule(scheduleID); err != nil {
+		return fmt.Errorf("ошибка удаления расписания: %v", err)
+	}
 	return nil
 }
 
@@ -626,6 +847,9 @@ func (sm *ScenarioManager) EnableSchedule(scheduleID string) error {
 		return err
 	}
 
+	if err := sm.storage.UpdateSchedule(schedule); err != nil {
+		return fmt.Errorf("ошибка сохранения расписания: %v", err)
+	}
 	return nil
 }
 
@@ -649,6 +873,9 @@ func (sm *ScenarioManager) DisableSchedule(scheduleID string) error {
 		schedule.NextRun = nil
 	}
 
+	if err := sm.storage.UpdateSchedule(schedule); err != nil {
+		return fmt.Errorf("ошибка сохранения расписания: %v", err)
+	}
 	return nil
 }
 
@@ -663,10 +890,13 @@ func (sm *ScenarioManager) CreateChain(chain *models.ScenarioChain) error {
 	defer sm.mutex.Unlock()
 
 	if chain.ID == "" {
-		chain.ID = generateID()
+		chain.ID = sm.generateID()
+	}
+	if err := validateTimeScale(chain.TimeScale); err != nil {
+		return err
 	}
 
-	chain.CreatedAt = time.Now()
+	chain.CreatedAt = sm.clock.Now()
 	chain.Status = "pending"
 
 	if err := sm.storage.SaveChain(chain); err != nil {
@@ -701,7 +931,9 @@ func (sm *ScenarioManager) DeleteChain(chainID string) error {
 	return nil
 }
 
-func (sm *ScenarioManager) StartChain(chainID string) error {
+// StartChain запускает цепочку; timeScale ускоряет задержки и длительности
+// шагов, 0 - ускорение из настроек цепочки
+func (sm *ScenarioManager) StartChain(chainID string, timeScale float64) error {
 	sm.mutex.Lock()
 	defer sm.mutex.Unlock()
 
@@ -713,11 +945,19 @@ func (sm *ScenarioManager) StartChain(chainID string) error {
 		return fmt.Errorf("цепочка не найдена: %s", chainID)
 	}
 
+	if timeScale == 0 {
+		timeScale = chain.TimeScale
+	}
+	if err := validateTimeScale(timeScale); err != nil {
+		return err
+	}
+
 	execution := &models.ChainExecution{
-		ID:        generateID(),
+		ID:        sm.generateID(),
 		ChainID:   chainID,
 		Status:    "running",
-		StartedAt: time.Now(),
+		TimeScale: timeScale,
+		StartedAt: sm.clock.Now(),
 		Steps:     make([]models.ChainExecutionStep, len(chain.Steps)),
 	}
 
@@ -751,7 +991,7 @@ func (sm *ScenarioManager) StopChain(chainExecutionID string) error {
 	}
 
 	execution.Status = "stopped"
-	completedAt := time.Now()
+	completedAt := sm.clock.Now()
 	execution.CompletedAt = &completedAt
 
 	if err := sm.storage.UpdateChainExecution(execution); err != nil {
@@ -769,7 +1009,7 @@ func (sm *ScenarioManager) executeChain(chain *models.ScenarioChain, execution *
 		sm.mutex.Lock()
 		if execution.Status == "running" {
 			execution.Status = "completed"
-			completedAt := time.Now()
+			completedAt := sm.clock.Now()
 			execution.CompletedAt = &completedAt
 		}
 
@@ -783,6 +1023,8 @@ func (sm *ScenarioManager) executeChain(chain *models.ScenarioChain, execution *
 		log.Printf("✅ Завершена цепочка: %s", chain.Name)
 	}()
 
+	warp := newTimeWarp(sm.clock, execution.TimeScale, execution.StartedAt, nil)
+
 	for i, step := range chain.Steps {
 		sm.mutex.RLock()
 		if execution.Status != "running" {
@@ -793,7 +1035,7 @@ func (sm *ScenarioManager) executeChain(chain *models.ScenarioChain, execution *
 
 		sm.mutex.Lock()
 		execution.Steps[i].Status = "running"
-		startedAt := time.Now()
+		startedAt := sm.clock.Now()
 		execution.Steps[i].StartedAt = &startedAt
 		sm.mutex.Unlock()
 
@@ -806,13 +1048,13 @@ func (sm *ScenarioManager) executeChain(chain *models.ScenarioChain, execution *
 		if step.DelayBefore > 0 {
 			log.Printf("⏰ Задержка перед шагом %d: %d секунд", i+1, step.DelayBefore)
 			select {
-			case <-time.After(time.Duration(step.DelayBefore) * time.Second):
+			case <-warp.after(time.Duration(step.DelayBefore) * time.Second):
 			case <-sm.stopChan:
 				return
 			}
 		}
 
-		if err := sm.StartScenario(step.ScenarioType, step.Config); err != nil {
+		if err := sm.startScenario(step.ScenarioType, stepConfig(step), warp); err != nil {
 			log.Printf("❌ Ошибка выполнения шага %d: %v", i+1, err)
 
 			sm.mutex.Lock()
@@ -829,7 +1071,7 @@ func (sm *ScenarioManager) executeChain(chain *models.ScenarioChain, execution *
 			if duration, ok := getDurationFromConfig(step.Config); ok && duration > 0 {
 				log.Printf("⏰ Ожидание завершения шага %d: %v", i+1, duration)
 				select {
-				case <-time.After(duration):
+				case <-warp.after(duration):
 				case <-sm.stopChan:
 					return
 				}
@@ -838,7 +1080,7 @@ func (sm *ScenarioManager) executeChain(chain *models.ScenarioChain, execution *
 
 		sm.mutex.Lock()
 		execution.Steps[i].Status = "completed"
-		completedAt := time.Now()
+		completedAt := sm.clock.Now()
 		execution.Steps[i].CompletedAt = &completedAt
 		sm.mutex.Unlock()
 
@@ -861,16 +1103,19 @@ func (sm *ScenarioManager) CreateChainSchedule(schedule *models.ChainSchedule) e
 	defer sm.mutex.Unlock()
 
 	if schedule.ID == "" {
-		schedule.ID = generateID()
+		schedule.ID = sm.generateID()
 	}
 
 	if _, exists := predefinedChains[schedule.ChainName]; !exists {
 		return fmt.Errorf("цепочка не найдена: %s", schedule.ChainName)
 	}
 
-	if _, err := cron.ParseStandard(schedule.CronExpr); err != nil {
+	if _, err := cronParser.Parse(schedule.CronExpr); err != nil {
 		return fmt.Errorf("неверное cron выражение: %v", err)
 	}
+	if err := validateTimeScale(schedule.TimeScale); err != nil {
+		return err
+	}
 
 	if schedule.StartDate != nil && schedule.EndDate != nil {
 		if schedule.EndDate.Before(*schedule.StartDate) {
@@ -878,7 +1123,7 @@ func (sm *ScenarioManager) CreateChainSchedule(schedule *models.ChainSchedule) e
 		}
 	}
 
-	schedule.CreatedAt = time.Now()
+	schedule.CreatedAt = sm.clock.Now()
 	sm.chainSchedules[schedule.ID] = schedule
 
 	if err := sm.storage.SaveChainSchedule(schedule); err != nil {
@@ -896,7 +1141,11 @@ func (sm *ScenarioManager) CreateChainSchedule(schedule *models.ChainSchedule) e
 }
 
 func (sm *ScenarioManager) scheduleChainCronJob(schedule *models.ChainSchedule) error {
-	now := time.Now()
+	warp, err := sm.newScheduleWarp(schedule.CronExpr, schedule.TimeScale)
+	if err != nil {
+		return fmt.Errorf("неверное cron выражение: %v", err)
+	}
+	now := warp.start
 	if schedule.StartDate != nil && now.Before(*schedule.StartDate) {
 		log.Printf("⏰ Расписание цепочки %s начнет действовать с: %v", schedule.Name, schedule.StartDate.Format("2006-01-02 15:04:05"))
 	}
@@ -914,8 +1163,8 @@ func (sm *ScenarioManager) scheduleChainCronJob(schedule *models.ChainSchedule)
 		delete(sm.chainCronEntries, schedule.ID)
 	}
 
-	entryID, err := sm.cronScheduler.AddFunc(schedule.CronExpr, func() {
-		sm.executeScheduledChain(schedule)
+	entryID, err := sm.addCronFunc(schedule.CronExpr, warp, func(at time.Time) {
+		sm.executeScheduledChain(schedule, at, warp)
 	})
 	if err != nil {
 		return fmt.Errorf("ошибка добавления cron для цепочки: %v", err)
@@ -933,8 +1182,10 @@ func (sm *ScenarioManager) scheduleChainCronJob(schedule *models.ChainSchedule)
 	return nil
 }
 
-func (sm *ScenarioManager) executeScheduledChain(schedule *models.ChainSchedule) {
-	now := time.Now()
+// executeScheduledChain выполняет запуск расписания цепочки, назначенный на момент at,
+// в модельном времени расписания warp
+func (sm *ScenarioManager) executeScheduledChain(schedule *models.ChainSchedule, at time.Time, warp *timeWarp) {
+	now := warp.simulated(at)
 	if schedule.StartDate != nil && now.Before(*schedule.StartDate) {
 		return
 	}
@@ -959,17 +1210,22 @@ func (sm *ScenarioManager) executeScheduledChain(schedule *models.ChainSchedule)
 		return
 	}
 
+	if !sm.tryLock(scheduleRunKey("chain_schedule", schedule.ID, at), scheduleRunLockTTL) {
+		log.Printf("⏭️ Запуск расписания цепочки %s выполняет другой экземпляр", schedule.Name)
+		return
+	}
+
 	log.Printf("⏰ Запуск цепочки по расписанию: %s -> %s", schedule.Name, chain.Name)
 	for idx, st := range chain.Steps {
-		if err := sm.StartScenario(st, nil); err != nil {
+		if err := sm.startScenario(st, nil, warp); err != nil {
 			log.Printf("❌ Ошибка запуска шага %d цепочки %s: %v", idx+1, chain.Name, err)
 			break
 		}
-		time.Sleep(2 * time.Second)
+		sm.clock.Sleep(warp.real(2 * time.Second))
 	}
 
 	sm.mutex.Lock()
-	lastRun := time.Now()
+	lastRun := sm.clock.Now()
 	schedule.LastRun = &lastRun
 	if entryID, exists := sm.chainCronEntries[schedule.ID]; exists {
 		nextRun := sm.cronScheduler.Entry(entryID).Next
@@ -1005,7 +1261,19 @@ func (sm *ScenarioManager) UpdateChainSchedule(schedule *models.ChainSchedule) e
 	sm.mutex.Lock()
 	defer sm.mutex.Unlock()
 
+	// Задание cron ссылается на прежнюю запись расписания, поэтому
+	// перепланируется вместе с ее заменой
 	sm.chainSchedules[schedule.ID] = schedule
+	if entryID, exists := sm.chainCronEntries[schedule.ID]; exists {
+		sm.cronScheduler.Remove(entryID)
+		delete(sm.chainCronEntries, schedule.ID)
+		schedule.NextRun = nil
+	}
+	if schedule.Enabled {
+		if err := sm.scheduleChainCronJob(schedule); err != nil {
+			return err
+		}
+	}
 	return sm.storage.UpdateChainSchedule(schedule)
 }
 
@@ -1058,17 +1326,7 @@ func (sm *ScenarioManager) DeleteChainSchedule(id string) error {
 // ===== Вспомогательные методы =====
 
 func (sm *ScenarioManager) restoreState() {
-	activeScenarios, err := sm.storage.GetActiveScenarios()
-	if err != nil {
-		log.Printf("❌ Ошибка восстановления активных сценариев: %v", err)
-		return
-	}
-
-	for _, scenario := range activeScenarios {
-		sm.activeScenarios[scenario.Type] = scenario
-		log.Printf("🔄 Восстановлен активный сценарий: %s", scenario.Config.Name)
-		go sm.executeScenario(scenario)
-	}
+	sm.restoreScenarios()
 
 	schedules, err := sm.storage.GetSchedules()
 	if err != nil {
@@ -1105,6 +1363,52 @@ func (sm *ScenarioManager) restoreState() {
 	}
 }
 
+// restoreScenarios продолжает активные сценарии из хранилища, которые не
+// выполняются этим экземпляром и аренду которых удалось занять
+func (sm *ScenarioManager) restoreScenarios() {
+	activeScenarios, err := sm.storage.GetActiveScenarios()
+	if err != nil {
+		log.Printf("❌ Ошибка восстановления активных сценариев: %v", err)
+		return
+	}
+
+	sm.mutex.Lock()
+	defer sm.mutex.Unlock()
+
+	locked := make(map[string]bool)
+	for _, scenario := range activeScenarios {
+		if _, running := sm.activeScenarios[scenario.Type]; !running && sm.tryLock(scenarioLeaseKey(scenario.Type), scenarioLeaseTTL) {
+			locked[scenario.Type] = true
+		}
+	}
+	if len(locked) == 0 {
+		return
+	}
+
+	// Другой экземпляр мог завершить сценарий и отпустить аренду между
+	// чтением и блокировкой, поэтому сценарии перечитываются под арендой
+	if activeScenarios, err = sm.storage.GetActiveScenarios(); err != nil {
+		log.Printf("❌ Ошибка восстановления активных сценариев: %v", err)
+		activeScenarios = nil
+	}
+	for _, scenario := range activeScenarios {
+		if !locked[scenario.Type] {
+			continue
+		}
+		delete(locked, scenario.Type)
+		out, err := sinks.NewFanout(scenario.Config.Sinks)
+		if err != nil {
+			log.Printf("❌ Ошибка создания приемников сценария %s: %v", scenario.Config.Name, err)
+		}
+		sm.activeScenarios[scenario.Type] = scenario
+		log.Printf("🔄 Восстановлен активный сценарий: %s", scenario.Config.Name)
+		go sm.executeScenario(scenario, out)
+	}
+	for scenarioType := range locked {
+		sm.unlock(scenarioLeaseKey(scenarioType))
+	}
+}
+
 func getDurationFromConfig(config map[string]interface{}) (time.Duration, bool) {
 	if seconds, ok := config["duration_seconds"].(float64); ok && seconds > 0 {
 		return time.Duration(seconds) * time.Second, true
@@ -1118,6 +1422,148 @@ func getDurationFromConfig(config map[string]interface{}) (time.Duration, bool)
 	return 0, false
 }
 
-func generateID() string {
-	return strconv.FormatInt(time.Now().UnixNano(), 36)
+// batchClock задает время пакетов сценария. Сценарий с seed не зависит от
+// моментов срабатывания таймера: пакет batch заканчивается ровно через
+// (batch+1)*tick модельного времени, а метки времени логов отсчитываются от
+// simulated_start (по умолчанию SeedEpoch), поэтому повторный запуск с тем же
+// seed дает те же логи и метрики.
+type batchClock struct {
+	seeded bool
+	begin  time.Time // Модельное время начала выполнения
+	stamp  time.Time // Метка времени в момент begin
+	tick   time.Duration
+}
+
+func newBatchClock(scenario *models.Scenario, begin time.Time, tick time.Duration) batchClock {
+	c := batchClock{seeded: scenario.Config.Seed != nil, begin: begin, stamp: begin, tick: tick}
+	if c.seeded {
+		c.stamp = generator.SeedEpoch
+		if scenario.SimulatedStart != nil {
+			c.stamp = *scenario.SimulatedStart
+		}
+	}
+	return c
+}
+
+// end возвращает модельное время окончания пакета batch, пришедшего по таймеру в now
+func (c batchClock) end(batch int, now time.Time) time.Time {
+	if !c.seeded {
+		return now
+	}
+	return c.begin.Add(time.Duration(batch+1) * c.tick)
+}
+
+// stamped переводит модельное время в метку времени логов
+func (c batchClock) stamped(t time.Time) time.Time {
+	return c.stamp.Add(t.Sub(c.begin))
+}
+
+// at возвращает опорное время пакета [from, to): у сценария с seed - начало
+// пакета, simulated_start + batch*tick, иначе момент срабатывания таймера
+func (c batchClock) at(from, to time.Time) time.Time {
+	if c.seeded {
+		return c.stamped(from)
+	}
+	return to
+}
+
+// generationOptions возвращает параметры генерации для очередного пакета сценария
+// с опорным временем now. Каждый пакет сценария с seed получает производный seed.
+func generationOptions(config models.ScenarioConfig, out sinks.Fanout, batch int, now time.Time) generator.Options {
+	opts := generator.Options{Labels: config.Labels, Traces: config.Traces, Profile: config.Profile, StartTime: now}
+	if len(out) > 0 {
+		opts.Sink = out
+	}
+
+	if config.Seed != nil {
+		seed := *config.Seed + int64(batch)
+		opts.Seed = &seed
+	}
+	return opts
+}
+
+// parseSinkConfigs разбирает список приемников из пользовательской конфигурации
+func parseSinkConfigs(raw interface{}) ([]models.SinkConfig, error) {
+	items, ok := raw.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("sinks должен быть списком")
+	}
+
+	configs := make([]models.SinkConfig, 0, len(items))
+	for i, item := range items {
+		m, ok := item.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("sinks[%d]: ожидается объект", i)
+		}
+
+		cfg := models.SinkConfig{}
+		cfg.Type, _ = m["type"].(string)
+		cfg.Name, _ = m["name"].(string)
+		cfg.Options, _ = m["options"].(map[string]interface{})
+		if cfg.Type == "" {
+			return nil, fmt.Errorf("sinks[%d]: не указан тип приемника", i)
+		}
+		configs = append(configs, cfg)
+	}
+	return configs, nil
+}
+
+// stepConfig возвращает конфигурацию шага цепочки с учетом seed шага
+func stepConfig(step models.ChainStep) map[string]interface{} {
+	if step.Seed == nil {
+		return step.Config
+	}
+
+	config := make(map[string]interface{}, len(step.Config)+1)
+	for k, v := range step.Config {
+		config[k] = v
+	}
+	config["seed"] = *step.Seed
+	return config
+}
+
+// parseSeed разбирает seed из пользовательской конфигурации. Точное значение
+// приходит как json.Number или строка; float64 принимается, только если
+// представляет целое число без потери точности (до 2^53).
+func parseSeed(value interface{}) (int64, error) {
+	switch v := value.(type) {
+	case int64:
+		return v, nil
+	case int:
+		return int64(v), nil
+	case json.Number:
+		seed, err := strconv.ParseInt(v.String(), 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("seed должен быть целым числом int64: %s", v)
+		}
+		return seed, nil
+	case float64:
+		if v != math.Trunc(v) || math.Abs(v) > 1<<53 {
+			return 0, fmt.Errorf("seed должен быть целым числом не больше 2^53 или строкой: %v", v)
+		}
+		return int64(v), nil
+	case string:
+		seed, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("seed должен быть целым числом int64: %s", v)
+		}
+		return seed, nil
+	default:
+		return 0, fmt.Errorf("некорректный seed: %v", value)
+	}
+}
+
+// generateID возвращает идентификатор из времени часов менеджера. Идентификаторы
+// строго возрастают: поддельные часы между вызовами могут стоять на месте.
+func (sm *ScenarioManager) generateID() string {
+	for {
+		last := sm.lastID.Load()
+		id := sm.clock.Now().UnixNano()
+		if id <= last {
+			id = last + 1
+		}
+		if sm.lastID.CompareAndSwap(last, id) {
+			return strconv.FormatInt(id, 36)
+		}
+	}
 }
@@ -0,0 +1,424 @@
+package scenarios
+
+import (
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"log-metrics-simulator/clock"
+	"log-metrics-simulator/models"
+	"log-metrics-simulator/storage"
+)
+
+// newTestManager создает менеджер на поддельных часах. Хранилище bolt
+// возвращает копии записей, поэтому тест читает их без гонок с менеджером.
+func newTestManager(t *testing.T) (*ScenarioManager, *clock.Fake) {
+	t.Helper()
+	store, err := storage.New(storage.Config{Type: "bolt", Path: filepath.Join(t.TempDir(), "simulator.db")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	fake := clock.NewFake(testStart)
+	sm := NewScenarioManager(store, fake)
+	t.Cleanup(sm.Stop)
+	return sm, fake
+}
+
+// scheduleState возвращает поля расписания, которые меняют задания cron
+func scheduleState(sm *ScenarioManager, schedule *models.Schedule) (enabled bool, lastRun, nextRun *time.Time) {
+	sm.mutex.RLock()
+	defer sm.mutex.RUnlock()
+	return schedule.Enabled, schedule.LastRun, schedule.NextRun
+}
+
+func executionStarts(t *testing.T, sm *ScenarioManager, scheduleID string) []time.Time {
+	t.Helper()
+	executions, err := sm.GetExecutions(scheduleID, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	starts := make([]time.Time, 0, len(executions))
+	for _, e := range executions {
+		if e.Status == "completed" {
+			starts = append(starts, e.StartedAt)
+		}
+	}
+	return starts
+}
+
+func TestScheduleStartAndEndDates(t *testing.T) {
+	sm, fake := newTestManager(t)
+
+	startDate := testStart.Add(time.Hour)
+	endDate := testStart.Add(2 * time.Hour)
+	schedule := &models.Schedule{
+		Name:         "window",
+		ScenarioType: "error_spike",
+		CronExpr:     "0 */30 * * * *",
+		Enabled:      true,
+		StartDate:    &startDate,
+		EndDate:      &endDate,
+	}
+	if err := sm.CreateSchedule(schedule); err != nil {
+		t.Fatal(err)
+	}
+	if want := testStart.Add(30 * time.Minute); !schedule.NextRun.Equal(want) {
+		t.Fatalf("следующий запуск %v, ожидался %v", schedule.NextRun, want)
+	}
+
+	// Запуски до даты начала пропускаются, с даты начала до даты окончания
+	// включительно выполняются
+	var want []time.Time
+	for step := 1; step <= 4; step++ {
+		fake.BlockUntil(1)
+		fake.Advance(30 * time.Minute)
+		at := fake.Now()
+		if !at.Before(startDate) {
+			want = append(want, at)
+		}
+		waitFor(t, "выполнение расписания в "+at.Format(time.TimeOnly), func() bool {
+			_, lastRun, _ := scheduleState(sm, schedule)
+			return len(want) == 0 || lastRun != nil && lastRun.Equal(at)
+		})
+	}
+
+	starts := executionStarts(t, sm, schedule.ID)
+	if len(starts) != len(want) {
+		t.Fatalf("выполнения в %v, ожидались в %v", starts, want)
+	}
+	for _, at := range want {
+		found := false
+		for _, start := range starts {
+			found = found || start.Equal(at)
+		}
+		if !found {
+			t.Errorf("нет выполнения в %v", at)
+		}
+	}
+
+	// Первый запуск после даты окончания отключает расписание
+	fake.BlockUntil(1)
+	fake.Advance(30 * time.Minute)
+	waitFor(t, "автоотключение расписания", func() bool {
+		enabled, _, _ := scheduleState(sm, schedule)
+		return !enabled
+	})
+	waitFor(t, "снятие задания cron", func() bool { return fake.Waiters() == 0 })
+
+	stored, err := sm.storage.GetSchedule(schedule.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.Enabled {
+		t.Error("отключение расписания не сохранено")
+	}
+	if got := len(executionStarts(t, sm, schedule.ID)); got != len(want) {
+		t.Errorf("после даты окончания выполнений %d, ожидалось %d", got, len(want))
+	}
+}
+
+func TestScheduleCreatedAfterEndDate(t *testing.T) {
+	sm, fake := newTestManager(t)
+
+	endDate := testStart.Add(-time.Minute)
+	schedule := &models.Schedule{
+		Name:         "expired",
+		ScenarioType: "error_spike",
+		CronExpr:     "* * * * *",
+		Enabled:      true,
+		EndDate:      &endDate,
+	}
+	if err := sm.CreateSchedule(schedule); err != nil {
+		t.Fatal(err)
+	}
+	if schedule.Enabled || schedule.NextRun != nil {
+		t.Fatalf("расписание после даты окончания включено: enabled=%v next=%v", schedule.Enabled, schedule.NextRun)
+	}
+	if _, exists := sm.cronEntries[schedule.ID]; exists {
+		t.Error("расписание после даты окончания добавлено в cron")
+	}
+	if fake.Waiters() != 0 {
+		t.Error("планировщик ждет запуска отключенного расписания")
+	}
+}
+
+func TestScheduleTimeWarp(t *testing.T) {
+	sm, fake := newTestManager(t)
+
+	// Ежечасное расписание при ускорении 60 первый раз срабатывает в свое
+	// время по cron, а затем каждую реальную минуту
+	schedule := &models.Schedule{
+		Name:         "warped",
+		ScenarioType: "error_spike",
+		CronExpr:     "0 0 * * * *",
+		Enabled:      true,
+		TimeScale:    60,
+	}
+	if err := sm.CreateSchedule(schedule); err != nil {
+		t.Fatal(err)
+	}
+	if want := testStart.Add(time.Hour); !schedule.NextRun.Equal(want) {
+		t.Fatalf("следующий запуск %v, ожидался %v", schedule.NextRun, want)
+	}
+
+	for step := 1; step <= 2; step++ {
+		_, _, nextRun := scheduleState(sm, schedule)
+		fake.BlockUntil(1)
+		fake.Advance(nextRun.Sub(fake.Now()))
+		at := fake.Now()
+		waitFor(t, "запуск ускоренного расписания", func() bool {
+			_, lastRun, _ := scheduleState(sm, schedule)
+			return lastRun != nil && lastRun.Equal(at)
+		})
+		_, _, nextRun = scheduleState(sm, schedule)
+		if want := at.Add(time.Minute); nextRun == nil || !nextRun.Equal(want) {
+			t.Fatalf("следующий запуск %v, ожидался %v", nextRun, want)
+		}
+	}
+
+	if got := len(executionStarts(t, sm, schedule.ID)); got != 2 {
+		t.Errorf("выполнений %d, ожидалось 2", got)
+	}
+}
+
+func TestChainStepDelay(t *testing.T) {
+	for _, scale := range []float64{1, 10} {
+		t.Run("time_scale", func(t *testing.T) {
+			sm, fake := newTestManager(t)
+
+			chain := &models.ScenarioChain{
+				Name: "delayed",
+				Steps: []models.ChainStep{
+					{ScenarioType: "error_spike", Name: "errors"},
+					{ScenarioType: "normal_operation", Name: "normal", DelayBefore: 60},
+				},
+			}
+			if err := sm.CreateChain(chain); err != nil {
+				t.Fatal(err)
+			}
+			if err := sm.StartChain(chain.ID, scale); err != nil {
+				t.Fatal(err)
+			}
+
+			// Модельная задержка 60 секунд длится 60/scale реальных
+			delay := time.Duration(float64(time.Minute) / scale)
+			fake.BlockUntil(1)
+			fake.Advance(delay - time.Second)
+			if fake.Waiters() != 1 {
+				t.Fatal("задержка шага закончилась раньше срока")
+			}
+			sm.mutex.RLock()
+			_, started := sm.activeScenarios["normal_operation"]
+			sm.mutex.RUnlock()
+			if started {
+				t.Fatal("шаг запущен до окончания задержки")
+			}
+
+			fake.Advance(time.Second)
+			var execution *models.ChainExecution
+			waitFor(t, "завершение цепочки", func() bool {
+				executions, err := sm.GetChainExecutions(chain.ID, 0)
+				if err != nil || len(executions) != 1 {
+					return false
+				}
+				execution = executions[0]
+				return execution.Status == "completed"
+			})
+
+			for i, step := range execution.Steps {
+				if step.Status != "completed" {
+					t.Errorf("шаг %d в статусе %s", i, step.Status)
+				}
+			}
+			step := execution.Steps[1]
+			if got := step.CompletedAt.Sub(*step.StartedAt); got != delay {
+				t.Errorf("шаг выполнялся %v, ожидалось %v", got, delay)
+			}
+			if !execution.CompletedAt.Equal(testStart.Add(delay)) {
+				t.Errorf("цепочка завершена в %v, ожидалось %v", execution.CompletedAt, testStart.Add(delay))
+			}
+		})
+	}
+}
+
+func TestScenarioStartAndEndDates(t *testing.T) {
+	sm, fake := newTestManager(t)
+
+	startDate := testStart.Add(time.Minute)
+	endDate := testStart.Add(2 * time.Minute)
+	err := sm.StartScenario("continuous_load", map[string]interface{}{
+		"interval_seconds": float64(5),
+		"start_date":       startDate.Format(time.RFC3339),
+		"end_date":         endDate.Format(time.RFC3339),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	active := func() bool {
+		sm.mutex.RLock()
+		defer sm.mutex.RUnlock()
+		_, exists := sm.activeScenarios["continuous_load"]
+		return exists
+	}
+
+	// До даты начала сценарий ждет, не создавая тикер
+	fake.BlockUntil(1)
+	fake.Advance(time.Minute - time.Second)
+	if fake.Waiters() != 1 || !active() {
+		t.Fatal("сценарий не дождался даты начала")
+	}
+
+	fake.Advance(time.Second)
+	for active() {
+		if !fake.Now().Before(endDate.Add(time.Minute)) {
+			t.Fatal("сценарий не остановился после даты окончания")
+		}
+		fake.BlockUntil(1)
+		fake.Advance(5 * time.Second)
+		time.Sleep(time.Millisecond)
+	}
+	if !fake.Now().After(endDate) {
+		t.Errorf("сценарий остановлен в %v, до даты окончания %v", fake.Now(), endDate)
+	}
+}
+
+// sharedLocks - таблица блокировок общего хранилища со сроками по поддельным часам
+type sharedLocks struct {
+	clock   clock.Clock
+	mutex   sync.Mutex
+	owners  map[string]string
+	expires map[string]time.Time
+}
+
+func newSharedLocks(clk clock.Clock) *sharedLocks {
+	return &sharedLocks{clock: clk, owners: make(map[string]string), expires: make(map[string]time.Time)}
+}
+
+// replicaStorage - общее хранилище с блокировками, каким его видит одна реплика
+type replicaStorage struct {
+	storage.Storage
+	locks *sharedLocks
+	owner string
+}
+
+func (s *replicaStorage) TryLock(key string, ttl time.Duration) (bool, error) {
+	s.locks.mutex.Lock()
+	defer s.locks.mutex.Unlock()
+
+	now := s.locks.clock.Now()
+	if owner, ok := s.locks.owners[key]; ok && owner != s.owner && now.Before(s.locks.expires[key]) {
+		return false, nil
+	}
+	s.locks.owners[key] = s.owner
+	s.locks.expires[key] = now.Add(ttl)
+	return true, nil
+}
+
+func (s *replicaStorage) Unlock(key string) error {
+	s.locks.mutex.Lock()
+	defer s.locks.mutex.Unlock()
+
+	if s.locks.owners[key] == s.owner {
+		delete(s.locks.owners, key)
+	}
+	return nil
+}
+
+// Общее хранилище закрывается тестом после остановки всех реплик
+func (s *replicaStorage) Close() error {
+	return nil
+}
+
+// newReplicas создает менеджеры нескольких реплик над одним хранилищем bolt
+func newReplicas(t *testing.T, fake *clock.Fake, locks *sharedLocks, shared storage.Storage, names ...string) []*ScenarioManager {
+	t.Helper()
+	replicas := make([]*ScenarioManager, len(names))
+	for i, name := range names {
+		replicas[i] = NewScenarioManager(&replicaStorage{Storage: shared, locks: locks, owner: name}, fake)
+		t.Cleanup(replicas[i].Stop)
+	}
+	return replicas
+}
+
+func newSharedStorage(t *testing.T) storage.Storage {
+	t.Helper()
+	shared, err := storage.New(storage.Config{Type: "bolt", Path: filepath.Join(t.TempDir(), "shared.db")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { shared.Close() })
+	return shared
+}
+
+func TestScheduleRunsOnOneReplica(t *testing.T) {
+	fake := clock.NewFake(testStart)
+	locks := newSharedLocks(fake)
+	shared := newSharedStorage(t)
+
+	// Вторая реплика получает расписание из хранилища при запуске
+	first := newReplicas(t, fake, locks, shared, "first")[0]
+	schedule := &models.Schedule{Name: "shared", ScenarioType: "error_spike", CronExpr: "* * * * *", Enabled: true}
+	if err := first.CreateSchedule(schedule); err != nil {
+		t.Fatal(err)
+	}
+	newReplicas(t, fake, locks, shared, "second")
+
+	// У каждой реплики таймер планировщика и тикер продления аренды
+	for step := 1; step <= 3; step++ {
+		fake.BlockUntil(4)
+		fake.Advance(time.Minute)
+		waitFor(t, "выполнение расписания", func() bool {
+			return len(executionStarts(t, first, schedule.ID)) == step
+		})
+	}
+	time.Sleep(20 * time.Millisecond)
+	if got := len(executionStarts(t, first, schedule.ID)); got != 3 {
+		t.Errorf("выполнений %d, ожидалось 3: по одному на запуск", got)
+	}
+}
+
+func TestScenarioRestoredByOneReplica(t *testing.T) {
+	fake := clock.NewFake(testStart)
+	locks := newSharedLocks(fake)
+	shared := newSharedStorage(t)
+
+	config, err := buildScenarioConfig("continuous_load", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	scenario := &models.Scenario{Type: "continuous_load", Active: true, Config: config, Started: testStart, Interval: 5 * time.Second}
+	if err := shared.SaveScenario(scenario); err != nil {
+		t.Fatal(err)
+	}
+
+	// Аренда сценария осталась за упавшей репликой и еще не истекла
+	dead := &replicaStorage{Storage: shared, locks: locks, owner: "dead"}
+	dead.TryLock(scenarioLeaseKey(scenario.Type), scenarioLeaseTTL)
+
+	replicas := newReplicas(t, fake, locks, shared, "first", "second")
+	running := func(sm *ScenarioManager) bool {
+		sm.mutex.RLock()
+		defer sm.mutex.RUnlock()
+		_, exists := sm.activeScenarios[scenario.Type]
+		return exists
+	}
+	for _, sm := range replicas {
+		if running(sm) {
+			t.Fatal("сценарий восстановлен при занятой аренде")
+		}
+	}
+
+	// После истечения аренды сценарий забирает ровно одна реплика
+	fake.BlockUntil(2)
+	fake.Advance(scenarioLeaseTTL + time.Second)
+	waitFor(t, "восстановление сценария", func() bool {
+		return running(replicas[0]) || running(replicas[1])
+	})
+	fake.BlockUntil(2)
+	fake.Advance(scenarioLeaseTTL / 3)
+	time.Sleep(20 * time.Millisecond)
+	if running(replicas[0]) && running(replicas[1]) {
+		t.Fatal("сценарий восстановлен обеими репликами")
+	}
+}
@@ -0,0 +1,187 @@
+package scenarios
+
+import (
+	"sync"
+	"time"
+
+	"log-metrics-simulator/clock"
+
+	"github.com/robfig/cron/v3"
+)
+
+// scheduler выполняет задания по cron-расписаниям по часам менеджера.
+// Разбор выражений и расчет следующего запуска берутся из robfig/cron,
+// ожидание идет через clock, поэтому с поддельными часами расписания
+// срабатывают без реального ожидания.
+type scheduler struct {
+	clock clock.Clock
+
+	mutex   sync.Mutex
+	entries []*schedulerEntry
+	lastID  cron.EntryID
+	running bool
+	changed chan struct{}
+	stop    chan struct{}
+	done    chan struct{}
+}
+
+// scheduledFunc - задание планировщика. Получает плановый момент запуска:
+// горутина задания может стартовать позже, а даты расписания и блокировка
+// запуска должны считаться от срабатывания.
+type scheduledFunc func(at time.Time)
+
+type schedulerEntry struct {
+	id       cron.EntryID
+	schedule cron.Schedule
+	next     time.Time
+	prev     time.Time
+	job      scheduledFunc
+}
+
+func newScheduler(clk clock.Clock) *scheduler {
+	return &scheduler{
+		clock:   clk,
+		changed: make(chan struct{}, 1),
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+}
+
+// AddFunc добавляет функцию по cron-выражению с необязательным полем секунд
+func (s *scheduler) AddFunc(spec string, cmd scheduledFunc) (cron.EntryID, error) {
+	schedule, err := cronParser.Parse(spec)
+	if err != nil {
+		return 0, err
+	}
+	return s.Schedule(schedule, cmd), nil
+}
+
+// Schedule добавляет задание с произвольным расписанием
+func (s *scheduler) Schedule(schedule cron.Schedule, job scheduledFunc) cron.EntryID {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.lastID++
+	s.entries = append(s.entries, &schedulerEntry{
+		id:       s.lastID,
+		schedule: schedule,
+		next:     schedule.Next(s.clock.Now()),
+		job:      job,
+	})
+	s.notify()
+	return s.lastID
+}
+
+// Entry возвращает расписание и моменты запусков задания; пустое, если задания нет
+func (s *scheduler) Entry(id cron.EntryID) cron.Entry {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for _, e := range s.entries {
+		if e.id == id {
+			return cron.Entry{ID: e.id, Schedule: e.schedule, Next: e.next, Prev: e.prev}
+		}
+	}
+	return cron.Entry{}
+}
+
+func (s *scheduler) Remove(id cron.EntryID) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for i, e := range s.entries {
+		if e.id == id {
+			s.entries = append(s.entries[:i], s.entries[i+1:]...)
+			s.notify()
+			return
+		}
+	}
+}
+
+func (s *scheduler) Start() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.running {
+		return
+	}
+	s.running = true
+	go s.run()
+}
+
+// Stop останавливает планировщик; уже запущенные задания не прерываются
+func (s *scheduler) Stop() {
+	s.mutex.Lock()
+	running := s.running
+	s.running = false
+	s.mutex.Unlock()
+
+	if running {
+		close(s.stop)
+		<-s.done
+	}
+}
+
+// notify будит цикл после изменения списка заданий; вызывается под mutex
+func (s *scheduler) notify() {
+	select {
+	case s.changed <- struct{}{}:
+	default:
+	}
+}
+
+func (s *scheduler) run() {
+	defer close(s.done)
+
+	for {
+		var wake <-chan time.Time
+		var timer clock.Timer
+		if next := s.next(); !next.IsZero() {
+			timer = s.clock.NewTimer(next.Sub(s.clock.Now()))
+			wake = timer.C()
+		}
+
+		select {
+		case <-wake:
+			s.runDue(s.clock.Now())
+		case <-s.changed:
+		case <-s.stop:
+			if timer != nil {
+				timer.Stop()
+			}
+			return
+		}
+		if timer != nil {
+			timer.Stop()
+		}
+	}
+}
+
+// next возвращает ближайший запуск; нулевое время, если заданий нет
+func (s *scheduler) next() time.Time {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	var next time.Time
+	for _, e := range s.entries {
+		if !e.next.IsZero() && (next.IsZero() || e.next.Before(next)) {
+			next = e.next
+		}
+	}
+	return next
+}
+
+// runDue запускает задания, время которых наступило к now
+func (s *scheduler) runDue(now time.Time) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for _, e := range s.entries {
+		if e.next.IsZero() || e.next.After(now) {
+			continue
+		}
+		go e.job(e.next)
+		e.prev = e.next
+		e.next = e.schedule.Next(now)
+	}
+}
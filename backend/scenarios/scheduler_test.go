@@ -0,0 +1,246 @@
+package scenarios
+
+import (
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+
+	"log-metrics-simulator/clock"
+	"log-metrics-simulator/models"
+	"log-metrics-simulator/storage"
+)
+
+var testStart = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// waitFor ждет выполнения условия, которое выставляют горутины менеджера
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("не дождались: %s", what)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestSchedulerFiresOnFakeClock(t *testing.T) {
+	fake := clock.NewFake(testStart)
+	s := newScheduler(fake)
+	fired := make(chan time.Time, 10)
+	id, err := s.AddFunc("*/10 * * * * *", func(at time.Time) { fired <- at })
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Start()
+	defer s.Stop()
+
+	for i := 1; i <= 3; i++ {
+		fake.BlockUntil(1)
+		fake.Advance(9 * time.Second)
+		select {
+		case at := <-fired:
+			t.Fatalf("задание сработало раньше срока: %v", at)
+		default:
+		}
+
+		fake.Advance(time.Second)
+		select {
+		case at := <-fired:
+			if want := testStart.Add(time.Duration(i) * 10 * time.Second); !at.Equal(want) {
+				t.Fatalf("запуск %d в %v, ожидался %v", i, at, want)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("запуск %d не состоялся", i)
+		}
+	}
+
+	fake.BlockUntil(1)
+	entry := s.Entry(id)
+	if want := testStart.Add(30 * time.Second); !entry.Prev.Equal(want) {
+		t.Errorf("Prev = %v, ожидался %v", entry.Prev, want)
+	}
+	if want := testStart.Add(40 * time.Second); !entry.Next.Equal(want) {
+		t.Errorf("Next = %v, ожидался %v", entry.Next, want)
+	}
+
+	s.Remove(id)
+	waitFor(t, "снятие таймера удаленного задания", func() bool { return fake.Waiters() == 0 })
+	fake.Advance(time.Minute)
+	select {
+	case at := <-fired:
+		t.Fatalf("удаленное задание сработало: %v", at)
+	case <-time.After(10 * time.Millisecond):
+	}
+}
+
+func TestSchedulerPicksEarliestEntry(t *testing.T) {
+	fake := clock.NewFake(testStart)
+	s := newScheduler(fake)
+	fired := make(chan string, 10)
+	if _, err := s.AddFunc("0 * * * *", func(time.Time) { fired <- "hourly" }); err != nil {
+		t.Fatal(err)
+	}
+	s.Start()
+	defer s.Stop()
+
+	// Задание, добавленное после запуска, срабатывает раньше уже ожидающего
+	fake.BlockUntil(1)
+	if _, err := s.AddFunc("*/5 * * * *", func(time.Time) { fired <- "five" }); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, "перерасчет ближайшего запуска", func() bool {
+		fake.BlockUntil(1)
+		return s.next().Equal(testStart.Add(5 * time.Minute))
+	})
+
+	fake.Advance(5 * time.Minute)
+	select {
+	case name := <-fired:
+		if name != "five" {
+			t.Fatalf("первым сработало задание %s", name)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("задание не сработало")
+	}
+}
+
+func TestWarpedScheduleNext(t *testing.T) {
+	fake := clock.NewFake(testStart)
+	warp := newTimeWarp(fake, 60, testStart, nil)
+	schedule, err := cronParser.Parse("0 0 * * * *")
+	if err != nil {
+		t.Fatal(err)
+	}
+	warped := warpedSchedule{schedule: schedule, warp: warp}
+
+	// Каждый модельный час при ускорении 60 - реальная минута
+	next := testStart
+	for i := 1; i <= 3; i++ {
+		next = warped.Next(next)
+		if want := testStart.Add(time.Duration(i) * time.Minute); !next.Equal(want) {
+			t.Fatalf("запуск %d в %v, ожидался %v", i, next, want)
+		}
+	}
+
+	fake.Advance(90 * time.Second)
+	if want := testStart.Add(90 * time.Minute); !warp.now().Equal(want) {
+		t.Errorf("модельное время %v, ожидалось %v", warp.now(), want)
+	}
+	if got := warp.tick(time.Second); got != 6*time.Second {
+		t.Errorf("шаг тиков %v, ожидался 6s", got)
+	}
+}
+
+func TestTimeWarpSimulatedStart(t *testing.T) {
+	fake := clock.NewFake(testStart)
+	simulated := time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC)
+	warp := newTimeWarp(fake, 10, testStart, &simulated)
+
+	fake.Advance(time.Minute)
+	if want := simulated.Add(10 * time.Minute); !warp.now().Equal(want) {
+		t.Errorf("модельное время %v, ожидалось %v", warp.now(), want)
+	}
+	if wall := warp.wall(simulated.Add(time.Hour)); !wall.Equal(testStart.Add(6 * time.Minute)) {
+		t.Errorf("реальное время %v, ожидалось %v", wall, testStart.Add(6*time.Minute))
+	}
+
+	done := warp.after(time.Hour)
+	fake.Advance(6*time.Minute - time.Second)
+	select {
+	case <-done:
+		t.Fatal("модельный час прошел раньше срока")
+	default:
+	}
+	fake.Advance(time.Second)
+	select {
+	case <-done:
+	default:
+		t.Fatal("модельный час не прошел за 6 реальных минут")
+	}
+}
+
+func TestValidateTimeScale(t *testing.T) {
+	// 0 - без ускорения, иначе от 1 до maxTimeScale
+	for _, scale := range []float64{0, 1, 60, maxTimeScale} {
+		if err := validateTimeScale(scale); err != nil {
+			t.Errorf("%v: %v", scale, err)
+		}
+	}
+	for _, scale := range []float64{-1, 0.5, maxTimeScale + 1} {
+		if err := validateTimeScale(scale); err == nil {
+			t.Errorf("%v: ожидалась ошибка", scale)
+		}
+	}
+}
+
+func TestWarpedScheduleCreatedLongAgo(t *testing.T) {
+	store, err := storage.New(storage.Config{Type: "bolt", Path: filepath.Join(t.TempDir(), "simulator.db")})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Расписание создано неделю назад. Модельное время ускорения 60 от момента
+	// создания ушло бы на год вперед, и расписание сразу прошло бы дату окончания.
+	endDate := testStart.Add(2 * time.Hour)
+	schedule := &models.Schedule{
+		ID:           "old",
+		Name:         "old",
+		ScenarioType: "error_spike",
+		CronExpr:     "0 0 * * * *",
+		Enabled:      true,
+		TimeScale:    60,
+		EndDate:      &endDate,
+		CreatedAt:    testStart.Add(-7 * 24 * time.Hour),
+	}
+	if err := store.SaveSchedule(schedule); err != nil {
+		t.Fatal(err)
+	}
+
+	fake := clock.NewFake(testStart)
+	sm := NewScenarioManager(store, fake)
+	t.Cleanup(sm.Stop)
+
+	restored, ok := sm.GetSchedule(schedule.ID)
+	if !ok {
+		t.Fatal("расписание не восстановлено")
+	}
+	enabled, _, nextRun := scheduleState(sm, restored)
+	if !enabled || nextRun == nil {
+		t.Fatalf("восстановленное расписание отключено: enabled=%v next=%v", enabled, nextRun)
+	}
+	if want := testStart.Add(time.Hour); !nextRun.Equal(want) {
+		t.Fatalf("первый запуск %v, ожидался %v", nextRun, want)
+	}
+
+	// Модельные 01:00 и 02:00 не позже даты окончания, 03:00 - уже после нее
+	var want []time.Time
+	for step := 1; step <= 3; step++ {
+		_, _, nextRun := scheduleState(sm, restored)
+		fake.BlockUntil(1)
+		fake.Advance(nextRun.Sub(fake.Now()))
+		at := fake.Now()
+		if step < 3 {
+			want = append(want, at)
+		}
+		waitFor(t, "запуск в "+at.Format(time.TimeOnly), func() bool {
+			enabled, lastRun, _ := scheduleState(sm, restored)
+			return !enabled || (lastRun != nil && lastRun.Equal(at))
+		})
+	}
+
+	if enabled, _, _ := scheduleState(sm, restored); enabled {
+		t.Error("расписание не отключено после модельной даты окончания")
+	}
+	got := executionStarts(t, sm, schedule.ID)
+	sort.Slice(got, func(i, j int) bool { return got[i].Before(got[j]) })
+	if len(got) != len(want) {
+		t.Fatalf("выполнения в %v, ожидались в %v", got, want)
+	}
+	for i := range want {
+		if !got[i].Equal(want[i]) {
+			t.Fatalf("выполнения в %v, ожидались в %v", got, want)
+		}
+	}
+}
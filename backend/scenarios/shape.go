@@ -0,0 +1,71 @@
+package scenarios
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"log-metrics-simulator/generator"
+	"log-metrics-simulator/models"
+)
+
+// shapedLoad распределяет события сценария по форме нагрузки вместо равных пакетов
+type shapedLoad struct {
+	shape    *generator.TrafficShape
+	expected float64 // Событий по форме с начала сценария
+	emitted  int
+}
+
+// newShapedLoad строит нагрузку по форме сценария от метки времени start;
+// nil, если форма не задана. baseRate - скорость по умолчанию, при которой
+// сценарий выпускает LogCount событий за свой интервал или длительность.
+func newShapedLoad(config models.ScenarioConfig, baseRate float64, start time.Time) (*shapedLoad, error) {
+	if config.Shape == nil {
+		return nil, nil
+	}
+
+	spec := *config.Shape
+	if spec.BaseRate == 0 {
+		spec.BaseRate = baseRate
+	}
+
+	seed := time.Now().UnixNano()
+	if config.Seed != nil {
+		seed = *config.Seed
+	}
+	shape, err := generator.NewTrafficShape(spec, start, seed)
+	if err != nil {
+		return nil, err
+	}
+	return &shapedLoad{shape: shape}, nil
+}
+
+// batch возвращает размер пакета за интервал [from, to) и распределяет метки
+// времени пакета по этому интервалу
+func (l *shapedLoad) batch(opts *generator.Options, from, to time.Time) int {
+	l.expected += l.shape.Events(from, to)
+	size := int(l.expected) - l.emitted
+	l.emitted += size
+
+	if size > 0 {
+		opts.StartTime = from
+		opts.Rate = float64(size) / to.Sub(from).Seconds()
+	}
+	return size
+}
+
+// parseTrafficShape разбирает форму нагрузки из пользовательской конфигурации
+func parseTrafficShape(raw interface{}) (*models.TrafficShape, error) {
+	data, err := json.Marshal(raw)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка разбора shape: %v", err)
+	}
+	var shape models.TrafficShape
+	if err := json.Unmarshal(data, &shape); err != nil {
+		return nil, fmt.Errorf("ошибка разбора shape: %v", err)
+	}
+	if err := generator.ValidateTrafficShape(&shape); err != nil {
+		return nil, err
+	}
+	return &shape, nil
+}
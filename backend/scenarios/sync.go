@@ -0,0 +1,226 @@
+package scenarios
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"log-metrics-simulator/models"
+	"log-metrics-simulator/storage"
+)
+
+// ===== Синхронизация с другими экземплярами =====
+
+// watchStorage применяет изменения расписаний, сделанные другими экземплярами
+// симулятора через общее хранилище, если оно рассылает уведомления.
+// Собственные уведомления пропускаются.
+func (sm *ScenarioManager) watchStorage() {
+	notifier, ok := sm.storage.(storage.Notifier)
+	if !ok {
+		return
+	}
+	log.Println("🔄 Подписка на изменения расписаний других экземпляров")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-sm.stopChan
+		cancel()
+	}()
+
+	for event := range notifier.Subscribe(ctx) {
+		if event.Source == notifier.Source() {
+			continue
+		}
+		switch event.Entity {
+		case "schedule":
+			sm.reloadSchedule(event.ID)
+		case "chain_schedule":
+			sm.reloadChainSchedule(event.ID)
+		}
+	}
+}
+
+// SubscribeChanges возвращает поток изменений общего хранилища до отмены ctx
+func (sm *ScenarioManager) SubscribeChanges(ctx context.Context) (<-chan models.StorageEvent, error) {
+	notifier, ok := sm.storage.(storage.Notifier)
+	if !ok {
+		return nil, fmt.Errorf("хранилище не поддерживает уведомления об изменениях")
+	}
+	return notifier.Subscribe(ctx), nil
+}
+
+// reloadSchedule перечитывает расписание из хранилища. Задание cron
+// пересоздается, только если изменилось само расписание: после запуска
+// другой экземпляр записывает lastRun и nextRun, и их достаточно скопировать,
+// иначе экземпляры бесконечно перезаписывали бы расписание друг за другом.
+func (sm *ScenarioManager) reloadSchedule(id string) {
+	schedule, err := sm.storage.GetSchedule(id)
+	if err != nil {
+		log.Printf("❌ Ошибка чтения измененного расписания %s: %v", id, err)
+		return
+	}
+
+	sm.mutex.Lock()
+	defer sm.mutex.Unlock()
+
+	current, exists := sm.schedules[id]
+	if exists && schedule != nil && sameSchedule(current, schedule) {
+		current.Name = schedule.Name
+		current.LastRun = schedule.LastRun
+		current.NextRun = schedule.NextRun
+		return
+	}
+
+	if entryID, ok := sm.cronEntries[id]; ok {
+		sm.cronScheduler.Remove(entryID)
+		delete(sm.cronEntries, id)
+	}
+	if schedule == nil {
+		delete(sm.schedules, id)
+		log.Printf("🔄 Расписание %s удалено другим экземпляром", id)
+		return
+	}
+
+	sm.schedules[id] = schedule
+	if schedule.Enabled {
+		if err := sm.scheduleCronJob(schedule); err != nil {
+			log.Printf("❌ Ошибка перепланирования расписания %s: %v", schedule.Name, err)
+			return
+		}
+	}
+	log.Printf("🔄 Расписание %s изменено другим экземпляром", schedule.Name)
+}
+
+// reloadChainSchedule перечитывает расписание цепочки; см. reloadSchedule
+func (sm *ScenarioManager) reloadChainSchedule(id string) {
+	schedule, err := sm.storage.GetChainSchedule(id)
+	if err != nil {
+		log.Printf("❌ Ошибка чтения измененного расписания цепочки %s: %v", id, err)
+		return
+	}
+
+	sm.mutex.Lock()
+	defer sm.mutex.Unlock()
+
+	current, exists := sm.chainSchedules[id]
+	if exists && schedule != nil && sameChainSchedule(current, schedule) {
+		current.Name = schedule.Name
+		current.LastRun = schedule.LastRun
+		current.NextRun = schedule.NextRun
+		return
+	}
+
+	if entryID, ok := sm.chainCronEntries[id]; ok {
+		sm.cronScheduler.Remove(entryID)
+		delete(sm.chainCronEntries, id)
+	}
+	if schedule == nil {
+		delete(sm.chainSchedules, id)
+		log.Printf("🔄 Расписание цепочки %s удалено другим экземпляром", id)
+		return
+	}
+
+	sm.chainSchedules[id] = schedule
+	if schedule.Enabled {
+		if err := sm.scheduleChainCronJob(schedule); err != nil {
+			log.Printf("❌ Ошибка перепланирования расписания цепочки %s: %v", schedule.Name, err)
+			return
+		}
+	}
+	log.Printf("🔄 Расписание цепочки %s изменено другим экземпляром", schedule.Name)
+}
+
+// sameSchedule сравнивает поля, от которых зависит задание cron
+func sameSchedule(a, b *models.Schedule) bool {
+	return a.ScenarioType == b.ScenarioType && a.CronExpr == b.CronExpr &&
+		a.Enabled == b.Enabled && a.TimeScale == b.TimeScale && a.CreatedAt.Equal(b.CreatedAt) &&
+		sameTime(a.StartDate, b.StartDate) && sameTime(a.EndDate, b.EndDate)
+}
+
+func sameChainSchedule(a, b *models.ChainSchedule) bool {
+	return a.ChainName == b.ChainName && a.CronExpr == b.CronExpr &&
+		a.Enabled == b.Enabled && a.TimeScale == b.TimeScale && a.CreatedAt.Equal(b.CreatedAt) &&
+		sameTime(a.StartDate, b.StartDate) && sameTime(a.EndDate, b.EndDate)
+}
+
+func sameTime(a, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Equal(*b)
+}
+
+// ===== Блокировки запусков =====
+
+const (
+	scheduleRunLockTTL = time.Hour        // Блокировка запуска расписания; переживает расхождение часов реплик
+	scenarioLeaseTTL   = 30 * time.Second // Аренда сценария; продлевается, пока сценарий выполняется
+)
+
+// scheduleRunKey - ключ блокировки запуска расписания kind с id, назначенного на
+// момент at. Момент берется из расписания, поэтому у всех реплик ключ один.
+func scheduleRunKey(kind, id string, at time.Time) string {
+	return fmt.Sprintf("%s:%s:%d", kind, id, at.UnixNano())
+}
+
+// scenarioLeaseKey - ключ аренды активного сценария
+func scenarioLeaseKey(scenarioType string) string {
+	return "scenario:" + scenarioType
+}
+
+// tryLock занимает ключ в общем хранилище. Хранилище без блокировок
+// принадлежит одному экземпляру, и ключ всегда свободен; при ошибке хранилища
+// запуск пропускается, чтобы не выполнить его дважды.
+func (sm *ScenarioManager) tryLock(key string, ttl time.Duration) bool {
+	locker, ok := sm.storage.(storage.Locker)
+	if !ok {
+		return true
+	}
+	locked, err := locker.TryLock(key, ttl)
+	if err != nil {
+		log.Printf("❌ Ошибка блокировки %s: %v", key, err)
+		return false
+	}
+	return locked
+}
+
+func (sm *ScenarioManager) unlock(key string) {
+	if locker, ok := sm.storage.(storage.Locker); ok {
+		if err := locker.Unlock(key); err != nil {
+			log.Printf("❌ Ошибка снятия блокировки %s: %v", key, err)
+		}
+	}
+}
+
+// renewLeases продлевает аренду выполняемых сценариев и забирает активные
+// сценарии, аренду которых перестал продлевать другой экземпляр
+func (sm *ScenarioManager) renewLeases() {
+	if _, ok := sm.storage.(storage.Locker); !ok {
+		return
+	}
+
+	ticker := sm.clock.NewTicker(scenarioLeaseTTL / 3)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C():
+			sm.mutex.RLock()
+			types := make([]string, 0, len(sm.activeScenarios))
+			for scenarioType := range sm.activeScenarios {
+				types = append(types, scenarioType)
+			}
+			sm.mutex.RUnlock()
+
+			for _, scenarioType := range types {
+				if !sm.tryLock(scenarioLeaseKey(scenarioType), scenarioLeaseTTL) {
+					log.Printf("⚠️ Аренду сценария %s занял другой экземпляр", scenarioType)
+				}
+			}
+			sm.restoreScenarios()
+		case <-sm.stopChan:
+			return
+		}
+	}
+}
@@ -0,0 +1,128 @@
+package scenarios
+
+import (
+	"fmt"
+	"time"
+
+	"log-metrics-simulator/clock"
+
+	"github.com/robfig/cron/v3"
+)
+
+const (
+	maxTimeScale = 3600                   // Максимальное ускорение времени
+	minWarpTick  = 100 * time.Millisecond // Реальный период тиков ускоренного сценария не короче
+)
+
+// Разбор cron-выражений расписаний: стандартные пять полей или шесть с секундами
+var cronParser = cron.NewParser(cron.SecondOptional | cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow | cron.Descriptor)
+
+// timeWarp связывает модельное время запуска с реальным: модельное время идет
+// в scale раз быстрее и в момент wallStart равно start. Длительности,
+// задержки и даты сценариев задаются в модельном времени.
+type timeWarp struct {
+	clock     clock.Clock
+	scale     float64
+	wallStart time.Time
+	start     time.Time
+}
+
+// newTimeWarp создает ускорение scale от момента wallStart; scale 0 - без ускорения,
+// пустой start - модельное время в момент wallStart совпадает с реальным
+func newTimeWarp(clk clock.Clock, scale float64, wallStart time.Time, start *time.Time) *timeWarp {
+	if scale <= 0 {
+		scale = 1
+	}
+	w := &timeWarp{clock: clk, scale: scale, wallStart: wallStart, start: wallStart}
+	if start != nil {
+		w.start = *start
+	}
+	return w
+}
+
+// now возвращает текущее модельное время
+func (w *timeWarp) now() time.Time {
+	return w.simulated(w.clock.Now())
+}
+
+// simulated переводит реальное время в модельное
+func (w *timeWarp) simulated(wall time.Time) time.Time {
+	return w.start.Add(time.Duration(float64(wall.Sub(w.wallStart)) * w.scale))
+}
+
+// wall переводит модельное время в реальное
+func (w *timeWarp) wall(simulated time.Time) time.Time {
+	return w.wallStart.Add(w.real(simulated.Sub(w.start)))
+}
+
+// real возвращает реальную длительность модельного интервала d
+func (w *timeWarp) real(d time.Duration) time.Duration {
+	return time.Duration(float64(d) / w.scale)
+}
+
+// after срабатывает через модельный интервал d
+func (w *timeWarp) after(d time.Duration) <-chan time.Time {
+	return w.clock.After(w.real(d))
+}
+
+// tick возвращает модельный шаг тиков: d, если его реальная длительность
+// не короче minWarpTick, иначе шаг, соответствующий minWarpTick
+func (w *timeWarp) tick(d time.Duration) time.Duration {
+	if w.real(d) < minWarpTick {
+		return time.Duration(float64(minWarpTick) * w.scale)
+	}
+	return d
+}
+
+// warpedSchedule пересчитывает cron-расписание модельного времени в реальное
+type warpedSchedule struct {
+	schedule cron.Schedule
+	warp     *timeWarp
+}
+
+func (s warpedSchedule) Next(t time.Time) time.Time {
+	// Первый запуск происходит в свое время по cron, ускорение действует с него
+	if t.Before(s.warp.wallStart) {
+		return s.warp.wallStart
+	}
+	next := s.schedule.Next(s.warp.simulated(t))
+	if next.IsZero() {
+		return next
+	}
+	return s.warp.wall(next)
+}
+
+// validateTimeScale проверяет коэффициент ускорения времени
+func validateTimeScale(scale float64) error {
+	if scale != 0 && (scale < 1 || scale > maxTimeScale) {
+		return fmt.Errorf("time_scale должен быть 0 (без ускорения) или от 1 до %d", maxTimeScale)
+	}
+	return nil
+}
+
+// newScheduleWarp создает модельное время расписания. Оно совпадает с реальным
+// в момент первого запуска по cron после регистрации и идет от него в scale
+// раз быстрее. Поэтому модельное время не уходит вперед на время, прошедшее с
+// создания расписания, не накапливается между перезапусками, а реплики,
+// зарегистрировавшие расписание до одного и того же запуска, получают
+// одинаковые моменты запусков.
+func (sm *ScenarioManager) newScheduleWarp(spec string, scale float64) (*timeWarp, error) {
+	schedule, err := cronParser.Parse(spec)
+	if err != nil {
+		return nil, err
+	}
+	return newTimeWarp(sm.clock, scale, schedule.Next(sm.clock.Now()), nil), nil
+}
+
+// addCronFunc добавляет задание в планировщик; при ускорении cron-выражение
+// отсчитывается в модельном времени warp
+func (sm *ScenarioManager) addCronFunc(spec string, warp *timeWarp, job scheduledFunc) (cron.EntryID, error) {
+	if warp.scale == 1 {
+		return sm.cronScheduler.AddFunc(spec, job)
+	}
+	schedule, err := cronParser.Parse(spec)
+	if err != nil {
+		return 0, err
+	}
+	return sm.cronScheduler.Schedule(warpedSchedule{schedule: schedule, warp: warp}, job), nil
+}
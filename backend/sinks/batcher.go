@@ -0,0 +1,125 @@
+package sinks
+
+import (
+	"log"
+	"sync"
+	"time"
+)
+
+// Максимальное время отправки остатка буфера при закрытии приемника
+const batcherCloseTimeout = 10 * time.Second
+
+// batcher копит элементы и отправляет их пакетами по размеру или по
+// таймеру. При переполнении буфера отбрасываются самые старые элементы.
+type batcher[T any] struct {
+	name      string
+	batchSize int
+	maxBuffer int
+	send      func(items []T, stop <-chan struct{}) error
+
+	mutex   sync.Mutex
+	buffer  []T
+	flushCh chan struct{}
+	closing chan struct{}
+	done    chan struct{}
+	once    sync.Once
+}
+
+func newBatcher[T any](name string, batchSize, maxBuffer int, interval time.Duration, send func(items []T, stop <-chan struct{}) error) *batcher[T] {
+	if batchSize <= 0 {
+		batchSize = 1000
+	}
+	if maxBuffer < batchSize {
+		maxBuffer = batchSize
+	}
+	if interval <= 0 {
+		interval = time.Second
+	}
+
+	b := &batcher[T]{
+		name:      name,
+		batchSize: batchSize,
+		maxBuffer: maxBuffer,
+		send:      send,
+		flushCh:   make(chan struct{}, 1),
+		closing:   make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+	go b.run(interval)
+	return b
+}
+
+func (b *batcher[T]) add(items []T) bool {
+	select {
+	case <-b.closing:
+		return false
+	default:
+	}
+
+	b.mutex.Lock()
+	b.buffer = append(b.buffer, items...)
+	if over := len(b.buffer) - b.maxBuffer; over > 0 {
+		b.buffer = b.buffer[over:]
+		log.Printf("⚠️ Буфер %s переполнен, отброшено %d записей", b.name, over)
+	}
+	full := len(b.buffer) >= b.batchSize
+	b.mutex.Unlock()
+
+	if full {
+		select {
+		case b.flushCh <- struct{}{}:
+		default:
+		}
+	}
+	return true
+}
+
+func (b *batcher[T]) run(interval time.Duration) {
+	defer close(b.done)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			b.flush(b.closing)
+		case <-b.flushCh:
+			b.flush(b.closing)
+		case <-b.closing:
+			stop := make(chan struct{})
+			timer := time.AfterFunc(batcherCloseTimeout, func() { close(stop) })
+			b.flush(stop)
+			timer.Stop()
+			return
+		}
+	}
+}
+
+// flush отправляет буфер пакетами по batchSize элементов
+func (b *batcher[T]) flush(stop <-chan struct{}) {
+	for {
+		b.mutex.Lock()
+		n := len(b.buffer)
+		if n > b.batchSize {
+			n = b.batchSize
+		}
+		chunk := b.buffer[:n:n]
+		b.buffer = b.buffer[n:]
+		b.mutex.Unlock()
+
+		if len(chunk) == 0 {
+			return
+		}
+
+		if err := b.send(chunk, stop); err != nil {
+			log.Printf("❌ Ошибка отправки %d записей в %s: %v", len(chunk), b.name, err)
+		}
+	}
+}
+
+// close отправляет остаток буфера и останавливает фоновую отправку
+func (b *batcher[T]) close() {
+	b.once.Do(func() { close(b.closing) })
+	<-b.done
+}
@@ -0,0 +1,324 @@
+package sinks
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"log-metrics-simulator/models"
+)
+
+func init() {
+	Register("elasticsearch", func(options map[string]interface{}) (Sink, error) {
+		flushInterval, err := optDuration(options, "flush_interval", time.Second)
+		if err != nil {
+			return nil, err
+		}
+		timeout, err := optDuration(options, "timeout", 30*time.Second)
+		if err != nil {
+			return nil, err
+		}
+		retry, err := parseRetryConfig(options)
+		if err != nil {
+			return nil, err
+		}
+		return NewElasticsearchSink(ElasticsearchConfig{
+			URL:           optString(options, "url", ""),
+			Index:         optString(options, "index", "logs-sim-{YYYY}.{MM}.{DD}"),
+			DataStream:    optString(options, "data_stream", ""),
+			ECS:           optBool(options, "ecs", false),
+			Username:      optString(options, "username", ""),
+			Password:      optString(options, "password", ""),
+			APIKey:        optString(options, "api_key", ""),
+			BatchSize:     optInt(options, "batch_size", 1000),
+			FlushInterval: flushInterval,
+			MaxBuffer:     optInt(options, "max_buffer", 100000),
+			Timeout:       timeout,
+			Retry:         retry,
+		})
+	})
+}
+
+// Версия ECS, которой соответствует маппинг полей
+const ecsVersion = "8.11.0"
+
+// Имена счетчиков приемника в реестре метрик
+const (
+	sinkDocumentsIndexedMetric = "app_sink_documents_indexed_total"
+	sinkIndexingErrorsMetric   = "app_sink_indexing_errors_total"
+)
+
+// ElasticsearchConfig задает параметры приемника Elasticsearch/OpenSearch
+type ElasticsearchConfig struct {
+	URL           string // Адрес кластера
+	Index         string // Шаблон индекса; {YYYY}, {MM} и {DD} заменяются датой записи (UTC)
+	DataStream    string // Имя data stream; если задано, документы пишутся операцией create
+	ECS           bool   // Маппинг полей по Elastic Common Schema
+	Username      string // Basic-аутентификация
+	Password      string
+	APIKey        string // Заголовок Authorization: ApiKey
+	BatchSize     int
+	FlushInterval time.Duration
+	MaxBuffer     int
+	Timeout       time.Duration
+	Retry         RetryConfig
+}
+
+type esDocument struct {
+	index string
+	body  []byte
+}
+
+// esRawDocument - LogEntry как есть с меткой времени для data stream и метками сценария
+type esRawDocument struct {
+	models.LogEntry
+	AtTimestamp string            `json:"@timestamp,omitempty"`
+	Labels      map[string]string `json:"labels,omitempty"`
+}
+
+type esBulkItem struct {
+	Status int `json:"status"`
+	Error  *struct {
+		Type   string `json:"type"`
+		Reason string `json:"reason"`
+	} `json:"error"`
+}
+
+type esBulkResponse struct {
+	Errors bool                    `json:"errors"`
+	Items  []map[string]esBulkItem `json:"items"`
+}
+
+// ElasticsearchSink индексирует логи через _bulk API. Документы, отклоненные
+// с кодом 429 или 5xx, отправляются повторно; остальные ошибки и исчерпанные
+// повторы учитываются в счетчике app_sink_indexing_errors_total.
+type ElasticsearchSink struct {
+	config  ElasticsearchConfig
+	client  *http.Client
+	batcher *batcher[esDocument]
+}
+
+func NewElasticsearchSink(config ElasticsearchConfig) (*ElasticsearchSink, error) {
+	if config.URL == "" {
+		return nil, fmt.Errorf("не указан адрес Elasticsearch")
+	}
+	if config.DataStream == "" && config.Index == "" {
+		return nil, fmt.Errorf("не указан индекс")
+	}
+	config.URL = strings.TrimRight(config.URL, "/")
+
+	s := &ElasticsearchSink{
+		config: config,
+		client: &http.Client{Timeout: config.Timeout},
+	}
+	s.batcher = newBatcher("Elasticsearch", config.BatchSize, config.MaxBuffer, config.FlushInterval, s.send)
+	return s, nil
+}
+
+func (s *ElasticsearchSink) Write(batch Batch) error {
+	docs := make([]esDocument, 0, len(batch.Logs))
+	for _, entry := range batch.Logs {
+		var doc interface{}
+		if s.config.ECS {
+			doc = ecsDocument(entry, batch.Labels)
+		} else {
+			raw := esRawDocument{LogEntry: entry, Labels: batch.Labels}
+			if s.config.DataStream != "" {
+				raw.AtTimestamp = entry.Timestamp.UTC().Format(time.RFC3339Nano)
+			}
+			doc = raw
+		}
+
+		body, err := json.Marshal(doc)
+		if err != nil {
+			return err
+		}
+		docs = append(docs, esDocument{index: s.indexName(entry.Timestamp), body: body})
+	}
+
+	if !s.batcher.add(docs) {
+		return fmt.Errorf("приемник Elasticsearch закрыт")
+	}
+	return nil
+}
+
+func (s *ElasticsearchSink) indexName(ts time.Time) string {
+	if s.config.DataStream != "" {
+		return s.config.DataStream
+	}
+	ts = ts.UTC()
+	// Подстановки в фигурных скобках не задевают те же буквы в имени индекса
+	return strings.NewReplacer(
+		"{YYYY}", ts.Format("2006"),
+		"{MM}", ts.Format("01"),
+		"{DD}", ts.Format("02"),
+	).Replace(s.config.Index)
+}
+
+// send отправляет пакет, повторяя временно отклоненные документы
+func (s *ElasticsearchSink) send(docs []esDocument, stop <-chan struct{}) error {
+	pending := docs
+	err := withRetry(s.config.Retry, stop, func() error {
+		retry, err := s.bulk(pending)
+		if err != nil {
+			return err
+		}
+		pending = retry
+		if len(pending) > 0 {
+			return fmt.Errorf("%d документов временно отклонено", len(pending))
+		}
+		return nil
+	})
+	if err != nil {
+		addCounter(sinkIndexingErrorsMetric, map[string]string{"sink": "elasticsearch", "error_type": "send_failed"}, float64(len(pending)))
+	}
+	return err
+}
+
+// bulk выполняет один запрос _bulk и возвращает документы для повтора
+func (s *ElasticsearchSink) bulk(docs []esDocument) ([]esDocument, error) {
+	op := "index"
+	if s.config.DataStream != "" {
+		op = "create"
+	}
+
+	var body bytes.Buffer
+	for _, doc := range docs {
+		action, _ := json.Marshal(map[string]map[string]string{op: {"_index": doc.index}})
+		body.Write(action)
+		body.WriteByte('\n')
+		body.Write(doc.body)
+		body.WriteByte('\n')
+	}
+
+	req, err := http.NewRequest(http.MethodPost, s.config.URL+"/_bulk", &body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-ndjson")
+	if s.config.APIKey != "" {
+		req.Header.Set("Authorization", "ApiKey "+s.config.APIKey)
+	} else if s.config.Username != "" {
+		req.SetBasicAuth(s.config.Username, s.config.Password)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, checkResponse(resp)
+	}
+
+	var result esBulkResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("ошибка разбора ответа _bulk: %v", err)
+	}
+
+	if !result.Errors {
+		addCounter(sinkDocumentsIndexedMetric, map[string]string{"sink": "elasticsearch"}, float64(len(docs)))
+		return nil, nil
+	}
+
+	// Элементы ответа идут в том же порядке, что и операции запроса
+	var retry []esDocument
+	indexed := 0
+	for i, item := range result.Items {
+		if i >= len(docs) {
+			break
+		}
+		for _, r := range item {
+			switch {
+			case r.Status >= 200 && r.Status < 300:
+				indexed++
+			case r.Status == http.StatusTooManyRequests || r.Status >= 500:
+				retry = append(retry, docs[i])
+			default:
+				errorType := "unknown"
+				if r.Error != nil && r.Error.Type != "" {
+					errorType = r.Error.Type
+				}
+				addCounter(sinkIndexingErrorsMetric, map[string]string{"sink": "elasticsearch", "error_type": errorType}, 1)
+			}
+		}
+	}
+	addCounter(sinkDocumentsIndexedMetric, map[string]string{"sink": "elasticsearch"}, float64(indexed))
+	return retry, nil
+}
+
+// ecsDocument раскладывает запись лога по полям Elastic Common Schema
+func ecsDocument(entry models.LogEntry, labels map[string]string) map[string]interface{} {
+	doc := map[string]interface{}{
+		"@timestamp": entry.Timestamp.UTC().Format(time.RFC3339Nano),
+		"ecs":        map[string]string{"version": ecsVersion},
+		"message":    entry.Message,
+		"log":        map[string]string{"level": strings.ToLower(entry.Level)},
+		"service":    map[string]string{"name": entry.Service},
+		"event":      map[string]interface{}{"duration": entry.Duration * int64(time.Millisecond)},
+	}
+
+	if entry.TraceID != "" {
+		doc["trace"] = map[string]string{"id": entry.TraceID}
+	}
+	if entry.SpanID != "" {
+		doc["span"] = map[string]string{"id": entry.SpanID}
+	}
+	if entry.UserID != "" {
+		doc["user"] = map[string]string{"id": entry.UserID}
+	}
+	if entry.IP != "" {
+		doc["client"] = map[string]string{"ip": entry.IP}
+	}
+	if entry.UserAgent != "" {
+		doc["user_agent"] = map[string]string{"original": entry.UserAgent}
+	}
+	if entry.Path != "" {
+		doc["url"] = map[string]string{"path": entry.Path}
+	}
+
+	httpFields := make(map[string]interface{})
+	if entry.Method != "" {
+		httpFields["request"] = map[string]string{"method": entry.Method}
+	}
+	if entry.Status != 0 {
+		httpFields["response"] = map[string]int{"status_code": entry.Status}
+	}
+	if len(httpFields) > 0 {
+		doc["http"] = httpFields
+	}
+
+	if entry.Error != "" || entry.Stack != "" {
+		errorFields := make(map[string]string)
+		if entry.Error != "" {
+			errorFields["message"] = entry.Error
+		}
+		if entry.Stack != "" {
+			errorFields["stack_trace"] = entry.Stack
+		}
+		doc["error"] = errorFields
+	}
+
+	// Поля без аналога в ECS и метки сценария попадают в labels
+	ecsLabels := make(map[string]string, len(labels)+1)
+	for k, v := range labels {
+		ecsLabels[k] = v
+	}
+	if entry.SessionID != "" {
+		ecsLabels["session_id"] = entry.SessionID
+	}
+	if len(ecsLabels) > 0 {
+		doc["labels"] = ecsLabels
+	}
+
+	return doc
+}
+
+func (s *ElasticsearchSink) Close() error {
+	s.batcher.close()
+	return nil
+}
@@ -0,0 +1,428 @@
+package sinks
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"log-metrics-simulator/models"
+)
+
+// esOperation - пара строк _bulk: действие и документ
+type esOperation struct {
+	action string
+	index  string
+	doc    map[string]interface{}
+}
+
+// esRequest - запрос _bulk, полученный заглушкой
+type esRequest struct {
+	header     http.Header
+	operations []esOperation
+}
+
+// esStub - HTTP-заглушка Elasticsearch. respond получает номер запроса и
+// его операции и возвращает код ответа и статусы элементов; без элементов
+// ответ _bulk не содержит ошибок.
+type esStub struct {
+	*httptest.Server
+
+	mutex    sync.Mutex
+	requests []esRequest
+}
+
+// esItemError - статус элемента ответа _bulk и тип ошибки
+type esItemError struct {
+	status    int
+	errorType string
+}
+
+func newESStub(t *testing.T, respond func(n int, ops []esOperation) (int, []esItemError)) *esStub {
+	stub := &esStub{}
+	stub.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/_bulk" {
+			t.Errorf("путь запроса %s, ожидался /_bulk", r.URL.Path)
+		}
+		body, _ := io.ReadAll(r.Body)
+		ops := parseBulk(t, body)
+
+		stub.mutex.Lock()
+		n := len(stub.requests)
+		stub.requests = append(stub.requests, esRequest{header: r.Header.Clone(), operations: ops})
+		stub.mutex.Unlock()
+
+		status, items := http.StatusOK, []esItemError(nil)
+		if respond != nil {
+			status, items = respond(n, ops)
+		}
+		if status != http.StatusOK {
+			w.WriteHeader(status)
+			return
+		}
+
+		resp := map[string]interface{}{"errors": len(items) > 0}
+		var respItems []map[string]interface{}
+		for i, op := range ops {
+			item := map[string]interface{}{"status": http.StatusCreated}
+			if i < len(items) {
+				item["status"] = items[i].status
+				if items[i].errorType != "" {
+					item["error"] = map[string]string{"type": items[i].errorType, "reason": "test"}
+				}
+			}
+			respItems = append(respItems, map[string]interface{}{op.action: item})
+		}
+		resp["items"] = respItems
+		json.NewEncoder(w).Encode(resp)
+	}))
+	t.Cleanup(stub.Close)
+	return stub
+}
+
+func (s *esStub) received() []esRequest {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return append([]esRequest(nil), s.requests...)
+}
+
+// parseBulk разбирает тело _bulk в формате NDJSON
+func parseBulk(t *testing.T, body []byte) []esOperation {
+	var ops []esOperation
+	scanner := bufio.NewScanner(bytes.NewReader(body))
+	scanner.Buffer(nil, 1<<20)
+	for scanner.Scan() {
+		var action map[string]map[string]string
+		if err := json.Unmarshal(scanner.Bytes(), &action); err != nil || len(action) != 1 {
+			t.Errorf("неверная строка действия %q", scanner.Text())
+			return ops
+		}
+		if !scanner.Scan() {
+			t.Error("действие без документа")
+			return ops
+		}
+		var op esOperation
+		for name, meta := range action {
+			op.action, op.index = name, meta["_index"]
+		}
+		if err := json.Unmarshal(scanner.Bytes(), &op.doc); err != nil {
+			t.Errorf("документ не JSON: %v", err)
+		}
+		ops = append(ops, op)
+	}
+	return ops
+}
+
+// captureCounters собирает счетчики приемников на время теста
+func captureCounters(t *testing.T) func(name string, labels map[string]string) float64 {
+	var mutex sync.Mutex
+	counters := make(map[string]float64)
+	key := func(name string, labels map[string]string) string {
+		data, _ := json.Marshal(labels)
+		return name + string(data)
+	}
+	SetCounterHook(func(name string, labels map[string]string, delta float64) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		counters[key(name, labels)] += delta
+	})
+	t.Cleanup(func() { SetCounterHook(nil) })
+
+	return func(name string, labels map[string]string) float64 {
+		mutex.Lock()
+		defer mutex.Unlock()
+		return counters[key(name, labels)]
+	}
+}
+
+func newTestElasticsearchSink(t *testing.T, url string, config ElasticsearchConfig) *ElasticsearchSink {
+	config.URL = url
+	if config.Index == "" && config.DataStream == "" {
+		config.Index = "logs-sim-{YYYY}.{MM}.{DD}"
+	}
+	if config.FlushInterval == 0 {
+		config.FlushInterval = time.Hour
+	}
+	if config.Timeout == 0 {
+		config.Timeout = 5 * time.Second
+	}
+	if config.Retry.MinBackoff == 0 {
+		config.Retry = RetryConfig{MaxRetries: 3, MinBackoff: time.Millisecond, MaxBackoff: time.Millisecond}
+	}
+	sink, err := NewElasticsearchSink(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return sink
+}
+
+var esSinkLabels = map[string]string{"sink": "elasticsearch"}
+
+func esErrorLabels(errorType string) map[string]string {
+	return map[string]string{"sink": "elasticsearch", "error_type": errorType}
+}
+
+func TestElasticsearchSinkIndex(t *testing.T) {
+	counter := captureCounters(t)
+	stub := newESStub(t, nil)
+	sink := newTestElasticsearchSink(t, stub.URL, ElasticsearchConfig{APIKey: "c2VjcmV0"})
+
+	if err := sink.Write(Batch{Logs: testLogs(), Labels: map[string]string{"scenario": "load"}}); err != nil {
+		t.Fatal(err)
+	}
+	sink.Close()
+
+	requests := stub.received()
+	if len(requests) != 1 {
+		t.Fatalf("получено %d запросов, ожидался 1", len(requests))
+	}
+	req := requests[0]
+	if got := req.header.Get("Content-Type"); got != "application/x-ndjson" {
+		t.Errorf("Content-Type = %q", got)
+	}
+	if got := req.header.Get("Authorization"); got != "ApiKey c2VjcmV0" {
+		t.Errorf("Authorization = %q", got)
+	}
+	if len(req.operations) != 4 {
+		t.Fatalf("получено %d документов, ожидалось 4", len(req.operations))
+	}
+	for _, op := range req.operations {
+		if op.action != "index" || op.index != "logs-sim-2024.01.01" {
+			t.Errorf("действие %s в индекс %s", op.action, op.index)
+		}
+		if _, ok := op.doc["@timestamp"]; ok {
+			t.Error("@timestamp в документе обычного индекса")
+		}
+		if labels, _ := op.doc["labels"].(map[string]interface{}); labels["scenario"] != "load" {
+			t.Errorf("метки документа %v", op.doc["labels"])
+		}
+	}
+	if got := counter(sinkDocumentsIndexedMetric, esSinkLabels); got != 4 {
+		t.Errorf("проиндексировано %v, ожидалось 4", got)
+	}
+}
+
+func TestElasticsearchSinkDataStream(t *testing.T) {
+	stub := newESStub(t, nil)
+	sink := newTestElasticsearchSink(t, stub.URL, ElasticsearchConfig{
+		DataStream: "logs-sim-default",
+		Username:   "elastic",
+		Password:   "changeme",
+	})
+
+	if err := sink.Write(Batch{Logs: testLogs()[:1]}); err != nil {
+		t.Fatal(err)
+	}
+	sink.Close()
+
+	requests := stub.received()
+	if len(requests) != 1 || len(requests[0].operations) != 1 {
+		t.Fatalf("получены запросы %+v", requests)
+	}
+	req := requests[0]
+	if user, pass, ok := (&http.Request{Header: req.header}).BasicAuth(); !ok || user != "elastic" || pass != "changeme" {
+		t.Errorf("basic-аутентификация %q:%q", user, pass)
+	}
+	op := req.operations[0]
+	if op.action != "create" || op.index != "logs-sim-default" {
+		t.Errorf("действие %s в индекс %s, ожидалось create в data stream", op.action, op.index)
+	}
+	if got := op.doc["@timestamp"]; got != "2024-01-01T12:00:03Z" {
+		t.Errorf("@timestamp = %v", got)
+	}
+}
+
+func TestElasticsearchSinkPartialFailure(t *testing.T) {
+	counter := captureCounters(t)
+	stub := newESStub(t, func(n int, ops []esOperation) (int, []esItemError) {
+		if n > 0 {
+			return http.StatusOK, nil
+		}
+		return http.StatusOK, []esItemError{
+			{status: http.StatusCreated},
+			{status: http.StatusTooManyRequests, errorType: "es_rejected_execution_exception"},
+			{status: http.StatusBadRequest, errorType: "mapper_parsing_exception"},
+			{status: http.StatusServiceUnavailable, errorType: "unavailable_shards_exception"},
+		}
+	})
+	sink := newTestElasticsearchSink(t, stub.URL, ElasticsearchConfig{})
+
+	if err := sink.Write(Batch{Logs: testLogs()}); err != nil {
+		t.Fatal(err)
+	}
+	sink.Close()
+
+	requests := stub.received()
+	if len(requests) != 2 {
+		t.Fatalf("получено %d запросов, ожидалось 2", len(requests))
+	}
+	// Повторно отправляются только документы, отклоненные с 429 и 5xx
+	var retried []string
+	for _, op := range requests[1].operations {
+		retried = append(retried, op.doc["message"].(string))
+	}
+	if want := []string{"o1", "o2"}; !reflect.DeepEqual(retried, want) {
+		t.Errorf("повторены документы %v, ожидались %v", retried, want)
+	}
+
+	if got := counter(sinkDocumentsIndexedMetric, esSinkLabels); got != 3 {
+		t.Errorf("проиндексировано %v, ожидалось 3", got)
+	}
+	if got := counter(sinkIndexingErrorsMetric, esErrorLabels("mapper_parsing_exception")); got != 1 {
+		t.Errorf("ошибок mapper_parsing_exception %v, ожидалась 1", got)
+	}
+	for _, errorType := range []string{"es_rejected_execution_exception", "unavailable_shards_exception", "send_failed"} {
+		if got := counter(sinkIndexingErrorsMetric, esErrorLabels(errorType)); got != 0 {
+			t.Errorf("ошибок %s %v, ожидалось 0: документ отправлен повторно", errorType, got)
+		}
+	}
+}
+
+func TestElasticsearchSinkSendFailed(t *testing.T) {
+	tests := []struct {
+		name     string
+		respond  func(n int, ops []esOperation) (int, []esItemError)
+		attempts int
+		failed   float64
+	}{
+		{
+			name: "документы отклоняются до исчерпания повторов",
+			respond: func(n int, ops []esOperation) (int, []esItemError) {
+				if n == 0 {
+					return http.StatusOK, []esItemError{{status: http.StatusCreated}, {status: http.StatusTooManyRequests}}
+				}
+				return http.StatusOK, []esItemError{{status: http.StatusTooManyRequests}}
+			},
+			attempts: 3,
+			failed:   1,
+		},
+		{
+			name: "503 на весь запрос",
+			respond: func(int, []esOperation) (int, []esItemError) {
+				return http.StatusServiceUnavailable, nil
+			},
+			attempts: 3,
+			failed:   2,
+		},
+		{
+			name: "400 на весь запрос не повторяется",
+			respond: func(int, []esOperation) (int, []esItemError) {
+				return http.StatusBadRequest, nil
+			},
+			attempts: 1,
+			failed:   2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			counter := captureCounters(t)
+			stub := newESStub(t, tt.respond)
+			sink := newTestElasticsearchSink(t, stub.URL, ElasticsearchConfig{
+				Retry: RetryConfig{MaxRetries: 2, MinBackoff: time.Millisecond, MaxBackoff: time.Millisecond},
+			})
+
+			if err := sink.Write(Batch{Logs: testLogs()[:2]}); err != nil {
+				t.Fatal(err)
+			}
+			sink.Close()
+
+			if got := len(stub.received()); got != tt.attempts {
+				t.Errorf("попыток %d, ожидалось %d", got, tt.attempts)
+			}
+			if got := counter(sinkIndexingErrorsMetric, esErrorLabels("send_failed")); got != tt.failed {
+				t.Errorf("send_failed = %v, ожидалось %v", got, tt.failed)
+			}
+		})
+	}
+}
+
+func TestElasticsearchIndexName(t *testing.T) {
+	ts := time.Date(2024, 3, 7, 23, 30, 0, 0, time.FixedZone("MSK", 3*3600))
+	tests := []struct {
+		index string
+		want  string
+	}{
+		{"logs-sim-{YYYY}.{MM}.{DD}", "logs-sim-2024.03.07"},
+		{"DDoS-logs-{YYYY}-{MM}", "DDoS-logs-2024-03"},
+		{"MMDD-YYYY", "MMDD-YYYY"},
+		{"logs", "logs"},
+	}
+	for _, tt := range tests {
+		s := &ElasticsearchSink{config: ElasticsearchConfig{Index: tt.index}}
+		if got := s.indexName(ts); got != tt.want {
+			t.Errorf("indexName(%q) = %q, ожидалось %q", tt.index, got, tt.want)
+		}
+	}
+
+	s := &ElasticsearchSink{config: ElasticsearchConfig{Index: "logs-{YYYY}", DataStream: "logs-sim-default"}}
+	if got := s.indexName(ts); got != "logs-sim-default" {
+		t.Errorf("с data stream индекс %q", got)
+	}
+}
+
+func TestECSDocument(t *testing.T) {
+	entry := models.LogEntry{
+		Timestamp: time.Date(2024, 1, 1, 15, 0, 0, 0, time.FixedZone("MSK", 3*3600)),
+		Level:     "ERROR",
+		Service:   "payment-service",
+		Message:   "payment failed",
+		TraceID:   "4bf92f3577b34da6a3ce929d0e0e4736",
+		SpanID:    "00f067aa0ba902b7",
+		UserID:    "user-1",
+		SessionID: "session-1",
+		IP:        "10.0.0.1",
+		UserAgent: "curl/8.0",
+		Method:    "POST",
+		Path:      "/api/pay",
+		Status:    502,
+		Duration:  150,
+		Error:     "gateway timeout",
+		Stack:     "at pay()",
+	}
+	data, err := json.Marshal(ecsDocument(entry, map[string]string{"scenario": "load"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var doc map[string]interface{}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]interface{}{
+		"@timestamp": "2024-01-01T12:00:00Z",
+		"ecs":        map[string]interface{}{"version": ecsVersion},
+		"message":    "payment failed",
+		"log":        map[string]interface{}{"level": "error"},
+		"service":    map[string]interface{}{"name": "payment-service"},
+		"event":      map[string]interface{}{"duration": float64(150 * time.Millisecond)},
+		"trace":      map[string]interface{}{"id": "4bf92f3577b34da6a3ce929d0e0e4736"},
+		"span":       map[string]interface{}{"id": "00f067aa0ba902b7"},
+		"user":       map[string]interface{}{"id": "user-1"},
+		"client":     map[string]interface{}{"ip": "10.0.0.1"},
+		"user_agent": map[string]interface{}{"original": "curl/8.0"},
+		"url":        map[string]interface{}{"path": "/api/pay"},
+		"http": map[string]interface{}{
+			"request":  map[string]interface{}{"method": "POST"},
+			"response": map[string]interface{}{"status_code": float64(502)},
+		},
+		"error":  map[string]interface{}{"message": "gateway timeout", "stack_trace": "at pay()"},
+		"labels": map[string]interface{}{"scenario": "load", "session_id": "session-1"},
+	}
+	if !reflect.DeepEqual(doc, want) {
+		t.Errorf("документ ECS:\n%v\nожидался:\n%v", doc, want)
+	}
+
+	// Пустые поля не попадают в документ
+	minimal := ecsDocument(models.LogEntry{Timestamp: entry.Timestamp, Level: "INFO", Service: "cart"}, nil)
+	for _, field := range []string{"trace", "span", "user", "client", "user_agent", "url", "http", "error", "labels"} {
+		if _, ok := minimal[field]; ok {
+			t.Errorf("пустое поле %s в документе", field)
+		}
+	}
+}
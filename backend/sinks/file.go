@@ -0,0 +1,375 @@
+package sinks
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"log-metrics-simulator/models"
+)
+
+func init() {
+	Register("file", func(options map[string]interface{}) (Sink, error) {
+		rotateInterval, err := optDuration(options, "rotate_interval", 0)
+		if err != nil {
+			return nil, err
+		}
+		return NewFileSink(FileConfig{
+			Path:           optString(options, "path", ""),
+			Format:         optString(options, "format", "json"),
+			MaxSizeBytes:   int64(optInt(options, "max_size_mb", 0)) * 1024 * 1024,
+			RotateInterval: rotateInterval,
+			Compress:       optBool(options, "compress", false),
+			MaxBackups:     optInt(options, "max_backups", 0),
+		})
+	})
+}
+
+// Формат суффикса ротированных файлов: сортируется лексикографически по времени.
+// За временем идет порядковый номер ротации в пределах одной миллисекунды.
+const rotationTimeFormat = "20060102T150405.000"
+
+// FileConfig задает параметры файлового приемника
+type FileConfig struct {
+	Path           string        // Путь к активному файлу
+	Format         string        // json или text
+	MaxSizeBytes   int64         // Ротация по размеру; 0 - без ограничения
+	RotateInterval time.Duration // Ротация по времени; 0 - без ротации по времени
+	Compress       bool          // Сжимать ротированные файлы gzip
+	MaxBackups     int           // Сколько ротированных файлов хранить; 0 - все
+}
+
+// FileSink пишет логи в файл с ротацией по размеру и времени.
+// Активный файл всегда имеет один и тот же путь, ротированные файлы
+// получают суффикс со временем ротации, поэтому Filebeat, Vector и
+// Promtail могут читать активный файл как обычный лог приложения.
+type FileSink struct {
+	config   FileConfig
+	mutex    sync.Mutex
+	file     *os.File
+	size     int64
+	openedAt time.Time
+
+	// Фоновая ротация по времени файла, в который ничего не пишется
+	done     chan struct{}
+	stopped  chan struct{}
+	stopOnce sync.Once
+}
+
+func NewFileSink(config FileConfig) (*FileSink, error) {
+	if config.Path == "" {
+		return nil, fmt.Errorf("не указан путь к файлу")
+	}
+	if config.Format != "json" && config.Format != "text" {
+		return nil, fmt.Errorf("неизвестный формат: %s", config.Format)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(config.Path), 0o755); err != nil {
+		return nil, fmt.Errorf("ошибка создания каталога: %v", err)
+	}
+
+	s := &FileSink{config: config}
+	if err := s.open(); err != nil {
+		return nil, err
+	}
+	if config.RotateInterval > 0 {
+		s.done = make(chan struct{})
+		s.stopped = make(chan struct{})
+		go s.rotateOnSchedule()
+	}
+	return s, nil
+}
+
+func (s *FileSink) open() error {
+	file, err := os.OpenFile(s.config.Path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("ошибка открытия файла: %v", err)
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("ошибка чтения размера файла: %v", err)
+	}
+
+	s.file = file
+	s.size = info.Size()
+	s.openedAt = time.Now()
+	return nil
+}
+
+func (s *FileSink) Write(batch Batch) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.file == nil {
+		return fmt.Errorf("файл закрыт")
+	}
+
+	buf := bufio.NewWriter(s.file)
+	for _, entry := range batch.Logs {
+		line, err := s.formatLine(entry)
+		if err != nil {
+			return err
+		}
+
+		if s.needsRotation(int64(len(line))) {
+			if err := buf.Flush(); err != nil {
+				return err
+			}
+			if err := s.rotate(); err != nil {
+				return err
+			}
+			buf.Reset(s.file)
+		}
+
+		n, err := buf.Write(line)
+		s.size += int64(n)
+		if err != nil {
+			return err
+		}
+	}
+	return buf.Flush()
+}
+
+func (s *FileSink) formatLine(entry models.LogEntry) ([]byte, error) {
+	if s.config.Format == "text" {
+		return []byte(fmt.Sprintf("%s [%s] %s: %s %s %d %dms %s\n",
+			entry.Timestamp.Format("2006-01-02T15:04:05.000Z"),
+			entry.Level,
+			entry.Service,
+			entry.Method,
+			entry.Path,
+			entry.Status,
+			entry.Duration,
+			entry.Message,
+		)), nil
+	}
+
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return nil, err
+	}
+	return append(line, '\n'), nil
+}
+
+func (s *FileSink) needsRotation(next int64) bool {
+	if s.config.MaxSizeBytes > 0 && s.size > 0 && s.size+next > s.config.MaxSizeBytes {
+		return true
+	}
+	if s.config.RotateInterval > 0 && time.Since(s.openedAt) >= s.config.RotateInterval {
+		return true
+	}
+	return false
+}
+
+// rotateOnSchedule ротирует файл по истечении RotateInterval, даже если в
+// него ничего не пишется: при записи срок проверяет needsRotation
+func (s *FileSink) rotateOnSchedule() {
+	defer close(s.stopped)
+	timer := time.NewTimer(s.config.RotateInterval)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-s.done:
+			return
+		case <-timer.C:
+		}
+		timer.Reset(s.rotateIfDue())
+	}
+}
+
+// rotateIfDue ротирует непустой файл, открытый дольше RotateInterval, и
+// возвращает время до следующей проверки. Для пустого файла интервал
+// отсчитывается заново, чтобы не копить пустые ротированные файлы.
+func (s *FileSink) rotateIfDue() time.Duration {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.file == nil {
+		return s.config.RotateInterval
+	}
+	if wait := s.config.RotateInterval - time.Since(s.openedAt); wait > 0 {
+		return wait
+	}
+	if s.size == 0 {
+		s.openedAt = time.Now()
+		return s.config.RotateInterval
+	}
+	if err := s.rotate(); err != nil {
+		log.Printf("❌ Ошибка ротации %s: %v", s.config.Path, err)
+	}
+	return s.config.RotateInterval
+}
+
+// rotate переименовывает активный файл, при необходимости сжимает его,
+// удаляет лишние старые файлы и открывает новый активный файл
+func (s *FileSink) rotate() error {
+	if err := s.file.Close(); err != nil {
+		return s.reopen(fmt.Errorf("ошибка закрытия файла: %v", err))
+	}
+
+	rotated := s.rotatedName(time.Now())
+	if err := os.Rename(s.config.Path, rotated); err != nil {
+		// Запись продолжается в прежний файл, ротация повторится при следующей записи
+		return s.reopen(fmt.Errorf("ошибка ротации файла: %v", err))
+	}
+
+	if s.config.Compress {
+		if err := compressFile(rotated); err != nil {
+			log.Printf("❌ Ошибка сжатия %s: %v", rotated, err)
+		}
+	}
+
+	if err := s.removeOldBackups(); err != nil {
+		log.Printf("❌ Ошибка удаления старых файлов %s: %v", s.config.Path, err)
+	}
+
+	return s.reopen(nil)
+}
+
+// rotatedName возвращает имя ротированного файла. Ротации в одну миллисекунду
+// получают номера фиксированной ширины после наибольшего существующего, поэтому
+// сортировка по имени остается сортировкой по возрасту и после удаления старых файлов.
+func (s *FileSink) rotatedName(now time.Time) string {
+	base := s.config.Path + "." + now.Format(rotationTimeFormat) + "-"
+	seq := 0
+	matches, _ := filepath.Glob(base + "*")
+	for _, m := range matches {
+		digits := strings.TrimSuffix(strings.TrimSuffix(strings.TrimPrefix(m, base), ".tmp"), ".gz")
+		if n, err := strconv.Atoi(digits); err == nil && n >= seq {
+			seq = n + 1
+		}
+	}
+	return fmt.Sprintf("%s%03d", base, seq)
+}
+
+// reopen открывает активный файл после ротации и возвращает cause или ошибку
+// открытия. Если файл не открылся, запись невозможна до закрытия приемника.
+func (s *FileSink) reopen(cause error) error {
+	if err := s.open(); err != nil {
+		s.file = nil
+		if cause != nil {
+			return fmt.Errorf("%v; %v", cause, err)
+		}
+		return err
+	}
+	return cause
+}
+
+// isBackup сообщает, является ли name ротированным файлом активного файла
+// path: имя вида path.<время>-<номер> с необязательным .gz. Другие файлы с
+// тем же префиксом (app.log.bak, app.log.conf) не считаются ротированными.
+func isBackup(path, name string) bool {
+	suffix, ok := strings.CutPrefix(name, path+".")
+	if !ok {
+		return false
+	}
+	suffix = strings.TrimSuffix(suffix, ".gz")
+	stamp, seq, ok := strings.Cut(suffix, "-")
+	if !ok || seq == "" {
+		return false
+	}
+	if _, err := time.Parse(rotationTimeFormat, stamp); err != nil {
+		return false
+	}
+	for _, c := range seq {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+func (s *FileSink) removeOldBackups() error {
+	if s.config.MaxBackups <= 0 {
+		return nil
+	}
+
+	matches, err := filepath.Glob(s.config.Path + ".*")
+	if err != nil {
+		return err
+	}
+
+	// Имена содержат время ротации, поэтому сортировка по имени - сортировка по возрасту
+	backups := matches[:0]
+	for _, m := range matches {
+		if isBackup(s.config.Path, m) {
+			backups = append(backups, m)
+		}
+	}
+	sort.Strings(backups)
+
+	for len(backups) > s.config.MaxBackups {
+		if err := os.Remove(backups[0]); err != nil {
+			return err
+		}
+		backups = backups[1:]
+	}
+	return nil
+}
+
+// compressFile сжимает файл в path.gz и удаляет исходный
+func compressFile(path string) error {
+	src, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	tmp := path + ".gz.tmp"
+	dst, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+
+	gz := gzip.NewWriter(dst)
+	if _, err := io.Copy(gz, src); err != nil {
+		gz.Close()
+		dst.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := gz.Close(); err != nil {
+		dst.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := dst.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+
+	if err := os.Rename(tmp, path+".gz"); err != nil {
+		return err
+	}
+	return os.Remove(path)
+}
+
+func (s *FileSink) Close() error {
+	if s.done != nil {
+		s.stopOnce.Do(func() { close(s.done) })
+		<-s.stopped
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.file == nil {
+		return nil
+	}
+	err := s.file.Close()
+	s.file = nil
+	return err
+}
@@ -0,0 +1,312 @@
+package sinks
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"log-metrics-simulator/models"
+)
+
+// fileLogs возвращает count логов одинаковой длины с сообщениями m00, m01, ...
+func fileLogs(count int) []models.LogEntry {
+	base := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	logs := make([]models.LogEntry, count)
+	for i := range logs {
+		logs[i] = models.LogEntry{Timestamp: base, Service: "cart-service", Level: "INFO", Message: fmt.Sprintf("m%02d", i)}
+	}
+	return logs
+}
+
+// backupFiles возвращает ротированные файлы активного файла path от старых к новым
+func backupFiles(t *testing.T, path string) []string {
+	t.Helper()
+	matches, err := filepath.Glob(path + ".*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	backups := matches[:0]
+	for _, m := range matches {
+		if isBackup(path, m) {
+			backups = append(backups, m)
+		}
+	}
+	sort.Strings(backups)
+	return backups
+}
+
+// readMessages возвращает сообщения логов из ротированных файлов и активного
+// файла в порядке записи
+func readMessages(t *testing.T, path string) []string {
+	t.Helper()
+	var messages []string
+	for _, name := range append(backupFiles(t, path), path) {
+		file, err := os.Open(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var r io.Reader = file
+		if strings.HasSuffix(name, ".gz") {
+			gz, err := gzip.NewReader(file)
+			if err != nil {
+				t.Fatalf("%s: %v", name, err)
+			}
+			r = gz
+		}
+		scanner := bufio.NewScanner(r)
+		for scanner.Scan() {
+			var entry models.LogEntry
+			if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+				t.Fatalf("%s: %v", name, err)
+			}
+			messages = append(messages, entry.Message)
+		}
+		file.Close()
+	}
+	return messages
+}
+
+func TestFileSinkRotation(t *testing.T) {
+	sample, err := (&FileSink{config: FileConfig{Format: "json"}}).formatLine(fileLogs(1)[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	line := int64(len(sample))
+
+	tests := []struct {
+		name        string
+		config      FileConfig
+		wantBackups int
+		wantFirst   int // Номер первого сохранившегося лога
+		wantGzip    bool
+	}{
+		{name: "без ротации", config: FileConfig{}, wantBackups: 0},
+		{name: "по размеру", config: FileConfig{MaxSizeBytes: 3 * line}, wantBackups: 3},
+		// Все ротации приходятся на одну миллисекунду
+		{name: "на каждой строке", config: FileConfig{MaxSizeBytes: 1}, wantBackups: 9},
+		{name: "хранение", config: FileConfig{MaxSizeBytes: 1, MaxBackups: 3}, wantBackups: 3, wantFirst: 6},
+		{name: "сжатие", config: FileConfig{MaxSizeBytes: 1, Compress: true}, wantBackups: 9, wantGzip: true},
+		{name: "сжатие и хранение", config: FileConfig{MaxSizeBytes: 2 * line, Compress: true, MaxBackups: 2}, wantBackups: 2, wantFirst: 4, wantGzip: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "logs", "app.log")
+			config := tt.config
+			config.Path, config.Format = path, "json"
+			sink, err := NewFileSink(config)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer sink.Close()
+
+			if err := sink.Write(Batch{Logs: fileLogs(10)}); err != nil {
+				t.Fatal(err)
+			}
+
+			backups := backupFiles(t, path)
+			if len(backups) != tt.wantBackups {
+				t.Fatalf("ротированных файлов %d, ожидалось %d: %v", len(backups), tt.wantBackups, backups)
+			}
+			for _, name := range backups {
+				if strings.HasSuffix(name, ".gz") != tt.wantGzip {
+					t.Fatalf("файл %s, ожидалось сжатие: %v", name, tt.wantGzip)
+				}
+				if config.MaxSizeBytes > 1 && !tt.wantGzip {
+					info, err := os.Stat(name)
+					if err != nil {
+						t.Fatal(err)
+					}
+					if info.Size() > config.MaxSizeBytes {
+						t.Fatalf("размер %s %d больше предела %d", name, info.Size(), config.MaxSizeBytes)
+					}
+				}
+			}
+
+			var want []string
+			for _, entry := range fileLogs(10)[tt.wantFirst:] {
+				want = append(want, entry.Message)
+			}
+			if got := readMessages(t, path); strings.Join(got, ",") != strings.Join(want, ",") {
+				t.Fatalf("сообщения %v, ожидались %v", got, want)
+			}
+		})
+	}
+}
+
+func TestFileSinkRotatesByTime(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	sink, err := NewFileSink(FileConfig{Path: path, Format: "json", RotateInterval: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sink.Close()
+
+	logs := fileLogs(3)
+	if err := sink.Write(Batch{Logs: logs[:2]}); err != nil {
+		t.Fatal(err)
+	}
+	if backups := backupFiles(t, path); len(backups) != 0 {
+		t.Fatalf("ротация до истечения интервала: %v", backups)
+	}
+
+	sink.mutex.Lock()
+	sink.openedAt = time.Now().Add(-time.Hour)
+	sink.mutex.Unlock()
+
+	if err := sink.Write(Batch{Logs: logs[2:]}); err != nil {
+		t.Fatal(err)
+	}
+	if backups := backupFiles(t, path); len(backups) != 1 {
+		t.Fatalf("ротированных файлов %v, ожидался один", backups)
+	}
+	if got := readMessages(t, path); strings.Join(got, ",") != "m00,m01,m02" {
+		t.Fatalf("сообщения %v", got)
+	}
+}
+
+func TestFileSinkRotatesIdleFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	sink, err := NewFileSink(FileConfig{Path: path, Format: "json", RotateInterval: 20 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sink.Close()
+
+	if err := sink.Write(Batch{Logs: fileLogs(1)}); err != nil {
+		t.Fatal(err)
+	}
+	// После записи файл простаивает: ротацию выполняет таймер
+	deadline := time.Now().Add(5 * time.Second)
+	for len(backupFiles(t, path)) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("простаивающий файл не ротирован")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	// Пустой активный файл не ротируется
+	time.Sleep(100 * time.Millisecond)
+	if backups := backupFiles(t, path); len(backups) != 1 {
+		t.Fatalf("ротированных файлов %v, ожидался один", backups)
+	}
+	if got := readMessages(t, path); strings.Join(got, ",") != "m00" {
+		t.Fatalf("сообщения %v", got)
+	}
+}
+
+func TestFileSinkKeepsUnrelatedFiles(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+	unrelated := []string{"app.log.bak", "app.log.conf", "app.log.20240101T120000.000", "app.log.20240101T120000.000-1a", "app.log.x-001.gz"}
+	for _, name := range unrelated {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("keep"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	sample, err := (&FileSink{config: FileConfig{Format: "json"}}).formatLine(fileLogs(1)[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	sink, err := NewFileSink(FileConfig{Path: path, Format: "json", MaxSizeBytes: int64(len(sample)), MaxBackups: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := sink.Write(Batch{Logs: fileLogs(4)}); err != nil {
+		t.Fatal(err)
+	}
+	sink.Close()
+
+	if backups := backupFiles(t, path); len(backups) != 1 {
+		t.Fatalf("ротированных файлов %v, ожидался один", backups)
+	}
+	for _, name := range unrelated {
+		if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+			t.Errorf("удален посторонний файл %s: %v", name, err)
+		}
+	}
+}
+
+func TestIsBackup(t *testing.T) {
+	path := filepath.Join("logs", "app.log")
+	tests := []struct {
+		name string
+		want bool
+	}{
+		{path + ".20240101T120000.000-000", true},
+		{path + ".20240101T120000.000-012.gz", true},
+		{path + ".20240101T120000.000-000.gz.tmp", false},
+		{path + ".20240101T120000.000", false},
+		{path + ".bak", false},
+		{path + ".2024-000", false},
+		{path + ".20240101T120000.000-", false},
+		{filepath.Join("logs", "other.log") + ".20240101T120000.000-000", false},
+	}
+	for _, tt := range tests {
+		if got := isBackup(path, tt.name); got != tt.want {
+			t.Errorf("isBackup(%q) = %v, ожидалось %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestFileSinkRotatedNameSkipsExisting(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	sink := &FileSink{config: FileConfig{Path: path}}
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	first := sink.rotatedName(now)
+	if err := os.WriteFile(first, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	second := sink.rotatedName(now)
+	if err := os.WriteFile(second+".gz", nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	third := sink.rotatedName(now)
+
+	names := []string{first, second, third}
+	if first == second || second == third {
+		t.Fatalf("имена совпадают: %v", names)
+	}
+	if !sort.StringsAreSorted(names) {
+		t.Fatalf("имена не сортируются по порядку ротации: %v", names)
+	}
+}
+
+func TestFileSinkTextFormat(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	sink, err := NewFileSink(FileConfig{Path: path, Format: "text"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	entry := models.LogEntry{
+		Timestamp: time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC), Level: "ERROR", Service: "cart-service",
+		Method: "GET", Path: "/api/v1/cart", Status: 500, Duration: 42, Message: "failed",
+	}
+	if err := sink.Write(Batch{Logs: []models.LogEntry{entry}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := sink.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "2024-01-01T12:00:00.000Z [ERROR] cart-service: GET /api/v1/cart 500 42ms failed\n"
+	if string(data) != want {
+		t.Fatalf("строка %q, ожидалась %q", data, want)
+	}
+	if err := sink.Write(Batch{Logs: []models.LogEntry{entry}}); err == nil {
+		t.Fatal("запись в закрытый приемник должна завершаться ошибкой")
+	}
+}
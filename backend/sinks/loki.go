@@ -0,0 +1,281 @@
+package sinks
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang/snappy"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+func init() {
+	Register("loki", func(options map[string]interface{}) (Sink, error) {
+		flushInterval, err := optDuration(options, "flush_interval", time.Second)
+		if err != nil {
+			return nil, err
+		}
+		timeout, err := optDuration(options, "timeout", 10*time.Second)
+		if err != nil {
+			return nil, err
+		}
+		retry, err := parseRetryConfig(options)
+		if err != nil {
+			return nil, err
+		}
+		return NewLokiSink(LokiConfig{
+			URL:           optString(options, "url", ""),
+			Encoding:      optString(options, "encoding", "protobuf"),
+			TenantID:      optString(options, "tenant_id", ""),
+			Labels:        optStringMap(options, "labels"),
+			BatchSize:     optInt(options, "batch_size", 1000),
+			FlushInterval: flushInterval,
+			MaxBuffer:     optInt(options, "max_buffer", 100000),
+			Timeout:       timeout,
+			Retry:         retry,
+		})
+	})
+}
+
+const lokiPushPath = "/loki/api/v1/push"
+
+// LokiConfig задает параметры приемника Grafana Loki
+type LokiConfig struct {
+	URL           string            // Адрес Loki; путь /loki/api/v1/push добавляется при отсутствии
+	Encoding      string            // protobuf (snappy) или json
+	TenantID      string            // Значение заголовка X-Scope-OrgID
+	Labels        map[string]string // Статические метки всех потоков
+	BatchSize     int               // Максимальное число записей в одном запросе
+	FlushInterval time.Duration     // Период отправки неполного пакета
+	MaxBuffer     int               // Предел буфера; при превышении старые записи отбрасываются
+	Timeout       time.Duration     // Таймаут HTTP-запроса
+	Retry         RetryConfig
+}
+
+type lokiEntry struct {
+	stream    string // Метки потока в формате {k="v", ...}
+	labels    map[string]string
+	timestamp time.Time
+	line      string
+}
+
+type lokiStream struct {
+	labels  map[string]string
+	entries []lokiEntry
+}
+
+// LokiSink отправляет логи в Loki через push API. Записи копятся в буфере
+// и отправляются пакетами по размеру или по таймеру. Потоки размечаются
+// метками service, level и метками сценария.
+type LokiSink struct {
+	config  LokiConfig
+	client  *http.Client
+	batcher *batcher[lokiEntry]
+}
+
+func NewLokiSink(config LokiConfig) (*LokiSink, error) {
+	if config.URL == "" {
+		return nil, fmt.Errorf("не указан адрес Loki")
+	}
+	if config.Encoding != "protobuf" && config.Encoding != "json" {
+		return nil, fmt.Errorf("неизвестная кодировка: %s", config.Encoding)
+	}
+	if !strings.HasSuffix(config.URL, lokiPushPath) {
+		config.URL = strings.TrimRight(config.URL, "/") + lokiPushPath
+	}
+
+	s := &LokiSink{
+		config: config,
+		client: &http.Client{Timeout: config.Timeout},
+	}
+	s.batcher = newBatcher("Loki", config.BatchSize, config.MaxBuffer, config.FlushInterval, s.push)
+	return s, nil
+}
+
+func (s *LokiSink) Write(batch Batch) error {
+	entries := make([]lokiEntry, 0, len(batch.Logs))
+	for _, entry := range batch.Logs {
+		line, err := json.Marshal(entry)
+		if err != nil {
+			return err
+		}
+
+		labels := make(map[string]string, len(s.config.Labels)+len(batch.Labels)+2)
+		for k, v := range s.config.Labels {
+			labels[sanitizeLabelName(k)] = v
+		}
+		for k, v := range batch.Labels {
+			labels[sanitizeLabelName(k)] = v
+		}
+		labels["service"] = entry.Service
+		labels["level"] = entry.Level
+
+		entries = append(entries, lokiEntry{
+			stream:    formatLokiLabels(labels),
+			labels:    labels,
+			timestamp: entry.Timestamp,
+			line:      string(line),
+		})
+	}
+
+	if !s.batcher.add(entries) {
+		return fmt.Errorf("приемник Loki закрыт")
+	}
+	return nil
+}
+
+func (s *LokiSink) push(entries []lokiEntry, stop <-chan struct{}) error {
+	streams := groupLokiStreams(entries)
+
+	var body []byte
+	var contentType string
+	if s.config.Encoding == "json" {
+		var err error
+		if body, err = encodeLokiJSON(streams); err != nil {
+			return err
+		}
+		contentType = "application/json"
+	} else {
+		body = snappy.Encode(nil, encodeLokiProtobuf(streams))
+		contentType = "application/x-protobuf"
+	}
+
+	return withRetry(s.config.Retry, stop, func() error {
+		req, err := http.NewRequest(http.MethodPost, s.config.URL, bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", contentType)
+		if s.config.TenantID != "" {
+			req.Header.Set("X-Scope-OrgID", s.config.TenantID)
+		}
+
+		resp, err := s.client.Do(req)
+		if err != nil {
+			return err
+		}
+		return checkResponse(resp)
+	})
+}
+
+// groupLokiStreams группирует записи по потокам; записи потока упорядочены по времени
+func groupLokiStreams(entries []lokiEntry) []lokiStream {
+	index := make(map[string]int)
+	var streams []lokiStream
+	for _, e := range entries {
+		i, ok := index[e.stream]
+		if !ok {
+			i = len(streams)
+			index[e.stream] = i
+			streams = append(streams, lokiStream{labels: e.labels})
+		}
+		streams[i].entries = append(streams[i].entries, e)
+	}
+
+	for _, stream := range streams {
+		sort.SliceStable(stream.entries, func(a, b int) bool {
+			return stream.entries[a].timestamp.Before(stream.entries[b].timestamp)
+		})
+	}
+	return streams
+}
+
+// encodeLokiJSON кодирует запрос в JSON-формате push API
+func encodeLokiJSON(streams []lokiStream) ([]byte, error) {
+	type jsonStream struct {
+		Stream map[string]string `json:"stream"`
+		Values [][2]string       `json:"values"`
+	}
+
+	req := struct {
+		Streams []jsonStream `json:"streams"`
+	}{Streams: make([]jsonStream, 0, len(streams))}
+
+	for _, stream := range streams {
+		values := make([][2]string, 0, len(stream.entries))
+		for _, e := range stream.entries {
+			values = append(values, [2]string{strconv.FormatInt(e.timestamp.UnixNano(), 10), e.line})
+		}
+		req.Streams = append(req.Streams, jsonStream{Stream: stream.labels, Values: values})
+	}
+	return json.Marshal(req)
+}
+
+// encodeLokiProtobuf кодирует logproto.PushRequest:
+//
+//	PushRequest  { repeated StreamAdapter streams = 1; }
+//	StreamAdapter { string labels = 1; repeated EntryAdapter entries = 2; }
+//	EntryAdapter  { google.protobuf.Timestamp timestamp = 1; string line = 2; }
+func encodeLokiProtobuf(streams []lokiStream) []byte {
+	var req []byte
+	for _, stream := range streams {
+		var sb []byte
+		sb = protowire.AppendTag(sb, 1, protowire.BytesType)
+		sb = protowire.AppendString(sb, stream.entries[0].stream)
+
+		for _, e := range stream.entries {
+			var ts []byte
+			ts = protowire.AppendTag(ts, 1, protowire.VarintType)
+			ts = protowire.AppendVarint(ts, uint64(e.timestamp.Unix()))
+			ts = protowire.AppendTag(ts, 2, protowire.VarintType)
+			ts = protowire.AppendVarint(ts, uint64(e.timestamp.Nanosecond()))
+
+			var eb []byte
+			eb = protowire.AppendTag(eb, 1, protowire.BytesType)
+			eb = protowire.AppendBytes(eb, ts)
+			eb = protowire.AppendTag(eb, 2, protowire.BytesType)
+			eb = protowire.AppendString(eb, e.line)
+
+			sb = protowire.AppendTag(sb, 2, protowire.BytesType)
+			sb = protowire.AppendBytes(sb, eb)
+		}
+
+		req = protowire.AppendTag(req, 1, protowire.BytesType)
+		req = protowire.AppendBytes(req, sb)
+	}
+	return req
+}
+
+// formatLokiLabels формирует селектор потока {k="v", ...} с сортировкой по имени
+func formatLokiLabels(labels map[string]string) string {
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	b.WriteByte('{')
+	for i, k := range keys {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		b.WriteString(k)
+		b.WriteString("=")
+		b.WriteString(strconv.Quote(labels[k]))
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+// sanitizeLabelName заменяет недопустимые в имени метки символы на _
+func sanitizeLabelName(name string) string {
+	b := []byte(name)
+	for i, c := range b {
+		valid := c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (i > 0 && c >= '0' && c <= '9')
+		if !valid {
+			b[i] = '_'
+		}
+	}
+	return string(b)
+}
+
+func (s *LokiSink) Close() error {
+	s.batcher.close()
+	return nil
+}
@@ -0,0 +1,389 @@
+package sinks
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"log-metrics-simulator/models"
+
+	"github.com/golang/snappy"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// lokiRequest - запрос push API, полученный заглушкой
+type lokiRequest struct {
+	header http.Header
+	body   []byte
+}
+
+// lokiStub - HTTP-заглушка Loki, отвечающая кодами из statuses по очереди,
+// после них - 204
+type lokiStub struct {
+	*httptest.Server
+
+	mutex    sync.Mutex
+	statuses []int
+	requests []lokiRequest
+}
+
+func newLokiStub(t *testing.T, statuses ...int) *lokiStub {
+	stub := &lokiStub{statuses: statuses}
+	stub.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != lokiPushPath {
+			t.Errorf("путь запроса %s, ожидался %s", r.URL.Path, lokiPushPath)
+		}
+		body, _ := io.ReadAll(r.Body)
+
+		stub.mutex.Lock()
+		stub.requests = append(stub.requests, lokiRequest{header: r.Header.Clone(), body: body})
+		status := http.StatusNoContent
+		if len(stub.statuses) > 0 {
+			status, stub.statuses = stub.statuses[0], stub.statuses[1:]
+		}
+		stub.mutex.Unlock()
+
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(stub.Close)
+	return stub
+}
+
+func (s *lokiStub) received() []lokiRequest {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return append([]lokiRequest(nil), s.requests...)
+}
+
+// pushedStream - поток, разобранный из тела запроса
+type pushedStream struct {
+	labels     string
+	timestamps []time.Time
+	lines      []string
+}
+
+func newTestLokiSink(t *testing.T, url string, config LokiConfig) *LokiSink {
+	config.URL = url
+	if config.Encoding == "" {
+		config.Encoding = "protobuf"
+	}
+	if config.FlushInterval == 0 {
+		config.FlushInterval = time.Hour
+	}
+	if config.Timeout == 0 {
+		config.Timeout = 5 * time.Second
+	}
+	sink, err := NewLokiSink(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return sink
+}
+
+// testLogs возвращает логи двух сервисов с метками времени не по порядку
+func testLogs() []models.LogEntry {
+	base := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	return []models.LogEntry{
+		{Timestamp: base.Add(3 * time.Second), Service: "order-service", Level: "INFO", Message: "o3"},
+		{Timestamp: base.Add(1 * time.Second), Service: "order-service", Level: "INFO", Message: "o1"},
+		{Timestamp: base.Add(2 * time.Second), Service: "cart-service", Level: "ERROR", Message: "c2"},
+		{Timestamp: base.Add(2 * time.Second), Service: "order-service", Level: "INFO", Message: "o2"},
+	}
+}
+
+func TestLokiSinkProtobuf(t *testing.T) {
+	stub := newLokiStub(t)
+	sink := newTestLokiSink(t, stub.URL, LokiConfig{TenantID: "team-a", Labels: map[string]string{"env": "test"}})
+
+	if err := sink.Write(Batch{Logs: testLogs(), Labels: map[string]string{"scenario-name": "load"}}); err != nil {
+		t.Fatal(err)
+	}
+	sink.Close()
+
+	requests := stub.received()
+	if len(requests) != 1 {
+		t.Fatalf("получено %d запросов, ожидался 1", len(requests))
+	}
+	req := requests[0]
+	if got := req.header.Get("Content-Type"); got != "application/x-protobuf" {
+		t.Errorf("Content-Type = %q", got)
+	}
+	if got := req.header.Get("X-Scope-OrgID"); got != "team-a" {
+		t.Errorf("X-Scope-OrgID = %q", got)
+	}
+
+	raw, err := snappy.Decode(nil, req.body)
+	if err != nil {
+		t.Fatalf("тело не сжато snappy: %v", err)
+	}
+	streams := decodeLokiProtobuf(t, raw)
+	checkLokiStreams(t, streams)
+}
+
+func TestLokiSinkJSON(t *testing.T) {
+	stub := newLokiStub(t)
+	sink := newTestLokiSink(t, stub.URL, LokiConfig{Encoding: "json", Labels: map[string]string{"env": "test"}})
+
+	if err := sink.Write(Batch{Logs: testLogs(), Labels: map[string]string{"scenario-name": "load"}}); err != nil {
+		t.Fatal(err)
+	}
+	sink.Close()
+
+	requests := stub.received()
+	if len(requests) != 1 {
+		t.Fatalf("получено %d запросов, ожидался 1", len(requests))
+	}
+	req := requests[0]
+	if got := req.header.Get("Content-Type"); got != "application/json" {
+		t.Errorf("Content-Type = %q", got)
+	}
+	if got := req.header.Get("X-Scope-OrgID"); got != "" {
+		t.Errorf("X-Scope-OrgID без tenant_id = %q", got)
+	}
+
+	var push struct {
+		Streams []struct {
+			Stream map[string]string `json:"stream"`
+			Values [][2]string       `json:"values"`
+		} `json:"streams"`
+	}
+	if err := json.Unmarshal(req.body, &push); err != nil {
+		t.Fatal(err)
+	}
+
+	var streams []pushedStream
+	for _, s := range push.Streams {
+		stream := pushedStream{labels: formatLokiLabels(s.Stream)}
+		for _, v := range s.Values {
+			ns, err := time.ParseDuration(v[0] + "ns")
+			if err != nil {
+				t.Fatalf("метка времени %q: %v", v[0], err)
+			}
+			stream.timestamps = append(stream.timestamps, time.Unix(0, int64(ns)).UTC())
+			stream.lines = append(stream.lines, v[1])
+		}
+		streams = append(streams, stream)
+	}
+	checkLokiStreams(t, streams)
+}
+
+// checkLokiStreams проверяет разбиение testLogs на потоки и порядок записей
+func checkLokiStreams(t *testing.T, streams []pushedStream) {
+	t.Helper()
+
+	want := map[string][]string{
+		`{env="test", level="INFO", scenario_name="load", service="order-service"}`: {"o1", "o2", "o3"},
+		`{env="test", level="ERROR", scenario_name="load", service="cart-service"}`: {"c2"},
+	}
+	if len(streams) != len(want) {
+		t.Fatalf("получено %d потоков, ожидалось %d", len(streams), len(want))
+	}
+	for _, stream := range streams {
+		messages, ok := want[stream.labels]
+		if !ok {
+			t.Fatalf("неожиданный поток %s", stream.labels)
+		}
+		if len(stream.lines) != len(messages) {
+			t.Fatalf("поток %s: %d записей, ожидалось %d", stream.labels, len(stream.lines), len(messages))
+		}
+		for i, line := range stream.lines {
+			var entry models.LogEntry
+			if err := json.Unmarshal([]byte(line), &entry); err != nil {
+				t.Fatalf("строка не JSON: %v", err)
+			}
+			if entry.Message != messages[i] {
+				t.Errorf("поток %s: запись %d = %s, ожидалась %s", stream.labels, i, entry.Message, messages[i])
+			}
+			if !stream.timestamps[i].Equal(entry.Timestamp) {
+				t.Errorf("поток %s: метка времени %v не совпадает с логом %v", stream.labels, stream.timestamps[i], entry.Timestamp)
+			}
+			if i > 0 && stream.timestamps[i].Before(stream.timestamps[i-1]) {
+				t.Errorf("поток %s: записи не упорядочены по времени", stream.labels)
+			}
+		}
+	}
+}
+
+// decodeLokiProtobuf разбирает logproto.PushRequest
+func decodeLokiProtobuf(t *testing.T, data []byte) []pushedStream {
+	t.Helper()
+
+	var streams []pushedStream
+	forEachField(t, data, func(num protowire.Number, value []byte) {
+		if num != 1 {
+			return
+		}
+		var stream pushedStream
+		forEachField(t, value, func(num protowire.Number, value []byte) {
+			switch num {
+			case 1:
+				stream.labels = string(value)
+			case 2:
+				var ts time.Time
+				var line string
+				forEachField(t, value, func(num protowire.Number, value []byte) {
+					switch num {
+					case 1:
+						var sec, nsec int64
+						forEachVarint(t, value, func(num protowire.Number, v uint64) {
+							if num == 1 {
+								sec = int64(v)
+							} else if num == 2 {
+								nsec = int64(v)
+							}
+						})
+						ts = time.Unix(sec, nsec).UTC()
+					case 2:
+						line = string(value)
+					}
+				})
+				stream.timestamps = append(stream.timestamps, ts)
+				stream.lines = append(stream.lines, line)
+			}
+		})
+		streams = append(streams, stream)
+	})
+	return streams
+}
+
+// forEachField обходит поля с типом bytes
+func forEachField(t *testing.T, data []byte, fn func(num protowire.Number, value []byte)) {
+	t.Helper()
+	for len(data) > 0 {
+		num, typ, n := protowire.ConsumeTag(data)
+		if n < 0 || typ != protowire.BytesType {
+			t.Fatalf("некорректное поле protobuf: тип %v", typ)
+		}
+		data = data[n:]
+		value, n := protowire.ConsumeBytes(data)
+		if n < 0 {
+			t.Fatal("некорректная длина поля protobuf")
+		}
+		fn(num, value)
+		data = data[n:]
+	}
+}
+
+// forEachVarint обходит поля с типом varint
+func forEachVarint(t *testing.T, data []byte, fn func(num protowire.Number, value uint64)) {
+	t.Helper()
+	for len(data) > 0 {
+		num, typ, n := protowire.ConsumeTag(data)
+		if n < 0 || typ != protowire.VarintType {
+			t.Fatalf("некорректное поле protobuf: тип %v", typ)
+		}
+		data = data[n:]
+		value, n := protowire.ConsumeVarint(data)
+		if n < 0 {
+			t.Fatal("некорректный varint")
+		}
+		fn(num, value)
+		data = data[n:]
+	}
+}
+
+func TestLokiSinkRetry(t *testing.T) {
+	tests := []struct {
+		name     string
+		status   int
+		attempts int
+	}{
+		{"429 повторяется", http.StatusTooManyRequests, 2},
+		{"500 повторяется", http.StatusInternalServerError, 2},
+		{"503 повторяется", http.StatusServiceUnavailable, 2},
+		{"400 не повторяется", http.StatusBadRequest, 1},
+		{"404 не повторяется", http.StatusNotFound, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stub := newLokiStub(t, tt.status)
+			sink := newTestLokiSink(t, stub.URL, LokiConfig{
+				Retry: RetryConfig{MaxRetries: 3, MinBackoff: time.Millisecond, MaxBackoff: time.Millisecond},
+			})
+
+			if err := sink.Write(Batch{Logs: testLogs()[:1]}); err != nil {
+				t.Fatal(err)
+			}
+			sink.Close()
+
+			if got := len(stub.received()); got != tt.attempts {
+				t.Errorf("попыток %d, ожидалось %d", got, tt.attempts)
+			}
+		})
+	}
+}
+
+func TestLokiSinkRetryLimit(t *testing.T) {
+	stub := newLokiStub(t, 503, 503, 503, 503, 503, 503)
+	sink := newTestLokiSink(t, stub.URL, LokiConfig{
+		Retry: RetryConfig{MaxRetries: 2, MinBackoff: time.Millisecond, MaxBackoff: time.Millisecond},
+	})
+
+	if err := sink.Write(Batch{Logs: testLogs()[:1]}); err != nil {
+		t.Fatal(err)
+	}
+	sink.Close()
+
+	if got := len(stub.received()); got != 3 {
+		t.Errorf("попыток %d, ожидалось 3: первая и два повтора", got)
+	}
+}
+
+func TestLokiSinkBufferOverflow(t *testing.T) {
+	stub := newLokiStub(t)
+	sink := newTestLokiSink(t, stub.URL, LokiConfig{Encoding: "json", BatchSize: 2, MaxBuffer: 3})
+
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	logs := make([]models.LogEntry, 5)
+	for i := range logs {
+		logs[i] = models.LogEntry{Timestamp: base.Add(time.Duration(i) * time.Second), Service: "api-gateway", Level: "INFO", Message: string(rune('a' + i))}
+	}
+	if err := sink.Write(Batch{Logs: logs}); err != nil {
+		t.Fatal(err)
+	}
+	sink.Close()
+
+	var messages []string
+	for _, req := range stub.received() {
+		var push struct {
+			Streams []struct {
+				Values [][2]string `json:"values"`
+			} `json:"streams"`
+		}
+		if err := json.Unmarshal(req.body, &push); err != nil {
+			t.Fatal(err)
+		}
+		for _, s := range push.Streams {
+			for _, v := range s.Values {
+				var entry models.LogEntry
+				json.Unmarshal([]byte(v[1]), &entry)
+				messages = append(messages, entry.Message)
+			}
+		}
+	}
+
+	// Буфер вмещает 3 записи: две самые старые отброшены
+	want := []string{"c", "d", "e"}
+	if len(messages) != len(want) {
+		t.Fatalf("отправлено %v, ожидалось %v", messages, want)
+	}
+	for i := range want {
+		if messages[i] != want[i] {
+			t.Fatalf("отправлено %v, ожидалось %v", messages, want)
+		}
+	}
+}
+
+func TestLokiSinkClosed(t *testing.T) {
+	stub := newLokiStub(t)
+	sink := newTestLokiSink(t, stub.URL, LokiConfig{})
+	sink.Close()
+
+	if err := sink.Write(Batch{Logs: testLogs()}); err == nil {
+		t.Error("запись в закрытый приемник не вернула ошибку")
+	}
+}
@@ -0,0 +1,84 @@
+package sinks
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// Разбор опций приемников. Опции приходят из JSON, поэтому числа имеют тип float64.
+
+func optString(options map[string]interface{}, key, def string) string {
+	if v, ok := options[key].(string); ok && v != "" {
+		return v
+	}
+	return def
+}
+
+func optInt(options map[string]interface{}, key string, def int) int {
+	switch v := options[key].(type) {
+	case float64:
+		return int(v)
+	case int:
+		return v
+	case int64:
+		return int(v)
+	case string:
+		if i, err := strconv.Atoi(v); err == nil {
+			return i
+		}
+	}
+	return def
+}
+
+func optBool(options map[string]interface{}, key string, def bool) bool {
+	switch v := options[key].(type) {
+	case bool:
+		return v
+	case string:
+		if b, err := strconv.ParseBool(v); err == nil {
+			return b
+		}
+	}
+	return def
+}
+
+// optDuration принимает строку вида "1h30m" или число секунд
+func optDuration(options map[string]interface{}, key string, def time.Duration) (time.Duration, error) {
+	switch v := options[key].(type) {
+	case nil:
+		return def, nil
+	case float64:
+		return time.Duration(v * float64(time.Second)), nil
+	case int:
+		return time.Duration(v) * time.Second, nil
+	case string:
+		if v == "" {
+			return def, nil
+		}
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return 0, fmt.Errorf("неверное значение %s: %v", key, err)
+		}
+		return d, nil
+	default:
+		return 0, fmt.Errorf("неверный тип значения %s", key)
+	}
+}
+
+func optStringMap(options map[string]interface{}, key string) map[string]string {
+	result := make(map[string]string)
+	switch v := options[key].(type) {
+	case map[string]interface{}:
+		for k, val := range v {
+			if s, ok := val.(string); ok {
+				result[k] = s
+			}
+		}
+	case map[string]string:
+		for k, val := range v {
+			result[k] = val
+		}
+	}
+	return result
+}
@@ -0,0 +1,140 @@
+package sinks
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"log-metrics-simulator/models"
+	"log-metrics-simulator/otlp"
+)
+
+func init() {
+	Register("otlp", func(options map[string]interface{}) (Sink, error) {
+		flushInterval, err := optDuration(options, "flush_interval", time.Second)
+		if err != nil {
+			return nil, err
+		}
+		timeout, err := optDuration(options, "timeout", 10*time.Second)
+		if err != nil {
+			return nil, err
+		}
+		retry, err := parseRetryConfig(options)
+		if err != nil {
+			return nil, err
+		}
+		return NewOTLPSink(OTLPConfig{
+			Client: otlp.Config{
+				Endpoint:           optString(options, "endpoint", ""),
+				Encoding:           optString(options, "encoding", "protobuf"),
+				Headers:            optStringMap(options, "headers"),
+				ResourceAttributes: optStringMap(options, "resource_attributes"),
+				Timeout:            timeout,
+				MaxRetries:         retry.MaxRetries,
+				MinBackoff:         retry.MinBackoff,
+				MaxBackoff:         retry.MaxBackoff,
+			},
+			Logs:          optBool(options, "logs", true),
+			Traces:        optBool(options, "traces", true),
+			BatchSize:     optInt(options, "batch_size", 1000),
+			FlushInterval: flushInterval,
+			MaxBuffer:     optInt(options, "max_buffer", 100000),
+		})
+	})
+}
+
+// OTLPConfig задает параметры приемника OTLP/HTTP
+type OTLPConfig struct {
+	Client        otlp.Config
+	Logs          bool // Отправлять записи как OTLP log records
+	Traces        bool // Отправлять синтетические спаны по trace_id/span_id записей
+	BatchSize     int
+	FlushInterval time.Duration
+	MaxBuffer     int
+}
+
+type otlpItem struct {
+	entry    models.LogEntry
+	scenario string
+	labels   map[string]string
+}
+
+// OTLPSink отправляет логи и построенные по ним спаны в коллектор OpenTelemetry
+type OTLPSink struct {
+	config  OTLPConfig
+	client  *otlp.Client
+	batcher *batcher[otlpItem]
+}
+
+func NewOTLPSink(config OTLPConfig) (*OTLPSink, error) {
+	if !config.Logs && !config.Traces {
+		return nil, fmt.Errorf("не выбран ни один сигнал (logs, traces)")
+	}
+
+	client, err := otlp.NewClient(config.Client)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &OTLPSink{config: config, client: client}
+	s.batcher = newBatcher("OTLP", config.BatchSize, config.MaxBuffer, config.FlushInterval, s.send)
+	return s, nil
+}
+
+func (s *OTLPSink) Write(batch Batch) error {
+	items := make([]otlpItem, len(batch.Logs))
+	for i, entry := range batch.Logs {
+		items[i] = otlpItem{entry: entry, scenario: batch.Scenario, labels: batch.Labels}
+	}
+
+	if !s.batcher.add(items) {
+		return fmt.Errorf("приемник OTLP закрыт")
+	}
+	return nil
+}
+
+// send отправляет пакет; записи разных запусков группируются по сценарию и меткам
+func (s *OTLPSink) send(items []otlpItem, stop <-chan struct{}) error {
+	var errs []error
+	for start := 0; start < len(items); {
+		end := start + 1
+		for end < len(items) && items[end].scenario == items[start].scenario && sameLabels(items[end].labels, items[start].labels) {
+			end++
+		}
+
+		entries := make([]models.LogEntry, 0, end-start)
+		for _, item := range items[start:end] {
+			entries = append(entries, item.entry)
+		}
+
+		if s.config.Logs {
+			if err := s.client.ExportLogs(entries, items[start].scenario, items[start].labels, stop); err != nil {
+				errs = append(errs, fmt.Errorf("логи: %v", err))
+			}
+		}
+		if s.config.Traces {
+			if err := s.client.ExportTraces(entries, items[start].scenario, items[start].labels, stop); err != nil {
+				errs = append(errs, fmt.Errorf("трассировки: %v", err))
+			}
+		}
+		start = end
+	}
+	return errors.Join(errs...)
+}
+
+func sameLabels(a, b map[string]string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for k, v := range a {
+		if bv, ok := b[k]; !ok || bv != v {
+			return false
+		}
+	}
+	return true
+}
+
+func (s *OTLPSink) Close() error {
+	s.batcher.close()
+	return nil
+}
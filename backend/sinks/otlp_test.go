@@ -0,0 +1,183 @@
+package sinks
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"log-metrics-simulator/models"
+	"log-metrics-simulator/otlp"
+)
+
+// otlpPayload - запрос OTLP/JSON, полученный заглушкой: сценарий ресурса и
+// число записей или спанов
+type otlpPayload struct {
+	path     string
+	scenario string
+	items    int
+}
+
+// newOTLPCollector - HTTP-заглушка коллектора, разбирающая логи и
+// трассировки OTLP/JSON; отвечает status
+func newOTLPCollector(t *testing.T, status int) (*httptest.Server, func() []otlpPayload) {
+	var (
+		mutex    sync.Mutex
+		payloads []otlpPayload
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if ct := r.Header.Get("Content-Type"); ct != "application/json" {
+			t.Errorf("Content-Type %s", ct)
+		}
+		body, _ := io.ReadAll(r.Body)
+		var req struct {
+			ResourceLogs  []otlpResource `json:"resourceLogs"`
+			ResourceSpans []otlpResource `json:"resourceSpans"`
+		}
+		if err := json.Unmarshal(body, &req); err != nil {
+			t.Errorf("тело не JSON: %v", err)
+		}
+		mutex.Lock()
+		for _, res := range append(req.ResourceLogs, req.ResourceSpans...) {
+			payload := otlpPayload{path: r.URL.Path}
+			for _, attr := range res.Resource.Attributes {
+				if attr.Key == "simulator.scenario" {
+					payload.scenario = attr.Value.StringValue
+				}
+			}
+			for _, scope := range append(res.ScopeLogs, res.ScopeSpans...) {
+				payload.items += len(scope.LogRecords) + len(scope.Spans)
+			}
+			payloads = append(payloads, payload)
+		}
+		mutex.Unlock()
+		if status != http.StatusOK {
+			http.Error(w, "test", status)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server, func() []otlpPayload {
+		mutex.Lock()
+		defer mutex.Unlock()
+		return append([]otlpPayload(nil), payloads...)
+	}
+}
+
+type otlpResource struct {
+	Resource struct {
+		Attributes []struct {
+			Key   string `json:"key"`
+			Value struct {
+				StringValue string `json:"stringValue"`
+			} `json:"value"`
+		} `json:"attributes"`
+	} `json:"resource"`
+	ScopeLogs  []otlpScope `json:"scopeLogs"`
+	ScopeSpans []otlpScope `json:"scopeSpans"`
+}
+
+type otlpScope struct {
+	LogRecords []json.RawMessage `json:"logRecords"`
+	Spans      []json.RawMessage `json:"spans"`
+}
+
+func otlpTestLogs(n int, traced bool) []models.LogEntry {
+	logs := make([]models.LogEntry, n)
+	for i := range logs {
+		logs[i] = models.LogEntry{
+			Timestamp: time.Date(2024, 1, 1, 12, 0, i, 0, time.UTC),
+			Level:     "INFO",
+			Service:   "cart",
+			Message:   "ok",
+		}
+		if traced {
+			logs[i].TraceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+			logs[i].SpanID = "00f067aa0ba902b" + string(rune('0'+i))
+		}
+	}
+	return logs
+}
+
+func otlpTestClient(endpoint string) otlp.Config {
+	return otlp.Config{Endpoint: endpoint, Encoding: "json", MinBackoff: time.Millisecond, MaxBackoff: time.Millisecond}
+}
+
+func TestOTLPSinkGroupsBatches(t *testing.T) {
+	server, received := newOTLPCollector(t, http.StatusOK)
+	sink, err := New(models.SinkConfig{Type: "otlp", Options: map[string]interface{}{
+		"endpoint":       server.URL,
+		"encoding":       "json",
+		"flush_interval": "1h",
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Записи без trace_id не дают спанов
+	batches := []Batch{
+		{Scenario: "black_friday", Logs: otlpTestLogs(3, true)},
+		{Scenario: "normal_load", Logs: otlpTestLogs(2, false)},
+	}
+	for _, batch := range batches {
+		if err := sink.Write(batch); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := sink.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := sink.Write(batches[0]); err == nil {
+		t.Error("запись в закрытый приемник без ошибки")
+	}
+
+	want := []otlpPayload{
+		{path: "/v1/logs", scenario: "black_friday", items: 3},
+		{path: "/v1/traces", scenario: "black_friday", items: 3},
+		{path: "/v1/logs", scenario: "normal_load", items: 2},
+	}
+	got := received()
+	if len(got) != len(want) {
+		t.Fatalf("получено %+v, ожидалось %+v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("запрос %d: %+v, ожидалось %+v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestOTLPSinkSignals(t *testing.T) {
+	server, received := newOTLPCollector(t, http.StatusOK)
+	sink, err := NewOTLPSink(OTLPConfig{Client: otlpTestClient(server.URL), Traces: true, FlushInterval: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sink.Write(Batch{Logs: otlpTestLogs(2, true)})
+	sink.Close()
+	if got := received(); len(got) != 1 || got[0].path != "/v1/traces" {
+		t.Errorf("без логов отправлено %+v, ожидались только трассировки", got)
+	}
+
+	if _, err := NewOTLPSink(OTLPConfig{Client: otlpTestClient(server.URL)}); err == nil {
+		t.Error("приемник без сигналов создан")
+	}
+}
+
+func TestOTLPSinkSendErrors(t *testing.T) {
+	server, _ := newOTLPCollector(t, http.StatusBadRequest)
+	sink, err := NewOTLPSink(OTLPConfig{Client: otlpTestClient(server.URL), Logs: true, Traces: true, FlushInterval: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sink.Close()
+
+	items := []otlpItem{{entry: otlpTestLogs(1, true)[0]}}
+	err = sink.send(items, nil)
+	if err == nil || !strings.Contains(err.Error(), "логи: HTTP 400") || !strings.Contains(err.Error(), "трассировки: HTTP 400") {
+		t.Errorf("ошибка %v, ожидались ошибки логов и трассировок", err)
+	}
+}
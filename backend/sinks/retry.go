@@ -0,0 +1,85 @@
+package sinks
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// RetryConfig задает повторы отправки во внешние системы
+type RetryConfig struct {
+	MaxRetries int           // Количество повторов после первой попытки
+	MinBackoff time.Duration // Первая пауза, далее удваивается
+	MaxBackoff time.Duration // Максимальная пауза
+}
+
+func parseRetryConfig(options map[string]interface{}) (RetryConfig, error) {
+	minBackoff, err := optDuration(options, "min_backoff", 500*time.Millisecond)
+	if err != nil {
+		return RetryConfig{}, err
+	}
+	maxBackoff, err := optDuration(options, "max_backoff", 30*time.Second)
+	if err != nil {
+		return RetryConfig{}, err
+	}
+	return RetryConfig{
+		MaxRetries: optInt(options, "max_retries", 5),
+		MinBackoff: minBackoff,
+		MaxBackoff: maxBackoff,
+	}, nil
+}
+
+// statusError - ответ HTTP с неуспешным кодом
+type statusError struct {
+	status int
+	body   string
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("HTTP %d: %s", e.status, e.body)
+}
+
+// retryable сообщает, имеет ли смысл повторять запрос: сетевые ошибки,
+// 429 и 5xx повторяются, остальные ответы 4xx - нет
+func retryable(err error) bool {
+	if se, ok := err.(*statusError); ok {
+		return se.status == http.StatusTooManyRequests || se.status >= 500
+	}
+	return true
+}
+
+// checkResponse читает ответ и возвращает statusError для кодов вне 2xx
+func checkResponse(resp *http.Response) error {
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		io.Copy(io.Discard, resp.Body)
+		return nil
+	}
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	return &statusError{status: resp.StatusCode, body: string(body)}
+}
+
+// withRetry выполняет send с экспоненциальной паузой между попытками.
+// Закрытие stop прерывает ожидание и возвращает последнюю ошибку.
+func withRetry(cfg RetryConfig, stop <-chan struct{}, send func() error) error {
+	backoff := cfg.MinBackoff
+	for attempt := 0; ; attempt++ {
+		err := send()
+		if err == nil || !retryable(err) || attempt >= cfg.MaxRetries {
+			return err
+		}
+
+		select {
+		case <-time.After(backoff):
+		case <-stop:
+			return err
+		}
+
+		backoff *= 2
+		if backoff > cfg.MaxBackoff {
+			backoff = cfg.MaxBackoff
+		}
+	}
+}
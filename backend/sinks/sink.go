@@ -0,0 +1,196 @@
+package sinks
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+
+	"log-metrics-simulator/models"
+)
+
+// Batch - пакет логов одного запуска генерации
+type Batch struct {
+	Scenario string
+	Labels   map[string]string
+	Logs     []models.LogEntry
+}
+
+// Sink - приемник сгенерированных логов
+type Sink interface {
+	Write(batch Batch) error
+	Close() error
+}
+
+// Factory создает приемник по опциям из конфигурации
+type Factory func(options map[string]interface{}) (Sink, error)
+
+var (
+	factories      = make(map[string]Factory)
+	factoriesMutex sync.RWMutex
+)
+
+// Register регистрирует фабрику приемников указанного типа
+func Register(sinkType string, factory Factory) {
+	factoriesMutex.Lock()
+	defer factoriesMutex.Unlock()
+
+	factories[sinkType] = factory
+}
+
+// Types возвращает зарегистрированные типы приемников
+func Types() []string {
+	factoriesMutex.RLock()
+	defer factoriesMutex.RUnlock()
+
+	types := make([]string, 0, len(factories))
+	for t := range factories {
+		types = append(types, t)
+	}
+	sort.Strings(types)
+	return types
+}
+
+// New создает приемник по конфигурации
+func New(cfg models.SinkConfig) (Sink, error) {
+	factoriesMutex.RLock()
+	factory, ok := factories[cfg.Type]
+	factoriesMutex.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("неизвестный тип приемника: %s", cfg.Type)
+	}
+
+	sink, err := factory(cfg.Options)
+	if err != nil {
+		return nil, fmt.Errorf("приемник %s: %v", cfg.Type, err)
+	}
+	return sink, nil
+}
+
+// Fanout рассылает пакет во все вложенные приемники
+type Fanout []Sink
+
+// NewFanout создает приемники по списку конфигураций. При ошибке уже
+// созданные приемники закрываются.
+func NewFanout(configs []models.SinkConfig) (Fanout, error) {
+	fanout := make(Fanout, 0, len(configs))
+	for _, cfg := range configs {
+		sink, err := New(cfg)
+		if err != nil {
+			fanout.Close()
+			return nil, err
+		}
+		fanout = append(fanout, sink)
+	}
+	return fanout, nil
+}
+
+func (f Fanout) Write(batch Batch) error {
+	var errs []error
+	for _, sink := range f {
+		if err := sink.Write(batch); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (f Fanout) Close() error {
+	var errs []error
+	for _, sink := range f {
+		if err := sink.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// ===== Глобальные приемники =====
+
+type globalSink struct {
+	config models.SinkConfig
+	sink   Sink
+}
+
+var (
+	globalSinks      = make(map[string]*globalSink)
+	globalSinksMutex sync.RWMutex
+)
+
+// AddGlobal создает приемник и подключает его ко всем запускам генерации
+func AddGlobal(cfg models.SinkConfig) error {
+	if cfg.Name == "" {
+		cfg.Name = cfg.Type
+	}
+
+	sink, err := New(cfg)
+	if err != nil {
+		return err
+	}
+
+	globalSinksMutex.Lock()
+	previous := globalSinks[cfg.Name]
+	globalSinks[cfg.Name] = &globalSink{config: cfg, sink: sink}
+	globalSinksMutex.Unlock()
+
+	if previous != nil {
+		return previous.sink.Close()
+	}
+	return nil
+}
+
+// RemoveGlobal отключает и закрывает глобальный приемник
+func RemoveGlobal(name string) error {
+	globalSinksMutex.Lock()
+	gs, ok := globalSinks[name]
+	delete(globalSinks, name)
+	globalSinksMutex.Unlock()
+
+	if !ok {
+		return fmt.Errorf("приемник не найден: %s", name)
+	}
+	return gs.sink.Close()
+}
+
+// ListGlobal возвращает конфигурации глобальных приемников со скрытыми секретами
+func ListGlobal() []models.SinkConfig {
+	globalSinksMutex.RLock()
+	defer globalSinksMutex.RUnlock()
+
+	configs := make([]models.SinkConfig, 0, len(globalSinks))
+	for _, gs := range globalSinks {
+		configs = append(configs, Redact(gs.config))
+	}
+	sort.Slice(configs, func(i, j int) bool { return configs[i].Name < configs[j].Name })
+	return configs
+}
+
+// WriteGlobal отправляет пакет во все глобальные приемники
+func WriteGlobal(batch Batch) error {
+	globalSinksMutex.RLock()
+	defer globalSinksMutex.RUnlock()
+
+	var errs []error
+	for name, gs := range globalSinks {
+		if err := gs.sink.Write(batch); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %v", name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// CloseGlobal закрывает все глобальные приемники
+func CloseGlobal() error {
+	globalSinksMutex.Lock()
+	defer globalSinksMutex.Unlock()
+
+	var errs []error
+	for name, gs := range globalSinks {
+		if err := gs.sink.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %v", name, err))
+		}
+		delete(globalSinks, name)
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,31 @@
+package sinks
+
+import "sync"
+
+// CounterHook получает приращения счетчиков приемников (отправленные
+// документы, ошибки индексации). Подключается генератором, чтобы
+// счетчики попадали в общий реестр метрик.
+type CounterHook func(name string, labels map[string]string, delta float64)
+
+var (
+	counterHook      CounterHook
+	counterHookMutex sync.RWMutex
+)
+
+// SetCounterHook устанавливает получателя счетчиков приемников
+func SetCounterHook(hook CounterHook) {
+	counterHookMutex.Lock()
+	defer counterHookMutex.Unlock()
+
+	counterHook = hook
+}
+
+func addCounter(name string, labels map[string]string, delta float64) {
+	counterHookMutex.RLock()
+	hook := counterHook
+	counterHookMutex.RUnlock()
+
+	if hook != nil && delta > 0 {
+		hook(name, labels, delta)
+	}
+}
@@ -0,0 +1,43 @@
+package sinks
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"os"
+	"sync"
+)
+
+func init() {
+	Register("stdout", func(options map[string]interface{}) (Sink, error) {
+		return NewWriterSink(os.Stdout), nil
+	})
+}
+
+// WriterSink пишет логи в поток построчно в формате JSON
+type WriterSink struct {
+	mutex sync.Mutex
+	w     io.Writer
+}
+
+func NewWriterSink(w io.Writer) *WriterSink {
+	return &WriterSink{w: w}
+}
+
+func (s *WriterSink) Write(batch Batch) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	buf := bufio.NewWriter(s.w)
+	enc := json.NewEncoder(buf)
+	for _, entry := range batch.Logs {
+		if err := enc.Encode(entry); err != nil {
+			return err
+		}
+	}
+	return buf.Flush()
+}
+
+func (s *WriterSink) Close() error {
+	return nil
+}
@@ -0,0 +1,385 @@
+package sinks
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"log-metrics-simulator/models"
+)
+
+func init() {
+	Register("syslog", func(options map[string]interface{}) (Sink, error) {
+		reconnect, err := optDuration(options, "reconnect_interval", 2*time.Second)
+		if err != nil {
+			return nil, err
+		}
+		timeout, err := optDuration(options, "timeout", 5*time.Second)
+		if err != nil {
+			return nil, err
+		}
+		flushTimeout, err := optDuration(options, "flush_timeout", 5*time.Second)
+		if err != nil {
+			return nil, err
+		}
+		return NewSyslogSink(SyslogConfig{
+			Address:            optString(options, "address", ""),
+			Network:            optString(options, "network", "udp"),
+			Format:             optString(options, "format", "rfc5424"),
+			Framing:            optString(options, "framing", "octet-counting"),
+			Facility:           optInt(options, "facility", 16),
+			Hostname:           optString(options, "hostname", ""),
+			BufferSize:         optInt(options, "buffer_size", 10000),
+			ReconnectInterval:  reconnect,
+			Timeout:            timeout,
+			FlushTimeout:       flushTimeout,
+			CAFile:             optString(options, "ca_file", ""),
+			InsecureSkipVerify: optBool(options, "insecure_skip_verify", false),
+		})
+	})
+}
+
+// SD-ID структурированных данных RFC 5424 (32473 - номер из документации IANA)
+const syslogSDID = "sim@32473"
+
+// SyslogConfig задает параметры syslog-приемника
+type SyslogConfig struct {
+	Address            string        // host:port приемника
+	Network            string        // udp, tcp или tls
+	Format             string        // rfc5424 или rfc3164
+	Framing            string        // Для tcp/tls: octet-counting (RFC 6587) или newline
+	Facility           int           // Код facility, по умолчанию local0 (16)
+	Hostname           string        // HOSTNAME в заголовке, по умолчанию имя хоста
+	BufferSize         int           // Размер буфера сообщений на время недоступности приемника
+	ReconnectInterval  time.Duration // Пауза между попытками переподключения
+	Timeout            time.Duration // Таймаут подключения и записи одного сообщения
+	FlushTimeout       time.Duration // Максимальное время досылки буфера при закрытии
+	CAFile             string        // CA для проверки сертификата (tls)
+	InsecureSkipVerify bool          // Не проверять сертификат (tls)
+}
+
+// SyslogSink отправляет логи в syslog. Сообщения ставятся в буфер и
+// отправляются фоновой горутиной, которая переподключается при обрыве
+// соединения. При переполнении буфера отбрасываются самые старые сообщения.
+type SyslogSink struct {
+	config    SyslogConfig
+	tlsConfig *tls.Config
+
+	queue   chan []byte
+	stop    chan struct{}
+	done    chan struct{}
+	mutex   sync.RWMutex
+	closed  bool
+	dropped atomic.Int64
+}
+
+func NewSyslogSink(config SyslogConfig) (*SyslogSink, error) {
+	if config.Address == "" {
+		return nil, fmt.Errorf("не указан адрес syslog")
+	}
+	switch config.Network {
+	case "udp", "tcp", "tls":
+	default:
+		return nil, fmt.Errorf("неизвестный транспорт: %s", config.Network)
+	}
+	if config.Format != "rfc5424" && config.Format != "rfc3164" {
+		return nil, fmt.Errorf("неизвестный формат: %s", config.Format)
+	}
+	if config.Framing != "octet-counting" && config.Framing != "newline" {
+		return nil, fmt.Errorf("неизвестный способ разделения сообщений: %s", config.Framing)
+	}
+	if config.Facility < 0 || config.Facility > 23 {
+		return nil, fmt.Errorf("facility должен быть в диапазоне 0-23")
+	}
+	if config.Hostname == "" {
+		config.Hostname, _ = os.Hostname()
+		if config.Hostname == "" {
+			config.Hostname = "-"
+		}
+	}
+	if config.BufferSize <= 0 {
+		config.BufferSize = 10000
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = 5 * time.Second
+	}
+	if config.FlushTimeout <= 0 {
+		config.FlushTimeout = 5 * time.Second
+	}
+
+	s := &SyslogSink{
+		config: config,
+		queue:  make(chan []byte, config.BufferSize),
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+
+	if config.Network == "tls" {
+		host, _, err := net.SplitHostPort(config.Address)
+		if err != nil {
+			return nil, fmt.Errorf("неверный адрес: %v", err)
+		}
+		s.tlsConfig = &tls.Config{ServerName: host, InsecureSkipVerify: config.InsecureSkipVerify}
+		if config.CAFile != "" {
+			pem, err := os.ReadFile(config.CAFile)
+			if err != nil {
+				return nil, fmt.Errorf("ошибка чтения CA: %v", err)
+			}
+			pool := x509.NewCertPool()
+			if !pool.AppendCertsFromPEM(pem) {
+				return nil, fmt.Errorf("в %s нет сертификатов", config.CAFile)
+			}
+			s.tlsConfig.RootCAs = pool
+		}
+	}
+
+	go s.run()
+	return s, nil
+}
+
+func (s *SyslogSink) Write(batch Batch) error {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	if s.closed {
+		return fmt.Errorf("приемник syslog закрыт")
+	}
+
+	for _, entry := range batch.Logs {
+		s.enqueue(s.format(entry))
+	}
+	return nil
+}
+
+// enqueue ставит сообщение в буфер, вытесняя самое старое при переполнении
+func (s *SyslogSink) enqueue(msg []byte) {
+	for {
+		select {
+		case s.queue <- msg:
+			return
+		default:
+		}
+		select {
+		case <-s.queue:
+			if s.dropped.Add(1)%1000 == 1 {
+				log.Printf("⚠️ Буфер syslog %s переполнен, старые сообщения отбрасываются", s.config.Address)
+			}
+		default:
+		}
+	}
+}
+
+// run отправляет сообщения из буфера, переподключаясь при ошибках
+func (s *SyslogSink) run() {
+	defer close(s.done)
+
+	var conn net.Conn
+	var pending []byte
+	connected := true
+
+	defer func() {
+		if conn != nil {
+			conn.Close()
+		}
+	}()
+
+	for {
+		if pending == nil {
+			select {
+			case msg, ok := <-s.queue:
+				if !ok {
+					return
+				}
+				pending = msg
+			case <-s.stop:
+				return
+			}
+		}
+
+		if conn == nil {
+			c, err := s.dial()
+			if err != nil {
+				if connected {
+					log.Printf("❌ Syslog %s недоступен: %v", s.config.Address, err)
+					connected = false
+				}
+				select {
+				case <-time.After(s.config.ReconnectInterval):
+				case <-s.stop:
+					return
+				}
+				continue
+			}
+			if !connected {
+				log.Printf("🔄 Восстановлено соединение с syslog %s", s.config.Address)
+				connected = true
+			}
+			conn = c
+		}
+
+		// Приемник, переставший читать, не должен блокировать отправку навсегда
+		conn.SetWriteDeadline(time.Now().Add(s.config.Timeout))
+		if _, err := conn.Write(s.frame(pending)); err != nil {
+			// Сообщение остается в pending и будет отправлено после переподключения
+			conn.Close()
+			conn = nil
+			select {
+			case <-s.stop:
+				return
+			default:
+			}
+			continue
+		}
+		pending = nil
+	}
+}
+
+func (s *SyslogSink) dial() (net.Conn, error) {
+	dialer := &net.Dialer{Timeout: s.config.Timeout}
+	switch s.config.Network {
+	case "tls":
+		return tls.DialWithDialer(dialer, "tcp", s.config.Address, s.tlsConfig)
+	default:
+		return dialer.Dial(s.config.Network, s.config.Address)
+	}
+}
+
+// frame добавляет разделение сообщений для потоковых транспортов
+func (s *SyslogSink) frame(msg []byte) []byte {
+	if s.config.Network == "udp" {
+		return msg
+	}
+	if s.config.Framing == "newline" {
+		return append(msg, '\n')
+	}
+	return append([]byte(strconv.Itoa(len(msg))+" "), msg...)
+}
+
+// syslogSeverity сопоставляет уровень лога и severity syslog
+func syslogSeverity(level string) int {
+	switch strings.ToUpper(level) {
+	case "FATAL":
+		return 2 // critical
+	case "ERROR":
+		return 3 // error
+	case "WARN", "WARNING":
+		return 4 // warning
+	case "INFO":
+		return 6 // informational
+	case "DEBUG":
+		return 7 // debug
+	default:
+		return 5 // notice
+	}
+}
+
+func (s *SyslogSink) format(entry models.LogEntry) []byte {
+	pri := s.config.Facility*8 + syslogSeverity(entry.Level)
+
+	if s.config.Format == "rfc3164" {
+		// <PRI>Mmm dd hh:mm:ss HOSTNAME TAG: MSG
+		msg := entry.Message
+		if entry.TraceID != "" {
+			msg += " trace_id=" + entry.TraceID
+		}
+		if entry.Status != 0 {
+			msg += " status=" + strconv.Itoa(entry.Status)
+		}
+		return []byte(fmt.Sprintf("<%d>%s %s %s: %s",
+			pri,
+			entry.Timestamp.Format(time.Stamp),
+			s.config.Hostname,
+			syslogHeaderField(entry.Service, 32),
+			msg,
+		))
+	}
+
+	// <PRI>1 TIMESTAMP HOSTNAME APP-NAME PROCID MSGID [SD] MSG
+	return []byte(fmt.Sprintf("<%d>1 %s %s %s - - %s %s",
+		pri,
+		entry.Timestamp.Format("2006-01-02T15:04:05.000000Z07:00"),
+		syslogHeaderField(s.config.Hostname, 255),
+		syslogHeaderField(entry.Service, 48),
+		structuredData(entry),
+		entry.Message,
+	))
+}
+
+// syslogHeaderField приводит значение к печатному ASCII без пробелов и ограничивает длину
+func syslogHeaderField(value string, maxLen int) string {
+	var b strings.Builder
+	for _, r := range value {
+		if r > 32 && r < 127 {
+			b.WriteRune(r)
+		}
+		if b.Len() >= maxLen {
+			break
+		}
+	}
+	if b.Len() == 0 {
+		return "-"
+	}
+	return b.String()
+}
+
+// structuredData формирует элемент SD с полями корреляции
+func structuredData(entry models.LogEntry) string {
+	params := []struct{ name, value string }{
+		{"trace_id", entry.TraceID},
+		{"span_id", entry.SpanID},
+		{"user_id", entry.UserID},
+	}
+	if entry.Status != 0 {
+		params = append(params, struct{ name, value string }{"status", strconv.Itoa(entry.Status)})
+	}
+
+	var b strings.Builder
+	for _, p := range params {
+		if p.value == "" {
+			continue
+		}
+		b.WriteString(" " + p.name + `="` + escapeSDValue(p.value) + `"`)
+	}
+	if b.Len() == 0 {
+		return "-"
+	}
+	return "[" + syslogSDID + b.String() + "]"
+}
+
+// escapeSDValue экранирует значение параметра SD: ", \ и ]
+func escapeSDValue(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, `]`, `\]`).Replace(value)
+}
+
+func (s *SyslogSink) Close() error {
+	s.mutex.Lock()
+	if s.closed {
+		s.mutex.Unlock()
+		return nil
+	}
+	s.closed = true
+	close(s.queue)
+	s.mutex.Unlock()
+
+	// Даем время досылать буфер, затем останавливаем отправку. Текущая запись
+	// или подключение прерываются по таймауту.
+	select {
+	case <-s.done:
+		return nil
+	case <-time.After(s.config.FlushTimeout):
+	}
+	close(s.stop)
+	select {
+	case <-s.done:
+	case <-time.After(s.config.Timeout):
+	}
+	return fmt.Errorf("не удалось отправить буфер syslog %s", s.config.Address)
+}
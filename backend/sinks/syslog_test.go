@@ -0,0 +1,270 @@
+package sinks
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"log-metrics-simulator/models"
+)
+
+func newTestSyslogSink(t *testing.T, config SyslogConfig) *SyslogSink {
+	t.Helper()
+	if config.Format == "" {
+		config.Format = "rfc5424"
+	}
+	if config.Framing == "" {
+		config.Framing = "octet-counting"
+	}
+	if config.Facility == 0 {
+		config.Facility = 16
+	}
+	config.Hostname = "sim-host"
+	sink, err := NewSyslogSink(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return sink
+}
+
+// expectedMessages возвращает сообщения, которые приемник должен отправить для logs
+func expectedMessages(sink *SyslogSink, logs []models.LogEntry) []string {
+	messages := make([]string, len(logs))
+	for i, entry := range logs {
+		messages[i] = string(sink.format(entry))
+	}
+	return messages
+}
+
+// acceptOne принимает одно TCP-соединение и возвращает все прочитанные из него данные
+func acceptOne(t *testing.T, ln net.Listener) <-chan []byte {
+	received := make(chan []byte, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			t.Errorf("accept: %v", err)
+			close(received)
+			return
+		}
+		defer conn.Close()
+		conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		data, _ := io.ReadAll(conn)
+		received <- data
+	}()
+	return received
+}
+
+// splitOctetCounted разбирает поток с разделением по RFC 6587: "LEN SP MSG"
+func splitOctetCounted(t *testing.T, data []byte) []string {
+	t.Helper()
+	r := bufio.NewReader(strings.NewReader(string(data)))
+	var messages []string
+	for {
+		prefix, err := r.ReadString(' ')
+		if err == io.EOF && prefix == "" {
+			return messages
+		}
+		if err != nil {
+			t.Fatalf("обрыв заголовка кадра %q: %v", prefix, err)
+		}
+		n, err := strconv.Atoi(strings.TrimSuffix(prefix, " "))
+		if err != nil {
+			t.Fatalf("неверная длина кадра %q", prefix)
+		}
+		msg := make([]byte, n)
+		if _, err := io.ReadFull(r, msg); err != nil {
+			t.Fatalf("кадр короче %d байт: %v", n, err)
+		}
+		messages = append(messages, string(msg))
+	}
+}
+
+func checkMessages(t *testing.T, got, want []string) {
+	t.Helper()
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("получены сообщения:\n%s\nожидались:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestSyslogFormat(t *testing.T) {
+	entry := models.LogEntry{
+		Timestamp: time.Date(2024, 1, 1, 12, 0, 0, 123456000, time.UTC),
+		Level:     "ERROR",
+		Service:   "cart service",
+		TraceID:   "abc",
+		SpanID:    "def",
+		UserID:    `u"1]`,
+		Status:    500,
+		Message:   "failed",
+	}
+	tests := []struct {
+		format string
+		want   string
+	}{
+		{"rfc5424", `<131>1 2024-01-01T12:00:00.123456Z sim-host cartservice - - [sim@32473 trace_id="abc" span_id="def" user_id="u\"1\]" status="500"] failed`},
+		{"rfc3164", `<131>Jan  1 12:00:00 sim-host cartservice: failed trace_id=abc status=500`},
+	}
+	for _, tt := range tests {
+		sink := newTestSyslogSink(t, SyslogConfig{Address: "127.0.0.1:1", Network: "udp", Format: tt.format})
+		if got := string(sink.format(entry)); got != tt.want {
+			t.Errorf("%s: %s, ожидалось %s", tt.format, got, tt.want)
+		}
+		sink.Close()
+	}
+
+	sink := newTestSyslogSink(t, SyslogConfig{Address: "127.0.0.1:1", Network: "udp"})
+	defer sink.Close()
+	if got := string(sink.format(models.LogEntry{Timestamp: entry.Timestamp, Level: "DEBUG", Message: "m"})); got != "<135>1 2024-01-01T12:00:00.123456Z sim-host - - - - m" {
+		t.Errorf("сообщение без полей: %s", got)
+	}
+}
+
+func TestSyslogSinkUDP(t *testing.T) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pc.Close()
+
+	sink := newTestSyslogSink(t, SyslogConfig{Address: pc.LocalAddr().String(), Network: "udp"})
+	logs := testLogs()
+	if err := sink.Write(Batch{Logs: logs}); err != nil {
+		t.Fatal(err)
+	}
+	if err := sink.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	buf := make([]byte, 64*1024)
+	pc.SetReadDeadline(time.Now().Add(5 * time.Second))
+	for len(got) < len(logs) {
+		n, _, err := pc.ReadFrom(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, string(buf[:n]))
+	}
+	checkMessages(t, got, expectedMessages(sink, logs))
+}
+
+func TestSyslogSinkTCPFraming(t *testing.T) {
+	for _, framing := range []string{"octet-counting", "newline"} {
+		t.Run(framing, func(t *testing.T) {
+			ln, err := net.Listen("tcp", "127.0.0.1:0")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer ln.Close()
+			received := acceptOne(t, ln)
+
+			sink := newTestSyslogSink(t, SyslogConfig{Address: ln.Addr().String(), Network: "tcp", Framing: framing})
+			logs := testLogs()
+			if err := sink.Write(Batch{Logs: logs}); err != nil {
+				t.Fatal(err)
+			}
+			if err := sink.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			data := <-received
+			var got []string
+			if framing == "newline" {
+				got = strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+			} else {
+				got = splitOctetCounted(t, data)
+			}
+			checkMessages(t, got, expectedMessages(sink, logs))
+		})
+	}
+}
+
+func TestSyslogSinkBuffersUntilReconnect(t *testing.T) {
+	// Свободный порт, на котором пока никто не слушает
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+
+	sink := newTestSyslogSink(t, SyslogConfig{Address: addr, Network: "tcp", ReconnectInterval: 20 * time.Millisecond})
+	logs := testLogs()
+	if err := sink.Write(Batch{Logs: logs}); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(100 * time.Millisecond)
+
+	ln, err = net.Listen("tcp", addr)
+	if err != nil {
+		t.Skipf("порт %s занят: %v", addr, err)
+	}
+	defer ln.Close()
+	received := acceptOne(t, ln)
+
+	if err := sink.Close(); err != nil {
+		t.Fatal(err)
+	}
+	checkMessages(t, splitOctetCounted(t, <-received), expectedMessages(sink, logs))
+}
+
+func TestSyslogSinkCloseWithStalledReceiver(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	// Приемник принимает соединение, но не читает из него
+	accepted := make(chan net.Conn, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err == nil {
+			accepted <- conn
+		}
+	}()
+	defer func() {
+		select {
+		case conn := <-accepted:
+			conn.Close()
+		default:
+		}
+	}()
+
+	sink := newTestSyslogSink(t, SyslogConfig{
+		Address:      ln.Addr().String(),
+		Network:      "tcp",
+		BufferSize:   1000,
+		Timeout:      100 * time.Millisecond,
+		FlushTimeout: 100 * time.Millisecond,
+	})
+	logs := make([]models.LogEntry, 500)
+	for i := range logs {
+		logs[i] = models.LogEntry{Timestamp: time.Now(), Level: "INFO", Service: "cart-service", Message: strings.Repeat("x", 64*1024)}
+	}
+	if err := sink.Write(Batch{Logs: logs}); err != nil {
+		t.Fatal(err)
+	}
+
+	closed := make(chan error, 1)
+	go func() { closed <- sink.Close() }()
+	select {
+	case err := <-closed:
+		if err == nil {
+			t.Fatal("ожидалась ошибка недосланного буфера")
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("Close завис на приемнике, который не читает")
+	}
+
+	// Зависшая запись прерывается по таймауту, и горутина отправки завершается
+	select {
+	case <-sink.done:
+	case <-time.After(time.Second):
+		t.Fatal("отправка не прервана по таймауту записи")
+	}
+}
@@ -0,0 +1,411 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"log-metrics-simulator/models"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// Бакеты базы
+var (
+	bucketMeta            = []byte("meta")
+	bucketScenarios       = []byte("scenarios")
+	bucketSchedules       = []byte("schedules")
+	bucketExecutions      = []byte("executions")
+	bucketChains          = []byte("chains")
+	bucketChainExecutions = []byte("chain_executions")
+	bucketChainSchedules  = []byte("chain_schedules")
+
+	// Индексы выполнений: ключ - родитель/время начала/id, значение - id
+	bucketExecutionsBySchedule   = []byte("executions_by_schedule")
+	bucketChainExecutionsByChain = []byte("chain_executions_by_chain")
+
+	keySchemaVersion = []byte("schema_version")
+)
+
+// Формат времени в ключах индексов: фиксированная ширина сохраняет порядок
+const indexTimeFormat = "20060102T150405.000000000Z"
+
+// BoltStorage хранит сценарии, расписания, цепочки и историю выполнений во
+// встроенной базе bbolt, поэтому они переживают перезапуск. Записи хранятся в JSON.
+type BoltStorage struct {
+	db *bolt.DB
+}
+
+// migration - шаг схемы базы; номер последней примененной версии хранится в бакете meta
+type migration struct {
+	version     int
+	description string
+	apply       func(tx *bolt.Tx) error
+}
+
+// Миграции применяются по порядку, каждая в своей транзакции
+var boltMigrations = []migration{
+	{1, "бакеты сценариев, расписаний, цепочек и выполнений", createBuckets},
+	{2, "индексы выполнений по расписанию и цепочке", indexExecutions},
+}
+
+// NewBoltStorage открывает базу по пути path, создавая ее при необходимости,
+// и применяет миграции схемы
+func NewBoltStorage(path string) (*BoltStorage, error) {
+	if dir := filepath.Dir(path); dir != "" {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return nil, fmt.Errorf("ошибка создания каталога базы: %v", err)
+		}
+	}
+
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("ошибка открытия базы %s: %v", path, err)
+	}
+
+	s := &BoltStorage{db: db}
+	if err := s.migrate(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *BoltStorage) migrate() error {
+	var current int
+	err := s.db.View(func(tx *bolt.Tx) error {
+		if meta := tx.Bucket(bucketMeta); meta != nil {
+			current, _ = strconv.Atoi(string(meta.Get(keySchemaVersion)))
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	latest := boltMigrations[len(boltMigrations)-1].version
+	if current > latest {
+		return fmt.Errorf("версия схемы базы %d новее поддерживаемой %d", current, latest)
+	}
+
+	for _, m := range boltMigrations {
+		if m.version <= current {
+			continue
+		}
+		err := s.db.Update(func(tx *bolt.Tx) error {
+			if err := m.apply(tx); err != nil {
+				return err
+			}
+			meta, err := tx.CreateBucketIfNotExists(bucketMeta)
+			if err != nil {
+				return err
+			}
+			return meta.Put(keySchemaVersion, []byte(strconv.Itoa(m.version)))
+		})
+		if err != nil {
+			return fmt.Errorf("ошибка миграции %d (%s): %v", m.version, m.description, err)
+		}
+		log.Printf("🗄️ Применена миграция схемы %d: %s", m.version, m.description)
+	}
+	return nil
+}
+
+func createBuckets(tx *bolt.Tx) error {
+	for _, name := range [][]byte{bucketScenarios, bucketSchedules, bucketExecutions, bucketChains, bucketChainExecutions, bucketChainSchedules} {
+		if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// indexExecutions создает индексы выполнений и заполняет их по уже сохраненным записям
+func indexExecutions(tx *bolt.Tx) error {
+	bySchedule, err := tx.CreateBucketIfNotExists(bucketExecutionsBySchedule)
+	if err != nil {
+		return err
+	}
+	byChain, err := tx.CreateBucketIfNotExists(bucketChainExecutionsByChain)
+	if err != nil {
+		return err
+	}
+
+	err = tx.Bucket(bucketExecutions).ForEach(func(_, v []byte) error {
+		var execution models.ScheduleExecution
+		if err := json.Unmarshal(v, &execution); err != nil {
+			return err
+		}
+		return bySchedule.Put(indexKey(execution.ScheduleID, execution.StartedAt, execution.ID), []byte(execution.ID))
+	})
+	if err != nil {
+		return err
+	}
+
+	return tx.Bucket(bucketChainExecutions).ForEach(func(_, v []byte) error {
+		var execution models.ChainExecution
+		if err := json.Unmarshal(v, &execution); err != nil {
+			return err
+		}
+		return byChain.Put(indexKey(execution.ChainID, execution.StartedAt, execution.ID), []byte(execution.ID))
+	})
+}
+
+func indexKey(parent string, startedAt time.Time, id string) []byte {
+	return []byte(parent + "/" + startedAt.UTC().Format(indexTimeFormat) + "/" + id)
+}
+
+func (s *BoltStorage) Close() error {
+	return s.db.Close()
+}
+
+// ===== Общие операции над бакетами =====
+
+func put(tx *bolt.Tx, bucket []byte, key string, value interface{}) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	return tx.Bucket(bucket).Put([]byte(key), data)
+}
+
+// get читает запись в value; false, если записи нет
+func get(tx *bolt.Tx, bucket []byte, key string, value interface{}) (bool, error) {
+	data := tx.Bucket(bucket).Get([]byte(key))
+	if data == nil {
+		return false, nil
+	}
+	return true, json.Unmarshal(data, value)
+}
+
+func (s *BoltStorage) save(bucket []byte, key string, value interface{}) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return put(tx, bucket, key, value)
+	})
+}
+
+func (s *BoltStorage) delete(bucket []byte, key string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucket).Delete([]byte(key))
+	})
+}
+
+// load читает запись по ключу; nil без ошибки, если записи нет
+func load[T any](s *BoltStorage, bucket []byte, key string) (*T, error) {
+	var value *T
+	err := s.db.View(func(tx *bolt.Tx) error {
+		item := new(T)
+		found, err := get(tx, bucket, key, item)
+		if found {
+			value = item
+		}
+		return err
+	})
+	return value, err
+}
+
+func loadAll[T any](s *BoltStorage, bucket []byte) ([]*T, error) {
+	var values []*T
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucket).ForEach(func(_, v []byte) error {
+			item := new(T)
+			if err := json.Unmarshal(v, item); err != nil {
+				return err
+			}
+			values = append(values, item)
+			return nil
+		})
+	})
+	return values, err
+}
+
+// loadIndexed читает до limit записей родителя parent по индексу, новые сначала
+func loadIndexed[T any](s *BoltStorage, bucket, index []byte, parent string, limit int) ([]*T, error) {
+	var values []*T
+	err := s.db.View(func(tx *bolt.Tx) error {
+		prefix := []byte(parent + "/")
+		end := []byte(parent + "0") // Следующий за '/' символ: первый ключ после ключей родителя
+
+		c := tx.Bucket(index).Cursor()
+		k, id := c.Seek(end)
+		if k == nil {
+			k, id = c.Last()
+		} else {
+			k, id = c.Prev()
+		}
+		for ; k != nil && bytes.HasPrefix(k, prefix); k, id = c.Prev() {
+			if limit > 0 && len(values) >= limit {
+				break
+			}
+			item := new(T)
+			found, err := get(tx, bucket, string(id), item)
+			if err != nil {
+				return err
+			}
+			if found {
+				values = append(values, item)
+			}
+		}
+		return nil
+	})
+	return values, err
+}
+
+// ===== Сценарии =====
+
+func (s *BoltStorage) SaveScenario(scenario *models.Scenario) error {
+	return s.save(bucketScenarios, scenario.Type, scenario)
+}
+
+func (s *BoltStorage) GetActiveScenarios() ([]*models.Scenario, error) {
+	scenarios, err := loadAll[models.Scenario](s, bucketScenarios)
+	if err != nil {
+		return nil, err
+	}
+
+	var active []*models.Scenario
+	for _, scenario := range scenarios {
+		if scenario.Active {
+			active = append(active, scenario)
+		}
+	}
+	return active, nil
+}
+
+func (s *BoltStorage) UpdateScenario(scenario *models.Scenario) error {
+	return s.save(bucketScenarios, scenario.Type, scenario)
+}
+
+func (s *BoltStorage) DeleteScenario(scenarioType string) error {
+	return s.delete(bucketScenarios, scenarioType)
+}
+
+// ===== Расписания сценариев =====
+
+func (s *BoltStorage) SaveSchedule(schedule *models.Schedule) error {
+	return s.save(bucketSchedules, schedule.ID, schedule)
+}
+
+func (s *BoltStorage) GetSchedules() ([]*models.Schedule, error) {
+	return loadAll[models.Schedule](s, bucketSchedules)
+}
+
+func (s *BoltStorage) GetSchedule(id string) (*models.Schedule, error) {
+	return load[models.Schedule](s, bucketSchedules, id)
+}
+
+func (s *BoltStorage) UpdateSchedule(schedule *models.Schedule) error {
+	return s.save(bucketSchedules, schedule.ID, schedule)
+}
+
+func (s *BoltStorage) DeleteSchedule(id string) error {
+	return s.delete(bucketSchedules, id)
+}
+
+// ===== Выполнения расписаний =====
+
+func (s *BoltStorage) SaveExecution(execution *models.ScheduleExecution) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		index := tx.Bucket(bucketExecutionsBySchedule)
+
+		var previous models.ScheduleExecution
+		if found, err := get(tx, bucketExecutions, execution.ID, &previous); err != nil {
+			return err
+		} else if found {
+			if err := index.Delete(indexKey(previous.ScheduleID, previous.StartedAt, previous.ID)); err != nil {
+				return err
+			}
+		}
+
+		if err := put(tx, bucketExecutions, execution.ID, execution); err != nil {
+			return err
+		}
+		return index.Put(indexKey(execution.ScheduleID, execution.StartedAt, execution.ID), []byte(execution.ID))
+	})
+}
+
+func (s *BoltStorage) GetExecutions(scheduleID string, limit int) ([]*models.ScheduleExecution, error) {
+	return loadIndexed[models.ScheduleExecution](s, bucketExecutions, bucketExecutionsBySchedule, scheduleID, limit)
+}
+
+// ===== Цепочки =====
+
+func (s *BoltStorage) SaveChain(chain *models.ScenarioChain) error {
+	return s.save(bucketChains, chain.ID, chain)
+}
+
+func (s *BoltStorage) GetChains() ([]*models.ScenarioChain, error) {
+	return loadAll[models.ScenarioChain](s, bucketChains)
+}
+
+func (s *BoltStorage) GetChain(id string) (*models.ScenarioChain, error) {
+	return load[models.ScenarioChain](s, bucketChains, id)
+}
+
+func (s *BoltStorage) UpdateChain(chain *models.ScenarioChain) error {
+	return s.save(bucketChains, chain.ID, chain)
+}
+
+func (s *BoltStorage) DeleteChain(id string) error {
+	return s.delete(bucketChains, id)
+}
+
+// ===== Выполнения цепочек =====
+
+func (s *BoltStorage) SaveChainExecution(execution *models.ChainExecution) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		index := tx.Bucket(bucketChainExecutionsByChain)
+
+		var previous models.ChainExecution
+		if found, err := get(tx, bucketChainExecutions, execution.ID, &previous); err != nil {
+			return err
+		} else if found {
+			if err := index.Delete(indexKey(previous.ChainID, previous.StartedAt, previous.ID)); err != nil {
+				return err
+			}
+		}
+
+		if err := put(tx, bucketChainExecutions, execution.ID, execution); err != nil {
+			return err
+		}
+		return index.Put(indexKey(execution.ChainID, execution.StartedAt, execution.ID), []byte(execution.ID))
+	})
+}
+
+func (s *BoltStorage) GetChainExecutions(chainID string, limit int) ([]*models.ChainExecution, error) {
+	return loadIndexed[models.ChainExecution](s, bucketChainExecutions, bucketChainExecutionsByChain, chainID, limit)
+}
+
+func (s *BoltStorage) GetChainExecution(id string) (*models.ChainExecution, error) {
+	return load[models.ChainExecution](s, bucketChainExecutions, id)
+}
+
+func (s *BoltStorage) UpdateChainExecution(execution *models.ChainExecution) error {
+	return s.SaveChainExecution(execution)
+}
+
+// ===== Расписания цепочек =====
+
+func (s *BoltStorage) SaveChainSchedule(schedule *models.ChainSchedule) error {
+	return s.save(bucketChainSchedules, schedule.ID, schedule)
+}
+
+func (s *BoltStorage) GetChainSchedules() ([]*models.ChainSchedule, error) {
+	return loadAll[models.ChainSchedule](s, bucketChainSchedules)
+}
+
+func (s *BoltStorage) GetChainSchedule(id string) (*models.ChainSchedule, error) {
+	return load[models.ChainSchedule](s, bucketChainSchedules, id)
+}
+
+func (s *BoltStorage) UpdateChainSchedule(schedule *models.ChainSchedule) error {
+	return s.save(bucketChainSchedules, schedule.ID, schedule)
+}
+
+func (s *BoltStorage) DeleteChainSchedule(id string) error {
+	return s.delete(bucketChainSchedules, id)
+}
@@ -0,0 +1,317 @@
+package storage
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+
+	"log-metrics-simulator/models"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+var bucketLogs = []byte("logs")
+
+const (
+	logFlushInterval = time.Second // Как часто накопленные логи записываются на диск
+	logFlushBatch    = 10000       // Сколько логов накапливается до внеочередной записи
+	logPruneInterval = time.Minute // Как часто удаляются логи с истекшим сроком хранения
+)
+
+// BoltLogStore хранит логи в файле bbolt, поэтому история переживает
+// перезапуск и может превышать объем памяти. Ключ - порядковый номер записи,
+// значение - момент записи (8 байт, наносекунды Unix) и лог в JSON. Индексов
+// нет: Query и Stats читают файл целиком, поэтому для частых выборок с
+// фильтрами подходит MemoryLogStore.
+//
+// Append только накапливает логи в памяти: фоновый писатель сохраняет их
+// одной транзакцией раз в logFlushInterval или по logFlushBatch логов, чтобы
+// генерация не ждала fsync. Чтение сначала дописывает накопленное.
+type BoltLogStore struct {
+	db        *bolt.DB
+	capacity  int
+	retention time.Duration
+
+	mutex   sync.Mutex
+	pending []storedLog
+	count   int // Логов в файле
+
+	flushMutex sync.Mutex // Записи на диск идут по очереди и в порядке Append
+	lastPrune  time.Time
+
+	flushNow chan struct{}
+	done     chan struct{}
+	stopped  chan struct{}
+}
+
+// NewBoltLogStore открывает файл логов по пути path, создавая его при необходимости
+func NewBoltLogStore(path string, capacity int, retention time.Duration) (*BoltLogStore, error) {
+	if dir := filepath.Dir(path); dir != "" {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return nil, fmt.Errorf("ошибка создания каталога логов: %v", err)
+		}
+	}
+	if capacity <= 0 {
+		capacity = DefaultLogCapacity
+	}
+
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("ошибка открытия файла логов %s: %v", path, err)
+	}
+
+	s := &BoltLogStore{
+		db:        db,
+		capacity:  capacity,
+		retention: retention,
+		flushNow:  make(chan struct{}, 1),
+		done:      make(chan struct{}),
+		stopped:   make(chan struct{}),
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucketIfNotExists(bucketLogs)
+		if err != nil {
+			return err
+		}
+		s.count = b.Stats().KeyN
+		// Емкость и срок хранения могли уменьшиться с прошлого запуска
+		return s.prune(b, time.Now())
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("ошибка открытия файла логов %s: %v", path, err)
+	}
+	go s.writer()
+	return s, nil
+}
+
+// Append ставит логи в очередь на запись. Очередь не длиннее capacity:
+// более старые логи все равно были бы удалены при записи.
+func (s *BoltLogStore) Append(entries []models.LogEntry) error {
+	if len(entries) == 0 {
+		return nil
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	now := time.Now()
+	for _, entry := range entries {
+		s.pending = append(s.pending, storedLog{at: now, entry: entry})
+	}
+	if excess := len(s.pending) - s.capacity; excess > 0 {
+		s.pending = append(s.pending[:0], s.pending[excess:]...)
+	}
+	if len(s.pending) >= logFlushBatch {
+		select {
+		case s.flushNow <- struct{}{}:
+		default:
+		}
+	}
+	return nil
+}
+
+// writer записывает накопленные логи, пока хранилище не закрыто
+func (s *BoltLogStore) writer() {
+	defer close(s.stopped)
+	ticker := time.NewTicker(logFlushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.done:
+			return
+		case <-ticker.C:
+		case <-s.flushNow:
+		}
+		if err := s.flush(); err != nil {
+			log.Printf("❌ Ошибка записи логов в файл: %v", err)
+		}
+	}
+}
+
+// flush записывает накопленные логи одной транзакцией. Логи сверх емкости
+// удаляются сразу, логи с истекшим сроком - не чаще раза в logPruneInterval.
+// Если транзакция не удалась, логи возвращаются в начало очереди и будут
+// записаны при следующей попытке.
+func (s *BoltLogStore) flush() error {
+	s.flushMutex.Lock()
+	defer s.flushMutex.Unlock()
+
+	s.mutex.Lock()
+	pending := s.pending
+	s.pending = nil
+	count := s.count
+	s.mutex.Unlock()
+
+	now := time.Now()
+	pruneExpired := s.retention > 0 && now.Sub(s.lastPrune) >= logPruneInterval
+	if len(pending) == 0 && !pruneExpired {
+		return nil
+	}
+
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(bucketLogs)
+		for i := range pending {
+			data, err := json.Marshal(&pending[i].entry)
+			if err != nil {
+				return err
+			}
+			seq, err := b.NextSequence()
+			if err != nil {
+				return err
+			}
+			value := make([]byte, 8+len(data))
+			binary.BigEndian.PutUint64(value, uint64(pending[i].at.UnixNano()))
+			copy(value[8:], data)
+			if err := b.Put(logKey(seq), value); err != nil {
+				return err
+			}
+		}
+		count += len(pending)
+		if count <= s.capacity && !pruneExpired {
+			return nil
+		}
+		removed, err := s.pruneBucket(b, count, now)
+		count -= removed
+		return err
+	})
+	if err != nil {
+		s.requeue(pending)
+		return err
+	}
+	if pruneExpired {
+		s.lastPrune = now
+	}
+	s.mutex.Lock()
+	s.count = count
+	s.mutex.Unlock()
+	return nil
+}
+
+// requeue возвращает незаписанные логи в начало очереди. Очередь, как и в
+// Append, не длиннее capacity: вытесняются самые старые логи.
+func (s *BoltLogStore) requeue(pending []storedLog) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.pending = append(pending, s.pending...)
+	if excess := len(s.pending) - s.capacity; excess > 0 {
+		s.pending = append(s.pending[:0], s.pending[excess:]...)
+	}
+}
+
+// prune удаляет логи сверх емкости и с истекшим сроком хранения при открытии файла
+func (s *BoltLogStore) prune(b *bolt.Bucket, now time.Time) error {
+	removed, err := s.pruneBucket(b, s.count, now)
+	s.count -= removed
+	s.lastPrune = now
+	return err
+}
+
+// pruneBucket удаляет самые старые логи сверх емкости из count хранимых и с
+// истекшим сроком хранения; возвращает число удаленных
+func (s *BoltLogStore) pruneBucket(b *bolt.Bucket, count int, now time.Time) (int, error) {
+	excess := count - s.capacity
+	cutoff := now.Add(-s.retention)
+
+	var stale [][]byte
+	c := b.Cursor()
+	for k, v := c.First(); k != nil; k, v = c.Next() {
+		if len(stale) >= excess && (s.retention <= 0 || !logTime(v).Before(cutoff)) {
+			break
+		}
+		stale = append(stale, append([]byte(nil), k...))
+	}
+	for _, k := range stale {
+		if err := b.Delete(k); err != nil {
+			return 0, err
+		}
+	}
+	return len(stale), nil
+}
+
+func (s *BoltLogStore) Query(q LogQuery) ([]models.LogEntry, error) {
+	var result []models.LogEntry
+	err := s.scan(func(entry *models.LogEntry) bool {
+		if q.matches(entry) {
+			result = append(result, *entry)
+		}
+		return q.Limit <= 0 || len(result) < q.Limit
+	})
+	slices.Reverse(result)
+	return result, err
+}
+
+func (s *BoltLogStore) Range(fn func(entry models.LogEntry) bool) error {
+	return s.scan(func(entry *models.LogEntry) bool {
+		return fn(*entry)
+	})
+}
+
+func (s *BoltLogStore) Stats() (LogStats, error) {
+	stats := newLogStats()
+	err := s.scan(func(entry *models.LogEntry) bool {
+		stats.add(entry)
+		return true
+	})
+	return stats, err
+}
+
+// scan обходит логи с неистекшим сроком хранения от новых к старым,
+// предварительно записав накопленные
+func (s *BoltLogStore) scan(fn func(entry *models.LogEntry) bool) error {
+	if err := s.flush(); err != nil {
+		return err
+	}
+	cutoff := time.Now().Add(-s.retention)
+	return s.db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket(bucketLogs).Cursor()
+		for k, v := c.Last(); k != nil; k, v = c.Prev() {
+			if s.retention > 0 && logTime(v).Before(cutoff) {
+				return nil
+			}
+			var entry models.LogEntry
+			if err := json.Unmarshal(v[8:], &entry); err != nil {
+				return err
+			}
+			if !fn(&entry) {
+				return nil
+			}
+		}
+		return nil
+	})
+}
+
+// Len возвращает число логов в файле и в очереди на запись, не больше емкости
+func (s *BoltLogStore) Len() int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return min(s.count+len(s.pending), s.capacity)
+}
+
+// Close останавливает фоновую запись, дописывает накопленные логи и закрывает файл
+func (s *BoltLogStore) Close() error {
+	close(s.done)
+	<-s.stopped
+	err := s.flush()
+	if closeErr := s.db.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+func logKey(seq uint64) []byte {
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint64(key, seq)
+	return key
+}
+
+func logTime(value []byte) time.Time {
+	return time.Unix(0, int64(binary.BigEndian.Uint64(value)))
+}
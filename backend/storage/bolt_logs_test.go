@@ -0,0 +1,181 @@
+package storage
+
+import (
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"log-metrics-simulator/models"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+func testLogs(from, n int) []models.LogEntry {
+	entries := make([]models.LogEntry, n)
+	for i := range entries {
+		entries[i] = models.LogEntry{
+			Message: "log-" + strconv.Itoa(from+i),
+			Service: "order-service",
+			Level:   "INFO",
+			Status:  200,
+		}
+	}
+	return entries
+}
+
+// storedCount возвращает число логов, уже записанных в файл
+func storedCount(t *testing.T, s *BoltLogStore) int {
+	t.Helper()
+	var n int
+	err := s.db.View(func(tx *bolt.Tx) error {
+		n = tx.Bucket(bucketLogs).Stats().KeyN
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
+
+func TestBoltLogStoreWritesInBackground(t *testing.T) {
+	s, err := NewBoltLogStore(filepath.Join(t.TempDir(), "logs.db"), 100, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	if err := s.Append(testLogs(0, 3)); err != nil {
+		t.Fatal(err)
+	}
+	if s.Len() != 3 {
+		t.Errorf("Len = %d, ожидалось 3", s.Len())
+	}
+
+	deadline := time.Now().Add(5 * logFlushInterval)
+	for storedCount(t, s) != 3 {
+		if time.Now().After(deadline) {
+			t.Fatal("логи не записаны фоновым писателем")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestBoltLogStoreQuerySeesPending(t *testing.T) {
+	s, err := NewBoltLogStore(filepath.Join(t.TempDir(), "logs.db"), 5, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	if err := s.Append(testLogs(0, 4)); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Append(testLogs(4, 4)); err != nil {
+		t.Fatal(err)
+	}
+
+	// Чтение дописывает очередь, лишние логи удаляются при записи
+	logs, err := s.Query(LogQuery{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(logs) != 5 || logs[0].Message != "log-3" || logs[4].Message != "log-7" {
+		t.Fatalf("возвращены логи %v", logs)
+	}
+	if s.Len() != 5 || storedCount(t, s) != 5 {
+		t.Errorf("Len = %d, в файле %d, ожидалось 5", s.Len(), storedCount(t, s))
+	}
+
+	stats, err := s.Stats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Total != 5 || stats.Services["order-service"] != 5 {
+		t.Errorf("статистика %+v", stats)
+	}
+}
+
+func TestBoltLogStoreCloseFlushes(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "logs.db")
+	s, err := NewBoltLogStore(path, 100, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Append(testLogs(0, 10)); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// При меньшей емкости после перезапуска остаются последние логи
+	s, err = NewBoltLogStore(path, 4, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	if s.Len() != 4 {
+		t.Errorf("после перезапуска Len = %d, ожидалось 4", s.Len())
+	}
+	logs, err := s.Query(LogQuery{Limit: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(logs) != 1 || logs[0].Message != "log-9" {
+		t.Errorf("последний лог %v", logs)
+	}
+}
+
+func TestBoltLogStoreKeepsPendingOnFailedFlush(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "logs.db")
+	s, err := NewBoltLogStore(path, 4, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	if err := s.Append(testLogs(0, 3)); err != nil {
+		t.Fatal(err)
+	}
+
+	// Файл недоступен: транзакция записи завершается ошибкой
+	s.flushMutex.Lock()
+	if err := s.db.Close(); err != nil {
+		t.Fatal(err)
+	}
+	s.flushMutex.Unlock()
+	if err := s.flush(); err == nil {
+		t.Fatal("ожидалась ошибка записи в закрытый файл")
+	}
+	if err := s.Append(testLogs(3, 2)); err != nil {
+		t.Fatal(err)
+	}
+	if s.Len() != 4 {
+		t.Fatalf("после неудачной записи Len = %d, ожидалось 4", s.Len())
+	}
+
+	s.flushMutex.Lock()
+	s.db, err = bolt.Open(path, 0o600, &bolt.Options{Timeout: 5 * time.Second})
+	s.flushMutex.Unlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Неудачно записанные логи сохраняются первыми, самый старый вытеснен емкостью
+	logs, err := s.Query(LogQuery{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, entry := range logs {
+		got = append(got, entry.Message)
+	}
+	if want := "log-1 log-2 log-3 log-4"; strings.Join(got, " ") != want {
+		t.Fatalf("сохранены логи %v, ожидались %s", got, want)
+	}
+	if storedCount(t, s) != 4 {
+		t.Errorf("в файле %d логов, ожидалось 4", storedCount(t, s))
+	}
+}
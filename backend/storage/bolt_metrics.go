@@ -0,0 +1,90 @@
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"log-metrics-simulator/models"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+var bucketMetricFamilies = []byte("metric_families")
+
+// BoltMetricStore хранит снимок метрик в файле bbolt, поэтому счетчики и
+// гистограммы переживают перезапуск. Ключ - имя семейства, значение -
+// семейство в JSON. Снимок заменяется целиком одной транзакцией.
+type BoltMetricStore struct {
+	metricLimits
+	db *bolt.DB
+}
+
+// NewBoltMetricStore открывает файл метрик по пути path, создавая его при необходимости
+func NewBoltMetricStore(path string, capacity int, retention time.Duration) (*BoltMetricStore, error) {
+	if dir := filepath.Dir(path); dir != "" {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return nil, fmt.Errorf("ошибка создания каталога метрик: %v", err)
+		}
+	}
+	if capacity <= 0 {
+		capacity = DefaultMetricCapacity
+	}
+
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("ошибка открытия файла метрик %s: %v", path, err)
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(bucketMetricFamilies)
+		return err
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("ошибка открытия файла метрик %s: %v", path, err)
+	}
+	return &BoltMetricStore{metricLimits: metricLimits{capacity: capacity, retention: retention}, db: db}, nil
+}
+
+func (s *BoltMetricStore) Save(families []models.MetricFamily) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		if err := tx.DeleteBucket(bucketMetricFamilies); err != nil {
+			return err
+		}
+		b, err := tx.CreateBucket(bucketMetricFamilies)
+		if err != nil {
+			return err
+		}
+		for i := range families {
+			data, err := json.Marshal(&families[i])
+			if err != nil {
+				return err
+			}
+			if err := b.Put([]byte(families[i].Name), data); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (s *BoltMetricStore) Load() ([]models.MetricFamily, error) {
+	var families []models.MetricFamily
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucketMetricFamilies).ForEach(func(_, v []byte) error {
+			var family models.MetricFamily
+			if err := json.Unmarshal(v, &family); err != nil {
+				return err
+			}
+			families = append(families, family)
+			return nil
+		})
+	})
+	return families, err
+}
+
+func (s *BoltMetricStore) Close() error {
+	return s.db.Close()
+}
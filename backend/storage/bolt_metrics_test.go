@@ -0,0 +1,80 @@
+package storage
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"log-metrics-simulator/models"
+)
+
+func TestBoltMetricStoreSurvivesReopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "metrics.db")
+	s, err := NewBoltMetricStore(path, 0, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if capacity, retention := s.Limits(); capacity != DefaultMetricCapacity || retention != time.Hour {
+		t.Errorf("Limits = %d, %v", capacity, retention)
+	}
+
+	at := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	families := []models.MetricFamily{
+		{Name: "http_requests_total", Type: "counter", Help: "Requests", Series: []models.MetricSeries{
+			{Labels: map[string]string{"service": "api"}, Value: 42, Created: at, Timestamp: at},
+		}},
+		{Name: "request_duration_ms", Type: "histogram", Help: "Duration", Series: []models.MetricSeries{
+			{Labels: map[string]string{"service": "api"}, Created: at, Timestamp: at,
+				Bounds: []float64{10, 100}, BucketCounts: []uint64{1, 2, 3}, Sum: 512, Count: 6},
+		}},
+	}
+	if err := s.Save(families); err != nil {
+		t.Fatal(err)
+	}
+	// Новый снимок заменяет прежний целиком
+	if err := s.Save(families[1:]); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err = NewBoltMetricStore(path, 10, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	loaded, err := s.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(loaded, families[1:]) {
+		t.Fatalf("после перезапуска загружено %+v", loaded)
+	}
+}
+
+func TestNewMetricStore(t *testing.T) {
+	s, err := NewMetricStore(MetricStoreConfig{Retention: time.Minute})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := s.(*MemoryMetricStore); !ok {
+		t.Fatalf("без пути создано %T, ожидалось хранилище в памяти", s)
+	}
+	if capacity, retention := s.Limits(); capacity != DefaultMetricCapacity || retention != time.Minute {
+		t.Errorf("Limits = %d, %v", capacity, retention)
+	}
+
+	s, err = NewMetricStore(MetricStoreConfig{Path: filepath.Join(t.TempDir(), "metrics.db"), Capacity: 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	if _, ok := s.(*BoltMetricStore); !ok {
+		t.Fatalf("с путем создано %T, ожидалось хранилище в файле", s)
+	}
+	if capacity, _ := s.Limits(); capacity != 5 {
+		t.Errorf("емкость %d, ожидалось 5", capacity)
+	}
+}
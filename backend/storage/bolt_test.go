@@ -0,0 +1,293 @@
+package storage
+
+import (
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"log-metrics-simulator/models"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+func newTestBolt(t *testing.T, path string) *BoltStorage {
+	t.Helper()
+	s, err := NewBoltStorage(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+// schemaVersion читает версию схемы из закрытой базы
+func schemaVersion(t *testing.T, path string) int {
+	t.Helper()
+	db, err := bolt.Open(path, 0o600, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	var version int
+	db.View(func(tx *bolt.Tx) error {
+		version, _ = strconv.Atoi(string(tx.Bucket(bucketMeta).Get(keySchemaVersion)))
+		return nil
+	})
+	return version
+}
+
+func executionIDs[T any](items []*T, id func(*T) string) []string {
+	ids := make([]string, len(items))
+	for i, item := range items {
+		ids[i] = id(item)
+	}
+	return ids
+}
+
+func scheduleExecutionID(e *models.ScheduleExecution) string { return e.ID }
+func chainExecutionID(e *models.ChainExecution) string       { return e.ID }
+
+func TestBoltSurvivesReopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "data", "simulator.db")
+	s, err := NewBoltStorage(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	seed := int64(9007199254740993)
+	startDate := testTime(time.Hour)
+	scenario := &models.Scenario{
+		Type:   "load_test",
+		Active: true,
+		Config: models.ScenarioConfig{
+			Name:       "Load Test",
+			LogCount:   1000,
+			Labels:     map[string]string{"env": "test"},
+			Parameters: map[string]interface{}{"preset": "high_load"},
+			Seed:       &seed,
+		},
+		Started:   testTime(0),
+		Interval:  5 * time.Second,
+		StartDate: &startDate,
+	}
+	schedule := &models.Schedule{ID: "nightly", ScenarioType: "load_test", CronExpr: "0 3 * * *", Enabled: true, CreatedAt: testTime(0)}
+	chain := &models.ScenarioChain{
+		ID:        "rush",
+		Name:      "rush",
+		Steps:     []models.ChainStep{{ScenarioType: "load_test"}, {ScenarioType: "error_spike", DelayBefore: 30}},
+		Status:    "pending",
+		CreatedAt: testTime(0),
+	}
+	chainSchedule := &models.ChainSchedule{ID: "weekly", ChainName: "rush", CronExpr: "0 0 * * 1", CreatedAt: testTime(0)}
+	execution := &models.ScheduleExecution{ID: "nightly-1", ScheduleID: "nightly", Status: "completed", StartedAt: testTime(time.Minute)}
+	chainExecution := &models.ChainExecution{
+		ID:        "rush-1",
+		ChainID:   "rush",
+		Status:    "running",
+		StartedAt: testTime(time.Minute),
+		Steps:     []models.ChainExecutionStep{{StepIndex: 0, ScenarioType: "load_test", Status: "running"}},
+	}
+	for _, save := range []error{
+		s.SaveScenario(scenario),
+		s.SaveSchedule(schedule),
+		s.SaveChain(chain),
+		s.SaveChainSchedule(chainSchedule),
+		s.SaveExecution(execution),
+		s.SaveChainExecution(chainExecution),
+	} {
+		if save != nil {
+			t.Fatal(save)
+		}
+	}
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Повторное открытие не применяет миграции заново и видит все записи
+	s = newTestBolt(t, path)
+	scenarios, err := s.GetActiveScenarios()
+	if err != nil || len(scenarios) != 1 {
+		t.Fatalf("активных сценариев %d: %v", len(scenarios), err)
+	}
+	got := scenarios[0]
+	if *got.Config.Seed != seed || got.Config.Labels["env"] != "test" || got.Config.Parameters["preset"] != "high_load" {
+		t.Errorf("конфигурация %+v не совпадает с сохраненной", got.Config)
+	}
+	if !got.Started.Equal(scenario.Started) || got.Interval != scenario.Interval || !got.StartDate.Equal(startDate) {
+		t.Errorf("сценарий %+v не совпадает с сохраненным", got)
+	}
+	if got, err := s.GetSchedule("nightly"); err != nil || got == nil || got.CronExpr != schedule.CronExpr {
+		t.Errorf("расписание %+v: %v", got, err)
+	}
+	if got, err := s.GetChain("rush"); err != nil || got == nil || len(got.Steps) != 2 || got.Steps[1].DelayBefore != 30 {
+		t.Errorf("цепочка %+v: %v", got, err)
+	}
+	if got, err := s.GetChainSchedule("weekly"); err != nil || got == nil || got.ChainName != "rush" {
+		t.Errorf("расписание цепочки %+v: %v", got, err)
+	}
+	if got, err := s.GetChainExecution("rush-1"); err != nil || got == nil || len(got.Steps) != 1 {
+		t.Errorf("выполнение цепочки %+v: %v", got, err)
+	}
+	if executions, err := s.GetExecutions("nightly", 0); err != nil || len(executions) != 1 {
+		t.Errorf("выполнений расписания %d: %v", len(executions), err)
+	}
+
+	// Остановленный сценарий не активен, удаленные записи не находятся
+	scenario.Active = false
+	if err := s.UpdateScenario(scenario); err != nil {
+		t.Fatal(err)
+	}
+	if scenarios, err := s.GetActiveScenarios(); err != nil || len(scenarios) != 0 {
+		t.Errorf("после остановки активных сценариев %d: %v", len(scenarios), err)
+	}
+	if err := s.DeleteSchedule("nightly"); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := s.GetSchedule("nightly"); err != nil || got != nil {
+		t.Errorf("удаленное расписание найдено: %v, %v", got, err)
+	}
+	if executions, err := s.GetExecutions("nightly", 0); err != nil || len(executions) != 1 {
+		t.Errorf("после удаления расписания выполнений %d: %v", len(executions), err)
+	}
+}
+
+func TestBoltMigratesVersion1(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "simulator.db")
+
+	// База версии 1: выполнения без индексов
+	db, err := bolt.Open(path, 0o600, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		if err := createBuckets(tx); err != nil {
+			return err
+		}
+		meta, err := tx.CreateBucketIfNotExists(bucketMeta)
+		if err != nil {
+			return err
+		}
+		if err := meta.Put(keySchemaVersion, []byte("1")); err != nil {
+			return err
+		}
+		for i := 0; i < 3; i++ {
+			id := "nightly-" + strconv.Itoa(i)
+			execution := models.ScheduleExecution{ID: id, ScheduleID: "nightly", Status: "completed", StartedAt: testTime(time.Duration(i) * time.Hour)}
+			if err := put(tx, bucketExecutions, id, execution); err != nil {
+				return err
+			}
+		}
+		chainExecution := models.ChainExecution{ID: "rush-0", ChainID: "rush", Status: "completed", StartedAt: testTime(0)}
+		return put(tx, bucketChainExecutions, chainExecution.ID, chainExecution)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+
+	s, err := NewBoltStorage(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	executions, err := s.GetExecutions("nightly", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := executionIDs(executions, scheduleExecutionID); len(got) != 3 || got[0] != "nightly-2" || got[2] != "nightly-0" {
+		t.Errorf("выполнения после миграции %v, ожидались новые сначала", got)
+	}
+	chainExecutions, err := s.GetChainExecutions("rush", 0)
+	if err != nil || len(chainExecutions) != 1 {
+		t.Errorf("выполнений цепочки после миграции %d: %v", len(chainExecutions), err)
+	}
+	s.Close()
+
+	if version := schemaVersion(t, path); version != boltMigrations[len(boltMigrations)-1].version {
+		t.Errorf("версия схемы %d после миграции", version)
+	}
+}
+
+func TestBoltRejectsNewerSchema(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "simulator.db")
+	s, err := NewBoltStorage(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucketMeta).Put(keySchemaVersion, []byte("99"))
+	})
+	s.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if s, err := NewBoltStorage(path); err == nil {
+		s.Close()
+		t.Fatal("открыта база с более новой схемой")
+	}
+}
+
+func TestBoltExecutionsIndex(t *testing.T) {
+	s := newTestBolt(t, filepath.Join(t.TempDir(), "simulator.db"))
+
+	// Родители подобраны так, чтобы их ключи шли вплотную: "a/" < "a0/" < "ab/",
+	// а "z" - последний ключ индекса
+	for _, parent := range []string{"a", "a0", "ab", "z"} {
+		for i := 0; i < 3; i++ {
+			execution := &models.ChainExecution{
+				ID:        parent + "-" + strconv.Itoa(i),
+				ChainID:   parent,
+				StartedAt: testTime(time.Duration(i) * time.Minute),
+			}
+			if err := s.SaveChainExecution(execution); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	for _, parent := range []string{"a", "a0", "ab", "z"} {
+		executions, err := s.GetChainExecutions(parent, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := executionIDs(executions, chainExecutionID)
+		want := []string{parent + "-2", parent + "-1", parent + "-0"}
+		if len(got) != 3 || got[0] != want[0] || got[1] != want[1] || got[2] != want[2] {
+			t.Errorf("%s: выполнения %v, ожидалось %v", parent, got, want)
+		}
+	}
+	for _, parent := range []string{"", "0", "b", "zz"} {
+		if executions, err := s.GetChainExecutions(parent, 0); err != nil || len(executions) != 0 {
+			t.Errorf("%q: найдено выполнений %d: %v", parent, len(executions), err)
+		}
+	}
+	if executions, err := s.GetChainExecutions("a", 2); err != nil || len(executions) != 2 || executions[0].ID != "a-2" {
+		t.Errorf("с limit 2 возвращено %v: %v", executionIDs(executions, chainExecutionID), err)
+	}
+
+	// Обновление с новым временем начала переносит запись индекса, а не дублирует ее
+	moved := &models.ChainExecution{ID: "a-0", ChainID: "a", Status: "completed", StartedAt: testTime(time.Hour)}
+	if err := s.UpdateChainExecution(moved); err != nil {
+		t.Fatal(err)
+	}
+	executions, err := s.GetChainExecutions("a", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := executionIDs(executions, chainExecutionID); len(got) != 3 || got[0] != "a-0" || executions[0].Status != "completed" {
+		t.Errorf("после обновления выполнения %v", got)
+	}
+
+	execution := &models.ScheduleExecution{ID: "run", ScheduleID: "nightly", StartedAt: testTime(0)}
+	if err := s.SaveExecution(execution); err != nil {
+		t.Fatal(err)
+	}
+	execution.StartedAt = testTime(time.Hour)
+	if err := s.SaveExecution(execution); err != nil {
+		t.Fatal(err)
+	}
+	if executions, err := s.GetExecutions("nightly", 0); err != nil || len(executions) != 1 {
+		t.Errorf("после повторного сохранения выполнений %d: %v", len(executions), err)
+	}
+}
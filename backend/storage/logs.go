@@ -0,0 +1,321 @@
+package storage
+
+import (
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"log-metrics-simulator/models"
+)
+
+// DefaultLogCapacity - сколько логов хранится, если емкость не задана
+const DefaultLogCapacity = 50000
+
+// LogStoreConfig задает хранилище сгенерированных логов
+type LogStoreConfig struct {
+	Path      string        // Файл базы bbolt; пусто - логи только в памяти
+	Capacity  int           // Сколько последних логов хранить; 0 - DefaultLogCapacity
+	Retention time.Duration // Сколько хранить логи с момента записи; 0 - без ограничения
+}
+
+// LogQuery - условия выборки логов; пустые поля не фильтруют
+type LogQuery struct {
+	Limit   int // Сколько последних подходящих логов вернуть; 0 - все
+	Service string
+	Level   string
+	Status  int // 0 - любой статус
+	TraceID string
+}
+
+func (q LogQuery) matches(entry *models.LogEntry) bool {
+	return (q.Service == "" || entry.Service == q.Service) &&
+		(q.Level == "" || entry.Level == q.Level) &&
+		(q.Status == 0 || entry.Status == q.Status) &&
+		(q.TraceID == "" || entry.TraceID == q.TraceID)
+}
+
+// LogStats - число хранимых логов в разрезе сервисов, уровней и статусов
+type LogStats struct {
+	Total    int
+	Services map[string]int
+	Levels   map[string]int
+	Statuses map[int]int
+}
+
+func newLogStats() LogStats {
+	return LogStats{
+		Services: make(map[string]int),
+		Levels:   make(map[string]int),
+		Statuses: make(map[int]int),
+	}
+}
+
+func (st *LogStats) add(entry *models.LogEntry) {
+	st.Total++
+	st.Services[entry.Service]++
+	st.Levels[entry.Level]++
+	st.Statuses[entry.Status]++
+}
+
+// LogStore хранит сгенерированные логи для выдачи через API. Срок хранения
+// отсчитывается от момента записи, а не от Timestamp лога: при ускорении
+// времени и догрузке истории метки логов уходят в прошлое и будущее.
+type LogStore interface {
+	Append(entries []models.LogEntry) error
+	// Query возвращает последние подходящие логи в порядке записи
+	Query(q LogQuery) ([]models.LogEntry, error)
+	// Range обходит логи от новых к старым, пока fn возвращает true
+	Range(fn func(entry models.LogEntry) bool) error
+	Stats() (LogStats, error)
+	Len() int
+	Close() error
+}
+
+// NewLogStore создает хранилище логов: с путем - в файле на диске, иначе в памяти
+func NewLogStore(cfg LogStoreConfig) (LogStore, error) {
+	if cfg.Capacity <= 0 {
+		cfg.Capacity = DefaultLogCapacity
+	}
+	if cfg.Path != "" {
+		return NewBoltLogStore(cfg.Path, cfg.Capacity, cfg.Retention)
+	}
+	return NewMemoryLogStore(cfg.Capacity, cfg.Retention), nil
+}
+
+// storedLog - лог с моментом записи в хранилище
+type storedLog struct {
+	at    time.Time
+	entry models.LogEntry
+}
+
+// postings - номера логов с одним значением поля, по возрастанию
+type postings []uint64
+
+// MemoryLogStore хранит последние capacity логов в памяти процесса в
+// кольцевом буфере. Индексы по сервису, уровню, статусу и trace ID хранят
+// номера записей, поэтому выборка с фильтром просматривает только логи из
+// самого короткого подходящего индекса, а статистика считается по длинам
+// индексов. Логи вытесняются в порядке записи, поэтому номер вытесняемого
+// лога всегда первый в своих индексах и запись не сдвигает остальные.
+type MemoryLogStore struct {
+	ring      []storedLog
+	next      uint64 // Номер следующей записи
+	first     uint64 // Номер самого старого хранимого лога
+	capacity  int
+	retention time.Duration
+
+	byService map[string]postings
+	byLevel   map[string]postings
+	byStatus  map[int]postings
+	byTrace   map[string]postings
+
+	mutex sync.RWMutex
+}
+
+func NewMemoryLogStore(capacity int, retention time.Duration) *MemoryLogStore {
+	if capacity <= 0 {
+		capacity = DefaultLogCapacity
+	}
+	return &MemoryLogStore{
+		capacity:  capacity,
+		retention: retention,
+		byService: make(map[string]postings),
+		byLevel:   make(map[string]postings),
+		byStatus:  make(map[int]postings),
+		byTrace:   make(map[string]postings),
+	}
+}
+
+// Сколько логов записывается за одно взятие блокировки: между частями
+// большой пачки успевают выполниться чтения
+const memoryAppendChunk = 1024
+
+// Append готовит записи вне блокировки и переносит их в буфер частями по
+// memoryAppendChunk, чтобы запись большой пачки не останавливала выборки
+func (s *MemoryLogStore) Append(entries []models.LogEntry) error {
+	// Из пачки больше емкости сохранятся только последние логи
+	if len(entries) > s.capacity {
+		entries = entries[len(entries)-s.capacity:]
+	}
+	now := time.Now()
+	batch := make([]storedLog, len(entries))
+	for i := range entries {
+		batch[i] = storedLog{at: now, entry: entries[i]}
+	}
+
+	for len(batch) > 0 {
+		n := min(len(batch), memoryAppendChunk)
+		s.insert(batch[:n])
+		batch = batch[n:]
+	}
+
+	if s.retention > 0 {
+		s.mutex.Lock()
+		defer s.mutex.Unlock()
+
+		cutoff := now.Add(-s.retention)
+		for s.first < s.next && s.slot(s.first).at.Before(cutoff) {
+			s.evict()
+		}
+	}
+	return nil
+}
+
+// insert записывает подготовленные логи в буфер и индексы, вытесняя самые старые
+func (s *MemoryLogStore) insert(batch []storedLog) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for i := range batch {
+		if s.next-s.first == uint64(s.capacity) {
+			s.evict()
+		}
+		// Буфер растет до емкости по мере записи, затем перезаписывается по кругу
+		if len(s.ring) < s.capacity {
+			s.ring = append(s.ring, batch[i])
+		} else {
+			*s.slot(s.next) = batch[i]
+		}
+		s.index(s.next, &s.slot(s.next).entry)
+		s.next++
+	}
+}
+
+func (s *MemoryLogStore) slot(seq uint64) *storedLog {
+	return &s.ring[seq%uint64(s.capacity)]
+}
+
+func (s *MemoryLogStore) index(seq uint64, entry *models.LogEntry) {
+	s.byService[entry.Service] = append(s.byService[entry.Service], seq)
+	s.byLevel[entry.Level] = append(s.byLevel[entry.Level], seq)
+	s.byStatus[entry.Status] = append(s.byStatus[entry.Status], seq)
+	if entry.TraceID != "" {
+		s.byTrace[entry.TraceID] = append(s.byTrace[entry.TraceID], seq)
+	}
+}
+
+// evict вытесняет самый старый лог и убирает его номер из начала индексов
+func (s *MemoryLogStore) evict() {
+	stored := s.slot(s.first)
+	popPosting(s.byService, stored.entry.Service)
+	popPosting(s.byLevel, stored.entry.Level)
+	popPosting(s.byStatus, stored.entry.Status)
+	if stored.entry.TraceID != "" {
+		popPosting(s.byTrace, stored.entry.TraceID)
+	}
+	*stored = storedLog{}
+	s.first++
+}
+
+func popPosting[K comparable](index map[K]postings, key K) {
+	if list := index[key]; len(list) > 1 {
+		index[key] = list[1:]
+	} else {
+		delete(index, key)
+	}
+}
+
+func (s *MemoryLogStore) Query(q LogQuery) ([]models.LogEntry, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	live := s.firstLive()
+	var result []models.LogEntry
+	candidates, filtered := s.candidates(q)
+	if !filtered {
+		for seq := s.next; seq > live && (q.Limit <= 0 || len(result) < q.Limit); seq-- {
+			result = append(result, s.slot(seq-1).entry)
+		}
+	} else {
+		for i := len(candidates) - 1; i >= 0 && candidates[i] >= live && (q.Limit <= 0 || len(result) < q.Limit); i-- {
+			if entry := &s.slot(candidates[i]).entry; q.matches(entry) {
+				result = append(result, *entry)
+			}
+		}
+	}
+	slices.Reverse(result)
+	return result, nil
+}
+
+// candidates возвращает самый короткий индекс среди заданных в запросе
+// фильтров; filtered - задан ли хоть один фильтр
+func (s *MemoryLogStore) candidates(q LogQuery) (best postings, filtered bool) {
+	consider := func(list postings) {
+		if !filtered || len(list) < len(best) {
+			best = list
+		}
+		filtered = true
+	}
+	if q.Service != "" {
+		consider(s.byService[q.Service])
+	}
+	if q.Level != "" {
+		consider(s.byLevel[q.Level])
+	}
+	if q.Status != 0 {
+		consider(s.byStatus[q.Status])
+	}
+	if q.TraceID != "" {
+		consider(s.byTrace[q.TraceID])
+	}
+	return best, filtered
+}
+
+func (s *MemoryLogStore) Range(fn func(entry models.LogEntry) bool) error {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	live := s.firstLive()
+	for seq := s.next; seq > live; seq-- {
+		if !fn(s.slot(seq - 1).entry) {
+			break
+		}
+	}
+	return nil
+}
+
+func (s *MemoryLogStore) Stats() (LogStats, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	live := s.firstLive()
+	stats := newLogStats()
+	stats.Total = int(s.next - live)
+	countPostings(s.byService, live, stats.Services)
+	countPostings(s.byLevel, live, stats.Levels)
+	countPostings(s.byStatus, live, stats.Statuses)
+	return stats, nil
+}
+
+// countPostings записывает в counts число номеров не меньше live по каждому ключу
+func countPostings[K comparable](index map[K]postings, live uint64, counts map[K]int) {
+	for key, list := range index {
+		stale := sort.Search(len(list), func(i int) bool { return list[i] >= live })
+		if n := len(list) - stale; n > 0 {
+			counts[key] = n
+		}
+	}
+}
+
+func (s *MemoryLogStore) Len() int {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return int(s.next - s.firstLive())
+}
+
+// firstLive возвращает номер первого лога, срок хранения которого не истек;
+// устаревшие логи вытесняются при следующей записи. Вызывается под mutex.
+func (s *MemoryLogStore) firstLive() uint64 {
+	if s.retention <= 0 {
+		return s.first
+	}
+	cutoff := time.Now().Add(-s.retention)
+	return s.first + uint64(sort.Search(int(s.next-s.first), func(i int) bool {
+		return !s.slot(s.first + uint64(i)).at.Before(cutoff)
+	}))
+}
+
+func (s *MemoryLogStore) Close() error {
+	return nil
+}
@@ -0,0 +1,183 @@
+package storage
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"log-metrics-simulator/models"
+)
+
+// mixedLogs возвращает логи log-<from>..; четные - cart-service INFO 200,
+// нечетные - payment-service ERROR 500, каждая пара логов - одна трассировка
+func mixedLogs(from, n int) []models.LogEntry {
+	entries := testLogs(from, n)
+	for i := range entries {
+		seq := from + i
+		if seq%2 == 1 {
+			entries[i].Service, entries[i].Level, entries[i].Status = "payment-service", "ERROR", 500
+		} else {
+			entries[i].Service = "cart-service"
+		}
+		entries[i].TraceID = "trace-" + strconv.Itoa(seq/2)
+	}
+	return entries
+}
+
+func messages(entries []models.LogEntry) string {
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Message)
+	}
+	return strings.Join(names, " ")
+}
+
+func query(t *testing.T, s LogStore, q LogQuery) string {
+	t.Helper()
+	logs, err := s.Query(q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return messages(logs)
+}
+
+func TestMemoryLogStoreWrapsAround(t *testing.T) {
+	s := NewMemoryLogStore(5, 0)
+	for from := 0; from < 12; from += 4 {
+		if err := s.Append(mixedLogs(from, 4)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if s.Len() != 5 || len(s.ring) != 5 {
+		t.Fatalf("Len = %d, буфер %d, ожидалось 5", s.Len(), len(s.ring))
+	}
+	if got, want := query(t, s, LogQuery{}), "log-7 log-8 log-9 log-10 log-11"; got != want {
+		t.Errorf("Query = %s, ожидалось %s", got, want)
+	}
+	if got, want := query(t, s, LogQuery{Limit: 2}), "log-10 log-11"; got != want {
+		t.Errorf("Query с Limit = %s, ожидалось %s", got, want)
+	}
+
+	var ranged []models.LogEntry
+	err := s.Range(func(entry models.LogEntry) bool {
+		ranged = append(ranged, entry)
+		return len(ranged) < 3
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := messages(ranged), "log-11 log-10 log-9"; got != want {
+		t.Errorf("Range = %s, ожидалось %s", got, want)
+	}
+}
+
+func TestMemoryLogStoreQueriesAfterEviction(t *testing.T) {
+	s := NewMemoryLogStore(5, 0)
+	if err := s.Append(mixedLogs(0, 12)); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		query LogQuery
+		want  string
+	}{
+		{"сервис", LogQuery{Service: "cart-service"}, "log-8 log-10"},
+		{"уровень", LogQuery{Level: "ERROR"}, "log-7 log-9 log-11"},
+		{"статус с лимитом", LogQuery{Status: 500, Limit: 1}, "log-11"},
+		{"несколько фильтров", LogQuery{Service: "payment-service", Status: 200}, ""},
+		{"трассировка частично вытеснена", LogQuery{TraceID: "trace-3"}, "log-7"},
+		{"трассировка вытеснена", LogQuery{TraceID: "trace-1"}, ""},
+		{"неизвестный сервис", LogQuery{Service: "auth-service"}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := query(t, s, tt.query); got != tt.want {
+				t.Errorf("Query = %q, ожидалось %q", got, tt.want)
+			}
+		})
+	}
+
+	// Номера вытесненных логов убраны из индексов
+	if _, ok := s.byTrace["trace-1"]; ok {
+		t.Error("индекс вытесненной трассировки не удален")
+	}
+	if got := len(s.byService["payment-service"]); got != 3 {
+		t.Errorf("в индексе сервиса %d номеров, ожидалось 3", got)
+	}
+
+	stats, err := s.Stats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Total != 5 || stats.Services["cart-service"] != 2 || stats.Levels["ERROR"] != 3 || stats.Statuses[200] != 2 {
+		t.Errorf("статистика %+v", stats)
+	}
+}
+
+func TestMemoryLogStoreLargeBatch(t *testing.T) {
+	s := NewMemoryLogStore(3*memoryAppendChunk, 0)
+	if err := s.Append(mixedLogs(0, memoryAppendChunk)); err != nil {
+		t.Fatal(err)
+	}
+	// Пачка больше емкости записывается частями, сохраняются последние логи
+	if err := s.Append(mixedLogs(memoryAppendChunk, 4*memoryAppendChunk)); err != nil {
+		t.Fatal(err)
+	}
+
+	if s.Len() != 3*memoryAppendChunk {
+		t.Fatalf("Len = %d, ожидалось %d", s.Len(), 3*memoryAppendChunk)
+	}
+	logs, err := s.Query(LogQuery{Service: "cart-service"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	first := "log-" + strconv.Itoa(2*memoryAppendChunk)
+	if len(logs) != 3*memoryAppendChunk/2 || logs[0].Message != first {
+		t.Fatalf("выбрано %d логов, первый %s, ожидалось %d с %s", len(logs), logs[0].Message, 3*memoryAppendChunk/2, first)
+	}
+}
+
+func TestMemoryLogStoreRetention(t *testing.T) {
+	s := NewMemoryLogStore(10, time.Hour)
+	if err := s.Append(mixedLogs(0, 6)); err != nil {
+		t.Fatal(err)
+	}
+	// Первые четыре лога записаны больше часа назад
+	for seq := uint64(0); seq < 4; seq++ {
+		s.slot(seq).at = time.Now().Add(-2 * time.Hour)
+	}
+
+	// Устаревшие логи не видны сразу, хотя вытесняются только при записи
+	if s.Len() != 2 {
+		t.Errorf("Len = %d, ожидалось 2", s.Len())
+	}
+	if got, want := query(t, s, LogQuery{}), "log-4 log-5"; got != want {
+		t.Errorf("Query = %s, ожидалось %s", got, want)
+	}
+	if got, want := query(t, s, LogQuery{Service: "payment-service"}), "log-5"; got != want {
+		t.Errorf("Query по сервису = %s, ожидалось %s", got, want)
+	}
+	stats, err := s.Stats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Total != 2 || stats.Services["cart-service"] != 1 || stats.Statuses[500] != 1 {
+		t.Errorf("статистика %+v", stats)
+	}
+
+	if err := s.Append(mixedLogs(6, 1)); err != nil {
+		t.Fatal(err)
+	}
+	if s.first != 4 {
+		t.Errorf("первый хранимый номер %d, ожидалось 4", s.first)
+	}
+	if _, ok := s.byTrace["trace-0"]; ok {
+		t.Error("индекс устаревшей трассировки не удален")
+	}
+	if got, want := query(t, s, LogQuery{}), "log-4 log-5 log-6"; got != want {
+		t.Errorf("Query = %s, ожидалось %s", got, want)
+	}
+}
@@ -0,0 +1,86 @@
+package storage
+
+import (
+	"sync"
+	"time"
+
+	"log-metrics-simulator/models"
+)
+
+// DefaultMetricCapacity - сколько серий метрик хранится, если емкость не задана
+const DefaultMetricCapacity = 100000
+
+// MetricStoreConfig задает хранилище состояния метрик
+type MetricStoreConfig struct {
+	Path      string        // Файл базы bbolt; пусто - метрики только в памяти
+	Capacity  int           // Сколько серий хранить; 0 - DefaultMetricCapacity
+	Retention time.Duration // Сколько хранить серию с последнего обновления; 0 - без ограничения
+}
+
+// MetricStore хранит состояние реестра метрик генератора. Реестр обновляется
+// в памяти, а хранилище получает его снимки и отдает последний снимок при
+// запуске, поэтому счетчики продолжают расти после перезапуска. Емкость и
+// срок хранения соблюдает реестр: хранилище сообщает их через Limits.
+type MetricStore interface {
+	// Save заменяет сохраненное состояние снимком families
+	Save(families []models.MetricFamily) error
+	// Load возвращает последний сохраненный снимок
+	Load() ([]models.MetricFamily, error)
+	// Limits возвращает емкость в сериях и срок хранения серии
+	Limits() (capacity int, retention time.Duration)
+	Close() error
+}
+
+// NewMetricStore создает хранилище метрик: с путем - в файле на диске, иначе в памяти
+func NewMetricStore(cfg MetricStoreConfig) (MetricStore, error) {
+	if cfg.Capacity <= 0 {
+		cfg.Capacity = DefaultMetricCapacity
+	}
+	if cfg.Path != "" {
+		return NewBoltMetricStore(cfg.Path, cfg.Capacity, cfg.Retention)
+	}
+	return NewMemoryMetricStore(cfg.Capacity, cfg.Retention), nil
+}
+
+// metricLimits - емкость и срок хранения серий
+type metricLimits struct {
+	capacity  int
+	retention time.Duration
+}
+
+func (l metricLimits) Limits() (int, time.Duration) {
+	return l.capacity, l.retention
+}
+
+// MemoryMetricStore хранит последний снимок метрик в памяти процесса:
+// метрики не переживают перезапуск, но ограничены емкостью и сроком хранения
+type MemoryMetricStore struct {
+	metricLimits
+
+	mutex    sync.RWMutex
+	families []models.MetricFamily
+}
+
+func NewMemoryMetricStore(capacity int, retention time.Duration) *MemoryMetricStore {
+	if capacity <= 0 {
+		capacity = DefaultMetricCapacity
+	}
+	return &MemoryMetricStore{metricLimits: metricLimits{capacity: capacity, retention: retention}}
+}
+
+func (s *MemoryMetricStore) Save(families []models.MetricFamily) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.families = families
+	return nil
+}
+
+func (s *MemoryMetricStore) Load() ([]models.MetricFamily, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return s.families, nil
+}
+
+func (s *MemoryMetricStore) Close() error {
+	return nil
+}
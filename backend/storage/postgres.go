@@ -0,0 +1,608 @@
+package storage
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"log-metrics-simulator/models"
+
+	"github.com/lib/pq"
+)
+
+// Ключ advisory-блокировки, под которой реплики по очереди применяют миграции
+const migrationLockID = 0x5113a7
+
+// sqlMigration - шаг схемы базы; примененные версии записываются в schema_migrations
+type sqlMigration struct {
+	version     int
+	description string
+	statements  string
+}
+
+var postgresMigrations = []sqlMigration{
+	{1, "таблицы сценариев, расписаний, цепочек и выполнений", `
+		CREATE TABLE scenarios (
+			type            TEXT PRIMARY KEY,
+			active          BOOLEAN NOT NULL,
+			config          JSONB NOT NULL,
+			started         TIMESTAMPTZ NOT NULL,
+			duration_ns     BIGINT NOT NULL DEFAULT 0,
+			interval_ns     BIGINT NOT NULL DEFAULT 0,
+			start_date      TIMESTAMPTZ,
+			end_date        TIMESTAMPTZ,
+			simulated_start TIMESTAMPTZ
+		);
+
+		CREATE TABLE schedules (
+			id            TEXT PRIMARY KEY,
+			name          TEXT NOT NULL,
+			scenario_type TEXT NOT NULL,
+			cron_expr     TEXT NOT NULL,
+			enabled       BOOLEAN NOT NULL,
+			time_scale    DOUBLE PRECISION NOT NULL DEFAULT 0,
+			start_date    TIMESTAMPTZ,
+			end_date      TIMESTAMPTZ,
+			last_run      TIMESTAMPTZ,
+			next_run      TIMESTAMPTZ,
+			created_at    TIMESTAMPTZ NOT NULL
+		);
+
+		CREATE TABLE schedule_executions (
+			id            TEXT PRIMARY KEY,
+			schedule_id   TEXT NOT NULL REFERENCES schedules (id) ON DELETE CASCADE,
+			scenario_type TEXT NOT NULL,
+			status        TEXT NOT NULL,
+			started_at    TIMESTAMPTZ NOT NULL,
+			completed_at  TIMESTAMPTZ,
+			error         TEXT NOT NULL DEFAULT '',
+			logs_count    INTEGER NOT NULL DEFAULT 0
+		);
+
+		CREATE TABLE chains (
+			id           TEXT PRIMARY KEY,
+			name         TEXT NOT NULL,
+			description  TEXT NOT NULL DEFAULT '',
+			steps        JSONB NOT NULL,
+			status       TEXT NOT NULL,
+			time_scale   DOUBLE PRECISION NOT NULL DEFAULT 0,
+			started_at   TIMESTAMPTZ,
+			completed_at TIMESTAMPTZ,
+			created_at   TIMESTAMPTZ NOT NULL,
+			created_by   TEXT NOT NULL DEFAULT ''
+		);
+
+		CREATE TABLE chain_executions (
+			id           TEXT PRIMARY KEY,
+			chain_id     TEXT NOT NULL REFERENCES chains (id) ON DELETE CASCADE,
+			status       TEXT NOT NULL,
+			time_scale   DOUBLE PRECISION NOT NULL DEFAULT 0,
+			started_at   TIMESTAMPTZ NOT NULL,
+			completed_at TIMESTAMPTZ,
+			error        TEXT NOT NULL DEFAULT ''
+		);
+
+		CREATE TABLE chain_execution_steps (
+			execution_id  TEXT NOT NULL REFERENCES chain_executions (id) ON DELETE CASCADE,
+			step_index    INTEGER NOT NULL,
+			scenario_type TEXT NOT NULL,
+			status        TEXT NOT NULL,
+			started_at    TIMESTAMPTZ,
+			completed_at  TIMESTAMPTZ,
+			error         TEXT NOT NULL DEFAULT '',
+			PRIMARY KEY (execution_id, step_index)
+		);
+
+		CREATE TABLE chain_schedules (
+			id         TEXT PRIMARY KEY,
+			name       TEXT NOT NULL,
+			chain_name TEXT NOT NULL,
+			cron_expr  TEXT NOT NULL,
+			enabled    BOOLEAN NOT NULL,
+			time_scale DOUBLE PRECISION NOT NULL DEFAULT 0,
+			start_date TIMESTAMPTZ,
+			end_date   TIMESTAMPTZ,
+			last_run   TIMESTAMPTZ,
+			next_run   TIMESTAMPTZ,
+			created_at TIMESTAMPTZ NOT NULL
+		);`},
+	{2, "индексы истории выполнений по расписанию и цепочке", `
+		CREATE INDEX schedule_executions_by_schedule ON schedule_executions (schedule_id, started_at DESC);
+		CREATE INDEX chain_executions_by_chain ON chain_executions (chain_id, started_at DESC);
+		CREATE INDEX scenarios_active ON scenarios (type) WHERE active;`},
+	// История выполнений переживает удаление расписания или цепочки, как в
+	// хранилищах memory и bolt
+	{3, "история выполнений без каскадного удаления", `
+		ALTER TABLE schedule_executions DROP CONSTRAINT IF EXISTS schedule_executions_schedule_id_fkey;
+		ALTER TABLE chain_executions DROP CONSTRAINT IF EXISTS chain_executions_chain_id_fkey;
+		ALTER TABLE chain_execution_steps DROP CONSTRAINT IF EXISTS chain_execution_steps_execution_id_fkey;
+		ALTER TABLE chain_execution_steps ADD CONSTRAINT chain_execution_steps_execution_id_fkey
+			FOREIGN KEY (execution_id) REFERENCES chain_executions (id);`},
+	{4, "блокировки запусков расписаний и сценариев между репликами", `
+		CREATE TABLE run_locks (
+			key        TEXT PRIMARY KEY,
+			owner      TEXT NOT NULL,
+			expires_at TIMESTAMPTZ NOT NULL
+		);
+		CREATE INDEX run_locks_expires_at ON run_locks (expires_at);`},
+}
+
+// PostgresStorage хранит состояние менеджера сценариев в PostgreSQL, поэтому
+// несколько реплик симулятора могут работать с общими расписаниями и цепочками.
+// Какая реплика выполняет запуск, решают блокировки в таблице run_locks.
+type PostgresStorage struct {
+	db    *sql.DB
+	owner string // Идентификатор экземпляра в run_locks
+}
+
+// NewPostgresStorage подключается к базе по строке подключения dsn и применяет миграции
+func NewPostgresStorage(dsn string) (*PostgresStorage, error) {
+	if dsn == "" {
+		return nil, fmt.Errorf("не задана строка подключения к PostgreSQL")
+	}
+
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка подключения к PostgreSQL: %v", err)
+	}
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("ошибка подключения к PostgreSQL: %v", err)
+	}
+
+	s := &PostgresStorage{db: db, owner: instanceID()}
+	if err := s.migrate(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *PostgresStorage) migrate() error {
+	for _, m := range postgresMigrations {
+		err := s.inTx(func(tx *sql.Tx) error {
+			if _, err := tx.Exec(`SELECT pg_advisory_xact_lock($1)`, migrationLockID); err != nil {
+				return err
+			}
+			_, err := tx.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (
+				version     INTEGER PRIMARY KEY,
+				description TEXT NOT NULL,
+				applied_at  TIMESTAMPTZ NOT NULL DEFAULT now()
+			)`)
+			if err != nil {
+				return err
+			}
+
+			var applied bool
+			if err := tx.QueryRow(`SELECT EXISTS (SELECT 1 FROM schema_migrations WHERE version = $1)`, m.version).Scan(&applied); err != nil {
+				return err
+			}
+			if applied {
+				return nil
+			}
+
+			if _, err := tx.Exec(m.statements); err != nil {
+				return err
+			}
+			if _, err := tx.Exec(`INSERT INTO schema_migrations (version, description) VALUES ($1, $2)`, m.version, m.description); err != nil {
+				return err
+			}
+			log.Printf("🗄️ Применена миграция схемы %d: %s", m.version, m.description)
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("ошибка миграции %d (%s): %v", m.version, m.description, err)
+		}
+	}
+	return nil
+}
+
+// inTx выполняет fn в транзакции и откатывает ее при ошибке
+func (s *PostgresStorage) inTx(fn func(tx *sql.Tx) error) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// sqlLimit превращает неположительный limit в LIMIT NULL - без ограничения
+func sqlLimit(limit int) sql.NullInt64 {
+	return sql.NullInt64{Int64: int64(limit), Valid: limit > 0}
+}
+
+func (s *PostgresStorage) Close() error {
+	return s.db.Close()
+}
+
+// ===== Блокировки запусков =====
+
+// TryLock занимает ключ строкой run_locks: вставка или перехват истекшей
+// блокировки выполняются одним запросом, поэтому ключ достается одной реплике.
+// Истекшие блокировки других ключей удаляются заодно.
+func (s *PostgresStorage) TryLock(key string, ttl time.Duration) (bool, error) {
+	if _, err := s.db.Exec(`DELETE FROM run_locks WHERE expires_at < now()`); err != nil {
+		return false, err
+	}
+	result, err := s.db.Exec(`INSERT INTO run_locks (key, owner, expires_at)
+		VALUES ($1, $2, now() + $3::double precision * interval '1 millisecond')
+		ON CONFLICT (key) DO UPDATE SET owner = $2, expires_at = EXCLUDED.expires_at
+		WHERE run_locks.owner = $2 OR run_locks.expires_at < now()`,
+		key, s.owner, ttl.Milliseconds())
+	if err != nil {
+		return false, err
+	}
+	locked, err := result.RowsAffected()
+	return locked == 1, err
+}
+
+func (s *PostgresStorage) Unlock(key string) error {
+	_, err := s.db.Exec(`DELETE FROM run_locks WHERE key = $1 AND owner = $2`, key, s.owner)
+	return err
+}
+
+// ===== Сценарии =====
+
+const scenarioColumns = `type, active, config, started, duration_ns, interval_ns, start_date, end_date, simulated_start`
+
+func (s *PostgresStorage) SaveScenario(scenario *models.Scenario) error {
+	config, err := json.Marshal(scenario.Config)
+	if err != nil {
+		return err
+	}
+	// JSONB передается строкой: []byte lib/pq отправляет в двоичном формате
+	_, err = s.db.Exec(`INSERT INTO scenarios (`+scenarioColumns+`)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		ON CONFLICT (type) DO UPDATE SET active = $2, config = $3, started = $4, duration_ns = $5,
+			interval_ns = $6, start_date = $7, end_date = $8, simulated_start = $9`,
+		scenario.Type, scenario.Active, string(config), scenario.Started, int64(scenario.Duration), int64(scenario.Interval),
+		scenario.StartDate, scenario.EndDate, scenario.SimulatedStart)
+	return err
+}
+
+func (s *PostgresStorage) GetActiveScenarios() ([]*models.Scenario, error) {
+	rows, err := s.db.Query(`SELECT ` + scenarioColumns + ` FROM scenarios WHERE active`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var scenarios []*models.Scenario
+	for rows.Next() {
+		var scenario models.Scenario
+		var config []byte
+		var duration, interval int64
+		err := rows.Scan(&scenario.Type, &scenario.Active, &config, &scenario.Started, &duration, &interval,
+			&scenario.StartDate, &scenario.EndDate, &scenario.SimulatedStart)
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(config, &scenario.Config); err != nil {
+			return nil, err
+		}
+		scenario.Duration = time.Duration(duration)
+		scenario.Interval = time.Duration(interval)
+		scenarios = append(scenarios, &scenario)
+	}
+	return scenarios, rows.Err()
+}
+
+func (s *PostgresStorage) UpdateScenario(scenario *models.Scenario) error {
+	return s.SaveScenario(scenario)
+}
+
+func (s *PostgresStorage) DeleteScenario(scenarioType string) error {
+	_, err := s.db.Exec(`DELETE FROM scenarios WHERE type = $1`, scenarioType)
+	return err
+}
+
+// ===== Расписания сценариев =====
+
+const scheduleColumns = `id, name, scenario_type, cron_expr, enabled, time_scale, start_date, end_date, last_run, next_run, created_at`
+
+func (s *PostgresStorage) SaveSchedule(schedule *models.Schedule) error {
+	_, err := s.db.Exec(`INSERT INTO schedules (`+scheduleColumns+`)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+		ON CONFLICT (id) DO UPDATE SET name = $2, scenario_type = $3, cron_expr = $4, enabled = $5,
+			time_scale = $6, start_date = $7, end_date = $8, last_run = $9, next_run = $10`,
+		schedule.ID, schedule.Name, schedule.ScenarioType, schedule.CronExpr, schedule.Enabled, schedule.TimeScale,
+		schedule.StartDate, schedule.EndDate, schedule.LastRun, schedule.NextRun, schedule.CreatedAt)
+	return err
+}
+
+func scanSchedule(row interface{ Scan(...interface{}) error }) (*models.Schedule, error) {
+	var schedule models.Schedule
+	err := row.Scan(&schedule.ID, &schedule.Name, &schedule.ScenarioType, &schedule.CronExpr, &schedule.Enabled,
+		&schedule.TimeScale, &schedule.StartDate, &schedule.EndDate, &schedule.LastRun, &schedule.NextRun, &schedule.CreatedAt)
+	return &schedule, err
+}
+
+func (s *PostgresStorage) GetSchedules() ([]*models.Schedule, error) {
+	rows, err := s.db.Query(`SELECT ` + scheduleColumns + ` FROM schedules ORDER BY created_at`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var schedules []*models.Schedule
+	for rows.Next() {
+		schedule, err := scanSchedule(rows)
+		if err != nil {
+			return nil, err
+		}
+		schedules = append(schedules, schedule)
+	}
+	return schedules, rows.Err()
+}
+
+func (s *PostgresStorage) GetSchedule(id string) (*models.Schedule, error) {
+	schedule, err := scanSchedule(s.db.QueryRow(`SELECT `+scheduleColumns+` FROM schedules WHERE id = $1`, id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return schedule, err
+}
+
+func (s *PostgresStorage) UpdateSchedule(schedule *models.Schedule) error {
+	return s.SaveSchedule(schedule)
+}
+
+func (s *PostgresStorage) DeleteSchedule(id string) error {
+	_, err := s.db.Exec(`DELETE FROM schedules WHERE id = $1`, id)
+	return err
+}
+
+// ===== Выполнения расписаний =====
+
+func (s *PostgresStorage) SaveExecution(execution *models.ScheduleExecution) error {
+	_, err := s.db.Exec(`INSERT INTO schedule_executions
+			(id, schedule_id, scenario_type, status, started_at, completed_at, error, logs_count)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		ON CONFLICT (id) DO UPDATE SET status = $4, completed_at = $6, error = $7, logs_count = $8`,
+		execution.ID, execution.ScheduleID, execution.ScenarioType, execution.Status, execution.StartedAt,
+		execution.CompletedAt, execution.Error, execution.LogsCount)
+	return err
+}
+
+// GetExecutions возвращает последние limit выполнений расписания, новые сначала;
+// limit 0 - все выполнения
+func (s *PostgresStorage) GetExecutions(scheduleID string, limit int) ([]*models.ScheduleExecution, error) {
+	rows, err := s.db.Query(`SELECT id, schedule_id, scenario_type, status, started_at, completed_at, error, logs_count
+		FROM schedule_executions WHERE schedule_id = $1 ORDER BY started_at DESC LIMIT $2`, scheduleID, sqlLimit(limit))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var executions []*models.ScheduleExecution
+	for rows.Next() {
+		var execution models.ScheduleExecution
+		err := rows.Scan(&execution.ID, &execution.ScheduleID, &execution.ScenarioType, &execution.Status,
+			&execution.StartedAt, &execution.CompletedAt, &execution.Error, &execution.LogsCount)
+		if err != nil {
+			return nil, err
+		}
+		executions = append(executions, &execution)
+	}
+	return executions, rows.Err()
+}
+
+// ===== Цепочки =====
+
+const chainColumns = `id, name, description, steps, status, time_scale, started_at, completed_at, created_at, created_by`
+
+func (s *PostgresStorage) SaveChain(chain *models.ScenarioChain) error {
+	steps, err := json.Marshal(chain.Steps)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.Exec(`INSERT INTO chains (`+chainColumns+`)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		ON CONFLICT (id) DO UPDATE SET name = $2, description = $3, steps = $4, status = $5,
+			time_scale = $6, started_at = $7, completed_at = $8, created_by = $10`,
+		chain.ID, chain.Name, chain.Description, string(steps), chain.Status, chain.TimeScale,
+		chain.StartedAt, chain.CompletedAt, chain.CreatedAt, chain.CreatedBy)
+	return err
+}
+
+func scanChain(row interface{ Scan(...interface{}) error }) (*models.ScenarioChain, error) {
+	var chain models.ScenarioChain
+	var steps []byte
+	err := row.Scan(&chain.ID, &chain.Name, &chain.Description, &steps, &chain.Status, &chain.TimeScale,
+		&chain.StartedAt, &chain.CompletedAt, &chain.CreatedAt, &chain.CreatedBy)
+	if err != nil {
+		return nil, err
+	}
+	return &chain, json.Unmarshal(steps, &chain.Steps)
+}
+
+func (s *PostgresStorage) GetChains() ([]*models.ScenarioChain, error) {
+	rows, err := s.db.Query(`SELECT ` + chainColumns + ` FROM chains ORDER BY created_at`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var chains []*models.ScenarioChain
+	for rows.Next() {
+		chain, err := scanChain(rows)
+		if err != nil {
+			return nil, err
+		}
+		chains = append(chains, chain)
+	}
+	return chains, rows.Err()
+}
+
+func (s *PostgresStorage) GetChain(id string) (*models.ScenarioChain, error) {
+	chain, err := scanChain(s.db.QueryRow(`SELECT `+chainColumns+` FROM chains WHERE id = $1`, id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return chain, err
+}
+
+func (s *PostgresStorage) UpdateChain(chain *models.ScenarioChain) error {
+	return s.SaveChain(chain)
+}
+
+func (s *PostgresStorage) DeleteChain(id string) error {
+	_, err := s.db.Exec(`DELETE FROM chains WHERE id = $1`, id)
+	return err
+}
+
+// ===== Выполнения цепочек =====
+
+// SaveChainExecution сохраняет выполнение вместе с шагами в одной транзакции
+func (s *PostgresStorage) SaveChainExecution(execution *models.ChainExecution) error {
+	return s.inTx(func(tx *sql.Tx) error {
+		_, err := tx.Exec(`INSERT INTO chain_executions (id, chain_id, status, time_scale, started_at, completed_at, error)
+			VALUES ($1, $2, $3, $4, $5, $6, $7)
+			ON CONFLICT (id) DO UPDATE SET status = $3, time_scale = $4, completed_at = $6, error = $7`,
+			execution.ID, execution.ChainID, execution.Status, execution.TimeScale, execution.StartedAt,
+			execution.CompletedAt, execution.Error)
+		if err != nil {
+			return err
+		}
+
+		for _, step := range execution.Steps {
+			_, err := tx.Exec(`INSERT INTO chain_execution_steps
+					(execution_id, step_index, scenario_type, status, started_at, completed_at, error)
+				VALUES ($1, $2, $3, $4, $5, $6, $7)
+				ON CONFLICT (execution_id, step_index) DO UPDATE SET scenario_type = $3, status = $4,
+					started_at = $5, completed_at = $6, error = $7`,
+				execution.ID, step.StepIndex, step.ScenarioType, step.Status, step.StartedAt, step.CompletedAt, step.Error)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// GetChainExecutions возвращает последние limit выполнений цепочки с шагами, новые сначала
+func (s *PostgresStorage) GetChainExecutions(chainID string, limit int) ([]*models.ChainExecution, error) {
+	return s.queryChainExecutions(`WHERE chain_id = $1 ORDER BY started_at DESC LIMIT $2`, chainID, sqlLimit(limit))
+}
+
+func (s *PostgresStorage) GetChainExecution(id string) (*models.ChainExecution, error) {
+	executions, err := s.queryChainExecutions(`WHERE id = $1`, id)
+	if err != nil || len(executions) == 0 {
+		return nil, err
+	}
+	return executions[0], nil
+}
+
+func (s *PostgresStorage) UpdateChainExecution(execution *models.ChainExecution) error {
+	return s.SaveChainExecution(execution)
+}
+
+// queryChainExecutions читает выполнения по условию where и дополняет их шагами
+func (s *PostgresStorage) queryChainExecutions(where string, args ...interface{}) ([]*models.ChainExecution, error) {
+	rows, err := s.db.Query(`SELECT id, chain_id, status, time_scale, started_at, completed_at, error
+		FROM chain_executions `+where, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var executions []*models.ChainExecution
+	byID := make(map[string]*models.ChainExecution)
+	var ids []string
+	for rows.Next() {
+		var execution models.ChainExecution
+		err := rows.Scan(&execution.ID, &execution.ChainID, &execution.Status, &execution.TimeScale,
+			&execution.StartedAt, &execution.CompletedAt, &execution.Error)
+		if err != nil {
+			return nil, err
+		}
+		executions = append(executions, &execution)
+		byID[execution.ID] = &execution
+		ids = append(ids, execution.ID)
+	}
+	if err := rows.Err(); err != nil || len(ids) == 0 {
+		return executions, err
+	}
+
+	steps, err := s.db.Query(`SELECT execution_id, step_index, scenario_type, status, started_at, completed_at, error
+		FROM chain_execution_steps WHERE execution_id = ANY($1) ORDER BY execution_id, step_index`, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+	defer steps.Close()
+
+	for steps.Next() {
+		var executionID string
+		var step models.ChainExecutionStep
+		err := steps.Scan(&executionID, &step.StepIndex, &step.ScenarioType, &step.Status,
+			&step.StartedAt, &step.CompletedAt, &step.Error)
+		if err != nil {
+			return nil, err
+		}
+		execution := byID[executionID]
+		execution.Steps = append(execution.Steps, step)
+	}
+	return executions, steps.Err()
+}
+
+// ===== Расписания цепочек =====
+
+const chainScheduleColumns = `id, name, chain_name, cron_expr, enabled, time_scale, start_date, end_date, last_run, next_run, created_at`
+
+func (s *PostgresStorage) SaveChainSchedule(schedule *models.ChainSchedule) error {
+	_, err := s.db.Exec(`INSERT INTO chain_schedules (`+chainScheduleColumns+`)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+		ON CONFLICT (id) DO UPDATE SET name = $2, chain_name = $3, cron_expr = $4, enabled = $5,
+			time_scale = $6, start_date = $7, end_date = $8, last_run = $9, next_run = $10`,
+		schedule.ID, schedule.Name, schedule.ChainName, schedule.CronExpr, schedule.Enabled, schedule.TimeScale,
+		schedule.StartDate, schedule.EndDate, schedule.LastRun, schedule.NextRun, schedule.CreatedAt)
+	return err
+}
+
+func scanChainSchedule(row interface{ Scan(...interface{}) error }) (*models.ChainSchedule, error) {
+	var schedule models.ChainSchedule
+	err := row.Scan(&schedule.ID, &schedule.Name, &schedule.ChainName, &schedule.CronExpr, &schedule.Enabled,
+		&schedule.TimeScale, &schedule.StartDate, &schedule.EndDate, &schedule.LastRun, &schedule.NextRun, &schedule.CreatedAt)
+	return &schedule, err
+}
+
+func (s *PostgresStorage) GetChainSchedules() ([]*models.ChainSchedule, error) {
+	rows, err := s.db.Query(`SELECT ` + chainScheduleColumns + ` FROM chain_schedules ORDER BY created_at`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var schedules []*models.ChainSchedule
+	for rows.Next() {
+		schedule, err := scanChainSchedule(rows)
+		if err != nil {
+			return nil, err
+		}
+		schedules = append(schedules, schedule)
+	}
+	return schedules, rows.Err()
+}
+
+func (s *PostgresStorage) GetChainSchedule(id string) (*models.ChainSchedule, error) {
+	schedule, err := scanChainSchedule(s.db.QueryRow(`SELECT `+chainScheduleColumns+` FROM chain_schedules WHERE id = $1`, id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return schedule, err
+}
+
+func (s *PostgresStorage) UpdateChainSchedule(schedule *models.ChainSchedule) error {
+	return s.SaveChainSchedule(schedule)
+}
+
+func (s *PostgresStorage) DeleteChainSchedule(id string) error {
+	_, err := s.db.Exec(`DELETE FROM chain_schedules WHERE id = $1`, id)
+	return err
+}
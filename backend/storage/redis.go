@@ -0,0 +1,479 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"log-metrics-simulator/models"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	defaultRedisPrefix = "simulator"
+	redisTimeout       = 5 * time.Second
+)
+
+// RedisStorage хранит данные в Redis: каждая запись - хэш {prefix}:{entity}:{id}
+// с полем на каждое свойство, id сценариев, расписаний и цепочек - в множествах
+// коллекций, выполнения - в хэшах со сроком жизни retention, а их порядок для
+// расписания или цепочки - в sorted set по времени старта. Каждое изменение
+// публикуется в канал {prefix}:events. Реализует Locker для запусков расписаний.
+type RedisStorage struct {
+	client    *redis.Client
+	prefix    string
+	retention time.Duration
+	source    string
+}
+
+// NewRedisStorage подключается к Redis по адресу вида redis://host:6379/0.
+// Пустой prefix - "simulator"; retention 0 - история выполнений без ограничения.
+func NewRedisStorage(url, prefix string, retention time.Duration) (*RedisStorage, error) {
+	if url == "" {
+		return nil, fmt.Errorf("не задан адрес Redis")
+	}
+	opts, err := redis.ParseURL(url)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка адреса Redis: %v", err)
+	}
+	if prefix == "" {
+		prefix = defaultRedisPrefix
+	}
+
+	client := redis.NewClient(opts)
+	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+	defer cancel()
+	if err := client.Ping(ctx).Err(); err != nil {
+		client.Close()
+		return nil, fmt.Errorf("ошибка подключения к Redis: %v", err)
+	}
+
+	return &RedisStorage{
+		client:    client,
+		prefix:    prefix,
+		retention: retention,
+		source:    instanceID(),
+	}, nil
+}
+
+func (s *RedisStorage) Close() error {
+	return s.client.Close()
+}
+
+// Source возвращает идентификатор экземпляра, которым подписаны его уведомления
+func (s *RedisStorage) Source() string {
+	return s.source
+}
+
+// Subscribe подписывается на канал уведомлений; поток закрывается после отмены ctx
+func (s *RedisStorage) Subscribe(ctx context.Context) <-chan models.StorageEvent {
+	pubsub := s.client.Subscribe(ctx, s.key("events"))
+	events := make(chan models.StorageEvent, 64)
+
+	go func() {
+		defer close(events)
+		defer pubsub.Close()
+
+		messages := pubsub.Channel()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case msg, ok := <-messages:
+				if !ok {
+					return
+				}
+				var event models.StorageEvent
+				if err := json.Unmarshal([]byte(msg.Payload), &event); err != nil {
+					log.Printf("⚠️ Некорректное уведомление хранилища: %v", err)
+					continue
+				}
+				select {
+				case events <- event:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+	return events
+}
+
+func (s *RedisStorage) key(parts ...string) string {
+	return s.prefix + ":" + strings.Join(parts, ":")
+}
+
+func redisContext() (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.Background(), redisTimeout)
+}
+
+// publish добавляет в конвейер уведомление об изменении записи
+func (s *RedisStorage) publish(ctx context.Context, pipe redis.Pipeliner, entity, action, id string) error {
+	event, err := json.Marshal(models.StorageEvent{
+		Entity: entity,
+		Action: action,
+		ID:     id,
+		Source: s.source,
+		Time:   time.Now(),
+	})
+	if err != nil {
+		return err
+	}
+	return pipe.Publish(ctx, s.key("events"), event).Err()
+}
+
+// ===== Блокировки запусков =====
+
+// Ключ блокировки занимается за source командой SET NX PX; продление и
+// освобождение проверяют владельца внутри скрипта, чтобы не тронуть ключ,
+// который после истечения уже занял другой экземпляр.
+var (
+	redisTryLock = redis.NewScript(`
+if redis.call('SET', KEYS[1], ARGV[1], 'NX', 'PX', ARGV[2]) then
+	return 1
+end
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	redis.call('PEXPIRE', KEYS[1], ARGV[2])
+	return 1
+end
+return 0`)
+
+	redisUnlock = redis.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('DEL', KEYS[1])
+end
+return 0`)
+)
+
+// TryLock занимает ключ {prefix}:lock:{key}, например
+// simulator:lock:schedule:<id>:<время запуска>
+func (s *RedisStorage) TryLock(key string, ttl time.Duration) (bool, error) {
+	ctx, cancel := redisContext()
+	defer cancel()
+
+	locked, err := redisTryLock.Run(ctx, s.client, []string{s.key("lock", key)}, s.source, ttl.Milliseconds()).Int()
+	return locked == 1, err
+}
+
+func (s *RedisStorage) Unlock(key string) error {
+	ctx, cancel := redisContext()
+	defer cancel()
+
+	return redisUnlock.Run(ctx, s.client, []string{s.key("lock", key)}, s.source).Err()
+}
+
+// ===== Общие операции над записями =====
+
+// putRecord заменяет поля хэша записи, добавляет ее id в множество коллекции
+// и уведомляет об этом
+func (s *RedisStorage) putRecord(collection, entity, id string, fields redisFields) error {
+	ctx, cancel := redisContext()
+	defer cancel()
+
+	key := s.key(entity, id)
+	_, err := s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Del(ctx, key)
+		pipe.HSet(ctx, key, map[string]interface{}(fields))
+		pipe.SAdd(ctx, s.key(collection), id)
+		return s.publish(ctx, pipe, entity, "saved", id)
+	})
+	return err
+}
+
+// deleteRecord удаляет запись коллекции и уведомляет об этом. История
+// выполнений остается до истечения retention, как в остальных хранилищах.
+func (s *RedisStorage) deleteRecord(collection, entity, id string) error {
+	ctx, cancel := redisContext()
+	defer cancel()
+
+	_, err := s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Del(ctx, s.key(entity, id))
+		pipe.SRem(ctx, s.key(collection), id)
+		return s.publish(ctx, pipe, entity, "deleted", id)
+	})
+	return err
+}
+
+// getRecord читает хэш записи; nil, если ее нет
+func getRecord[T any](s *RedisStorage, entity, id string, decode func(*redisHash) (*T, error)) (*T, error) {
+	ctx, cancel := redisContext()
+	defer cancel()
+
+	fields, err := s.client.HGetAll(ctx, s.key(entity, id)).Result()
+	if err != nil || len(fields) == 0 {
+		return nil, err
+	}
+	return decode(&redisHash{fields: fields})
+}
+
+// getRecords читает все записи коллекции в произвольном порядке
+func getRecords[T any](s *RedisStorage, collection, entity string, decode func(*redisHash) (*T, error)) ([]*T, error) {
+	ctx, cancel := redisContext()
+	defer cancel()
+
+	ids, err := s.client.SMembers(ctx, s.key(collection)).Result()
+	if err != nil {
+		return nil, err
+	}
+	return readHashes(ctx, s, entity, ids, decode, nil)
+}
+
+// readHashes читает хэши записей ids одним конвейером; id без хэша
+// передаются в missing
+func readHashes[T any](ctx context.Context, s *RedisStorage, entity string, ids []string, decode func(*redisHash) (*T, error), missing func(id string)) ([]*T, error) {
+	pipe := s.client.Pipeline()
+	cmds := make([]*redis.MapStringStringCmd, len(ids))
+	for i, id := range ids {
+		cmds[i] = pipe.HGetAll(ctx, s.key(entity, id))
+	}
+	if len(ids) > 0 {
+		if _, err := pipe.Exec(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	records := make([]*T, 0, len(ids))
+	for i, cmd := range cmds {
+		fields := cmd.Val()
+		if len(fields) == 0 {
+			if missing != nil {
+				missing(ids[i])
+			}
+			continue
+		}
+		record, err := decode(&redisHash{fields: fields})
+		if err != nil {
+			return nil, fmt.Errorf("запись %s: %v", s.key(entity, ids[i]), err)
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+// putExecution заменяет поля хэша выполнения, задает ему срок жизни retention
+// и добавляет его в index; записи index старше retention удаляются
+func (s *RedisStorage) putExecution(entity, id, index string, startedAt time.Time, fields redisFields) error {
+	ctx, cancel := redisContext()
+	defer cancel()
+
+	key := s.key(entity, id)
+	_, err := s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Del(ctx, key)
+		pipe.HSet(ctx, key, map[string]interface{}(fields))
+		pipe.ZAdd(ctx, index, redis.Z{Score: float64(startedAt.UnixMilli()), Member: id})
+		if s.retention > 0 {
+			pipe.Expire(ctx, key, s.retention)
+			cutoff := time.Now().Add(-s.retention).UnixMilli()
+			pipe.ZRemRangeByScore(ctx, index, "-inf", "("+strconv.FormatInt(cutoff, 10))
+			pipe.Expire(ctx, index, s.retention)
+		}
+		return s.publish(ctx, pipe, entity, "saved", id)
+	})
+	return err
+}
+
+// getExecutions возвращает последние limit выполнений из index, новые сначала;
+// limit <= 0 - все. Устаревшие по сроку жизни записи удаляются из index.
+func getExecutions[T any](s *RedisStorage, entity, index string, limit int, decode func(*redisHash) (*T, error)) ([]*T, error) {
+	ctx, cancel := redisContext()
+	defer cancel()
+
+	stop := int64(-1)
+	if limit > 0 {
+		stop = int64(limit) - 1
+	}
+	ids, err := s.client.ZRevRange(ctx, index, 0, stop).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	var expired []interface{}
+	executions, err := readHashes(ctx, s, entity, ids, decode, func(id string) {
+		expired = append(expired, id)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(expired) > 0 {
+		s.client.ZRem(ctx, index, expired...)
+	}
+	return executions, nil
+}
+
+// ===== Сценарии =====
+
+func (s *RedisStorage) SaveScenario(scenario *models.Scenario) error {
+	fields, err := scenarioFields(scenario)
+	if err != nil {
+		return err
+	}
+	return s.putRecord("scenarios", "scenario", scenario.Type, fields)
+}
+
+func (s *RedisStorage) GetActiveScenarios() ([]*models.Scenario, error) {
+	scenarios, err := getRecords(s, "scenarios", "scenario", decodeScenario)
+	if err != nil {
+		return nil, err
+	}
+	active := make([]*models.Scenario, 0, len(scenarios))
+	for _, scenario := range scenarios {
+		if scenario.Active {
+			active = append(active, scenario)
+		}
+	}
+	return active, nil
+}
+
+func (s *RedisStorage) UpdateScenario(scenario *models.Scenario) error {
+	return s.SaveScenario(scenario)
+}
+
+func (s *RedisStorage) DeleteScenario(scenarioType string) error {
+	return s.deleteRecord("scenarios", "scenario", scenarioType)
+}
+
+// ===== Расписания сценариев =====
+
+func (s *RedisStorage) SaveSchedule(schedule *models.Schedule) error {
+	fields, err := scheduleFields(schedule)
+	if err != nil {
+		return err
+	}
+	return s.putRecord("schedules", "schedule", schedule.ID, fields)
+}
+
+func (s *RedisStorage) GetSchedules() ([]*models.Schedule, error) {
+	schedules, err := getRecords(s, "schedules", "schedule", decodeSchedule)
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(schedules, func(i, j int) bool {
+		return schedules[i].CreatedAt.Before(schedules[j].CreatedAt)
+	})
+	return schedules, nil
+}
+
+func (s *RedisStorage) GetSchedule(id string) (*models.Schedule, error) {
+	return getRecord(s, "schedule", id, decodeSchedule)
+}
+
+func (s *RedisStorage) UpdateSchedule(schedule *models.Schedule) error {
+	return s.SaveSchedule(schedule)
+}
+
+func (s *RedisStorage) DeleteSchedule(id string) error {
+	return s.deleteRecord("schedules", "schedule", id)
+}
+
+// ===== Выполнения расписаний =====
+
+func (s *RedisStorage) SaveExecution(execution *models.ScheduleExecution) error {
+	fields, err := executionFields(execution)
+	if err != nil {
+		return err
+	}
+	index := s.key("schedule", execution.ScheduleID, "executions")
+	return s.putExecution("execution", execution.ID, index, execution.StartedAt, fields)
+}
+
+// GetExecutions возвращает последние limit выполнений расписания, новые сначала
+func (s *RedisStorage) GetExecutions(scheduleID string, limit int) ([]*models.ScheduleExecution, error) {
+	return getExecutions(s, "execution", s.key("schedule", scheduleID, "executions"), limit, decodeExecution)
+}
+
+// ===== Цепочки сценариев =====
+
+func (s *RedisStorage) SaveChain(chain *models.ScenarioChain) error {
+	fields, err := chainFields(chain)
+	if err != nil {
+		return err
+	}
+	return s.putRecord("chains", "chain", chain.ID, fields)
+}
+
+func (s *RedisStorage) GetChains() ([]*models.ScenarioChain, error) {
+	chains, err := getRecords(s, "chains", "chain", decodeChain)
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(chains, func(i, j int) bool {
+		return chains[i].CreatedAt.Before(chains[j].CreatedAt)
+	})
+	return chains, nil
+}
+
+func (s *RedisStorage) GetChain(id string) (*models.ScenarioChain, error) {
+	return getRecord(s, "chain", id, decodeChain)
+}
+
+func (s *RedisStorage) UpdateChain(chain *models.ScenarioChain) error {
+	return s.SaveChain(chain)
+}
+
+func (s *RedisStorage) DeleteChain(id string) error {
+	return s.deleteRecord("chains", "chain", id)
+}
+
+// ===== Выполнения цепочек =====
+
+func (s *RedisStorage) SaveChainExecution(execution *models.ChainExecution) error {
+	fields, err := chainExecutionFields(execution)
+	if err != nil {
+		return err
+	}
+	index := s.key("chain", execution.ChainID, "executions")
+	return s.putExecution("chain_execution", execution.ID, index, execution.StartedAt, fields)
+}
+
+// GetChainExecutions возвращает последние limit выполнений цепочки, новые сначала
+func (s *RedisStorage) GetChainExecutions(chainID string, limit int) ([]*models.ChainExecution, error) {
+	return getExecutions(s, "chain_execution", s.key("chain", chainID, "executions"), limit, decodeChainExecution)
+}
+
+// GetChainExecution читает выполнение; nil, если его нет или оно устарело
+func (s *RedisStorage) GetChainExecution(id string) (*models.ChainExecution, error) {
+	return getRecord(s, "chain_execution", id, decodeChainExecution)
+}
+
+func (s *RedisStorage) UpdateChainExecution(execution *models.ChainExecution) error {
+	return s.SaveChainExecution(execution)
+}
+
+// ===== Расписания цепочек =====
+
+func (s *RedisStorage) SaveChainSchedule(schedule *models.ChainSchedule) error {
+	fields, err := chainScheduleFields(schedule)
+	if err != nil {
+		return err
+	}
+	return s.putRecord("chain_schedules", "chain_schedule", schedule.ID, fields)
+}
+
+func (s *RedisStorage) GetChainSchedules() ([]*models.ChainSchedule, error) {
+	schedules, err := getRecords(s, "chain_schedules", "chain_schedule", decodeChainSchedule)
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(schedules, func(i, j int) bool {
+		return schedules[i].CreatedAt.Before(schedules[j].CreatedAt)
+	})
+	return schedules, nil
+}
+
+func (s *RedisStorage) GetChainSchedule(id string) (*models.ChainSchedule, error) {
+	return getRecord(s, "chain_schedule", id, decodeChainSchedule)
+}
+
+func (s *RedisStorage) UpdateChainSchedule(schedule *models.ChainSchedule) error {
+	return s.SaveChainSchedule(schedule)
+}
+
+func (s *RedisStorage) DeleteChainSchedule(id string) error {
+	return s.deleteRecord("chain_schedules", "chain_schedule", id)
+}
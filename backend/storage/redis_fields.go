@@ -0,0 +1,340 @@
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"log-metrics-simulator/models"
+)
+
+// Записи Redis хранятся полями хэша, чтобы их можно было читать через HGET:
+// строки и числа как есть, время в RFC 3339, длительности в формате Go ("5s").
+// Вложенные структуры - конфигурация сценария и шаги цепочек - хранятся
+// JSON в одном поле, как в колонках JSONB PostgreSQL. Пустые необязательные
+// поля не записываются.
+
+// redisFields - поля хэша для HSET
+type redisFields map[string]interface{}
+
+func (f redisFields) time(field string, t *time.Time) {
+	if t != nil {
+		f[field] = formatRedisTime(*t)
+	}
+}
+
+func (f redisFields) json(field string, value interface{}) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Errorf("поле %s: %v", field, err)
+	}
+	f[field] = string(data)
+	return nil
+}
+
+func formatRedisTime(t time.Time) string {
+	return t.Format(time.RFC3339Nano)
+}
+
+func formatRedisFloat(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+// redisHash разбирает поля хэша из HGETALL; первая ошибка сохраняется в err,
+// отсутствующее поле - нулевое значение
+type redisHash struct {
+	fields map[string]string
+	err    error
+}
+
+func (h *redisHash) fail(field string, err error) {
+	if h.err == nil {
+		h.err = fmt.Errorf("поле %s: %v", field, err)
+	}
+}
+
+func (h *redisHash) str(field string) string {
+	return h.fields[field]
+}
+
+func (h *redisHash) bool(field string) bool {
+	value, ok := h.fields[field]
+	if !ok {
+		return false
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		h.fail(field, err)
+	}
+	return b
+}
+
+func (h *redisHash) int(field string) int {
+	value, ok := h.fields[field]
+	if !ok {
+		return 0
+	}
+	i, err := strconv.Atoi(value)
+	if err != nil {
+		h.fail(field, err)
+	}
+	return i
+}
+
+func (h *redisHash) float(field string) float64 {
+	value, ok := h.fields[field]
+	if !ok {
+		return 0
+	}
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		h.fail(field, err)
+	}
+	return f
+}
+
+func (h *redisHash) duration(field string) time.Duration {
+	value, ok := h.fields[field]
+	if !ok {
+		return 0
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		h.fail(field, err)
+	}
+	return d
+}
+
+func (h *redisHash) time(field string) time.Time {
+	if t := h.timePtr(field); t != nil {
+		return *t
+	}
+	return time.Time{}
+}
+
+func (h *redisHash) timePtr(field string) *time.Time {
+	value, ok := h.fields[field]
+	if !ok {
+		return nil
+	}
+	t, err := time.Parse(time.RFC3339Nano, value)
+	if err != nil {
+		h.fail(field, err)
+		return nil
+	}
+	return &t
+}
+
+func (h *redisHash) json(field string, value interface{}) {
+	data, ok := h.fields[field]
+	if !ok {
+		return
+	}
+	if err := json.Unmarshal([]byte(data), value); err != nil {
+		h.fail(field, err)
+	}
+}
+
+// ===== Сценарии =====
+
+func scenarioFields(scenario *models.Scenario) (redisFields, error) {
+	f := redisFields{
+		"type":     scenario.Type,
+		"active":   strconv.FormatBool(scenario.Active),
+		"started":  formatRedisTime(scenario.Started),
+		"duration": scenario.Duration.String(),
+		"interval": scenario.Interval.String(),
+	}
+	f.time("start_date", scenario.StartDate)
+	f.time("end_date", scenario.EndDate)
+	f.time("simulated_start", scenario.SimulatedStart)
+	return f, f.json("config", scenario.Config)
+}
+
+func decodeScenario(h *redisHash) (*models.Scenario, error) {
+	scenario := &models.Scenario{
+		Type:           h.str("type"),
+		Active:         h.bool("active"),
+		Started:        h.time("started"),
+		Duration:       h.duration("duration"),
+		Interval:       h.duration("interval"),
+		StartDate:      h.timePtr("start_date"),
+		EndDate:        h.timePtr("end_date"),
+		SimulatedStart: h.timePtr("simulated_start"),
+	}
+	h.json("config", &scenario.Config)
+	return scenario, h.err
+}
+
+// ===== Расписания сценариев =====
+
+func scheduleFields(schedule *models.Schedule) (redisFields, error) {
+	f := redisFields{
+		"id":            schedule.ID,
+		"name":          schedule.Name,
+		"scenario_type": schedule.ScenarioType,
+		"cron_expr":     schedule.CronExpr,
+		"enabled":       strconv.FormatBool(schedule.Enabled),
+		"time_scale":    formatRedisFloat(schedule.TimeScale),
+		"created_at":    formatRedisTime(schedule.CreatedAt),
+	}
+	f.time("start_date", schedule.StartDate)
+	f.time("end_date", schedule.EndDate)
+	f.time("last_run", schedule.LastRun)
+	f.time("next_run", schedule.NextRun)
+	return f, nil
+}
+
+func decodeSchedule(h *redisHash) (*models.Schedule, error) {
+	schedule := &models.Schedule{
+		ID:           h.str("id"),
+		Name:         h.str("name"),
+		ScenarioType: h.str("scenario_type"),
+		CronExpr:     h.str("cron_expr"),
+		Enabled:      h.bool("enabled"),
+		TimeScale:    h.float("time_scale"),
+		StartDate:    h.timePtr("start_date"),
+		EndDate:      h.timePtr("end_date"),
+		LastRun:      h.timePtr("last_run"),
+		NextRun:      h.timePtr("next_run"),
+		CreatedAt:    h.time("created_at"),
+	}
+	return schedule, h.err
+}
+
+// ===== Выполнения расписаний =====
+
+func executionFields(execution *models.ScheduleExecution) (redisFields, error) {
+	f := redisFields{
+		"id":            execution.ID,
+		"schedule_id":   execution.ScheduleID,
+		"scenario_type": execution.ScenarioType,
+		"status":        execution.Status,
+		"started_at":    formatRedisTime(execution.StartedAt),
+		"logs_count":    strconv.Itoa(execution.LogsCount),
+	}
+	f.time("completed_at", execution.CompletedAt)
+	if execution.Error != "" {
+		f["error"] = execution.Error
+	}
+	return f, nil
+}
+
+func decodeExecution(h *redisHash) (*models.ScheduleExecution, error) {
+	execution := &models.ScheduleExecution{
+		ID:           h.str("id"),
+		ScheduleID:   h.str("schedule_id"),
+		ScenarioType: h.str("scenario_type"),
+		Status:       h.str("status"),
+		StartedAt:    h.time("started_at"),
+		CompletedAt:  h.timePtr("completed_at"),
+		Error:        h.str("error"),
+		LogsCount:    h.int("logs_count"),
+	}
+	return execution, h.err
+}
+
+// ===== Цепочки =====
+
+func chainFields(chain *models.ScenarioChain) (redisFields, error) {
+	f := redisFields{
+		"id":         chain.ID,
+		"name":       chain.Name,
+		"status":     chain.Status,
+		"time_scale": formatRedisFloat(chain.TimeScale),
+		"created_at": formatRedisTime(chain.CreatedAt),
+	}
+	if chain.Description != "" {
+		f["description"] = chain.Description
+	}
+	if chain.CreatedBy != "" {
+		f["created_by"] = chain.CreatedBy
+	}
+	f.time("started_at", chain.StartedAt)
+	f.time("completed_at", chain.CompletedAt)
+	return f, f.json("steps", chain.Steps)
+}
+
+func decodeChain(h *redisHash) (*models.ScenarioChain, error) {
+	chain := &models.ScenarioChain{
+		ID:          h.str("id"),
+		Name:        h.str("name"),
+		Description: h.str("description"),
+		Status:      h.str("status"),
+		TimeScale:   h.float("time_scale"),
+		StartedAt:   h.timePtr("started_at"),
+		CompletedAt: h.timePtr("completed_at"),
+		CreatedAt:   h.time("created_at"),
+		CreatedBy:   h.str("created_by"),
+	}
+	h.json("steps", &chain.Steps)
+	return chain, h.err
+}
+
+// ===== Выполнения цепочек =====
+
+func chainExecutionFields(execution *models.ChainExecution) (redisFields, error) {
+	f := redisFields{
+		"id":         execution.ID,
+		"chain_id":   execution.ChainID,
+		"status":     execution.Status,
+		"time_scale": formatRedisFloat(execution.TimeScale),
+		"started_at": formatRedisTime(execution.StartedAt),
+	}
+	f.time("completed_at", execution.CompletedAt)
+	if execution.Error != "" {
+		f["error"] = execution.Error
+	}
+	return f, f.json("steps", execution.Steps)
+}
+
+func decodeChainExecution(h *redisHash) (*models.ChainExecution, error) {
+	execution := &models.ChainExecution{
+		ID:          h.str("id"),
+		ChainID:     h.str("chain_id"),
+		Status:      h.str("status"),
+		TimeScale:   h.float("time_scale"),
+		StartedAt:   h.time("started_at"),
+		CompletedAt: h.timePtr("completed_at"),
+		Error:       h.str("error"),
+	}
+	h.json("steps", &execution.Steps)
+	return execution, h.err
+}
+
+// ===== Расписания цепочек =====
+
+func chainScheduleFields(schedule *models.ChainSchedule) (redisFields, error) {
+	f := redisFields{
+		"id":         schedule.ID,
+		"name":       schedule.Name,
+		"chain_name": schedule.ChainName,
+		"cron_expr":  schedule.CronExpr,
+		"enabled":    strconv.FormatBool(schedule.Enabled),
+		"time_scale": formatRedisFloat(schedule.TimeScale),
+		"created_at": formatRedisTime(schedule.CreatedAt),
+	}
+	f.time("start_date", schedule.StartDate)
+	f.time("end_date", schedule.EndDate)
+	f.time("last_run", schedule.LastRun)
+	f.time("next_run", schedule.NextRun)
+	return f, nil
+}
+
+func decodeChainSchedule(h *redisHash) (*models.ChainSchedule, error) {
+	schedule := &models.ChainSchedule{
+		ID:        h.str("id"),
+		Name:      h.str("name"),
+		ChainName: h.str("chain_name"),
+		CronExpr:  h.str("cron_expr"),
+		Enabled:   h.bool("enabled"),
+		TimeScale: h.float("time_scale"),
+		StartDate: h.timePtr("start_date"),
+		EndDate:   h.timePtr("end_date"),
+		LastRun:   h.timePtr("last_run"),
+		NextRun:   h.timePtr("next_run"),
+		CreatedAt: h.time("created_at"),
+	}
+	return schedule, h.err
+}
@@ -0,0 +1,285 @@
+package storage
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	"log-metrics-simulator/models"
+
+	"github.com/alicebob/miniredis/v2"
+)
+
+// newTestRedis подключает хранилище к miniredis; storages одного mr - реплики
+func newTestRedis(t *testing.T, mr *miniredis.Miniredis, retention time.Duration) *RedisStorage {
+	t.Helper()
+	s, err := NewRedisStorage("redis://"+mr.Addr(), "", retention)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+func TestRedisScheduleFields(t *testing.T) {
+	mr := miniredis.RunT(t)
+	s := newTestRedis(t, mr, 0)
+
+	nextRun := testTime(time.Hour)
+	schedule := &models.Schedule{
+		ID:           "nightly",
+		Name:         "Ночная нагрузка",
+		ScenarioType: "load_test",
+		CronExpr:     "0 3 * * *",
+		Enabled:      true,
+		TimeScale:    1.5,
+		NextRun:      &nextRun,
+		CreatedAt:    testTime(0),
+	}
+	if err := s.SaveSchedule(schedule); err != nil {
+		t.Fatal(err)
+	}
+
+	// Поля доступны другим инструментам через HGET
+	fields := map[string]string{
+		"name":       "Ночная нагрузка",
+		"cron_expr":  "0 3 * * *",
+		"enabled":    "true",
+		"time_scale": "1.5",
+		"next_run":   "2024-01-01T13:00:00Z",
+	}
+	for field, want := range fields {
+		if got := mr.HGet("simulator:schedule:nightly", field); got != want {
+			t.Errorf("HGET %s = %q, ожидалось %q", field, got, want)
+		}
+	}
+	if members, _ := mr.Members("simulator:schedules"); len(members) != 1 || members[0] != "nightly" {
+		t.Errorf("множество расписаний %v", members)
+	}
+
+	got, err := s.GetSchedule("nightly")
+	if err != nil || got == nil {
+		t.Fatalf("расписание не найдено: %v", err)
+	}
+	if got.Name != schedule.Name || got.TimeScale != 1.5 || !got.NextRun.Equal(nextRun) || !got.CreatedAt.Equal(schedule.CreatedAt) || got.LastRun != nil {
+		t.Errorf("расписание %+v не совпадает с сохраненным", got)
+	}
+
+	// Сброшенное необязательное поле удаляется из хэша
+	schedule.Enabled = false
+	schedule.NextRun = nil
+	if err := s.UpdateSchedule(schedule); err != nil {
+		t.Fatal(err)
+	}
+	if mr.HGet("simulator:schedule:nightly", "next_run") != "" {
+		t.Error("next_run остался в хэше после сброса")
+	}
+	schedules, err := s.GetSchedules()
+	if err != nil || len(schedules) != 1 || schedules[0].Enabled || schedules[0].NextRun != nil {
+		t.Errorf("после обновления расписания %+v, %v", schedules, err)
+	}
+
+	if got, err := s.GetSchedule("missing"); got != nil || err != nil {
+		t.Errorf("несуществующее расписание: %+v, %v", got, err)
+	}
+}
+
+func TestRedisScenarioRoundTrip(t *testing.T) {
+	s := newTestRedis(t, miniredis.RunT(t), 0)
+
+	seed := int64(9007199254740993)
+	scenario := &models.Scenario{
+		Type:   "load_test",
+		Active: true,
+		Config: models.ScenarioConfig{
+			Name:       "Load Test",
+			LogCount:   1000,
+			Labels:     map[string]string{"env": "test"},
+			Parameters: map[string]interface{}{"preset": "high_load"},
+			Seed:       &seed,
+		},
+		Started:  testTime(0),
+		Duration: 90 * time.Minute,
+		Interval: 5 * time.Second,
+	}
+	if err := s.SaveScenario(scenario); err != nil {
+		t.Fatal(err)
+	}
+
+	scenarios, err := s.GetActiveScenarios()
+	if err != nil || len(scenarios) != 1 {
+		t.Fatalf("активных сценариев %d: %v", len(scenarios), err)
+	}
+	got := scenarios[0]
+	if *got.Config.Seed != seed || got.Config.Labels["env"] != "test" || got.Config.Parameters["preset"] != "high_load" {
+		t.Errorf("конфигурация %+v не совпадает с сохраненной", got.Config)
+	}
+	if !got.Started.Equal(scenario.Started) || got.Duration != scenario.Duration || got.Interval != scenario.Interval {
+		t.Errorf("сценарий %+v не совпадает с сохраненным", got)
+	}
+
+	if err := s.DeleteScenario("load_test"); err != nil {
+		t.Fatal(err)
+	}
+	if scenarios, err := s.GetActiveScenarios(); err != nil || len(scenarios) != 0 {
+		t.Errorf("после удаления активных сценариев %d: %v", len(scenarios), err)
+	}
+}
+
+func TestRedisExecutionsRetention(t *testing.T) {
+	mr := miniredis.RunT(t)
+	s := newTestRedis(t, mr, time.Hour)
+
+	now := time.Now()
+	for i := 0; i < 3; i++ {
+		execution := &models.ScheduleExecution{
+			ID:           "run-" + strconv.Itoa(i),
+			ScheduleID:   "nightly",
+			ScenarioType: "load_test",
+			Status:       "completed",
+			StartedAt:    now.Add(time.Duration(i) * time.Minute),
+			LogsCount:    100 * i,
+		}
+		if err := s.SaveExecution(execution); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.SaveSchedule(&models.Schedule{ID: "nightly", CreatedAt: now}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.DeleteSchedule("nightly"); err != nil {
+		t.Fatal(err)
+	}
+
+	// История остается после удаления расписания до истечения retention
+	executions, err := s.GetExecutions("nightly", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(executions) != 2 || executions[0].ID != "run-2" || executions[0].LogsCount != 200 {
+		t.Fatalf("последние выполнения %+v", executions)
+	}
+	if ttl := mr.TTL("simulator:execution:run-0"); ttl != time.Hour {
+		t.Errorf("срок жизни выполнения %v, ожидался 1h", ttl)
+	}
+
+	mr.FastForward(time.Hour)
+	if executions, err := s.GetExecutions("nightly", 0); err != nil || len(executions) != 0 {
+		t.Errorf("после retention выполнений %d: %v", len(executions), err)
+	}
+	if mr.Exists("simulator:schedule:nightly:executions") {
+		if members, _ := mr.ZMembers("simulator:schedule:nightly:executions"); len(members) != 0 {
+			t.Errorf("в индексе остались устаревшие выполнения %v", members)
+		}
+	}
+}
+
+func TestRedisChainExecutionSteps(t *testing.T) {
+	s := newTestRedis(t, miniredis.RunT(t), 0)
+
+	started := testTime(time.Minute)
+	execution := &models.ChainExecution{
+		ID:        "rush-run",
+		ChainID:   "rush",
+		Status:    "failed",
+		StartedAt: started,
+		Error:     "шаг 1 завершился ошибкой",
+		Steps: []models.ChainExecutionStep{
+			{StepIndex: 0, ScenarioType: "load_test", Status: "completed", StartedAt: &started},
+			{StepIndex: 1, ScenarioType: "error_spike", Status: "failed", Error: "timeout"},
+		},
+	}
+	if err := s.SaveChainExecution(execution); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := s.GetChainExecution(execution.ID)
+	if err != nil || got == nil {
+		t.Fatalf("выполнение не найдено: %v", err)
+	}
+	if got.Error != execution.Error || len(got.Steps) != 2 || got.Steps[1].Error != "timeout" || !got.Steps[0].StartedAt.Equal(started) {
+		t.Errorf("выполнение %+v не совпадает с сохраненным", got)
+	}
+}
+
+func TestRedisPublishesChanges(t *testing.T) {
+	mr := miniredis.RunT(t)
+	first := newTestRedis(t, mr, 0)
+	second := newTestRedis(t, mr, 0)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events := second.Subscribe(ctx)
+	deadline := time.Now().Add(5 * time.Second)
+	for mr.PubSubNumSub("simulator:events")["simulator:events"] == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("подписка не установлена")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	if err := first.SaveChain(&models.ScenarioChain{ID: "rush", Name: "rush", CreatedAt: testTime(0)}); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case event := <-events:
+		if event.Entity != "chain" || event.Action != "saved" || event.ID != "rush" || event.Source != first.Source() {
+			t.Errorf("уведомление %+v", event)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("уведомление не получено")
+	}
+}
+
+func TestRedisTryLock(t *testing.T) {
+	mr := miniredis.RunT(t)
+	first := newTestRedis(t, mr, 0)
+	second := newTestRedis(t, mr, 0)
+	key := "schedule:nightly:1704110400000000000"
+
+	tryLock := func(s *RedisStorage, ttl time.Duration) bool {
+		t.Helper()
+		locked, err := s.TryLock(key, ttl)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return locked
+	}
+
+	if !tryLock(first, time.Minute) {
+		t.Fatal("свободный ключ не занят")
+	}
+	if owner, _ := mr.Get("simulator:lock:" + key); owner != first.Source() {
+		t.Errorf("владелец блокировки %q, ожидался %q", owner, first.Source())
+	}
+	if tryLock(second, time.Minute) {
+		t.Fatal("ключ занят второй репликой, пока его держит первая")
+	}
+	mr.FastForward(50 * time.Second)
+	if !tryLock(first, time.Minute) {
+		t.Fatal("реплика не продлила свою блокировку")
+	}
+	if ttl := mr.TTL("simulator:lock:" + key); ttl != time.Minute {
+		t.Errorf("срок блокировки после продления %v", ttl)
+	}
+
+	if err := second.Unlock(key); err != nil {
+		t.Fatal(err)
+	}
+	if tryLock(second, time.Minute) {
+		t.Fatal("Unlock снял чужую блокировку")
+	}
+	if err := first.Unlock(key); err != nil {
+		t.Fatal(err)
+	}
+	if !tryLock(second, time.Second) {
+		t.Fatal("освобожденный ключ не занят")
+	}
+
+	// Истекшую блокировку забирает другая реплика
+	mr.FastForward(2 * time.Second)
+	if !tryLock(first, time.Minute) {
+		t.Fatal("истекшая блокировка не перехвачена")
+	}
+}
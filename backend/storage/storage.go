@@ -1,6 +1,66 @@
 package storage
 
-import "log-metrics-simulator/models"
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"log-metrics-simulator/models"
+)
+
+// Config выбирает реализацию хранилища
+type Config struct {
+	Type      string        // memory, bolt, postgres или redis
+	Path      string        // Файл базы bolt
+	DSN       string        // Строка подключения к PostgreSQL или Redis
+	Prefix    string        // Префикс ключей Redis
+	Retention time.Duration // Срок хранения истории выполнений в Redis; 0 - без ограничения
+}
+
+// New создает хранилище по конфигурации: memory - в памяти процесса, bolt -
+// во встроенной базе, postgres и redis - в общей базе нескольких реплик
+func New(cfg Config) (Storage, error) {
+	switch cfg.Type {
+	case "", "memory":
+		return NewMemoryStorage(), nil
+	case "bolt":
+		return NewBoltStorage(cfg.Path)
+	case "postgres":
+		return NewPostgresStorage(cfg.DSN)
+	case "redis":
+		return NewRedisStorage(cfg.DSN, cfg.Prefix, cfg.Retention)
+	}
+	return nil, fmt.Errorf("неизвестный тип хранилища: %s (memory, bolt, postgres, redis)", cfg.Type)
+}
+
+// Notifier - хранилище, которое рассылает уведомления об изменениях всем
+// экземплярам симулятора, работающим с ним
+type Notifier interface {
+	// Subscribe возвращает поток уведомлений; поток закрывается после отмены ctx
+	Subscribe(ctx context.Context) <-chan models.StorageEvent
+	// Source возвращает идентификатор этого экземпляра в уведомлениях
+	Source() string
+}
+
+// Locker - общее хранилище, через которое экземпляры договариваются, кто из
+// них выполняет запуск расписания или сценарий. Ключ занимается на ttl и
+// освобождается сам, если занявший экземпляр перестал его продлевать.
+type Locker interface {
+	// TryLock занимает ключ за этим экземпляром или продлевает уже занятый им;
+	// false - ключ занят другим экземпляром
+	TryLock(key string, ttl time.Duration) (bool, error)
+	// Unlock освобождает ключ, если он занят этим экземпляром
+	Unlock(key string) error
+}
+
+// instanceID возвращает идентификатор экземпляра симулятора для уведомлений
+// и блокировок общего хранилища
+func instanceID() string {
+	host, _ := os.Hostname()
+	return fmt.Sprintf("%s-%d-%s", host, os.Getpid(), strconv.FormatInt(time.Now().UnixNano(), 36))
+}
 
 // Storage интерфейс определяет все методы для работы с хранилищем данных
 type Storage interface {